@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+	"github.com/squadracorsepolito/can-debug/internal/export"
+)
+
+// runRecord records every frame received on the bus to a candump log file until Ctrl+C is pressed
+func runRecord(canBus bus.Bus, path string) error {
+	rec, err := candump.StartRecording(canBus, path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔴 Recording %s to %s (Ctrl+C to stop)\n", canBus.Capabilities().Channel, path)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	if err := rec.Stop(); err != nil {
+		return fmt.Errorf("error writing log file %s: %w", path, err)
+	}
+	fmt.Printf("\n💾 Recorded %d frames to %s\n", rec.Count(), path)
+
+	return nil
+}
+
+// runDecode implements the "decode" subcommand: it decodes a candump log with a DBC and writes it as CSV
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	dbcPath := fs.String("dbc", "", "DBC file used to decode the frames (required)")
+	formatName := fs.String("format", "frames", "CSV layout: frames (one row per frame) or wide (one column per signal, resampled)")
+	period := fs.Duration("period", 10*time.Millisecond, "sample period of the wide format")
+	output := fs.String("o", "", "output CSV file (default stdout)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Use:\n  can-debug decode --dbc file.dbc [--format frames|wide] [--period 10ms] [-o out.csv] session.log\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *dbcPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("a DBC file and a log file are required")
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	dbc, err := can.LoadDBC(*dbcPath)
+	if err != nil {
+		return err
+	}
+	decoder := can.NewDecoder(can.Messages(dbc))
+
+	entries, err := candump.ReadLogFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		defer out.Close()
+	}
+
+	return export.WriteCSV(out, entries, decoder, export.Options{
+		Format: format,
+		Period: *period,
+	})
+}
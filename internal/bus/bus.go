@@ -0,0 +1,139 @@
+// Package bus abstracts the transport used to exchange CAN frames, so that the
+// UI can talk to SocketCAN or any other backend through the same interface.
+package bus
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame represents a CAN frame exchanged through a [Bus].
+type Frame struct {
+	// Timestamp is the time the frame was received (zero for frames to be sent)
+	Timestamp time.Time
+	// ID is the CAN ID
+	ID uint32
+	// Length is the number of bytes of data in the frame (up to 8, or 64 for CAN FD frames)
+	Length uint8
+	// Data is the frame payload, only the first Length bytes are meaningful
+	Data [MaxFDDataLength]byte
+	// IsRemote is true for remote frames
+	IsRemote bool
+	// IsExtended is true for frames with 29-bit IDs
+	IsExtended bool
+	// IsFD is true for CAN FD frames
+	IsFD bool
+	// BRS is true if the data phase of a CAN FD frame uses the bit rate switch
+	BRS bool
+	// Len8DLC is the DLC 9-15 of a classic frame with 8 bytes of data (0 sends the DLC 8),
+	// the controller must allow it (Linux: "ip link set <iface> type can cc-len8-dlc on")
+	Len8DLC uint8
+}
+
+// Payload returns the meaningful bytes of the frame.
+func (f *Frame) Payload() []byte {
+	return f.Data[:f.Length]
+}
+
+// DLC returns the data length code of the frame.
+func (f *Frame) DLC() uint8 {
+	if f.Len8DLC != 0 {
+		return f.Len8DLC
+	}
+	return LengthToDLC(int(f.Length))
+}
+
+// Validate returns an error if the frame cannot be sent as is.
+func (f *Frame) Validate() error {
+	switch {
+	case f.IsExtended && f.ID > 0x1FFFFFFF:
+		return fmt.Errorf("invalid extended CAN ID 0x%X: it does not fit in 29 bits", f.ID)
+	case !f.IsExtended && f.ID > 0x7FF:
+		return fmt.Errorf("invalid standard CAN ID 0x%X: it does not fit in 11 bits", f.ID)
+	case f.IsFD && int(f.Length) != ValidLength(int(f.Length)):
+		return fmt.Errorf("invalid CAN FD data length %d", f.Length)
+	case !f.IsFD && f.Length > MaxDataLength:
+		return fmt.Errorf("invalid data length %d: classic CAN frames carry up to 8 bytes", f.Length)
+	case f.IsFD && f.IsRemote:
+		return fmt.Errorf("CAN FD frames cannot be remote frames")
+	case f.Len8DLC != 0 && (f.IsFD || f.IsRemote || f.Length != MaxDataLength || f.Len8DLC <= MaxDataLength || f.Len8DLC > 15):
+		return fmt.Errorf("invalid DLC %d: only classic data frames with 8 bytes have a DLC of 9-15", f.Len8DLC)
+	}
+	return nil
+}
+
+// Capabilities describes what a [Bus] backend supports.
+type Capabilities struct {
+	Backend string // name of the backend, e.g. "socketcan"
+	Channel string // name of the channel/interface, e.g. "vcan0"
+	// ReceiveOwn is true if the frames sent through the bus
+	// are also delivered to its receivers
+	ReceiveOwn bool
+	// FD is true if CAN FD frames can be sent and received
+	FD bool
+}
+
+// Receiver is a stream of the frames seen on a [Bus].
+// It mimics the Receive/Frame loop of the socketcan receivers.
+type Receiver interface {
+	// Receive blocks until a new frame is available, it returns false once the receiver is closed
+	Receive() bool
+	// Frame returns the last received frame
+	Frame() Frame
+	// Close stops the stream, it is safe to call it more than once
+	Close() error
+}
+
+// Bus is a CAN transport that can send and receive frames.
+type Bus interface {
+	// Send transmits a frame on the bus
+	Send(ctx context.Context, frame Frame) error
+	// Subscribe returns a new receiver that gets every frame seen on the bus from now on
+	Subscribe() Receiver
+	// Close releases the bus and closes all of its receivers
+	Close() error
+	// Capabilities returns what the backend supports
+	Capabilities() Capabilities
+}
+
+// Opener opens a [Bus] from the given URL.
+type Opener func(ctx context.Context, u *url.URL) (Bus, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = make(map[string]Opener)
+)
+
+// Register makes a backend available to [Open] under the given URL scheme.
+func Register(scheme string, opener Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[scheme] = opener
+}
+
+// Open opens the bus described by the given address.
+// The address is URL-style (e.g. "socketcan://vcan0"),
+// a plain interface name (e.g. "vcan0") is treated as a SocketCAN interface.
+func Open(ctx context.Context, address string) (Bus, error) {
+	if !strings.Contains(address, "://") {
+		address = "socketcan://" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bus address %q: %w", address, err)
+	}
+
+	openersMu.RLock()
+	opener, ok := openers[u.Scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown bus backend %q", u.Scheme)
+	}
+
+	return opener(ctx, u)
+}
@@ -0,0 +1,44 @@
+package bus
+
+// Payload limits of classic CAN and CAN FD frames
+const (
+	MaxDataLength   = 8
+	MaxFDDataLength = 64
+)
+
+// fdLengths maps the DLC codes 9-15 of CAN FD frames to their payload length
+var fdLengths = [...]uint8{12, 16, 20, 24, 32, 48, 64}
+
+// DLCToLength returns the payload length encoded by a DLC.
+// For classic frames the DLCs 9-15 mean 8 bytes.
+func DLCToLength(dlc uint8, fd bool) uint8 {
+	switch {
+	case dlc <= 8:
+		return dlc
+	case !fd:
+		return MaxDataLength
+	case dlc > 15:
+		return MaxFDDataLength
+	default:
+		return fdLengths[dlc-9]
+	}
+}
+
+// LengthToDLC returns the smallest DLC able to hold a payload of the given length.
+func LengthToDLC(length int) uint8 {
+	if length <= 8 {
+		return uint8(max(length, 0))
+	}
+	for i, l := range fdLengths {
+		if length <= int(l) {
+			return uint8(9 + i)
+		}
+	}
+	return 15
+}
+
+// ValidLength rounds up a payload length to the nearest length a frame can carry
+// (0-8, then 12, 16, 20, 24, 32, 48, 64 for CAN FD).
+func ValidLength(length int) int {
+	return int(DLCToLength(LengthToDLC(length), true))
+}
@@ -0,0 +1,98 @@
+package bus
+
+import (
+	"sync"
+)
+
+// receiverBufferSize is the number of frames a receiver can hold before new frames are dropped
+const receiverBufferSize = 4096
+
+// hub fans out the frames of a bus to all of its receivers.
+type hub struct {
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: make(map[*subscriber]struct{}),
+	}
+}
+
+// subscribe registers a new receiver, if the hub is already closed the receiver is returned closed
+func (h *hub) subscribe() *subscriber {
+	s := &subscriber{
+		h:  h,
+		ch: make(chan Frame, receiverBufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(s.ch)
+		return s
+	}
+	h.subs[s] = struct{}{}
+
+	return s
+}
+
+// publish delivers the frame to every receiver without blocking,
+// a receiver that is not keeping up loses the frame
+func (h *hub) publish(frame Frame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		select {
+		case s.ch <- frame:
+		default:
+		}
+	}
+}
+
+// remove unregisters the receiver and closes its stream
+func (h *hub) remove(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// close closes all the receivers, further subscriptions are returned closed
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// subscriber is the [Receiver] returned by a hub
+type subscriber struct {
+	h     *hub
+	ch    chan Frame
+	frame Frame
+}
+
+func (s *subscriber) Receive() bool {
+	frame, ok := <-s.ch
+	if !ok {
+		return false
+	}
+	s.frame = frame
+	return true
+}
+
+func (s *subscriber) Frame() Frame {
+	return s.frame
+}
+
+func (s *subscriber) Close() error {
+	s.h.remove(s)
+	return nil
+}
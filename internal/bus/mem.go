@@ -0,0 +1,124 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("mem", openMem)
+}
+
+// memDefaultName is the name of the virtual bus opened by "mem://"
+const memDefaultName = "default"
+
+// memNetworks holds the in-process virtual buses by name,
+// so that every bus opened with the same name sees the same traffic.
+// A network is removed when its last bus is closed
+var (
+	memNetworksMu sync.Mutex
+	memNetworks   = make(map[string]*memNetwork)
+)
+
+// memNetwork is a virtual bus shared by all the [Bus] opened on it
+type memNetwork struct {
+	mu    sync.RWMutex
+	buses map[*memBus]struct{}
+}
+
+// memBus is an in-memory loopback [Bus]: every frame sent is delivered
+// to the receivers of all the buses opened on the same virtual network, the sender included
+type memBus struct {
+	name      string
+	network   *memNetwork
+	hub       *hub
+	closeOnce sync.Once
+}
+
+// NewMem opens the in-process virtual bus with the given name (the same as "mem://name").
+func NewMem(name string) Bus {
+	if name == "" {
+		name = memDefaultName
+	}
+
+	memNetworksMu.Lock()
+	defer memNetworksMu.Unlock()
+
+	network, ok := memNetworks[name]
+	if !ok {
+		network = &memNetwork{
+			buses: make(map[*memBus]struct{}),
+		}
+		memNetworks[name] = network
+	}
+
+	b := &memBus{
+		name:    name,
+		network: network,
+		hub:     newHub(),
+	}
+
+	network.mu.Lock()
+	network.buses[b] = struct{}{}
+	network.mu.Unlock()
+
+	return b
+}
+
+// openMem opens the virtual bus named by the host of the URL (e.g. mem://bench)
+func openMem(_ context.Context, u *url.URL) (Bus, error) {
+	return NewMem(u.Host), nil
+}
+
+func (b *memBus) Send(ctx context.Context, frame Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := frame.Validate(); err != nil {
+		return err
+	}
+
+	b.network.mu.RLock()
+	defer b.network.mu.RUnlock()
+	if _, ok := b.network.buses[b]; !ok {
+		return errors.New("virtual bus closed")
+	}
+
+	frame.Timestamp = time.Now()
+	for other := range b.network.buses {
+		other.hub.publish(frame)
+	}
+
+	return nil
+}
+
+func (b *memBus) Subscribe() Receiver {
+	return b.hub.subscribe()
+}
+
+func (b *memBus) Close() error {
+	b.closeOnce.Do(func() {
+		memNetworksMu.Lock()
+		b.network.mu.Lock()
+		delete(b.network.buses, b)
+		if len(b.network.buses) == 0 && memNetworks[b.name] == b.network {
+			delete(memNetworks, b.name)
+		}
+		b.network.mu.Unlock()
+		memNetworksMu.Unlock()
+		b.hub.close()
+	})
+	return nil
+}
+
+func (b *memBus) Capabilities() Capabilities {
+	return Capabilities{
+		Backend:    "mem",
+		Channel:    b.name,
+		ReceiveOwn: true,
+		FD:         true,
+	}
+}
@@ -0,0 +1,199 @@
+package bus
+
+import (
+	"context"
+	"testing"
+)
+
+// receive returns the next frame of the receiver, the frames of the virtual buses
+// are queued by Send so there is no need to wait for them
+func receive(tb testing.TB, recv Receiver) Frame {
+	tb.Helper()
+
+	if pending := len(recv.(*subscriber).ch); pending == 0 {
+		tb.Fatal("no frame received")
+	}
+	if !recv.Receive() {
+		tb.Fatal("receiver closed")
+	}
+	return recv.Frame()
+}
+
+// TestMemFanOut checks that a frame reaches every receiver of every bus of the network, the sender included
+func TestMemFanOut(t *testing.T) {
+	a := NewMem(t.Name())
+	defer a.Close()
+	b := NewMem(t.Name())
+	defer b.Close()
+	other := NewMem(t.Name() + "/other")
+	defer other.Close()
+
+	receivers := []Receiver{a.Subscribe(), a.Subscribe(), b.Subscribe()}
+	otherRecv := other.Subscribe()
+
+	sent := Frame{ID: 0x123, Length: 3, Data: [MaxFDDataLength]byte{1, 2, 3}}
+	if err := a.Send(context.Background(), sent); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, recv := range receivers {
+		frame := receive(t, recv)
+		if frame.ID != sent.ID || string(frame.Payload()) != string(sent.Payload()) {
+			t.Errorf("receiver %d: got 0x%X % X, want 0x%X % X", i, frame.ID, frame.Payload(), sent.ID, sent.Payload())
+		}
+		if frame.Timestamp.IsZero() {
+			t.Errorf("receiver %d: frame without timestamp", i)
+		}
+	}
+	if pending := len(otherRecv.(*subscriber).ch); pending != 0 {
+		t.Errorf("%d frames delivered to another network", pending)
+	}
+}
+
+// TestMemReceiverClose checks that closing a receiver, or its bus, ends its stream
+func TestMemReceiverClose(t *testing.T) {
+	b := NewMem(t.Name())
+	recv := b.Subscribe()
+	kept := b.Subscribe()
+
+	if err := recv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if recv.Receive() {
+		t.Fatal("Receive returned true after Close")
+	}
+
+	// The other receivers of the bus still get the frames
+	if err := b.Send(context.Background(), Frame{ID: 0x10}); err != nil {
+		t.Fatal(err)
+	}
+	if frame := receive(t, kept); frame.ID != 0x10 {
+		t.Fatalf("got ID 0x%X, want 0x10", frame.ID)
+	}
+
+	b.Close()
+	if kept.Receive() {
+		t.Fatal("Receive returned true after the bus was closed")
+	}
+	if b.Subscribe().Receive() {
+		t.Fatal("receiver subscribed after Close is not closed")
+	}
+	if err := b.Send(context.Background(), Frame{ID: 0x10}); err == nil {
+		t.Fatal("Send succeeded on a closed bus")
+	}
+}
+
+// TestMemNetworkPruned checks that a virtual network is released with its last bus
+func TestMemNetworkPruned(t *testing.T) {
+	networks := func() int {
+		memNetworksMu.Lock()
+		defer memNetworksMu.Unlock()
+		return len(memNetworks)
+	}
+	before := networks()
+
+	a := NewMem(t.Name())
+	b := NewMem(t.Name())
+	if got := networks(); got != before+1 {
+		t.Fatalf("%d networks after opening two buses on the same name, want %d", got, before+1)
+	}
+	a.Close()
+	a.Close()
+	if got := networks(); got != before+1 {
+		t.Fatalf("network removed while a bus is still open")
+	}
+	b.Close()
+	if got := networks(); got != before {
+		t.Fatalf("%d networks after closing the last bus, want %d", got, before)
+	}
+
+	// A new bus with the same name starts a new network, not bound to the closed one
+	c := NewMem(t.Name())
+	defer c.Close()
+	recv := c.Subscribe()
+	if err := c.Send(context.Background(), Frame{ID: 0x7FF}); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, recv)
+}
+
+// TestMemSendValidates checks that the frames that cannot be sent are rejected before reaching the receivers
+func TestMemSendValidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		ok    bool
+	}{
+		{"standard", Frame{ID: 0x7FF, Length: 8}, true},
+		{"standard ID too long", Frame{ID: 0x800}, false},
+		{"extended", Frame{ID: 0x1FFFFFFF, IsExtended: true}, true},
+		{"extended ID too long", Frame{ID: 0x20000000, IsExtended: true}, false},
+		{"classic 9 bytes", Frame{ID: 1, Length: 9}, false},
+		{"FD 12 bytes", Frame{ID: 1, Length: 12, IsFD: true}, true},
+		{"FD 64 bytes", Frame{ID: 1, Length: 64, IsFD: true, BRS: true}, true},
+		{"FD 13 bytes", Frame{ID: 1, Length: 13, IsFD: true}, false},
+		{"FD remote", Frame{ID: 1, IsFD: true, IsRemote: true}, false},
+		{"remote", Frame{ID: 1, Length: 4, IsRemote: true}, true},
+		{"classic DLC 15", Frame{ID: 1, Length: 8, Len8DLC: 15}, true},
+		{"classic DLC 9 with 4 bytes", Frame{ID: 1, Length: 4, Len8DLC: 9}, false},
+		{"classic DLC 16", Frame{ID: 1, Length: 8, Len8DLC: 16}, false},
+		{"FD DLC 15 of 8 bytes", Frame{ID: 1, Length: 8, IsFD: true, Len8DLC: 15}, false},
+	}
+
+	b := NewMem(t.Name())
+	defer b.Close()
+	recv := b.Subscribe()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.frame.Validate(); (err == nil) != tt.ok {
+				t.Fatalf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+			err := b.Send(context.Background(), tt.frame)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Send() = %v, want ok %v", err, tt.ok)
+			}
+			pending := len(recv.(*subscriber).ch)
+			if tt.ok {
+				receive(t, recv)
+			} else if pending != 0 {
+				t.Fatal("rejected frame delivered")
+			}
+		})
+	}
+}
+
+// TestDLC checks the conversions between DLCs and payload lengths
+func TestDLC(t *testing.T) {
+	tests := []struct {
+		length int
+		dlc    uint8
+		valid  int
+	}{
+		{0, 0, 0},
+		{8, 8, 8},
+		{9, 9, 12},
+		{12, 9, 12},
+		{13, 10, 16},
+		{33, 14, 48},
+		{64, 15, 64},
+		{100, 15, 64},
+	}
+	for _, tt := range tests {
+		if got := LengthToDLC(tt.length); got != tt.dlc {
+			t.Errorf("LengthToDLC(%d) = %d, want %d", tt.length, got, tt.dlc)
+		}
+		if got := ValidLength(tt.length); got != tt.valid {
+			t.Errorf("ValidLength(%d) = %d, want %d", tt.length, got, tt.valid)
+		}
+	}
+	if got := DLCToLength(15, false); got != MaxDataLength {
+		t.Errorf("DLCToLength(15, classic) = %d, want %d", got, MaxDataLength)
+	}
+	if got := DLCToLength(13, true); got != 32 {
+		t.Errorf("DLCToLength(13, FD) = %d, want 32", got)
+	}
+}
@@ -0,0 +1,193 @@
+package bus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("socketcan", openSocketCAN)
+}
+
+// Sizes of the Linux struct can_frame and struct canfd_frame
+const (
+	canFrameSize   = 16
+	canFDFrameSize = 72
+)
+
+// Flags of the can_id field and of the flags field of struct canfd_frame
+const (
+	canEFFFlag = 0x80000000 // extended frame format
+	canRTRFlag = 0x40000000 // remote transmission request
+	canERRFlag = 0x20000000 // error frame
+	canFDBRS   = 0x01       // bit rate switch
+	canFDFDF   = 0x04       // FD frame (mark for struct canfd_frame)
+)
+
+// canLen8DLCOffset is the offset of the len8_dlc field of struct can_frame, the DLC 9-15 of a frame with 8 bytes
+const canLen8DLCOffset = 7
+
+// socketCANBus is a [Bus] backed by a SocketCAN raw socket (Linux only)
+type socketCANBus struct {
+	channel   string
+	conn      io.ReadWriteCloser
+	fd        bool
+	writeMu   sync.Mutex
+	hub       *hub
+	closeOnce sync.Once
+}
+
+// openSocketCAN opens the interface named by the host of the URL (e.g. socketcan://vcan0)
+func openSocketCAN(_ context.Context, u *url.URL) (Bus, error) {
+	channel := u.Host
+	if channel == "" {
+		channel = u.Opaque
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("no SocketCAN interface name provided")
+	}
+
+	conn, fd, err := dialRaw(channel) // platform-specific
+	if err != nil {
+		return nil, fmt.Errorf("error opening SocketCAN interface %q: %w", channel, err)
+	}
+
+	b := &socketCANBus{
+		channel: channel,
+		conn:    conn,
+		fd:      fd,
+		hub:     newHub(),
+	}
+	go b.receiveLoop()
+
+	return b, nil
+}
+
+// receiveLoop reads the socket until it is closed and publishes every frame to the receivers
+func (b *socketCANBus) receiveLoop() {
+	buf := make([]byte, canFDFrameSize)
+	for {
+		n, err := b.conn.Read(buf)
+		if err != nil {
+			break
+		}
+
+		frame, ok := unmarshalFrame(buf[:n])
+		if !ok {
+			continue
+		}
+		frame.Timestamp = time.Now()
+		b.hub.publish(frame)
+	}
+	b.hub.close()
+}
+
+// unmarshalFrame decodes a struct can_frame or struct canfd_frame, error frames are discarded
+func unmarshalFrame(buf []byte) (Frame, bool) {
+	frame := Frame{}
+	if len(buf) != canFrameSize && len(buf) != canFDFrameSize {
+		return frame, false
+	}
+
+	canID := binary.NativeEndian.Uint32(buf[0:4])
+	if canID&canERRFlag != 0 {
+		return frame, false
+	}
+
+	frame.IsExtended = canID&canEFFFlag != 0
+	if frame.IsExtended {
+		frame.ID = canID & 0x1FFFFFFF
+	} else {
+		frame.ID = canID & 0x7FF
+	}
+
+	frame.Length = buf[4]
+	if len(buf) == canFDFrameSize {
+		frame.IsFD = true
+		frame.BRS = buf[5]&canFDBRS != 0
+		frame.Length = min(frame.Length, MaxFDDataLength)
+	} else {
+		frame.IsRemote = canID&canRTRFlag != 0
+		frame.Length = min(frame.Length, MaxDataLength)
+		if dlc := buf[canLen8DLCOffset]; !frame.IsRemote && frame.Length == MaxDataLength && dlc > MaxDataLength && dlc <= 15 {
+			frame.Len8DLC = dlc
+		}
+	}
+	copy(frame.Data[:], buf[8:8+int(frame.Length)])
+
+	return frame, true
+}
+
+// marshalFrame encodes the frame as a struct can_frame, or as a struct canfd_frame for FD frames
+func marshalFrame(frame Frame) []byte {
+	canID := frame.ID
+	if frame.IsExtended {
+		canID |= canEFFFlag
+	}
+
+	if !frame.IsFD {
+		if frame.IsRemote {
+			canID |= canRTRFlag
+		}
+		buf := make([]byte, canFrameSize)
+		binary.NativeEndian.PutUint32(buf[0:4], canID)
+		buf[4] = frame.Length
+		buf[canLen8DLCOffset] = frame.Len8DLC
+		copy(buf[8:], frame.Payload())
+		return buf
+	}
+
+	buf := make([]byte, canFDFrameSize)
+	binary.NativeEndian.PutUint32(buf[0:4], canID)
+	buf[4] = frame.Length
+	buf[5] = canFDFDF
+	if frame.BRS {
+		buf[5] |= canFDBRS
+	}
+	copy(buf[8:], frame.Payload())
+	return buf
+}
+
+func (b *socketCANBus) Send(ctx context.Context, frame Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := frame.Validate(); err != nil {
+		return err
+	}
+	if frame.IsFD && !b.fd {
+		return errors.New("the interface does not support CAN FD frames")
+	}
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	_, err := b.conn.Write(marshalFrame(frame))
+	return err
+}
+
+func (b *socketCANBus) Subscribe() Receiver {
+	return b.hub.subscribe()
+}
+
+func (b *socketCANBus) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		err = b.conn.Close()
+		b.hub.close()
+	})
+	return err
+}
+
+func (b *socketCANBus) Capabilities() Capabilities {
+	return Capabilities{
+		Backend: "socketcan",
+		Channel: b.channel,
+		FD:      b.fd,
+	}
+}
@@ -0,0 +1,45 @@
+package bus
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dialRaw opens a CAN_RAW socket bound to the interface.
+// CAN FD frames are enabled when the interface supports them (its MTU is the size of struct canfd_frame).
+func dialRaw(channel string) (io.ReadWriteCloser, bool, error) {
+	ifi, err := net.InterfaceByName(channel)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fd, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.CAN_RAW)
+	if err != nil {
+		return nil, false, fmt.Errorf("socket: %w", err)
+	}
+
+	fdCapable := ifi.MTU == canFDFrameSize
+	if fdCapable {
+		if err := unix.SetsockoptInt(fd, unix.SOL_CAN_RAW, unix.CAN_RAW_FD_FRAMES, 1); err != nil {
+			fdCapable = false
+		}
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrCAN{Ifindex: ifi.Index}); err != nil {
+		unix.Close(fd)
+		return nil, false, fmt.Errorf("bind: %w", err)
+	}
+
+	// A non-blocking descriptor is handled by the runtime poller,
+	// so that closing the file unblocks a pending read
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, false, fmt.Errorf("set non-blocking: %w", err)
+	}
+
+	return os.NewFile(uintptr(fd), channel), fdCapable, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bus
+
+import (
+	"errors"
+	"io"
+)
+
+// dialRaw is not supported on this platform
+func dialRaw(_ string) (io.ReadWriteCloser, bool, error) {
+	return nil, false, errors.New("SocketCAN is only available on Linux, use mem:// for the loopback bus")
+}
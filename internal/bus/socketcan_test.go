@@ -0,0 +1,32 @@
+package bus
+
+import "testing"
+
+// TestMarshalFrame checks that the frames survive the encoding as Linux structs
+func TestMarshalFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		size  int
+	}{
+		{"standard", Frame{ID: 0x123, Length: 2, Data: [MaxFDDataLength]byte{1, 2}}, canFrameSize},
+		{"extended remote", Frame{ID: 0x1FFFFFFF, IsExtended: true, IsRemote: true, Length: 4}, canFrameSize},
+		{"DLC over 8", Frame{ID: 0x7FF, Length: 8, Data: [MaxFDDataLength]byte{1, 2, 3, 4, 5, 6, 7, 8}, Len8DLC: 13}, canFrameSize},
+		{"FD", Frame{ID: 0x100, IsFD: true, BRS: true, Length: 12, Data: [MaxFDDataLength]byte{11: 0xFF}}, canFDFrameSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := marshalFrame(tt.frame)
+			if len(buf) != tt.size {
+				t.Fatalf("%d bytes, want %d", len(buf), tt.size)
+			}
+			frame, ok := unmarshalFrame(buf)
+			if !ok || frame != tt.frame {
+				t.Fatalf("unmarshalFrame() = %+v, %v, want %+v", frame, ok, tt.frame)
+			}
+			if frame.DLC() != max(tt.frame.Len8DLC, LengthToDLC(int(tt.frame.Length))) {
+				t.Errorf("DLC() = %d", frame.DLC())
+			}
+		})
+	}
+}
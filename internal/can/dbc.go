@@ -0,0 +1,44 @@
+package can
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// LoadDBC imports the DBC file at path
+func LoadDBC(path string) (*acmelib.Bus, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening DCB file: %w", err)
+	}
+	defer file.Close()
+
+	bus, err := acmelib.ImportDBCFile("debug_bus", file)
+	if err != nil {
+		return nil, fmt.Errorf("error in loading DCB file: %w", err)
+	}
+
+	return bus, nil
+}
+
+// Messages collects the messages sent by all the nodes of the bus
+func Messages(bus *acmelib.Bus) []*acmelib.Message {
+	messages := make([]*acmelib.Message, 0)
+	for _, nodeInt := range bus.NodeInterfaces() {
+		messages = append(messages, nodeInt.SentMessages()...)
+	}
+	return messages
+}
+
+// Nodes returns the node interfaces of the bus that send at least one message
+func Nodes(bus *acmelib.Bus) []*acmelib.NodeInterface {
+	nodes := make([]*acmelib.NodeInterface, 0)
+	for _, nodeInt := range bus.NodeInterfaces() {
+		if len(nodeInt.SentMessages()) > 0 {
+			nodes = append(nodes, nodeInt)
+		}
+	}
+	return nodes
+}
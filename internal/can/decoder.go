@@ -7,14 +7,14 @@ import (
 )
 
 type Decoder struct {
-	m map[uint32]func([]byte) []*acmelib.SignalDecoding
+	m map[uint32]*acmelib.Message
 }
 
 func NewDecoder(messages []*acmelib.Message) *Decoder {
-	m := make(map[uint32]func([]byte) []*acmelib.SignalDecoding)
+	m := make(map[uint32]*acmelib.Message)
 
 	for _, msg := range messages {
-		m[uint32(msg.GetCANID())] = msg.SignalLayout().Decode
+		m[Key(MessageID(msg))] = msg
 	}
 
 	return &Decoder{
@@ -22,7 +22,7 @@ func NewDecoder(messages []*acmelib.Message) *Decoder {
 	}
 }
 
-func (d *Decoder) Decode(ctx context.Context, canID uint32, data []byte) []*acmelib.SignalDecoding {
+func (d *Decoder) Decode(ctx context.Context, canID uint32, extended bool, data []byte) []*acmelib.SignalDecoding {
 	if ctx != nil {
 		select {
 		case <-ctx.Done():
@@ -31,9 +31,15 @@ func (d *Decoder) Decode(ctx context.Context, canID uint32, data []byte) []*acme
 		}
 	}
 
-	fn, ok := d.m[canID]
+	msg, ok := d.m[Key(canID, extended)]
 	if !ok {
 		return nil
 	}
-	return fn(data)
+	return msg.SignalLayout().Decode(data)
+}
+
+// Lookup returns the message with the given CAN ID, if it is defined in the DBC
+func (d *Decoder) Lookup(canID uint32, extended bool) (*acmelib.Message, bool) {
+	msg, ok := d.m[Key(canID, extended)]
+	return msg, ok
 }
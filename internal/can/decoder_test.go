@@ -0,0 +1,58 @@
+package can
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// testDBC is the DBC of the benchmarks
+const testDBC = "../test/MCB.dbc"
+
+// testPayload is the ID and a random payload of a message
+type testPayload struct {
+	id       uint32
+	extended bool
+	data     []byte
+}
+
+func loadTestMessages(tb testing.TB) []*acmelib.Message {
+	tb.Helper()
+
+	bus, err := LoadDBC(testDBC)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	messages := Messages(bus)
+	if len(messages) == 0 {
+		tb.Fatalf("no messages in %s", testDBC)
+	}
+	return messages
+}
+
+// BenchmarkDecode decodes a frame of every message of the DBC in turn, with random payloads
+func BenchmarkDecode(b *testing.B) {
+	messages := loadTestMessages(b)
+	decoder := NewDecoder(messages)
+
+	rnd := rand.New(rand.NewPCG(1, 2))
+	payloads := make([]testPayload, len(messages))
+	for i, msg := range messages {
+		id, extended := MessageID(msg)
+		data := make([]byte, msg.SizeByte())
+		for j := range data {
+			data[j] = byte(rnd.IntN(256))
+		}
+		payloads[i] = testPayload{id: id, extended: extended, data: data}
+	}
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := payloads[i%len(payloads)]
+		decoder.Decode(ctx, p.id, p.extended, p.data)
+	}
+}
@@ -0,0 +1,74 @@
+package can
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// DiagTool is the name of the node of the diagnostic tool in the DBC
+const DiagTool = "DIAG_TOOL"
+
+// DiagTarget is an ECU reachable by the diagnostic tool: the tool sends the requests with TxID
+// (a message of DiagTool received by the ECU only) and gets the responses with RxID
+// (a message of the ECU received by DiagTool)
+type DiagTarget struct {
+	Node     string
+	TxID     uint32
+	RxID     uint32
+	Extended bool
+}
+
+// DiagTargets returns the ECUs exchanging a message pair with the DiagTool node, sorted by name.
+// ISO-TP sends and receives frames of the same format, so the ECUs whose pair mixes a standard
+// and an extended ID are not targets: they are returned as mixed, sorted by name
+func DiagTargets(messages []*acmelib.Message) (targets []DiagTarget, mixed []string) {
+	requests := make(map[string]*acmelib.Message)  // ECU -> message of the tool received by it
+	responses := make(map[string]*acmelib.Message) // ECU -> message of the ECU received by the tool
+	for _, msg := range messages {
+		sender := msg.SenderNodeInterface().Node().Name()
+		receivers := msg.Receivers()
+		if len(receivers) != 1 {
+			continue
+		}
+		receiver := receivers[0].Node().Name()
+
+		switch {
+		case sender == DiagTool && receiver != DiagTool:
+			if _, ok := requests[receiver]; !ok {
+				requests[receiver] = msg
+			}
+		case receiver == DiagTool && sender != DiagTool:
+			if _, ok := responses[sender]; !ok {
+				responses[sender] = msg
+			}
+		}
+	}
+
+	targets = make([]DiagTarget, 0, len(requests))
+	for node, request := range requests {
+		response, ok := responses[node]
+		if !ok {
+			continue
+		}
+		txID, txExtended := MessageID(request)
+		rxID, rxExtended := MessageID(response)
+		if txExtended != rxExtended {
+			mixed = append(mixed, node)
+			continue
+		}
+		targets = append(targets, DiagTarget{
+			Node:     node,
+			TxID:     txID,
+			RxID:     rxID,
+			Extended: txExtended,
+		})
+	}
+	slices.SortFunc(targets, func(a, b DiagTarget) int {
+		return strings.Compare(a.Node, b.Node)
+	})
+	slices.Sort(mixed)
+
+	return targets, mixed
+}
@@ -0,0 +1,63 @@
+package can
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// diagDBC has a standard, an extended and a mixed pair with DIAG_TOOL, and an ECU without response
+const diagDBC = `VERSION ""
+
+NS_ :
+
+BS_ :
+
+BU_: DIAG_TOOL ECU_STD ECU_EXT ECU_MIXED ECU_MUTE
+
+BO_ 2016 DIAG_TOOL__reqStd: 8 DIAG_TOOL
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" ECU_STD
+
+BO_ 2024 ECU_STD__resp: 8 ECU_STD
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" DIAG_TOOL
+
+BO_ 2564428017 DIAG_TOOL__reqExt: 8 DIAG_TOOL
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" ECU_EXT
+
+BO_ 2564485392 ECU_EXT__resp: 8 ECU_EXT
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" DIAG_TOOL
+
+BO_ 2017 DIAG_TOOL__reqMixed: 8 DIAG_TOOL
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" ECU_MIXED
+
+BO_ 2564485601 ECU_MIXED__resp: 8 ECU_MIXED
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" DIAG_TOOL
+
+BO_ 2018 DIAG_TOOL__reqMute: 8 DIAG_TOOL
+  SG_ payload : 0|64@1+ (1,0) [0|0] "" ECU_MUTE
+`
+
+// TestDiagTargets checks the targets found in a DBC and the pairs mixing standard and extended IDs
+func TestDiagTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diag.dbc")
+	if err := os.WriteFile(path, []byte(diagDBC), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bus, err := LoadDBC(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets, mixed := DiagTargets(Messages(bus))
+	want := []DiagTarget{
+		{Node: "ECU_EXT", TxID: 0x18DA10F1, RxID: 0x18DAF110, Extended: true},
+		{Node: "ECU_STD", TxID: 0x7E0, RxID: 0x7E8},
+	}
+	if !slices.Equal(targets, want) {
+		t.Errorf("targets %+v, want %+v", targets, want)
+	}
+	if !slices.Equal(mixed, []string{"ECU_MIXED"}) {
+		t.Errorf("mixed %v, want ECU_MIXED", mixed)
+	}
+}
@@ -0,0 +1,29 @@
+package can
+
+import (
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// frameFormatAttribute is the DBC attribute declaring the frame format of a message
+const frameFormatAttribute = "VFrameFormat"
+
+// IsFD reports whether the message is sent as a CAN FD frame: it is longer than 8 bytes,
+// or its VFrameFormat attribute is StandardCAN_FD or ExtendedCAN_FD
+func IsFD(msg *acmelib.Message) bool {
+	if msg.SizeByte() > 8 {
+		return true
+	}
+
+	for _, att := range msg.AttributeAssignments() {
+		if att.Attribute().Name() != frameFormatAttribute {
+			continue
+		}
+		if format, ok := att.Value().(string); ok && strings.HasSuffix(format, "_FD") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package can
+
+import (
+	"fmt"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// ExtendedFlag is the bit set by the DBC format on the IDs of extended (29-bit) messages
+const ExtendedFlag uint32 = 1 << 31
+
+// CAN ID limits
+const (
+	MaxStandardID = 0x7FF
+	MaxExtendedID = 0x1FFFFFFF
+)
+
+// SplitID splits a DBC message ID into the ID sent on the bus and whether it is extended.
+// IDs with the ExtendedFlag set, or not fitting in 11 bits, are extended.
+func SplitID(dbcID uint32) (uint32, bool) {
+	if dbcID&ExtendedFlag != 0 || dbcID > MaxStandardID {
+		return dbcID & MaxExtendedID, true
+	}
+	return dbcID, false
+}
+
+// MessageID returns the ID sent on the bus for the message and whether it is extended
+func MessageID(msg *acmelib.Message) (uint32, bool) {
+	return SplitID(uint32(msg.GetCANID()))
+}
+
+// Key returns a value identifying a CAN ID, so that standard and extended
+// frames with the same numeric ID are kept apart (DBC style: ExtendedFlag set for extended IDs)
+func Key(id uint32, extended bool) uint32 {
+	if extended {
+		return id | ExtendedFlag
+	}
+	return id
+}
+
+// FormatID formats a CAN ID as hex, extended IDs always have 8 digits (like candump)
+func FormatID(id uint32, extended bool) string {
+	if extended {
+		return fmt.Sprintf("0x%08X", id)
+	}
+	return fmt.Sprintf("0x%X", id)
+}
@@ -0,0 +1,59 @@
+package can
+
+import "testing"
+
+// TestIDRoundTrip checks that the key of an ID splits back into the same ID and format
+func TestIDRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       uint32
+		extended bool
+		key      uint32
+		format   string
+	}{
+		{"standard", 0x123, false, 0x123, "0x123"},
+		{"standard zero", 0, false, 0, "0x0"},
+		{"highest standard", MaxStandardID, false, 0x7FF, "0x7FF"},
+		{"extended 0x7FF", MaxStandardID, true, 0x800007FF, "0x000007FF"},
+		{"extended", 0x18DAF110, true, 0x98DAF110, "0x18DAF110"},
+		{"highest extended", MaxExtendedID, true, 0x9FFFFFFF, "0x1FFFFFFF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := Key(tt.id, tt.extended)
+			if key != tt.key {
+				t.Fatalf("Key(0x%X, %v) = 0x%X, want 0x%X", tt.id, tt.extended, key, tt.key)
+			}
+			if id, extended := SplitID(key); id != tt.id || extended != tt.extended {
+				t.Fatalf("SplitID(0x%X) = 0x%X, %v, want 0x%X, %v", key, id, extended, tt.id, tt.extended)
+			}
+			if format := FormatID(tt.id, tt.extended); format != tt.format {
+				t.Fatalf("FormatID(0x%X, %v) = %q, want %q", tt.id, tt.extended, format, tt.format)
+			}
+		})
+	}
+
+	// Standard and extended 0x7FF are kept apart
+	if Key(MaxStandardID, false) == Key(MaxStandardID, true) {
+		t.Error("same key for standard and extended 0x7FF")
+	}
+}
+
+// TestSplitIDWithoutFlag checks that the IDs too long for 11 bits are extended even without the ExtendedFlag
+func TestSplitIDWithoutFlag(t *testing.T) {
+	tests := []struct {
+		dbcID    uint32
+		id       uint32
+		extended bool
+	}{
+		{0x7FF, 0x7FF, false},
+		{0x800, 0x800, true},
+		{MaxExtendedID, MaxExtendedID, true},
+		{0xFFFFFFFF, MaxExtendedID, true}, // bits above the 29 of the ID dropped
+	}
+	for _, tt := range tests {
+		if id, extended := SplitID(tt.dbcID); id != tt.id || extended != tt.extended {
+			t.Errorf("SplitID(0x%X) = 0x%X, %v, want 0x%X, %v", tt.dbcID, id, extended, tt.id, tt.extended)
+		}
+	}
+}
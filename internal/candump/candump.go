@@ -0,0 +1,82 @@
+// Package candump reads and writes CAN log files in the format
+// of the can-utils "candump -L" command: "(timestamp) interface ID#DATA"
+// ("ID##<flags>DATA" for CAN FD frames, "ID#DATA_<dlc>" for classic frames with a DLC of 9-15).
+package candump
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// fdFlagBRS is the bit rate switch flag of the CAN FD flags nibble ("ID##<flags>DATA")
+const fdFlagBRS = 0x1
+
+// FormatFrame formats the frame as a "candump -L" line (without the trailing newline).
+func FormatFrame(frame bus.Frame, iface string) string {
+	var sb strings.Builder
+
+	ts := frame.Timestamp
+	fmt.Fprintf(&sb, "(%d.%06d) %s ", ts.Unix(), ts.Nanosecond()/1000, iface)
+
+	if frame.IsExtended {
+		fmt.Fprintf(&sb, "%08X#", frame.ID)
+	} else {
+		fmt.Fprintf(&sb, "%03X#", frame.ID)
+	}
+
+	if frame.IsRemote {
+		sb.WriteString("R")
+		if frame.Length > 0 {
+			fmt.Fprintf(&sb, "%d", frame.Length)
+		}
+		return sb.String()
+	}
+
+	if frame.IsFD {
+		// CAN FD frames have a second '#' followed by the flags nibble
+		flags := 0
+		if frame.BRS {
+			flags |= fdFlagBRS
+		}
+		fmt.Fprintf(&sb, "#%X", flags)
+	}
+
+	sb.WriteString(strings.ToUpper(hex.EncodeToString(frame.Payload())))
+	if frame.Len8DLC != 0 {
+		fmt.Fprintf(&sb, "_%X", frame.Len8DLC)
+	}
+
+	return sb.String()
+}
+
+// Writer writes frames to an [io.Writer] in the "candump -L" format.
+type Writer struct {
+	w     *bufio.Writer
+	iface string
+}
+
+// NewWriter returns a writer that logs the frames as received on the given interface.
+func NewWriter(w io.Writer, iface string) *Writer {
+	return &Writer{
+		w:     bufio.NewWriter(w),
+		iface: iface,
+	}
+}
+
+// WriteFrame writes a single frame as a log line.
+func (w *Writer) WriteFrame(frame bus.Frame) error {
+	if _, err := w.w.WriteString(FormatFrame(frame, w.iface)); err != nil {
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+// Flush writes any buffered line to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
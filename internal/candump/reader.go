@@ -0,0 +1,184 @@
+package candump
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Entry is a frame read from a log file.
+type Entry struct {
+	Interface string
+	Frame     bus.Frame // the timestamp of the frame is the one written in the log
+}
+
+// ParseLine parses a "candump -L" line, e.g. "(1436509052.249713) vcan0 123#DEADBEEF".
+func ParseLine(line string) (Entry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return Entry{}, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	ts, err := parseTimestamp(fields[0])
+	if err != nil {
+		return Entry{}, err
+	}
+
+	frame, err := parseFrame(fields[2])
+	if err != nil {
+		return Entry{}, err
+	}
+	frame.Timestamp = ts
+
+	return Entry{
+		Interface: fields[1],
+		Frame:     frame,
+	}, nil
+}
+
+// parseTimestamp parses a "(seconds.micros)" timestamp
+func parseTimestamp(s string) (time.Time, error) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	s = s[1 : len(s)-1]
+
+	secStr, fracStr, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secStr, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	var nsec int64
+	if fracStr != "" {
+		// Normalize the fraction to nanoseconds
+		if len(fracStr) > 9 {
+			fracStr = fracStr[:9]
+		}
+		fracStr += strings.Repeat("0", 9-len(fracStr))
+		nsec, err = strconv.ParseInt(fracStr, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+		}
+	}
+
+	return time.Unix(sec, nsec), nil
+}
+
+// parseFrame parses a "ID#DATA" (or "ID##<flags>DATA", "ID#R<dlc>", "ID#DATA_<dlc>") frame, the ID is extended if it has more than 3 hex digits
+func parseFrame(s string) (bus.Frame, error) {
+	frame := bus.Frame{}
+
+	idStr, dataStr, ok := strings.Cut(s, "#")
+	if !ok {
+		return frame, fmt.Errorf("invalid frame %q: missing '#'", s)
+	}
+
+	id, err := strconv.ParseUint(idStr, 16, 32)
+	if err != nil {
+		return frame, fmt.Errorf("invalid frame ID %q", idStr)
+	}
+	frame.ID = uint32(id)
+	frame.IsExtended = len(idStr) > 3
+
+	if dlcStr, ok := strings.CutPrefix(dataStr, "R"); ok {
+		// Remote frame: "R" or "R<dlc>", the requested length is kept as the length of the frame
+		frame.IsRemote = true
+		if dlcStr != "" {
+			dlc, err := strconv.ParseUint(dlcStr, 16, 8)
+			if err != nil || dlc > bus.MaxDataLength {
+				return frame, fmt.Errorf("invalid remote frame DLC %q", dlcStr)
+			}
+			frame.Length = uint8(dlc)
+		}
+		return frame, nil
+	}
+
+	if strings.HasPrefix(dataStr, "#") {
+		// CAN FD frame: "##<flags>DATA"
+		if len(dataStr) < 2 {
+			return frame, fmt.Errorf("invalid CAN FD frame %q: missing flags", s)
+		}
+		flags, err := strconv.ParseUint(dataStr[1:2], 16, 8)
+		if err != nil {
+			return frame, fmt.Errorf("invalid CAN FD flags %q", dataStr[1:2])
+		}
+		frame.IsFD = true
+		frame.BRS = flags&fdFlagBRS != 0
+		dataStr = dataStr[2:]
+	}
+
+	dataStr, dlcStr, ok := strings.Cut(dataStr, "_")
+	if ok {
+		// Classic frame of 8 bytes with a DLC of 9-15: "DATA_<dlc>"
+		dlc, err := strconv.ParseUint(dlcStr, 16, 8)
+		if err != nil || frame.IsFD || len(dataStr) != 2*bus.MaxDataLength || dlc <= bus.MaxDataLength || dlc > 15 {
+			return frame, fmt.Errorf("invalid DLC %q of a frame with 8 bytes", dlcStr)
+		}
+		frame.Len8DLC = uint8(dlc)
+	}
+
+	data, err := hex.DecodeString(dataStr)
+	if err != nil {
+		return frame, fmt.Errorf("invalid frame data %q", dataStr)
+	}
+	maxLength := bus.MaxDataLength
+	if frame.IsFD {
+		maxLength = bus.MaxFDDataLength
+	}
+	if len(data) > maxLength {
+		return frame, fmt.Errorf("invalid frame data %q: more than %d bytes", dataStr, maxLength)
+	}
+	copy(frame.Data[:], data)
+	frame.Length = uint8(len(data))
+
+	return frame, nil
+}
+
+// ReadLog reads all the frames of a log, empty lines and lines starting with '#' are skipped.
+func ReadLog(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+
+	sc := bufio.NewScanner(r)
+	lineNum := 0
+	for sc.Scan() {
+		lineNum++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := ParseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ReadLogFile reads all the frames of the log file at path.
+func ReadLogFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := ReadLog(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading log file %s: %w", path, err)
+	}
+	return entries, nil
+}
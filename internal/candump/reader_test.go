@@ -0,0 +1,132 @@
+package candump
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// frameOf returns a frame with the payload
+func frameOf(id uint32, data ...byte) bus.Frame {
+	frame := bus.Frame{ID: id, Length: uint8(len(data))}
+	copy(frame.Data[:], data)
+	return frame
+}
+
+// TestParseLine parses candump lines and formats them back
+func TestParseLine(t *testing.T) {
+	extended := frameOf(0x18FEF100, 0x11, 0x22)
+	extended.IsExtended = true
+	shortExtended := frameOf(0x123)
+	shortExtended.IsExtended = true
+	remote := bus.Frame{ID: 0x123, IsRemote: true}
+	remoteDLC := bus.Frame{ID: 0x123, Length: 4, IsRemote: true}
+	remoteExtended := bus.Frame{ID: 0x1ABCDE, Length: 8, IsRemote: true, IsExtended: true}
+	fd := frameOf(0x123, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	fd.IsFD = true
+	fdBRS := fd
+	fdBRS.BRS = true
+	fdEmpty := bus.Frame{ID: 0x7FF, IsFD: true, BRS: true}
+	len8DLC := frameOf(0x123, 1, 2, 3, 4, 5, 6, 7, 8)
+	len8DLC.Len8DLC = 0xE
+
+	tests := []struct {
+		name   string
+		line   string
+		frame  bus.Frame
+		format string // line written back by FormatFrame, "" if the same
+	}{
+		{"standard", "(1436509052.249713) vcan0 123#DEADBEEF", frameOf(0x123, 0xDE, 0xAD, 0xBE, 0xEF), ""},
+		{"empty", "(1436509052.249713) vcan0 7FF#", frameOf(0x7FF), ""},
+		{"lowercase", "(1436509052.249713) vcan0 1a0#deadbeef", frameOf(0x1A0, 0xDE, 0xAD, 0xBE, 0xEF), "(1436509052.249713) vcan0 1A0#DEADBEEF"},
+		{"extended", "(1436509052.249713) can1 18FEF100#1122", extended, ""},
+		{"extended by ID length", "(1436509052.249713) vcan0 00000123#", shortExtended, ""},
+		{"remote", "(1436509052.249713) vcan0 123#R", remote, ""},
+		{"remote with DLC", "(1436509052.249713) vcan0 123#R4", remoteDLC, ""},
+		{"extended remote", "(1436509052.249713) vcan0 001ABCDE#R8", remoteExtended, ""},
+		{"FD", "(1436509052.249713) vcan0 123##00102030405060708090A0B0C", fd, ""},
+		{"FD with BRS", "(1436509052.249713) vcan0 123##10102030405060708090A0B0C", fdBRS, ""},
+		{"FD with ESI", "(1436509052.249713) vcan0 123##30102030405060708090A0B0C", fdBRS, "(1436509052.249713) vcan0 123##10102030405060708090A0B0C"},
+		{"FD empty", "(1436509052.249713) vcan0 7FF##1", fdEmpty, ""},
+		{"DLC over 8", "(1436509052.249713) vcan0 123#0102030405060708_E", len8DLC, ""},
+		{"lowercase DLC over 8", "(1436509052.249713) vcan0 123#0102030405060708_e", len8DLC, "(1436509052.249713) vcan0 123#0102030405060708_E"},
+		{"short timestamp", "(1436509052.25) vcan0 123#01", frameOf(0x123, 1), "(1436509052.250000) vcan0 123#01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := ParseLine(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := entry.Frame
+			got.Timestamp = time.Time{}
+			if got != tt.frame {
+				t.Fatalf("ParseLine(%q) = %+v, want %+v", tt.line, got, tt.frame)
+			}
+			if entry.Frame.Timestamp.Unix() != 1436509052 {
+				t.Errorf("timestamp %v", entry.Frame.Timestamp)
+			}
+
+			want := tt.format
+			if want == "" {
+				want = tt.line
+			}
+			if line := FormatFrame(entry.Frame, entry.Interface); line != want {
+				t.Errorf("FormatFrame() = %q, want %q", line, want)
+			}
+		})
+	}
+}
+
+// TestParseLineErrors checks that the malformed lines are rejected
+func TestParseLineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"missing fields", "(1436509052.249713) 123#00"},
+		{"bad timestamp", "1436509052.249713 vcan0 123#00"},
+		{"missing separator", "(1436509052.249713) vcan0 12300"},
+		{"bad ID", "(1436509052.249713) vcan0 12G#00"},
+		{"odd data", "(1436509052.249713) vcan0 123#123"},
+		{"classic too long", "(1436509052.249713) vcan0 123#000102030405060708"},
+		{"FD too long", "(1436509052.249713) vcan0 123##0" + strings.Repeat("00", 65)},
+		{"FD missing flags", "(1436509052.249713) vcan0 123##"},
+		{"FD bad flags", "(1436509052.249713) vcan0 123##G00"},
+		{"remote bad DLC", "(1436509052.249713) vcan0 123#RX"},
+		{"remote DLC too big", "(1436509052.249713) vcan0 123#R9"},
+		{"DLC over 8 with 4 bytes", "(1436509052.249713) vcan0 123#01020304_9"},
+		{"DLC 8 after the data", "(1436509052.249713) vcan0 123#0102030405060708_8"},
+		{"bad DLC after the data", "(1436509052.249713) vcan0 123#0102030405060708_X"},
+		{"FD DLC after the data", "(1436509052.249713) vcan0 123##00102030405060708_F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if entry, err := ParseLine(tt.line); err == nil {
+				t.Fatalf("ParseLine(%q) = %+v, want an error", tt.line, entry.Frame)
+			}
+		})
+	}
+}
+
+// TestReadLog reads a log with comments and empty lines, the errors report the line
+func TestReadLog(t *testing.T) {
+	entries, err := ReadLog(strings.NewReader("# header\n\n(1.000001) vcan0 123#01\n(2.5) vcan1 456#R2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+	if entries[1].Interface != "vcan1" || entries[1].Frame.Timestamp.Sub(entries[0].Frame.Timestamp) != 1499999*time.Microsecond {
+		t.Errorf("unexpected second entry %+v", entries[1])
+	}
+
+	_, err = ReadLog(strings.NewReader("(1.0) vcan0 123#01\n(2.0) vcan0 123#0\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("error %v, want one on line 2", err)
+	}
+}
@@ -0,0 +1,117 @@
+package candump
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// flushInterval is how often the recorded frames are flushed to the file
+const flushInterval = 500 * time.Millisecond
+
+// Recorder writes every frame received on a bus to a log file.
+type Recorder struct {
+	path  string
+	file  *os.File
+	w     *Writer
+	recv  bus.Receiver
+	count atomic.Int64
+
+	mu   sync.Mutex // protects w and err
+	err  error
+	done chan struct{}
+}
+
+// DefaultLogName returns a log file name based on the current time.
+func DefaultLogName() string {
+	return fmt.Sprintf("can-debug_%s.log", time.Now().Format("20060102-150405"))
+}
+
+// StartRecording creates the log file at path and starts recording all the frames of the bus.
+// The interface name written in the log is the channel of the bus.
+func StartRecording(b bus.Bus, path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating log file: %w", err)
+	}
+
+	iface := b.Capabilities().Channel
+	if iface == "" {
+		iface = "can0"
+	}
+
+	r := &Recorder{
+		path: path,
+		file: file,
+		w:    NewWriter(file, iface),
+		recv: b.Subscribe(),
+		done: make(chan struct{}),
+	}
+	go r.run()
+	go r.flushLoop()
+
+	return r, nil
+}
+
+// run writes the received frames until the receiver is closed
+func (r *Recorder) run() {
+	defer close(r.done)
+	for r.recv.Receive() {
+		r.mu.Lock()
+		if err := r.w.WriteFrame(r.recv.Frame()); err != nil && r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+		r.count.Add(1)
+	}
+}
+
+// flushLoop periodically flushes the log, so that it can be read while recording
+func (r *Recorder) flushLoop() {
+	tick := time.NewTicker(flushInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-tick.C:
+			r.mu.Lock()
+			if err := r.w.Flush(); err != nil && r.err == nil {
+				r.err = err
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// Path returns the path of the log file.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Count returns the number of frames recorded so far.
+func (r *Recorder) Count() int64 {
+	return r.count.Load()
+}
+
+// Stop stops the recording and closes the log file.
+// It returns the first error encountered while writing, if any.
+func (r *Recorder) Stop() error {
+	r.recv.Close()
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+
+	return r.err
+}
@@ -0,0 +1,76 @@
+// Package canopen implements the CANopen (CiA 301) services used to debug the nodes of the bus:
+// NMT commands and heartbeats, an SDO client with expedited and segmented transfers, and the
+// object names and data types of the EDS files.
+package canopen
+
+import "fmt"
+
+// Function codes of the predefined connection set: the COB-ID of a service is its function code plus the node ID
+const (
+	COBNMT       = 0x000
+	COBSync      = 0x080 // without node ID
+	COBEmergency = 0x080
+	COBTime      = 0x100
+	COBTPDO1     = 0x180
+	COBRPDO1     = 0x200
+	COBTPDO2     = 0x280
+	COBRPDO2     = 0x300
+	COBTPDO3     = 0x380
+	COBRPDO3     = 0x400
+	COBTPDO4     = 0x480
+	COBRPDO4     = 0x500
+	COBSDOTx     = 0x580 // responses of the SDO servers
+	COBSDORx     = 0x600 // requests to the SDO servers
+	COBHeartbeat = 0x700
+)
+
+// MaxNodeID is the highest node ID, 0 addresses every node in the NMT commands
+const MaxNodeID = 127
+
+// functionNames are the services of the function codes with a node ID
+var functionNames = map[uint32]string{
+	COBEmergency: "EMCY",
+	COBTPDO1:     "TPDO1",
+	COBRPDO1:     "RPDO1",
+	COBTPDO2:     "TPDO2",
+	COBRPDO2:     "RPDO2",
+	COBTPDO3:     "TPDO3",
+	COBRPDO3:     "RPDO3",
+	COBTPDO4:     "TPDO4",
+	COBRPDO4:     "RPDO4",
+	COBSDOTx:     "SDO tx",
+	COBSDORx:     "SDO rx",
+	COBHeartbeat: "Heartbeat",
+}
+
+// ParseCOBID returns the service of a standard ID in the predefined connection set and the node
+// it belongs to (0 for NMT, SYNC and TIME), "" if it is not part of it.
+func ParseCOBID(cobID uint32) (string, uint8) {
+	switch cobID {
+	case COBNMT:
+		return "NMT", 0
+	case COBSync:
+		return "SYNC", 0
+	case COBTime:
+		return "TIME", 0
+	}
+	node := uint8(cobID & MaxNodeID)
+	name, ok := functionNames[cobID&^MaxNodeID]
+	if !ok || node == 0 {
+		return "", 0
+	}
+	return name, node
+}
+
+// IsPDO tells whether the standard ID is the one of a PDO of the predefined connection set.
+func IsPDO(cobID uint32) bool {
+	return cobID&^MaxNodeID >= COBTPDO1 && cobID&^MaxNodeID <= COBRPDO4 && cobID&MaxNodeID != 0
+}
+
+// checkNode returns an error if the node ID is not between 1 and MaxNodeID
+func checkNode(node uint8) error {
+	if node == 0 || node > MaxNodeID {
+		return fmt.Errorf("invalid node ID %d: it must be between 1 and %d", node, MaxNodeID)
+	}
+	return nil
+}
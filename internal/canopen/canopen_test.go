@@ -0,0 +1,122 @@
+package canopen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// TestParseCOBID checks the services and nodes of the predefined connection set
+func TestParseCOBID(t *testing.T) {
+	tests := []struct {
+		cobID   uint32
+		service string
+		node    uint8
+		pdo     bool
+	}{
+		{0x000, "NMT", 0, false},
+		{0x080, "SYNC", 0, false},
+		{0x081, "EMCY", 1, false},
+		{0x0FF, "EMCY", 127, false},
+		{0x100, "TIME", 0, false},
+		{0x101, "", 0, false},
+		{0x180, "", 0, false}, // node 0
+		{0x181, "TPDO1", 1, true},
+		{0x1A2, "TPDO1", 0x22, true},
+		{0x222, "RPDO1", 0x22, true},
+		{0x4FF, "TPDO4", 127, true},
+		{0x57F, "RPDO4", 127, true},
+		{0x580, "", 0, false},
+		{0x5A2, "SDO tx", 0x22, false},
+		{0x622, "SDO rx", 0x22, false},
+		{0x700, "", 0, false},
+		{0x77F, "Heartbeat", 127, false},
+		{0x780, "", 0, false},
+		{0x7E5, "", 0, false},
+	}
+	for _, tt := range tests {
+		service, node := ParseCOBID(tt.cobID)
+		if service != tt.service || node != tt.node {
+			t.Errorf("ParseCOBID(0x%03X) = %q, %d, want %q, %d", tt.cobID, service, node, tt.service, tt.node)
+		}
+		if IsPDO(tt.cobID) != tt.pdo {
+			t.Errorf("IsPDO(0x%03X) = %v, want %v", tt.cobID, !tt.pdo, tt.pdo)
+		}
+	}
+}
+
+// testFrame returns a standard frame with the data
+func testFrame(id uint32, data ...byte) bus.Frame {
+	frame := bus.Frame{ID: id, Length: uint8(len(data))}
+	copy(frame.Data[:], data)
+	return frame
+}
+
+// TestNodeTable checks the states, periods and boot-ups of the heartbeats and the emergencies
+func TestNodeTable(t *testing.T) {
+	table := NewNodeTable()
+	start := time.Now()
+
+	steps := []struct {
+		after time.Duration
+		frame bus.Frame
+		want  bool
+	}{
+		{0, testFrame(COBHeartbeat+5, byte(StateBootUp)), true},
+		{10 * time.Millisecond, testFrame(COBHeartbeat+5, byte(StatePreOperational)), true},
+		{100 * time.Millisecond, testFrame(COBHeartbeat+5, 0x80|byte(StateOperational)), true}, // node guarding toggle
+		{0, testFrame(COBEmergency+5, 0x10, 0x32, 0x11, 0, 0, 0, 0, 0), true},
+		{0, testFrame(COBEmergency+5, 0x10), false},   // too short
+		{0, testFrame(COBHeartbeat + 3), false},       // empty
+		{0, testFrame(COBSync), false},                // SYNC, not the EMCY of a node
+		{0, testFrame(COBTPDO1+5, 1, 2, 3), false},    // not a node state
+		{0, testFrame(COBHeartbeat+3, 0x04, 0), true}, // another node
+		{200 * time.Millisecond, testFrame(COBHeartbeat+5, byte(StateBootUp)), true},
+		{0, bus.Frame{ID: COBHeartbeat + 7, IsExtended: true, Length: 1}, false},
+		{0, bus.Frame{ID: COBHeartbeat + 7, IsRemote: true}, false},
+	}
+	at := start
+	for i, s := range steps {
+		at = at.Add(s.after)
+		if got := table.Observe(at, s.frame); got != s.want {
+			t.Fatalf("step %d: Observe(0x%X) = %v, want %v", i, s.frame.ID, got, s.want)
+		}
+	}
+
+	nodes := table.Nodes()
+	if len(nodes) != 2 || nodes[0].ID != 3 || nodes[1].ID != 5 {
+		t.Fatalf("nodes %+v, want 3 and 5", nodes)
+	}
+	if n := nodes[0]; n.State != StateStopped || n.Heartbeats != 1 || n.BootUps != 0 || n.Period != 0 {
+		t.Errorf("node 3 = %+v, want stopped after 1 heartbeat", n)
+	}
+	n := nodes[1]
+	if n.State != StateBootUp || n.Heartbeats != 4 || n.BootUps != 2 || n.Period != 200*time.Millisecond || !n.Last.Equal(at) {
+		t.Errorf("node 5 = %+v, want booted up twice after 4 heartbeats", n)
+	}
+	if n.EmergencyCode != 0x3210 || n.EmergencyRegister != 0x11 || n.Emergencies != 1 {
+		t.Errorf("node 5 emergency 0x%04X/0x%02X x%d, want 0x3210/0x11 x1", n.EmergencyCode, n.EmergencyRegister, n.Emergencies)
+	}
+
+	states := map[State]string{StateBootUp: "Boot-up", StateOperational: "Operational", 0x33: "Unknown (0x33)"}
+	for state, want := range states {
+		if state.String() != want {
+			t.Errorf("State(0x%02X).String() = %q, want %q", byte(state), state.String(), want)
+		}
+	}
+}
+
+// TestNMTFrame checks the frames of the NMT commands
+func TestNMTFrame(t *testing.T) {
+	frame := NMTFrame(NMTResetNode, 0x22)
+	if frame.ID != COBNMT || frame.IsExtended || frame.Length != 2 || frame.Data[0] != 0x81 || frame.Data[1] != 0x22 {
+		t.Fatalf("NMTFrame() = %+v", frame)
+	}
+
+	b := bus.NewMem(t.Name())
+	defer b.Close()
+	if err := SendNMT(t.Context(), b, NMTStart, MaxNodeID+1); err == nil {
+		t.Error("NMT command sent to the node 128")
+	}
+}
@@ -0,0 +1,145 @@
+package canopen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Object is an entry of the object dictionary described by an EDS file.
+type Object struct {
+	Index      uint16
+	SubIndex   uint8
+	Name       string // ParameterName, prefixed by the name of the parent for the sub-indexes
+	DataType   DataType
+	AccessType string // ro, wo, rw, rww, rwr or const
+	Default    string
+}
+
+// EDS is the object dictionary of a device, read from its electronic data sheet.
+type EDS struct {
+	Product string // ProductName of the DeviceInfo section
+	objects map[uint32]Object
+}
+
+// LoadEDS reads the EDS file at path.
+func LoadEDS(path string) (*EDS, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening the EDS file: %w", err)
+	}
+	defer file.Close()
+
+	eds, err := ReadEDS(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return eds, nil
+}
+
+// ReadEDS parses an EDS (INI format): the sections [1018] and [1018sub1] describe the objects.
+func ReadEDS(r io.Reader) (*EDS, error) {
+	eds := &EDS{objects: make(map[uint32]Object)}
+	sections := make(map[string]map[string]string)
+	var order []string
+	var current map[string]string
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == ';' || text[0] == '#' {
+			continue
+		}
+		if text[0] == '[' {
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: malformed section", line)
+			}
+			name := strings.ToLower(strings.TrimSpace(text[1 : len(text)-1]))
+			if _, ok := sections[name]; !ok {
+				sections[name] = make(map[string]string)
+				order = append(order, name)
+			}
+			current = sections[name]
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || current == nil {
+			return nil, fmt.Errorf("line %d: expected key=value in a section", line)
+		}
+		current[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	eds.Product = sections["deviceinfo"]["productname"]
+	for _, name := range order {
+		index, sub, ok := parseSection(name)
+		if !ok {
+			continue
+		}
+		keys := sections[name]
+		object := Object{
+			Index:      index,
+			SubIndex:   sub,
+			Name:       keys["parametername"],
+			AccessType: strings.ToLower(keys["accesstype"]),
+			Default:    keys["defaultvalue"],
+		}
+		if dataType, err := strconv.ParseUint(keys["datatype"], 0, 16); err == nil {
+			object.DataType = DataType(dataType)
+		}
+		if parent, ok := sections[fmt.Sprintf("%04x", index)]; ok && name != fmt.Sprintf("%04x", index) {
+			object.Name = parent["parametername"] + " / " + object.Name
+		}
+		eds.objects[objectKey(index, sub)] = object
+	}
+	if len(eds.objects) == 0 {
+		return nil, fmt.Errorf("no objects")
+	}
+	return eds, nil
+}
+
+// parseSection returns the index and sub-index of an object section, e.g. "1018" or "1018sub1"
+func parseSection(name string) (uint16, uint8, bool) {
+	indexText, subText, hasSub := strings.Cut(name, "sub")
+	if len(indexText) != 4 {
+		return 0, 0, false
+	}
+	index, err := strconv.ParseUint(indexText, 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !hasSub {
+		return uint16(index), 0, true
+	}
+	sub, err := strconv.ParseUint(subText, 16, 8)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(index), uint8(sub), true
+}
+
+// objectKey identifies an object of the dictionary
+func objectKey(index uint16, sub uint8) uint32 {
+	return uint32(index)<<8 | uint32(sub)
+}
+
+// Object returns the object at index and sub-index, the sub-index 0 of the variables is the variable itself.
+func (e *EDS) Object(index uint16, sub uint8) (Object, bool) {
+	if e == nil {
+		return Object{}, false
+	}
+	object, ok := e.objects[objectKey(index, sub)]
+	return object, ok
+}
+
+// Len returns the number of objects described.
+func (e *EDS) Len() int {
+	return len(e.objects)
+}
@@ -0,0 +1,107 @@
+package canopen
+
+import (
+	"strings"
+	"testing"
+)
+
+// testEDS is an EDS with a variable, a record and sections that are not objects
+const testEDS = `; Test device
+[FileInfo]
+FileName=test.eds
+
+[DeviceInfo]
+VendorName=Squadra Corse
+ProductName = Inverter
+
+[MandatoryObjects]
+SupportedObjects=2
+1=0x1000
+2=0x1018
+
+[1000]
+ParameterName=Device type
+ObjectType=0x7
+DataType=0x0007
+AccessType=ro
+DefaultValue=0x00000192
+
+[1018]
+ParameterName=Identity object
+ObjectType=0x9
+SubNumber=2
+
+[1018sub0]
+ParameterName=Highest sub-index supported
+DataType=0x0005
+AccessType=const
+DefaultValue=1
+
+[1018SUB1]
+ParameterName=Vendor-ID
+DataType=7
+AccessType=RO
+
+# Manufacturer objects
+[2000]
+ParameterName=Speed limit
+DataType=0x0003
+AccessType=rww
+`
+
+// TestReadEDS checks the objects read from an EDS
+func TestReadEDS(t *testing.T) {
+	eds, err := ReadEDS(strings.NewReader(testEDS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sub-index 0 of the record replaces the record itself
+	if eds.Product != "Inverter" || eds.Len() != 4 {
+		t.Fatalf("product %q with %d objects, want Inverter with 4", eds.Product, eds.Len())
+	}
+
+	tests := []struct {
+		index uint16
+		sub   uint8
+		want  Object
+	}{
+		{0x1000, 0, Object{Name: "Device type", DataType: Unsigned32, AccessType: "ro", Default: "0x00000192"}},
+		{0x1018, 0, Object{Name: "Identity object / Highest sub-index supported", DataType: Unsigned8, AccessType: "const", Default: "1"}},
+		{0x1018, 1, Object{Name: "Identity object / Vendor-ID", DataType: Unsigned32, AccessType: "ro"}},
+		{0x2000, 0, Object{Name: "Speed limit", DataType: Integer16, AccessType: "rww"}},
+	}
+	for _, tt := range tests {
+		tt.want.Index, tt.want.SubIndex = tt.index, tt.sub
+		if object, ok := eds.Object(tt.index, tt.sub); !ok || object != tt.want {
+			t.Errorf("Object(%04X:%02X) = %+v, %v, want %+v", tt.index, tt.sub, object, ok, tt.want)
+		}
+	}
+	if _, ok := eds.Object(0x1018, 2); ok {
+		t.Error("sub-index 2 of 1018 found")
+	}
+	if _, ok := (*EDS)(nil).Object(0x1000, 0); ok {
+		t.Error("object found without an EDS")
+	}
+}
+
+// TestReadEDSErrors checks the EDS files rejected
+func TestReadEDSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		eds  string
+		want string
+	}{
+		{"malformed section", "[1000\nParameterName=Device type\n", "line 1: malformed section"},
+		{"key outside of a section", "ParameterName=Device type\n[1000]\n", "line 1: expected key=value"},
+		{"line without value", "[1000]\nParameterName\n", "line 2: expected key=value"},
+		{"no objects", "[DeviceInfo]\nProductName=Inverter\n[10000]\n[1000subX]\n", "no objects"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadEDS(strings.NewReader(tt.eds))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ReadEDS() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
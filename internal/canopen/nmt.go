@@ -0,0 +1,164 @@
+package canopen
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Command is an NMT command sent by the master.
+type Command byte
+
+// NMT commands
+const (
+	NMTStart               Command = 0x01
+	NMTStop                Command = 0x02
+	NMTEnterPreOperational Command = 0x80
+	NMTResetNode           Command = 0x81
+	NMTResetCommunication  Command = 0x82
+)
+
+// NMTCommands lists the NMT commands.
+var NMTCommands = []Command{NMTStart, NMTStop, NMTEnterPreOperational, NMTResetNode, NMTResetCommunication}
+
+func (c Command) String() string {
+	switch c {
+	case NMTStart:
+		return "Start"
+	case NMTStop:
+		return "Stop"
+	case NMTEnterPreOperational:
+		return "Enter pre-operational"
+	case NMTResetNode:
+		return "Reset node"
+	case NMTResetCommunication:
+		return "Reset communication"
+	}
+	return fmt.Sprintf("NMT 0x%02X", byte(c))
+}
+
+// NMTFrame returns the frame of an NMT command for a node, 0 for every node.
+func NMTFrame(cmd Command, node uint8) bus.Frame {
+	frame := bus.Frame{ID: COBNMT, Length: 2}
+	frame.Data[0], frame.Data[1] = byte(cmd), node
+	return frame
+}
+
+// SendNMT sends an NMT command to a node, 0 for every node.
+func SendNMT(ctx context.Context, b bus.Bus, cmd Command, node uint8) error {
+	if node > MaxNodeID {
+		return fmt.Errorf("invalid node ID %d: it must be between 0 (every node) and %d", node, MaxNodeID)
+	}
+	return b.Send(ctx, NMTFrame(cmd, node))
+}
+
+// State is the NMT state of a node, sent in its heartbeats.
+type State byte
+
+// NMT states
+const (
+	StateBootUp         State = 0x00
+	StateStopped        State = 0x04
+	StateOperational    State = 0x05
+	StatePreOperational State = 0x7F
+)
+
+func (s State) String() string {
+	switch s {
+	case StateBootUp:
+		return "Boot-up"
+	case StateStopped:
+		return "Stopped"
+	case StateOperational:
+		return "Operational"
+	case StatePreOperational:
+		return "Pre-operational"
+	}
+	return fmt.Sprintf("Unknown (0x%02X)", byte(s))
+}
+
+// Node is what the heartbeats and emergencies of a node tell about it.
+type Node struct {
+	ID         uint8
+	State      State
+	Heartbeats uint64
+	BootUps    int           // boot-up messages, sent after every reset
+	Period     time.Duration // between the last two heartbeats
+	Last       time.Time     // last heartbeat
+	// Last emergency, its code is 0 once the errors are reset
+	EmergencyCode     uint16
+	EmergencyRegister byte
+	Emergencies       int
+	LastEmergency     time.Time
+}
+
+// NodeTable follows the heartbeats and emergencies of the nodes. It is not safe for concurrent use.
+type NodeTable struct {
+	nodes map[uint8]*Node
+}
+
+// NewNodeTable returns an empty table.
+func NewNodeTable() *NodeTable {
+	return &NodeTable{nodes: make(map[uint8]*Node)}
+}
+
+// Observe records a frame received at the given time, it returns true if it is a heartbeat or an emergency.
+func (t *NodeTable) Observe(at time.Time, frame bus.Frame) bool {
+	if frame.IsExtended || frame.IsRemote {
+		return false
+	}
+	service, id := ParseCOBID(frame.ID)
+	data := frame.Payload()
+
+	switch {
+	case service == "Heartbeat" && len(data) >= 1:
+		node := t.node(id)
+		// The toggle bit of node guarding is not part of the state
+		node.State = State(data[0] & 0x7F)
+		if node.State == StateBootUp {
+			node.BootUps++
+		}
+		if node.Heartbeats > 0 {
+			node.Period = at.Sub(node.Last)
+		}
+		node.Heartbeats++
+		node.Last = at
+		return true
+
+	case service == "EMCY" && len(data) >= 3:
+		node := t.node(id)
+		node.EmergencyCode = binary.LittleEndian.Uint16(data)
+		node.EmergencyRegister = data[2]
+		node.Emergencies++
+		node.LastEmergency = at
+		return true
+	}
+	return false
+}
+
+// node returns the node with the ID, adding it if it is new
+func (t *NodeTable) node(id uint8) *Node {
+	node, ok := t.nodes[id]
+	if !ok {
+		node = &Node{ID: id}
+		t.nodes[id] = node
+	}
+	return node
+}
+
+// Nodes returns the nodes sorted by ID.
+func (t *NodeTable) Nodes() []Node {
+	nodes := make([]Node, 0, len(t.nodes))
+	for _, node := range t.nodes {
+		nodes = append(nodes, *node)
+	}
+	slices.SortFunc(nodes, func(a, b Node) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return nodes
+}
@@ -0,0 +1,354 @@
+package canopen
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Command specifiers of the SDO requests (client) and responses (server), bits 7-5 of the first byte
+const (
+	ccsDownloadSegment  = 0 << 5
+	ccsInitiateDownload = 1 << 5
+	ccsInitiateUpload   = 2 << 5
+	ccsUploadSegment    = 3 << 5
+	scsUploadSegment    = 0 << 5
+	scsDownloadSegment  = 1 << 5
+	scsInitiateUpload   = 2 << 5
+	scsInitiateDownload = 3 << 5
+	csAbort             = 4 << 5
+	csMask              = 7 << 5
+)
+
+// Bits of the first byte of the SDO messages
+const (
+	sdoSizeIndicated = 0x01
+	sdoExpedited     = 0x02
+	sdoLastSegment   = 0x01
+	sdoToggle        = 0x10
+)
+
+const (
+	// DefaultSDOTimeout is how long the client waits for each response of the server
+	DefaultSDOTimeout = time.Second
+	// expeditedSize is the longest value sent in a single message
+	expeditedSize = 4
+	// segmentSize is the number of bytes in each segment
+	segmentSize = 7
+	// maxSegmented is the longest value the client uploads
+	maxSegmented = 1 << 20
+	// responsesBuffer is the number of responses of the server waiting for the client
+	responsesBuffer = 16
+)
+
+// Abort codes sent by the client
+const (
+	AbortToggleBit    = 0x05030000
+	AbortTimeout      = 0x05040000
+	AbortCommand      = 0x05040001
+	AbortOutOfMemory  = 0x05040005
+	AbortGeneralError = 0x08000000
+)
+
+// abortTexts are the descriptions of the abort codes of CiA 301
+var abortTexts = map[uint32]string{
+	AbortToggleBit:    "toggle bit not alternated",
+	AbortTimeout:      "SDO protocol timed out",
+	AbortCommand:      "command specifier not valid or unknown",
+	AbortOutOfMemory:  "out of memory",
+	0x06010000:        "unsupported access to an object",
+	0x06010001:        "attempt to read a write only object",
+	0x06010002:        "attempt to write a read only object",
+	0x06020000:        "object does not exist in the object dictionary",
+	0x06040041:        "object cannot be mapped to the PDO",
+	0x06040042:        "the objects to be mapped would exceed the PDO length",
+	0x06040043:        "general parameter incompatibility",
+	0x06040047:        "general internal incompatibility in the device",
+	0x06060000:        "access failed due to a hardware error",
+	0x06070010:        "data type does not match, length of service parameter does not match",
+	0x06070012:        "data type does not match, length of service parameter too high",
+	0x06070013:        "data type does not match, length of service parameter too low",
+	0x06090011:        "sub-index does not exist",
+	0x06090030:        "invalid value for parameter",
+	0x06090031:        "value of parameter written too high",
+	0x06090032:        "value of parameter written too low",
+	0x06090036:        "maximum value is less than minimum value",
+	0x060A0023:        "resource not available: SDO connection",
+	AbortGeneralError: "general error",
+	0x08000020:        "data cannot be transferred or stored to the application",
+	0x08000021:        "data cannot be transferred or stored to the application because of local control",
+	0x08000022:        "data cannot be transferred or stored to the application because of the present device state",
+	0x08000023:        "object dictionary dynamic generation fails or no object dictionary is present",
+	0x08000024:        "no data available",
+}
+
+var (
+	ErrSDOTimeout  = errors.New("no response from the SDO server")
+	ErrSDOProtocol = errors.New("invalid SDO response")
+	ErrSDOClosed   = errors.New("SDO client closed")
+)
+
+// AbortError is an SDO transfer aborted by the server.
+type AbortError struct {
+	Index    uint16
+	SubIndex uint8
+	Code     uint32
+}
+
+func (e *AbortError) Error() string {
+	text, ok := abortTexts[e.Code]
+	if !ok {
+		text = "unknown abort code"
+	}
+	return fmt.Sprintf("SDO %04X:%02X aborted: %s (0x%08X)", e.Index, e.SubIndex, text, e.Code)
+}
+
+// Client is an SDO client talking to the server of a node. Its transfers are serialized.
+type Client struct {
+	bus     bus.Bus
+	node    uint8
+	Timeout time.Duration // DefaultSDOTimeout if 0
+
+	mu        sync.Mutex // serializes the transfers
+	recv      bus.Receiver
+	responses chan [8]byte
+}
+
+// NewClient returns a client of the SDO server of the node, it receives the responses until Close.
+func NewClient(b bus.Bus, node uint8) (*Client, error) {
+	if err := checkNode(node); err != nil {
+		return nil, err
+	}
+	c := &Client{
+		bus:       b,
+		node:      node,
+		recv:      b.Subscribe(),
+		responses: make(chan [8]byte, responsesBuffer),
+	}
+	go c.run()
+	return c, nil
+}
+
+// Node returns the ID of the node of the server.
+func (c *Client) Node() uint8 {
+	return c.node
+}
+
+// Close stops receiving the responses.
+func (c *Client) Close() error {
+	return c.recv.Close()
+}
+
+// run passes the responses of the server to the transfers, it is intended as a goroutine
+func (c *Client) run() {
+	defer close(c.responses)
+
+	for c.recv.Receive() {
+		frame := c.recv.Frame()
+		if frame.IsExtended || frame.IsRemote || frame.ID != COBSDOTx+uint32(c.node) || frame.Length != 8 {
+			continue
+		}
+		var response [8]byte
+		copy(response[:], frame.Payload())
+		select {
+		case c.responses <- response:
+		default:
+			// Nobody is waiting for so many responses
+		}
+	}
+}
+
+// Upload reads the value of an object of the node, expedited or segmented as the server chooses.
+func (c *Client) Upload(ctx context.Context, index uint16, sub uint8) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	response, err := c.exchange(ctx, index, sub, initiate(ccsInitiateUpload, index, sub))
+	if err != nil {
+		return nil, err
+	}
+	if response[0]&csMask != scsInitiateUpload || !sameObject(response, index, sub) {
+		return nil, c.protocolError(ctx, index, sub, "command 0x%02X for %04X:%02X instead of the upload response", response[0], binary.LittleEndian.Uint16(response[1:]), response[3])
+	}
+
+	if response[0]&sdoExpedited != 0 {
+		size := expeditedSize
+		if response[0]&sdoSizeIndicated != 0 {
+			size -= int(response[0] >> 2 & 0x03)
+		}
+		return append([]byte(nil), response[4:4+size]...), nil
+	}
+
+	// Segmented: the server tells the size, if it knows it
+	size := -1
+	if response[0]&sdoSizeIndicated != 0 {
+		size = int(binary.LittleEndian.Uint32(response[4:]))
+		if size > maxSegmented {
+			c.abort(ctx, index, sub, AbortOutOfMemory)
+			return nil, fmt.Errorf("SDO %04X:%02X: %d bytes are too many to upload", index, sub, size)
+		}
+	}
+	data := make([]byte, 0, max(size, 0))
+	toggle := byte(0)
+	for {
+		response, err := c.exchange(ctx, index, sub, [8]byte{ccsUploadSegment | toggle})
+		if err != nil {
+			return nil, err
+		}
+		if response[0]&csMask != scsUploadSegment {
+			return nil, c.protocolError(ctx, index, sub, "command 0x%02X instead of an upload segment", response[0])
+		}
+		if response[0]&sdoToggle != toggle {
+			c.abort(ctx, index, sub, AbortToggleBit)
+			return nil, fmt.Errorf("SDO %04X:%02X: %w: toggle bit not alternated", index, sub, ErrSDOProtocol)
+		}
+
+		data = append(data, response[1:1+segmentSize-int(response[0]>>1&0x07)]...)
+		if len(data) > maxSegmented {
+			c.abort(ctx, index, sub, AbortOutOfMemory)
+			return nil, fmt.Errorf("SDO %04X:%02X: more than %d bytes to upload", index, sub, maxSegmented)
+		}
+		if response[0]&sdoLastSegment != 0 {
+			break
+		}
+		toggle ^= sdoToggle
+	}
+
+	if size >= 0 && len(data) != size {
+		return nil, fmt.Errorf("SDO %04X:%02X: %w: %d bytes uploaded instead of %d", index, sub, ErrSDOProtocol, len(data), size)
+	}
+	return data, nil
+}
+
+// Download writes the value of an object of the node: expedited up to 4 bytes, segmented beyond.
+func (c *Client) Download(ctx context.Context, index uint16, sub uint8, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(data) == 0 {
+		return fmt.Errorf("SDO %04X:%02X: no data to download", index, sub)
+	}
+
+	request := initiate(ccsInitiateDownload, index, sub)
+	if len(data) <= expeditedSize {
+		request[0] |= sdoExpedited | sdoSizeIndicated | byte(expeditedSize-len(data))<<2
+		copy(request[4:], data)
+	} else {
+		request[0] |= sdoSizeIndicated
+		binary.LittleEndian.PutUint32(request[4:], uint32(len(data)))
+	}
+	response, err := c.exchange(ctx, index, sub, request)
+	if err != nil {
+		return err
+	}
+	if response[0]&csMask != scsInitiateDownload || !sameObject(response, index, sub) {
+		return c.protocolError(ctx, index, sub, "command 0x%02X for %04X:%02X instead of the download response", response[0], binary.LittleEndian.Uint16(response[1:]), response[3])
+	}
+	if len(data) <= expeditedSize {
+		return nil
+	}
+
+	toggle := byte(0)
+	for offset := 0; offset < len(data); offset += segmentSize {
+		segment := data[offset:min(offset+segmentSize, len(data))]
+		request := [8]byte{ccsDownloadSegment | toggle | byte(segmentSize-len(segment))<<1}
+		if offset+segmentSize >= len(data) {
+			request[0] |= sdoLastSegment
+		}
+		copy(request[1:], segment)
+
+		response, err := c.exchange(ctx, index, sub, request)
+		if err != nil {
+			return err
+		}
+		if response[0]&csMask != scsDownloadSegment {
+			return c.protocolError(ctx, index, sub, "command 0x%02X instead of a download segment response", response[0])
+		}
+		if response[0]&sdoToggle != toggle {
+			c.abort(ctx, index, sub, AbortToggleBit)
+			return fmt.Errorf("SDO %04X:%02X: %w: toggle bit not alternated", index, sub, ErrSDOProtocol)
+		}
+		toggle ^= sdoToggle
+	}
+	return nil
+}
+
+// initiate returns the first request of a transfer
+func initiate(command byte, index uint16, sub uint8) [8]byte {
+	return [8]byte{command, byte(index), byte(index >> 8), sub}
+}
+
+// sameObject tells whether the initiate response is about the object requested
+func sameObject(response [8]byte, index uint16, sub uint8) bool {
+	return binary.LittleEndian.Uint16(response[1:]) == index && response[3] == sub
+}
+
+// exchange sends a request and waits for the response, the aborts of the server are returned as *AbortError
+func (c *Client) exchange(ctx context.Context, index uint16, sub uint8, request [8]byte) ([8]byte, error) {
+	// Responses arrived late belong to previous transfers
+drain:
+	for {
+		select {
+		case _, ok := <-c.responses:
+			if !ok {
+				break drain
+			}
+		default:
+			break drain
+		}
+	}
+
+	frame := bus.Frame{ID: COBSDORx + uint32(c.node), Length: 8}
+	copy(frame.Data[:], request[:])
+	if err := c.bus.Send(ctx, frame); err != nil {
+		return [8]byte{}, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSDOTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case response, ok := <-c.responses:
+		if !ok {
+			return [8]byte{}, ErrSDOClosed
+		}
+		if response[0]&csMask == csAbort {
+			return [8]byte{}, &AbortError{
+				Index:    binary.LittleEndian.Uint16(response[1:]),
+				SubIndex: response[3],
+				Code:     binary.LittleEndian.Uint32(response[4:]),
+			}
+		}
+		return response, nil
+	case <-timer.C:
+		c.abort(ctx, index, sub, AbortTimeout)
+		return [8]byte{}, fmt.Errorf("SDO %04X:%02X of node %d: %w", index, sub, c.node, ErrSDOTimeout)
+	case <-ctx.Done():
+		c.abort(context.Background(), index, sub, AbortGeneralError)
+		return [8]byte{}, ctx.Err()
+	}
+}
+
+// abort tells the server that the transfer is over
+func (c *Client) abort(ctx context.Context, index uint16, sub uint8, code uint32) {
+	frame := bus.Frame{ID: COBSDORx + uint32(c.node), Length: 8}
+	request := initiate(csAbort, index, sub)
+	binary.LittleEndian.PutUint32(request[4:], code)
+	copy(frame.Data[:], request[:])
+	c.bus.Send(ctx, frame)
+}
+
+// protocolError aborts the transfer after an unexpected response
+func (c *Client) protocolError(ctx context.Context, index uint16, sub uint8, format string, args ...any) error {
+	c.abort(ctx, index, sub, AbortCommand)
+	return fmt.Errorf("SDO %04X:%02X: %w: %s", index, sub, ErrSDOProtocol, fmt.Sprintf(format, args...))
+}
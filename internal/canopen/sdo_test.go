@@ -0,0 +1,322 @@
+package canopen
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// testNode is the node of the SDO server of the tests
+const testNode = 0x22
+
+// abortNoObject is the abort code of the objects missing from the dictionary
+const abortNoObject = 0x06020000
+
+// sdoServer is a fake SDO server of testNode answering from its objects
+type sdoServer struct {
+	mu      sync.Mutex // protects objects, written by the transfers
+	objects map[uint32][]byte
+
+	// Misbehaviours
+	silent      bool // never answers
+	noSize      bool // does not indicate the size of the uploads
+	announce    int  // size announced for the segmented uploads (the real one if 0)
+	badToggle   int  // segment (from 1) answered with the wrong toggle bit (0 never)
+	wrongObject bool // answers the initiate requests about the next sub-index
+
+	aborts chan uint32 // codes of the aborts received
+
+	// transfer in progress
+	key      uint32
+	upload   []byte
+	download []byte
+	segments int
+}
+
+// newSDOServer returns a server with the objects, by objectKey
+func newSDOServer(objects map[uint32][]byte) *sdoServer {
+	if objects == nil {
+		objects = make(map[uint32][]byte)
+	}
+	return &sdoServer{objects: objects, aborts: make(chan uint32, 16)}
+}
+
+// value returns the value of an object
+func (s *sdoServer) value(index uint16, sub uint8) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.objects[objectKey(index, sub)]
+	return value, ok
+}
+
+// run answers the requests received until the receiver is closed, it is intended as a goroutine
+func (s *sdoServer) run(b bus.Bus, recv bus.Receiver) {
+	for recv.Receive() {
+		frame := recv.Frame()
+		if frame.ID != COBSDORx+testNode || frame.Length != 8 {
+			continue
+		}
+		var request [8]byte
+		copy(request[:], frame.Payload())
+
+		response, ok := s.handle(request)
+		if !ok || s.silent {
+			continue
+		}
+		answer := bus.Frame{ID: COBSDOTx + testNode, Length: 8}
+		copy(answer.Data[:], response[:])
+		b.Send(context.Background(), answer)
+	}
+}
+
+// handle processes a request, it returns the response to send (false for none)
+func (s *sdoServer) handle(request [8]byte) ([8]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, sub := binary.LittleEndian.Uint16(request[1:]), request[3]
+	toggle := request[0] & sdoToggle
+	if s.segments+1 == s.badToggle {
+		toggle ^= sdoToggle
+	}
+
+	switch request[0] & csMask {
+	case csAbort:
+		s.aborts <- binary.LittleEndian.Uint32(request[4:])
+		return [8]byte{}, false
+
+	case ccsInitiateUpload:
+		value, ok := s.objects[objectKey(index, sub)]
+		if !ok {
+			response := initiate(csAbort, index, sub)
+			binary.LittleEndian.PutUint32(response[4:], abortNoObject)
+			return response, true
+		}
+		response := s.initiateResponse(scsInitiateUpload, index, sub)
+		if len(value) <= expeditedSize {
+			response[0] |= sdoExpedited
+			if !s.noSize {
+				response[0] |= sdoSizeIndicated | byte(expeditedSize-len(value))<<2
+			}
+			copy(response[4:], value)
+			return response, true
+		}
+		if !s.noSize {
+			size := len(value)
+			if s.announce != 0 {
+				size = s.announce
+			}
+			response[0] |= sdoSizeIndicated
+			binary.LittleEndian.PutUint32(response[4:], uint32(size))
+		}
+		s.upload, s.segments = value, 0
+		return response, true
+
+	case ccsUploadSegment:
+		s.segments++
+		n := min(segmentSize, len(s.upload))
+		response := [8]byte{scsUploadSegment | toggle | byte(segmentSize-n)<<1}
+		copy(response[1:], s.upload[:n])
+		if s.upload = s.upload[n:]; len(s.upload) == 0 {
+			response[0] |= sdoLastSegment
+		}
+		return response, true
+
+	case ccsInitiateDownload:
+		if request[0]&sdoExpedited != 0 {
+			n := expeditedSize - int(request[0]>>2&0x03)
+			s.objects[objectKey(index, sub)] = bytes.Clone(request[4 : 4+n])
+		} else {
+			s.key, s.download, s.segments = objectKey(index, sub), []byte{}, 0
+		}
+		return s.initiateResponse(scsInitiateDownload, index, sub), true
+
+	case ccsDownloadSegment:
+		s.segments++
+		n := segmentSize - int(request[0]>>1&0x07)
+		s.download = append(s.download, request[1:1+n]...)
+		if request[0]&sdoLastSegment != 0 {
+			s.objects[s.key] = s.download
+		}
+		return [8]byte{scsDownloadSegment | toggle}, true
+	}
+	return [8]byte{}, false
+}
+
+// initiateResponse returns the response to an initiate request
+func (s *sdoServer) initiateResponse(command byte, index uint16, sub uint8) [8]byte {
+	if s.wrongObject {
+		sub++
+	}
+	return initiate(command, index, sub)
+}
+
+// nextAbort waits for the next abort received by the server
+func (s *sdoServer) nextAbort(t *testing.T) uint32 {
+	t.Helper()
+
+	select {
+	case code := <-s.aborts:
+		return code
+	case <-time.After(time.Second):
+		t.Fatal("no abort received by the server")
+		return 0
+	}
+}
+
+// startSDO starts the server on a virtual bus, it returns a client of it
+func startSDO(t *testing.T, server *sdoServer) *Client {
+	t.Helper()
+
+	b := bus.NewMem(t.Name())
+	recv := b.Subscribe()
+	go server.run(b, recv)
+	client, err := NewClient(b, testNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Timeout = 100 * time.Millisecond
+	t.Cleanup(func() {
+		client.Close()
+		recv.Close()
+		b.Close()
+	})
+	return client
+}
+
+// testValue returns a value of n bytes
+func testValue(n int) []byte {
+	value := make([]byte, n)
+	for i := range value {
+		value[i] = byte(0xA0 + i)
+	}
+	return value
+}
+
+// TestSDOUpload reads values expedited and segmented, with and without their size
+func TestSDOUpload(t *testing.T) {
+	for _, noSize := range []bool{false, true} {
+		for _, n := range []int{1, 3, 4, 5, 7, 8, 14, 100} {
+			t.Run(fmt.Sprintf("%d bytes size indicated %v", n, !noSize), func(t *testing.T) {
+				server := newSDOServer(map[uint32][]byte{objectKey(0x2000, 1): testValue(n)})
+				server.noSize = noSize
+				client := startSDO(t, server)
+
+				want := testValue(n)
+				if noSize && n < expeditedSize {
+					// Without the size the expedited values take the whole message
+					want = append(want, make([]byte, expeditedSize-n)...)
+				}
+				value, err := client.Upload(context.Background(), 0x2000, 1)
+				if err != nil || !bytes.Equal(value, want) {
+					t.Errorf("Upload() = % X, %v, want % X", value, err, want)
+				}
+			})
+		}
+	}
+}
+
+// TestSDODownload writes values expedited and segmented
+func TestSDODownload(t *testing.T) {
+	for _, n := range []int{1, 4, 5, 7, 8, 14, 15, 100} {
+		t.Run(fmt.Sprintf("%d bytes", n), func(t *testing.T) {
+			server := newSDOServer(nil)
+			client := startSDO(t, server)
+
+			if err := client.Download(context.Background(), 0x2001, 2, testValue(n)); err != nil {
+				t.Fatalf("Download() = %v", err)
+			}
+			if value, _ := server.value(0x2001, 2); !bytes.Equal(value, testValue(n)) {
+				t.Errorf("Download() wrote % X", value)
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		client := startSDO(t, newSDOServer(nil))
+		if err := client.Download(context.Background(), 0x2001, 2, nil); err == nil {
+			t.Error("empty value downloaded")
+		}
+	})
+}
+
+// TestSDOErrors checks the transfers aborted by the server and the ones the client aborts
+func TestSDOErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(s *sdoServer) // misbehaviour of the server
+		download bool               // Download instead of Upload of 0x2000:01
+		sub      uint8              // sub-index transferred (1 if 0)
+		want     error              // nil for an *AbortError of the server
+		abort    uint32             // code of the abort of the client, 0 for none
+	}{
+		{name: "object missing", setup: func(*sdoServer) {}, sub: 9},
+		{name: "upload toggle bit", setup: func(s *sdoServer) { s.badToggle = 2 }, want: ErrSDOProtocol, abort: AbortToggleBit},
+		{name: "download toggle bit", setup: func(s *sdoServer) { s.badToggle = 1 }, download: true, want: ErrSDOProtocol, abort: AbortToggleBit},
+		{name: "upload timeout", setup: func(s *sdoServer) { s.silent = true }, want: ErrSDOTimeout, abort: AbortTimeout},
+		{name: "download timeout", setup: func(s *sdoServer) { s.silent = true }, download: true, want: ErrSDOTimeout, abort: AbortTimeout},
+		{name: "wrong object", setup: func(s *sdoServer) { s.wrongObject = true }, want: ErrSDOProtocol, abort: AbortCommand},
+		{name: "size mismatch", setup: func(s *sdoServer) { s.announce = 21 }, want: ErrSDOProtocol},
+		{name: "too long", setup: func(s *sdoServer) { s.announce = maxSegmented + 1 }, want: errors.New("too many"), abort: AbortOutOfMemory},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSDOServer(map[uint32][]byte{objectKey(0x2000, 1): testValue(20)})
+			tt.setup(server)
+			sub := max(tt.sub, 1)
+			client := startSDO(t, server)
+
+			var err error
+			if tt.download {
+				err = client.Download(context.Background(), 0x2000, sub, testValue(20))
+			} else {
+				_, err = client.Upload(context.Background(), 0x2000, sub)
+			}
+
+			var abortErr *AbortError
+			switch {
+			case tt.want == nil:
+				if !errors.As(err, &abortErr) || abortErr.Code != abortNoObject || abortErr.Index != 0x2000 || abortErr.SubIndex != sub {
+					t.Fatalf("got %v, want the abort of the server", err)
+				}
+				if want := "SDO 2000:09 aborted: object does not exist in the object dictionary (0x06020000)"; err.Error() != want {
+					t.Fatalf("Error() = %q, want %q", err.Error(), want)
+				}
+			case errors.Is(err, tt.want):
+			case err == nil || !bytes.Contains([]byte(err.Error()), []byte(tt.want.Error())):
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+
+			if tt.abort != 0 {
+				if code := server.nextAbort(t); code != tt.abort {
+					t.Fatalf("abort 0x%08X sent, want 0x%08X", code, tt.abort)
+				}
+			}
+		})
+	}
+}
+
+// TestNewClient checks the node IDs of the SDO servers
+func TestNewClient(t *testing.T) {
+	b := bus.NewMem(t.Name())
+	defer b.Close()
+
+	for _, node := range []uint8{0, MaxNodeID + 1, 0xFF} {
+		if _, err := NewClient(b, node); err == nil {
+			t.Errorf("client of the node %d created", node)
+		}
+	}
+	client, err := NewClient(b, MaxNodeID)
+	if err != nil || client.Node() != MaxNodeID {
+		t.Fatalf("NewClient(%d) = %v", MaxNodeID, err)
+	}
+	client.Close()
+}
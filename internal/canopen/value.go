@@ -0,0 +1,152 @@
+package canopen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DataType is the type of an object of the dictionary, as declared by the EDS files.
+type DataType uint16
+
+// Data types of CiA 301
+const (
+	Boolean       DataType = 0x0001
+	Integer8      DataType = 0x0002
+	Integer16     DataType = 0x0003
+	Integer32     DataType = 0x0004
+	Unsigned8     DataType = 0x0005
+	Unsigned16    DataType = 0x0006
+	Unsigned32    DataType = 0x0007
+	Real32        DataType = 0x0008
+	VisibleString DataType = 0x0009
+	OctetString   DataType = 0x000A
+	Domain        DataType = 0x000F
+	Real64        DataType = 0x0011
+	Integer64     DataType = 0x0015
+	Unsigned64    DataType = 0x001B
+)
+
+var dataTypeNames = map[DataType]string{
+	Boolean:       "BOOLEAN",
+	Integer8:      "INTEGER8",
+	Integer16:     "INTEGER16",
+	Integer32:     "INTEGER32",
+	Unsigned8:     "UNSIGNED8",
+	Unsigned16:    "UNSIGNED16",
+	Unsigned32:    "UNSIGNED32",
+	Real32:        "REAL32",
+	VisibleString: "VISIBLE_STRING",
+	OctetString:   "OCTET_STRING",
+	Domain:        "DOMAIN",
+	Real64:        "REAL64",
+	Integer64:     "INTEGER64",
+	Unsigned64:    "UNSIGNED64",
+}
+
+func (t DataType) String() string {
+	if name, ok := dataTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", uint16(t))
+}
+
+// Size returns the number of bytes of the numeric types, 0 for the others.
+func (t DataType) Size() int {
+	switch t {
+	case Boolean, Integer8, Unsigned8:
+		return 1
+	case Integer16, Unsigned16:
+		return 2
+	case Integer32, Unsigned32, Real32:
+		return 4
+	case Integer64, Unsigned64, Real64:
+		return 8
+	}
+	return 0
+}
+
+// FormatValue formats the data of an object of the type (little endian), false if the type
+// is not known or the data does not match it.
+func FormatValue(t DataType, data []byte) (string, bool) {
+	if t == VisibleString {
+		if !utf8.Valid(data) {
+			return "", false
+		}
+		return strconv.Quote(strings.TrimRight(string(data), "\x00")), true
+	}
+	if t.Size() == 0 || len(data) != t.Size() {
+		return "", false
+	}
+
+	var u uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		u = u<<8 | uint64(data[i])
+	}
+	switch t {
+	case Boolean:
+		return strconv.FormatBool(u != 0), true
+	case Integer8:
+		return strconv.Itoa(int(int8(u))), true
+	case Integer16:
+		return strconv.Itoa(int(int16(u))), true
+	case Integer32:
+		return strconv.Itoa(int(int32(u))), true
+	case Integer64:
+		return strconv.FormatInt(int64(u), 10), true
+	case Real32:
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(u))), 'g', -1, 32), true
+	case Real64:
+		return strconv.FormatFloat(math.Float64frombits(u), 'g', -1, 64), true
+	}
+	return fmt.Sprintf("%d (0x%0*X)", u, 2*len(data), u), true
+}
+
+// ParseValue encodes a value typed as text for an object of the type (little endian):
+// numbers (0x for hex), true/false, or the text of the strings.
+func ParseValue(t DataType, text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	size := t.Size()
+
+	switch t {
+	case VisibleString:
+		return []byte(text), nil
+	case Boolean:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BOOLEAN %q", text)
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case Real32:
+		f, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REAL32 %q", text)
+		}
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+	case Real64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REAL64 %q", text)
+		}
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)), nil
+	case Integer8, Integer16, Integer32, Integer64:
+		i, err := strconv.ParseInt(text, 0, 8*size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", t, text)
+		}
+		return binary.LittleEndian.AppendUint64(nil, uint64(i))[:size], nil
+	case Unsigned8, Unsigned16, Unsigned32, Unsigned64:
+		u, err := strconv.ParseUint(text, 0, 8*size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", t, text)
+		}
+		return binary.LittleEndian.AppendUint64(nil, u)[:size], nil
+	}
+	return nil, fmt.Errorf("values of type %s are typed as hex bytes", t)
+}
@@ -0,0 +1,215 @@
+// Package export converts CAN logs into decoded signal tables.
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/squadracorsepolito/acmelib"
+
+	"github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+)
+
+// Format is the layout of the CSV output.
+type Format int
+
+const (
+	// FormatFrames writes one row per decoded frame, only the columns
+	// of the signals of that frame are filled
+	FormatFrames Format = iota
+	// FormatWide writes one row per sample period, every column holds
+	// the last value of its signal (resampled with zero-order hold)
+	FormatWide
+)
+
+// ParseFormat parses "frames" or "wide".
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "frames":
+		return FormatFrames, nil
+	case "wide":
+		return FormatWide, nil
+	default:
+		return 0, fmt.Errorf("unknown format %q (use frames or wide)", s)
+	}
+}
+
+// Options configures the CSV export.
+type Options struct {
+	Format Format
+	Period time.Duration // sample period of the wide format
+}
+
+// decodedFrame is a log entry decoded with the DBC
+type decodedFrame struct {
+	entry   candump.Entry
+	message *acmelib.Message
+	values  map[int]string // column index -> value
+}
+
+// columnSet assigns a column index to each "MESSAGE.signal" in order of first appearance
+type columnSet struct {
+	index map[string]int
+	names []string
+}
+
+func (c *columnSet) get(name string) int {
+	idx, ok := c.index[name]
+	if !ok {
+		idx = len(c.names)
+		c.index[name] = idx
+		c.names = append(c.names, name)
+	}
+	return idx
+}
+
+// FormatValue formats a decoded value for the CSV output (flags as 0/1, enums as their label).
+func FormatValue(sgn *acmelib.SignalDecoding) string {
+	switch v := sgn.Value.(type) {
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// formatTimestamp formats the time as unix seconds with microsecond precision
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1000)
+}
+
+// formatSeconds formats a duration as seconds with microsecond precision
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 6, 64)
+}
+
+// decodeAll decodes every frame of the log known by the decoder
+func decodeAll(entries []candump.Entry, dec *can.Decoder) ([]decodedFrame, *columnSet) {
+	columns := &columnSet{index: make(map[string]int)}
+	frames := make([]decodedFrame, 0, len(entries))
+
+	for _, entry := range entries {
+		msg, ok := dec.Lookup(entry.Frame.ID, entry.Frame.IsExtended)
+		if !ok {
+			continue
+		}
+
+		df := decodedFrame{
+			entry:   entry,
+			message: msg,
+			values:  make(map[int]string),
+		}
+		for _, sgn := range dec.Decode(context.Background(), entry.Frame.ID, entry.Frame.IsExtended, entry.Frame.Payload()) {
+			col := columns.get(msg.Name() + "." + sgn.Signal.Name())
+			df.values[col] = FormatValue(sgn)
+		}
+		frames = append(frames, df)
+	}
+
+	return frames, columns
+}
+
+// WriteCSV decodes the log entries with the decoder and writes them as CSV.
+// Frames with an ID not defined in the DBC are skipped.
+func WriteCSV(w io.Writer, entries []candump.Entry, dec *can.Decoder, opts Options) error {
+	frames, columns := decodeAll(entries, dec)
+
+	cw := csv.NewWriter(w)
+	var err error
+	switch opts.Format {
+	case FormatWide:
+		err = writeWide(cw, frames, columns, opts.Period)
+	default:
+		err = writeFrames(cw, frames, columns)
+	}
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeFrames writes one row per frame
+func writeFrames(cw *csv.Writer, frames []decodedFrame, columns *columnSet) error {
+	header := append([]string{"timestamp", "t", "interface", "id", "message"}, columns.names...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return nil
+	}
+
+	start := frames[0].entry.Frame.Timestamp
+	for _, df := range frames {
+		frame := df.entry.Frame
+		row := make([]string, len(header))
+		row[0] = formatTimestamp(frame.Timestamp)
+		row[1] = formatSeconds(frame.Timestamp.Sub(start))
+		row[2] = df.entry.Interface
+		row[3] = can.FormatID(frame.ID, frame.IsExtended)
+		row[4] = df.message.Name()
+		for col, value := range df.values {
+			row[5+col] = value
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeWide writes one row per sample period from the first frame, holding the last value of each signal
+func writeWide(cw *csv.Writer, frames []decodedFrame, columns *columnSet, period time.Duration) error {
+	if period <= 0 {
+		return fmt.Errorf("the sample period must be positive")
+	}
+
+	header := append([]string{"timestamp", "t"}, columns.names...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return nil
+	}
+
+	start := frames[0].entry.Frame.Timestamp
+	end := frames[len(frames)-1].entry.Frame.Timestamp
+	current := make([]string, len(columns.names))
+
+	// The last sample is the first one at or after the last frame, so that every frame is in the output
+	next := 0
+	for t := start; ; t = t.Add(period) {
+		// Apply all the frames received up to the sample time
+		for next < len(frames) && !frames[next].entry.Frame.Timestamp.After(t) {
+			for col, value := range frames[next].values {
+				current[col] = value
+			}
+			next++
+		}
+
+		row := make([]string, 0, len(header))
+		row = append(row, formatTimestamp(t), formatSeconds(t.Sub(start)))
+		row = append(row, current...)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		if !t.Before(end) {
+			break
+		}
+	}
+
+	return nil
+}
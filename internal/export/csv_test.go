@@ -0,0 +1,178 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/acmelib"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+)
+
+// testStart is the timestamp of the first frame of the tests
+var testStart = time.Unix(1700000000, 0)
+
+// decoded returns a decoded frame of the message at the offset from testStart
+func decoded(msg *acmelib.Message, id uint32, offset time.Duration, values map[int]string) decodedFrame {
+	return decodedFrame{
+		entry: candump.Entry{
+			Interface: "vcan0",
+			Frame:     bus.Frame{Timestamp: testStart.Add(offset), ID: id},
+		},
+		message: msg,
+		values:  values,
+	}
+}
+
+// readCSV returns the records written by write
+func readCSV(t *testing.T, write func(cw *csv.Writer) error) [][]string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if err := write(cw); err != nil {
+		t.Fatal(err)
+	}
+	cw.Flush()
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v\n%s", err, buf.String())
+	}
+	return records
+}
+
+// testColumns returns the columns A.x, B.y and A.z
+func testColumns() *columnSet {
+	columns := &columnSet{index: make(map[string]int)}
+	for _, name := range []string{"A.x", "B.y", "A.z"} {
+		columns.get(name)
+	}
+	return columns
+}
+
+// TestWriteFrames checks that the values of each frame are in the columns of their signals
+func TestWriteFrames(t *testing.T) {
+	a := acmelib.NewMessage("A", 0x100, 8)
+	b := acmelib.NewMessage("B", 0x200, 8)
+	frames := []decodedFrame{
+		decoded(a, 0x100, 0, map[int]string{0: "1", 2: "on"}),
+		decoded(b, 0x200, 1500*time.Microsecond, map[int]string{1: "-2.5"}),
+	}
+
+	records := readCSV(t, func(cw *csv.Writer) error {
+		return writeFrames(cw, frames, testColumns())
+	})
+	want := [][]string{
+		{"timestamp", "t", "interface", "id", "message", "A.x", "B.y", "A.z"},
+		{"1700000000.000000", "0.000000", "vcan0", "0x100", "A", "1", "", "on"},
+		{"1700000000.001500", "0.001500", "vcan0", "0x200", "B", "", "-2.5", ""},
+	}
+	checkRecords(t, records, want)
+}
+
+// TestWriteWide checks the zero-order hold of the values and the last sample
+func TestWriteWide(t *testing.T) {
+	tests := []struct {
+		name    string
+		offsets []time.Duration // of the frames setting A.x, B.y and A.x again
+		want    [][]string      // rows after the header, without the timestamp
+	}{
+		{
+			name:    "last frame on a sample",
+			offsets: []time.Duration{0, 15 * time.Millisecond, 30 * time.Millisecond},
+			want: [][]string{
+				{"0.000000", "1", "", ""},
+				{"0.010000", "1", "", ""},
+				{"0.020000", "1", "2", ""},
+				{"0.030000", "3", "2", ""},
+			},
+		},
+		{
+			name:    "last frame between samples",
+			offsets: []time.Duration{0, 10 * time.Millisecond, 25 * time.Millisecond},
+			want: [][]string{
+				{"0.000000", "1", "", ""},
+				{"0.010000", "1", "2", ""},
+				{"0.020000", "1", "2", ""},
+				{"0.030000", "3", "2", ""},
+			},
+		},
+		{
+			name:    "frames in the same period",
+			offsets: []time.Duration{0, 0, time.Millisecond},
+			want: [][]string{
+				{"0.000000", "1", "2", ""},
+				{"0.010000", "3", "2", ""},
+			},
+		},
+	}
+
+	msg := acmelib.NewMessage("A", 0x100, 8)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := []decodedFrame{
+				decoded(msg, 0x100, tt.offsets[0], map[int]string{0: "1"}),
+				decoded(msg, 0x200, tt.offsets[1], map[int]string{1: "2"}),
+				decoded(msg, 0x100, tt.offsets[2], map[int]string{0: "3"}),
+			}
+			records := readCSV(t, func(cw *csv.Writer) error {
+				return writeWide(cw, frames, testColumns(), 10*time.Millisecond)
+			})
+
+			want := [][]string{{"timestamp", "t", "A.x", "B.y", "A.z"}}
+			for _, row := range tt.want {
+				seconds, _ := time.ParseDuration(row[0] + "s")
+				want = append(want, append([]string{formatTimestamp(testStart.Add(seconds))}, row...))
+			}
+			checkRecords(t, records, want)
+		})
+	}
+}
+
+// TestWriteWideErrors checks the empty logs and the invalid periods
+func TestWriteWideErrors(t *testing.T) {
+	records := readCSV(t, func(cw *csv.Writer) error {
+		return writeWide(cw, nil, testColumns(), time.Millisecond)
+	})
+	checkRecords(t, records, [][]string{{"timestamp", "t", "A.x", "B.y", "A.z"}})
+
+	err := writeWide(csv.NewWriter(&bytes.Buffer{}), nil, testColumns(), 0)
+	if err == nil {
+		t.Fatal("no error with a zero period")
+	}
+}
+
+// TestParseFormat checks the names of the formats
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"frames": FormatFrames, "wide": FormatWide} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %v, %v", name, got, err)
+		}
+	}
+	for _, name := range []string{"", "long", "Wide"} {
+		if _, err := ParseFormat(name); err == nil {
+			t.Errorf("ParseFormat(%q) succeeded", name)
+		}
+	}
+}
+
+// checkRecords fails the test if the records differ
+func checkRecords(t *testing.T, got, want [][]string) {
+	t.Helper()
+
+	format := func(records [][]string) string {
+		lines := make([]string, len(records))
+		for i, record := range records {
+			lines[i] = strings.Join(record, ",")
+		}
+		return strings.Join(lines, "\n")
+	}
+	if format(got) != format(want) {
+		t.Fatalf("got\n%s\nwant\n%s", format(got), format(want))
+	}
+}
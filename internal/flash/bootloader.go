@@ -0,0 +1,350 @@
+package flash
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+	"github.com/squadracorsepolito/can-debug/internal/uds"
+)
+
+const (
+	// DefaultMemoryAddress and DefaultMemorySize are the flash memory of the simulated bootloader
+	DefaultMemoryAddress = 0x08000000
+	DefaultMemorySize    = 1 << 20
+	// DefaultBlockLength is the longest TransferData request accepted by the simulated bootloader
+	DefaultBlockLength = 1026
+	// eraseTime is how long the simulated bootloader takes to erase, answering "response pending" meanwhile
+	eraseTime = 200 * time.Millisecond
+	// erasedByte is the value of the erased flash memory
+	erasedByte = 0xFF
+)
+
+// BootloaderConfig configures a simulated bootloader.
+type BootloaderConfig struct {
+	// Conn has the IDs of the ECU: it receives the requests of the tester and sends the responses
+	Conn isotp.Config
+	// MemoryAddress and MemorySize are its flash memory (DefaultMemoryAddress and DefaultMemorySize if 0)
+	MemoryAddress uint32
+	MemorySize    int
+	// SecurityLevel is the level to unlock before erasing or downloading (0 for none),
+	// the keys are checked with Key (the "not" key algorithm if nil)
+	SecurityLevel byte
+	Key           uds.KeyFunc
+	// DropEvery drops the response to one TransferData every DropEvery (0 never), to test the retries
+	DropEvery int
+}
+
+// Bootloader is a simulated ECU bootloader answering the download sequence of [Flash] on a bus.
+type Bootloader struct {
+	cfg  BootloaderConfig
+	conn *isotp.Conn
+
+	mu       sync.Mutex // protects the state below, read by Memory
+	memory   []byte
+	session  byte
+	unlocked bool
+	seed     []byte
+
+	// download in progress
+	downloading bool
+	next        uint32 // address of the next byte downloaded
+	end         uint32
+	counter     byte // counter of the last block received
+	transfers   int
+	resets      int
+}
+
+// NewBootloader starts a simulated bootloader on the bus, it runs until Close.
+func NewBootloader(b bus.Bus, cfg BootloaderConfig) *Bootloader {
+	if cfg.MemoryAddress == 0 {
+		cfg.MemoryAddress = DefaultMemoryAddress
+	}
+	if cfg.MemorySize <= 0 {
+		cfg.MemorySize = DefaultMemorySize
+	}
+	if cfg.SecurityLevel != 0 && cfg.Key == nil {
+		cfg.Key, _ = uds.KeyAlgorithm("not")
+	}
+
+	bl := &Bootloader{
+		cfg:     cfg,
+		conn:    isotp.Dial(b, cfg.Conn),
+		memory:  bytes.Repeat([]byte{erasedByte}, cfg.MemorySize),
+		session: uds.SessionDefault,
+	}
+	go bl.run()
+	return bl
+}
+
+// Close stops the bootloader.
+func (bl *Bootloader) Close() error {
+	return bl.conn.Close()
+}
+
+// Memory returns a copy of size bytes of the flash memory at address.
+func (bl *Bootloader) Memory(address uint32, size int) []byte {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	start, ok := bl.offset(address, uint32(size))
+	if !ok {
+		return nil
+	}
+	return bytes.Clone(bl.memory[start : start+size])
+}
+
+// Resets returns how many times the ECU was reset.
+func (bl *Bootloader) Resets() int {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return bl.resets
+}
+
+// run answers the requests until the bootloader is closed
+func (bl *Bootloader) run() {
+	ctx := context.Background()
+	for {
+		request, err := bl.conn.Receive(ctx)
+		if err == isotp.ErrClosed {
+			return
+		}
+		if err != nil || len(request) == 0 {
+			continue
+		}
+
+		if response := bl.handle(request); response != nil {
+			bl.conn.Send(ctx, response)
+		}
+	}
+}
+
+// handle processes a request, it returns the response to send (nil for none)
+func (bl *Bootloader) handle(request []byte) []byte {
+	sid := request[0]
+	negative := func(code uds.NRC) []byte {
+		return []byte{0x7F, sid, byte(code)}
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	switch sid {
+	case uds.ServiceDiagnosticSessionControl:
+		if len(request) != 2 {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		if request[1] < uds.SessionDefault || request[1] > uds.SessionExtended {
+			return negative(uds.NRCSubFunctionNotSupported)
+		}
+		bl.session = request[1]
+		bl.unlocked = false
+		bl.downloading = false
+		// P2 50ms, P2* 5s
+		return []byte{sid + 0x40, request[1], 0x00, 0x32, 0x01, 0xF4}
+
+	case uds.ServiceTesterPresent:
+		if len(request) != 2 {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		if request[1]&0x80 != 0 {
+			return nil
+		}
+		return []byte{sid + 0x40, 0x00}
+
+	case uds.ServiceECUReset:
+		if len(request) != 2 {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		bl.session = uds.SessionDefault
+		bl.unlocked = false
+		bl.downloading = false
+		bl.resets++
+		return []byte{sid + 0x40, request[1]}
+
+	case uds.ServiceSecurityAccess:
+		return bl.securityAccess(request, negative)
+
+	case uds.ServiceRoutineControl:
+		return bl.routineControl(request, negative)
+
+	case uds.ServiceRequestDownload:
+		if bl.session != uds.SessionProgramming {
+			return negative(uds.NRCServiceNotSupportedInActiveSession)
+		}
+		if !bl.isUnlocked() {
+			return negative(uds.NRCSecurityAccessDenied)
+		}
+		if len(request) != 11 || request[2] != 0x44 {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		address := binary.BigEndian.Uint32(request[3:7])
+		size := binary.BigEndian.Uint32(request[7:11])
+		if _, ok := bl.offset(address, size); !ok || size == 0 {
+			return negative(uds.NRCRequestOutOfRange)
+		}
+		bl.downloading = true
+		bl.next, bl.end = address, address+size
+		bl.counter = 0
+		return []byte{sid + 0x40, 0x20, byte(DefaultBlockLength >> 8), byte(DefaultBlockLength & 0xFF)}
+
+	case uds.ServiceTransferData:
+		if !bl.downloading {
+			return negative(uds.NRCRequestSequenceError)
+		}
+		if len(request) < 3 || len(request) > DefaultBlockLength {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		response := []byte{sid + 0x40, request[1]}
+		switch request[1] {
+		case bl.counter:
+			// Block sent again, already written
+		case bl.counter + 1:
+			data := request[2:]
+			if bl.next+uint32(len(data)) > bl.end {
+				return negative(uds.NRCTransferDataSuspended)
+			}
+			start, _ := bl.offset(bl.next, uint32(len(data)))
+			for i, b := range data {
+				if bl.memory[start+i] != erasedByte {
+					return negative(uds.NRCGeneralProgrammingFailure)
+				}
+				bl.memory[start+i] = b
+			}
+			bl.next += uint32(len(data))
+			bl.counter = request[1]
+		default:
+			return negative(uds.NRCWrongBlockSequenceCounter)
+		}
+
+		bl.transfers++
+		if bl.cfg.DropEvery > 0 && bl.transfers%bl.cfg.DropEvery == 0 {
+			return nil // lost response, the tester sends the block again
+		}
+		return response
+
+	case uds.ServiceRequestTransferExit:
+		if !bl.downloading || bl.next != bl.end {
+			return negative(uds.NRCRequestSequenceError)
+		}
+		bl.downloading = false
+		return []byte{sid + 0x40}
+
+	default:
+		return negative(uds.NRCServiceNotSupported)
+	}
+}
+
+// securityAccess answers the seed requests and checks the keys
+func (bl *Bootloader) securityAccess(request []byte, negative func(uds.NRC) []byte) []byte {
+	if len(request) < 2 {
+		return negative(uds.NRCIncorrectMessageLength)
+	}
+	level := request[1]
+	if bl.cfg.SecurityLevel == 0 || level != bl.cfg.SecurityLevel && level != bl.cfg.SecurityLevel+1 {
+		return negative(uds.NRCSubFunctionNotSupported)
+	}
+
+	if level == bl.cfg.SecurityLevel {
+		if bl.unlocked {
+			return []byte{request[0] + 0x40, level, 0, 0, 0, 0}
+		}
+		bl.seed = binary.BigEndian.AppendUint32(nil, rand.Uint32()|1)
+		return append([]byte{request[0] + 0x40, level}, bl.seed...)
+	}
+
+	if bl.seed == nil {
+		return negative(uds.NRCRequestSequenceError)
+	}
+	want, err := bl.cfg.Key(bl.cfg.SecurityLevel, bl.seed)
+	bl.seed = nil
+	if err != nil || !bytes.Equal(want, request[2:]) {
+		return negative(uds.NRCInvalidKey)
+	}
+	bl.unlocked = true
+	return []byte{request[0] + 0x40, level}
+}
+
+// routineControl runs the erase and check routines
+func (bl *Bootloader) routineControl(request []byte, negative func(uds.NRC) []byte) []byte {
+	if len(request) < 4 {
+		return negative(uds.NRCIncorrectMessageLength)
+	}
+	if request[1] != uds.RoutineStart {
+		return negative(uds.NRCSubFunctionNotSupported)
+	}
+	if bl.session != uds.SessionProgramming {
+		return negative(uds.NRCServiceNotSupportedInActiveSession)
+	}
+	if !bl.isUnlocked() {
+		return negative(uds.NRCSecurityAccessDenied)
+	}
+
+	routine := binary.BigEndian.Uint16(request[2:4])
+	option := request[4:]
+	response := []byte{request[0] + 0x40, request[1], request[2], request[3]}
+
+	switch routine {
+	case RoutineEraseMemory:
+		if len(option) != 8 {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		address, size := binary.BigEndian.Uint32(option[:4]), binary.BigEndian.Uint32(option[4:])
+		start, ok := bl.offset(address, size)
+		if !ok {
+			return negative(uds.NRCRequestOutOfRange)
+		}
+
+		// Erasing takes a while: ask the tester to wait
+		bl.conn.Send(context.Background(), []byte{0x7F, request[0], byte(uds.NRCResponsePending)})
+		bl.mu.Unlock()
+		time.Sleep(eraseTime)
+		bl.mu.Lock()
+
+		for i := range int(size) {
+			bl.memory[start+i] = erasedByte
+		}
+		return append(response, 0x00)
+
+	case RoutineCheckMemory:
+		if len(option) != 12 {
+			return negative(uds.NRCIncorrectMessageLength)
+		}
+		address, size := binary.BigEndian.Uint32(option[:4]), binary.BigEndian.Uint32(option[4:8])
+		start, ok := bl.offset(address, size)
+		if !ok {
+			return negative(uds.NRCRequestOutOfRange)
+		}
+		status := byte(0x00)
+		if crc32.ChecksumIEEE(bl.memory[start:start+int(size)]) != binary.BigEndian.Uint32(option[8:]) {
+			status = 0x01
+		}
+		return append(response, status)
+
+	default:
+		return negative(uds.NRCRequestOutOfRange)
+	}
+}
+
+// isUnlocked tells whether the memory can be written
+func (bl *Bootloader) isUnlocked() bool {
+	return bl.cfg.SecurityLevel == 0 || bl.unlocked
+}
+
+// offset returns the index in the memory of size bytes at address, false if they are outside of it
+func (bl *Bootloader) offset(address, size uint32) (int, bool) {
+	if address < bl.cfg.MemoryAddress {
+		return 0, false
+	}
+	start := uint64(address - bl.cfg.MemoryAddress)
+	if start+uint64(size) > uint64(len(bl.memory)) {
+		return 0, false
+	}
+	return int(start), true
+}
@@ -0,0 +1,170 @@
+package flash
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+	"github.com/squadracorsepolito/can-debug/internal/uds"
+)
+
+// Routines of the bootloaders, started with RoutineControl
+const (
+	// RoutineEraseMemory erases the memory of a segment (option: address and size, 4 bytes each)
+	RoutineEraseMemory = 0xFF00
+	// RoutineCheckMemory checks the CRC-32 of a segment (option: address, size and CRC, 4 bytes each),
+	// the first byte of the status record is 0 if it matches
+	RoutineCheckMemory = 0x0202
+)
+
+// DefaultRetries is how many times a block is sent again when the bootloader does not acknowledge it
+const DefaultRetries = 3
+
+// Steps of the download, reported by Progress
+const (
+	StepSession  = "Programming session"
+	StepSecurity = "Security access"
+	StepErase    = "Erasing"
+	StepDownload = "Downloading"
+	StepVerify   = "Verifying"
+	StepReset    = "Resetting"
+	StepDone     = "Done"
+)
+
+// Options configures a download.
+type Options struct {
+	// SecurityLevel is the level unlocked before the download with Key (0 skips SecurityAccess)
+	SecurityLevel byte
+	Key           uds.KeyFunc
+	// Retries is how many times a block is sent again (DefaultRetries if 0, negative for none)
+	Retries int
+	// NoReset leaves the ECU in the bootloader at the end, instead of resetting it
+	NoReset bool
+}
+
+func (o Options) retries() int {
+	switch {
+	case o.Retries < 0:
+		return 0
+	case o.Retries == 0:
+		return DefaultRetries
+	}
+	return o.Retries
+}
+
+// Progress is the state of a download, reported after every step and block.
+type Progress struct {
+	Step     string
+	Segment  int // index of the segment being downloaded
+	Segments int
+	Sent     int // bytes of the image downloaded
+	Total    int
+	Retries  int // blocks sent again so far
+}
+
+// Flash downloads the image to the bootloader of the ECU:
+// programming session, security access, then for each segment erase, RequestDownload,
+// TransferData blocks, RequestTransferExit and CRC check, finally ECUReset.
+// The blocks not acknowledged (timeout, busy) are sent again with the same counter.
+// progress (if not nil) is called from the calling goroutine.
+func Flash(ctx context.Context, client *uds.Client, img *Image, opts Options, progress func(Progress)) error {
+	p := Progress{Segments: len(img.Segments), Total: img.Size()}
+	report := func(step string) {
+		p.Step = step
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	report(StepSession)
+	if _, err := client.DiagnosticSessionControl(ctx, uds.SessionProgramming); err != nil {
+		return fmt.Errorf("entering the programming session: %w", err)
+	}
+
+	if opts.SecurityLevel != 0 {
+		report(StepSecurity)
+		if opts.Key == nil {
+			return fmt.Errorf("no key algorithm for the security level 0x%02X", opts.SecurityLevel)
+		}
+		if err := client.SecurityAccess(ctx, opts.SecurityLevel, opts.Key); err != nil {
+			return fmt.Errorf("unlocking the security level 0x%02X: %w", opts.SecurityLevel, err)
+		}
+	}
+
+	for i, segment := range img.Segments {
+		p.Segment = i
+		size := uint32(len(segment.Data))
+
+		report(StepErase)
+		status, err := client.RoutineControl(ctx, uds.RoutineStart, RoutineEraseMemory, uds.AddressAndSize(segment.Address, size))
+		if err != nil {
+			return fmt.Errorf("erasing 0x%08X-0x%08X: %w", segment.Address, segment.End(), err)
+		}
+		if len(status) > 0 && status[0] != 0 {
+			return fmt.Errorf("erasing 0x%08X-0x%08X: routine failed with status 0x%02X", segment.Address, segment.End(), status[0])
+		}
+
+		report(StepDownload)
+		maxLength, err := client.RequestDownload(ctx, segment.Address, size)
+		if err != nil {
+			return fmt.Errorf("requesting the download of 0x%08X-0x%08X: %w", segment.Address, segment.End(), err)
+		}
+		blockSize := maxLength - 2 // service and counter
+
+		counter := byte(1)
+		for offset := 0; offset < len(segment.Data); offset += blockSize {
+			block := segment.Data[offset:min(offset+blockSize, len(segment.Data))]
+			if err := transferBlock(ctx, client, counter, block, opts.retries(), &p.Retries); err != nil {
+				return fmt.Errorf("downloading the block %d at 0x%08X: %w", counter, segment.Address+uint32(offset), err)
+			}
+			counter++ // wraps from 0xFF to 0x00
+
+			p.Sent += len(block)
+			report(StepDownload)
+		}
+
+		if err := client.RequestTransferExit(ctx); err != nil {
+			return fmt.Errorf("ending the download of 0x%08X-0x%08X: %w", segment.Address, segment.End(), err)
+		}
+
+		report(StepVerify)
+		option := binary.BigEndian.AppendUint32(uds.AddressAndSize(segment.Address, size), segment.CRC())
+		status, err = client.RoutineControl(ctx, uds.RoutineStart, RoutineCheckMemory, option)
+		if err != nil {
+			return fmt.Errorf("checking 0x%08X-0x%08X: %w", segment.Address, segment.End(), err)
+		}
+		if len(status) == 0 || status[0] != 0 {
+			return fmt.Errorf("checking 0x%08X-0x%08X: CRC 0x%08X does not match the memory", segment.Address, segment.End(), segment.CRC())
+		}
+	}
+
+	if !opts.NoReset {
+		report(StepReset)
+		if err := client.ECUReset(ctx, uds.ResetHard); err != nil {
+			return fmt.Errorf("resetting the ECU: %w", err)
+		}
+	}
+
+	report(StepDone)
+	return nil
+}
+
+// transferBlock sends a block, again with the same counter if it is not acknowledged
+func transferBlock(ctx context.Context, client *uds.Client, counter byte, block []byte, retries int, retried *int) error {
+	for attempt := 0; ; attempt++ {
+		err := client.TransferData(ctx, counter, block)
+		if err == nil || attempt >= retries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		*retried++
+	}
+}
+
+// retryable tells whether a block can be sent again after the error
+func retryable(err error) bool {
+	return errors.Is(err, uds.ErrNoResponse) || errors.Is(err, isotp.ErrTimeout) ||
+		uds.IsNRC(err, uds.NRCBusyRepeatRequest) ||
+		uds.IsNRC(err, uds.NRCTransferDataSuspended)
+}
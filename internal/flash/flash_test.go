@@ -0,0 +1,236 @@
+package flash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+	"github.com/squadracorsepolito/can-debug/internal/uds"
+)
+
+// Test IDs: the tester sends on testTx, the bootloader answers on testRx
+const (
+	testTx = 0x7E0
+	testRx = 0x7E8
+)
+
+// testTimeout is the P2 timeout of the test clients, waited for every response dropped
+const testTimeout = 100 * time.Millisecond
+
+// testImage returns an image with a segment of n bytes at every address
+func testImage(n int, addresses ...uint32) *Image {
+	img := &Image{}
+	for i, address := range addresses {
+		data := make([]byte, n)
+		for j := range data {
+			data[j] = byte(i + j*7)
+		}
+		img.Segments = append(img.Segments, Segment{Address: address, Data: data})
+	}
+	return img
+}
+
+// startBootloader starts a simulated bootloader on a virtual bus, it returns it with a client talking to it.
+// The transport is CAN FD to keep the longer downloads short.
+func startBootloader(t *testing.T, cfg BootloaderConfig) (*Bootloader, *uds.Client) {
+	t.Helper()
+
+	b := bus.NewMem(t.Name())
+	cfg.Conn = isotp.Config{TxID: testRx, RxID: testTx, FD: true}
+	bl := NewBootloader(b, cfg)
+	conn := isotp.Dial(b, isotp.Config{TxID: testTx, RxID: testRx, FD: true})
+	t.Cleanup(func() {
+		conn.Close()
+		bl.Close()
+		b.Close()
+	})
+
+	client := uds.NewClient(conn)
+	client.Timeout = testTimeout
+	return bl, client
+}
+
+// flash downloads the image, it returns the error and the last progress reported
+func flash(t *testing.T, client *uds.Client, img *Image, opts Options) (Progress, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var last Progress
+	err := Flash(ctx, client, img, opts, func(p Progress) { last = p })
+	return last, err
+}
+
+// checkMemory checks that the segments of the image are in the memory of the bootloader
+func checkMemory(t *testing.T, bl *Bootloader, img *Image) {
+	t.Helper()
+
+	for _, s := range img.Segments {
+		if got := bl.Memory(s.Address, len(s.Data)); !bytes.Equal(got, s.Data) {
+			t.Fatalf("memory at 0x%08X differs from the segment", s.Address)
+		}
+	}
+}
+
+// TestFlash downloads an image of two segments and checks the memory and the reset
+func TestFlash(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   BootloaderConfig
+		opts  Options
+		reset bool
+	}{
+		{name: "unlocked", reset: true},
+		{
+			name:  "security access",
+			cfg:   BootloaderConfig{SecurityLevel: 0x11},
+			opts:  Options{SecurityLevel: 0x11, Key: mustKeyAlgorithm(t, "not")},
+			reset: true,
+		},
+		{name: "no reset", opts: Options{NoReset: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl, client := startBootloader(t, tt.cfg)
+			img := testImage(3000, DefaultMemoryAddress, DefaultMemoryAddress+0x4000)
+
+			p, err := flash(t, client, img, tt.opts)
+			if err != nil {
+				t.Fatalf("Flash() = %v", err)
+			}
+			if p.Step != StepDone || p.Sent != img.Size() || p.Total != img.Size() || p.Retries != 0 {
+				t.Fatalf("last progress %+v", p)
+			}
+			checkMemory(t, bl, img)
+			if after := bl.Memory(img.Segments[0].End(), 1); after[0] != erasedByte {
+				t.Fatalf("memory after the segment written: 0x%02X", after[0])
+			}
+
+			resets := 0
+			if tt.reset {
+				resets = 1
+			}
+			if bl.Resets() != resets {
+				t.Fatalf("%d resets, want %d", bl.Resets(), resets)
+			}
+		})
+	}
+}
+
+// TestFlashSecurity checks that a download needs the right key of the level of the bootloader
+func TestFlashSecurity(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		nrc  uds.NRC // 0 if rejected before SecurityAccess
+		want string
+	}{
+		{
+			name: "wrong key",
+			opts: Options{SecurityLevel: 0x01, Key: mustKeyAlgorithm(t, "seed")},
+			nrc:  uds.NRCInvalidKey,
+			want: "unlocking the security level 0x01",
+		},
+		{
+			name: "wrong level",
+			opts: Options{SecurityLevel: 0x03, Key: mustKeyAlgorithm(t, "not")},
+			nrc:  uds.NRCSubFunctionNotSupported,
+			want: "unlocking the security level 0x03",
+		},
+		{
+			name: "locked",
+			nrc:  uds.NRCSecurityAccessDenied,
+			want: "erasing",
+		},
+		{
+			name: "no key",
+			opts: Options{SecurityLevel: 0x01},
+			want: "no key algorithm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl, client := startBootloader(t, BootloaderConfig{SecurityLevel: 0x01})
+			img := testImage(100, DefaultMemoryAddress)
+
+			_, err := flash(t, client, img, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Flash() = %v, want %q", err, tt.want)
+			}
+			if tt.nrc != 0 && !uds.IsNRC(err, tt.nrc) {
+				t.Fatalf("Flash() = %v, want %v", err, tt.nrc)
+			}
+			if !bytes.Equal(bl.Memory(DefaultMemoryAddress, 100), bytes.Repeat([]byte{erasedByte}, 100)) {
+				t.Fatal("memory written without unlocking")
+			}
+			if bl.Resets() != 0 {
+				t.Fatal("ECU reset after a failed download")
+			}
+		})
+	}
+}
+
+// TestFlashRetries drops some responses of the bootloader, the blocks are sent again with the same counter,
+// the counter wrapping past 0xFF
+func TestFlashRetries(t *testing.T) {
+	blockSize := DefaultBlockLength - 2
+	// More than 256 blocks: the counter wraps from 0xFF to 0x00
+	img := testImage(260*blockSize+17, DefaultMemoryAddress)
+
+	t.Run("sent again", func(t *testing.T) {
+		// The responses to the blocks 86, 171 and 256 (counter 0x00) are dropped
+		bl, client := startBootloader(t, BootloaderConfig{DropEvery: 86})
+
+		p, err := flash(t, client, img, Options{})
+		if err != nil {
+			t.Fatalf("Flash() = %v", err)
+		}
+		if p.Retries != 3 {
+			t.Fatalf("%d blocks sent again, want 3", p.Retries)
+		}
+		checkMemory(t, bl, img)
+	})
+
+	t.Run("no retries", func(t *testing.T) {
+		bl, client := startBootloader(t, BootloaderConfig{DropEvery: 3})
+
+		_, err := flash(t, client, img, Options{Retries: -1})
+		if err == nil || !strings.Contains(err.Error(), "downloading the block 3 ") {
+			t.Fatalf("Flash() = %v, want the block 3 failing", err)
+		}
+		if bl.Resets() != 0 {
+			t.Fatal("ECU reset after a failed download")
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		_, client := startBootloader(t, BootloaderConfig{DropEvery: 1})
+
+		start := time.Now()
+		_, err := flash(t, client, testImage(100, DefaultMemoryAddress), Options{Retries: 2})
+		if !errors.Is(err, uds.ErrNoResponse) {
+			t.Fatalf("Flash() = %v, want %v", err, uds.ErrNoResponse)
+		}
+		if elapsed := time.Since(start); elapsed < 3*testTimeout {
+			t.Fatalf("failed after %s, before sending the block 3 times", elapsed)
+		}
+	})
+}
+
+// mustKeyAlgorithm returns a registered key algorithm
+func mustKeyAlgorithm(t *testing.T, name string) uds.KeyFunc {
+	t.Helper()
+
+	key, ok := uds.KeyAlgorithm(name)
+	if !ok {
+		t.Fatalf("no key algorithm %q", name)
+	}
+	return key
+}
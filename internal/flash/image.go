@@ -0,0 +1,183 @@
+// Package flash downloads firmware images to the ECU bootloaders over UDS: it reads Intel HEX and
+// binary images, runs the download sequence and includes a simulated bootloader for the tests.
+package flash
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Segment is a contiguous block of the image.
+type Segment struct {
+	Address uint32
+	Data    []byte
+}
+
+// CRC returns the CRC-32 (IEEE) of the data of the segment, checked by the bootloader after the download.
+func (s Segment) CRC() uint32 {
+	return crc32.ChecksumIEEE(s.Data)
+}
+
+// End returns the address following the last byte of the segment.
+func (s Segment) End() uint32 {
+	return s.Address + uint32(len(s.Data))
+}
+
+// Image is a firmware image: its segments sorted by address, not overlapping.
+type Image struct {
+	Segments []Segment
+}
+
+// Size returns the number of bytes of the image.
+func (img *Image) Size() int {
+	size := 0
+	for _, s := range img.Segments {
+		size += len(s.Data)
+	}
+	return size
+}
+
+// LoadImage reads an Intel HEX (.hex, .ihex) or binary image, base is the address of the binary ones.
+func LoadImage(path string, base uint32) (*Image, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihex", ".ihx":
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error in opening the image: %w", err)
+		}
+		defer file.Close()
+
+		img, err := ReadIntelHex(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return img, nil
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error in reading the image: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("the image %s is empty", path)
+		}
+		return &Image{Segments: []Segment{{Address: base, Data: data}}}, nil
+	}
+}
+
+// Record types of the Intel HEX format
+const (
+	ihexData                   = 0x00
+	ihexEndOfFile              = 0x01
+	ihexExtendedSegmentAddress = 0x02
+	ihexStartSegmentAddress    = 0x03
+	ihexExtendedLinearAddress  = 0x04
+	ihexStartLinearAddress     = 0x05
+)
+
+// ReadIntelHex parses an Intel HEX image, the contiguous records are merged in a single segment.
+func ReadIntelHex(r io.Reader) (*Image, error) {
+	var chunks []Segment
+	var base uint32
+	scanner := bufio.NewScanner(r)
+	line := 0
+	ended := false
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if ended {
+			return nil, fmt.Errorf("line %d: record after the end of file", line)
+		}
+		if text[0] != ':' {
+			return nil, fmt.Errorf("line %d: record not starting with ':'", line)
+		}
+
+		record, err := hex.DecodeString(text[1:])
+		if err != nil || len(record) < 5 || len(record) != 5+int(record[0]) {
+			return nil, fmt.Errorf("line %d: malformed record", line)
+		}
+		var sum byte
+		for _, b := range record {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("line %d: wrong checksum", line)
+		}
+
+		data := record[4 : len(record)-1]
+		switch record[3] {
+		case ihexData:
+			address := base + (uint32(record[1])<<8 | uint32(record[2]))
+			chunks = append(chunks, Segment{Address: address, Data: data})
+		case ihexEndOfFile:
+			ended = true
+		case ihexExtendedSegmentAddress, ihexExtendedLinearAddress:
+			if len(data) != 2 {
+				return nil, fmt.Errorf("line %d: malformed address record", line)
+			}
+			base = uint32(data[0])<<8 | uint32(data[1])
+			if record[3] == ihexExtendedSegmentAddress {
+				base <<= 4
+			} else {
+				base <<= 16
+			}
+		case ihexStartSegmentAddress, ihexStartLinearAddress:
+			// Entry point of the program, not downloaded
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type 0x%02X", line, record[3])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !ended {
+		return nil, fmt.Errorf("missing end of file record")
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no data records")
+	}
+
+	return mergeSegments(chunks)
+}
+
+// mergeSegments sorts the chunks of an image and merges the contiguous ones
+func mergeSegments(chunks []Segment) (*Image, error) {
+	slices.SortStableFunc(chunks, func(a, b Segment) int {
+		switch {
+		case a.Address < b.Address:
+			return -1
+		case a.Address > b.Address:
+			return 1
+		}
+		return 0
+	})
+
+	img := &Image{}
+	for _, chunk := range chunks {
+		if len(chunk.Data) == 0 {
+			continue
+		}
+		if n := len(img.Segments); n > 0 {
+			last := &img.Segments[n-1]
+			if chunk.Address < last.End() {
+				return nil, fmt.Errorf("overlapping data at 0x%08X", chunk.Address)
+			}
+			if chunk.Address == last.End() {
+				last.Data = append(last.Data, chunk.Data...)
+				continue
+			}
+		}
+		img.Segments = append(img.Segments, Segment{Address: chunk.Address, Data: slices.Clone(chunk.Data)})
+	}
+	return img, nil
+}
@@ -0,0 +1,135 @@
+package flash
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ihexRecord returns an Intel HEX record with its checksum
+func ihexRecord(address uint16, kind byte, data ...byte) string {
+	record := append([]byte{byte(len(data)), byte(address >> 8), byte(address), kind}, data...)
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	return fmt.Sprintf(":%X%02X", record, -sum)
+}
+
+// ihexEOF is the end of file record
+var ihexEOF = ihexRecord(0, ihexEndOfFile)
+
+// TestReadIntelHex checks the addresses of the segments read
+func TestReadIntelHex(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		want    []Segment
+	}{
+		{
+			name: "contiguous records merged",
+			records: []string{
+				ihexRecord(0x0100, ihexData, 1, 2, 3),
+				ihexRecord(0x0103, ihexData, 4, 5),
+				ihexEOF,
+			},
+			want: []Segment{{Address: 0x0100, Data: []byte{1, 2, 3, 4, 5}}},
+		},
+		{
+			name: "records sorted",
+			records: []string{
+				ihexRecord(0x0200, ihexData, 3),
+				ihexRecord(0x0100, ihexData, 1),
+				ihexRecord(0x0101, ihexData, 2),
+				ihexEOF,
+			},
+			want: []Segment{{Address: 0x0100, Data: []byte{1, 2}}, {Address: 0x0200, Data: []byte{3}}},
+		},
+		{
+			name: "extended linear address",
+			records: []string{
+				ihexRecord(0, ihexExtendedLinearAddress, 0x08, 0x00),
+				ihexRecord(0xFFFE, ihexData, 1, 2),
+				ihexRecord(0, ihexExtendedLinearAddress, 0x08, 0x01),
+				ihexRecord(0x0000, ihexData, 3),
+				ihexRecord(0, ihexStartLinearAddress, 0x08, 0x00, 0x01, 0x00),
+				ihexEOF,
+			},
+			want: []Segment{{Address: 0x0800FFFE, Data: []byte{1, 2, 3}}},
+		},
+		{
+			name: "extended segment address",
+			records: []string{
+				ihexRecord(0, ihexExtendedSegmentAddress, 0x12, 0x34),
+				ihexRecord(0x0010, ihexData, 1),
+				ihexRecord(0, ihexStartSegmentAddress, 0x00, 0x00, 0x01, 0x00),
+				ihexEOF,
+			},
+			want: []Segment{{Address: 0x12350, Data: []byte{1}}},
+		},
+		{
+			name: "lowercase and blank lines",
+			records: []string{
+				strings.ToLower(ihexRecord(0x0010, ihexData, 0xAB)),
+				"",
+				ihexEOF,
+				"  ",
+			},
+			want: []Segment{{Address: 0x0010, Data: []byte{0xAB}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ReadIntelHex(strings.NewReader(strings.Join(tt.records, "\r\n")))
+			if err != nil {
+				t.Fatalf("ReadIntelHex() = %v", err)
+			}
+			if len(img.Segments) != len(tt.want) {
+				t.Fatalf("%d segments, want %d", len(img.Segments), len(tt.want))
+			}
+			for i, s := range img.Segments {
+				if s.Address != tt.want[i].Address || !bytes.Equal(s.Data, tt.want[i].Data) {
+					t.Errorf("segment %d = 0x%08X % X, want 0x%08X % X", i, s.Address, s.Data, tt.want[i].Address, tt.want[i].Data)
+				}
+			}
+		})
+	}
+}
+
+// TestReadIntelHexErrors checks the images rejected
+func TestReadIntelHexErrors(t *testing.T) {
+	badChecksum := ihexRecord(0x0100, ihexData, 1, 2)
+	badChecksum = badChecksum[:len(badChecksum)-2] + "00"
+
+	tests := []struct {
+		name    string
+		records []string
+		want    string
+	}{
+		{"bad checksum", []string{badChecksum, ihexEOF}, "line 1: wrong checksum"},
+		{
+			"overlap",
+			[]string{ihexRecord(0x0100, ihexData, 1, 2, 3), ihexRecord(0x0102, ihexData, 4), ihexEOF},
+			"overlapping data at 0x00000102",
+		},
+		{"missing end of file", []string{ihexRecord(0x0100, ihexData, 1)}, "missing end of file"},
+		{"record after the end", []string{ihexEOF, ihexRecord(0x0100, ihexData, 1)}, "line 2: record after the end of file"},
+		{"no data", []string{ihexEOF}, "no data records"},
+		{"no colon", []string{"0100000001FE", ihexEOF}, "line 1: record not starting with ':'"},
+		{"wrong length", []string{":0200000001FD", ihexEOF}, "line 1: malformed record"},
+		{"not hexadecimal", []string{":01000000ZZ00", ihexEOF}, "line 1: malformed record"},
+		{"malformed address", []string{ihexRecord(0, ihexExtendedLinearAddress, 0x08), ihexEOF}, "line 1: malformed address record"},
+		{"unknown type", []string{ihexRecord(0, 0x06, 1), ihexEOF}, "line 1: unknown record type 0x06"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadIntelHex(strings.NewReader(strings.Join(tt.records, "\n")))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ReadIntelHex() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
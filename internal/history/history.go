@@ -0,0 +1,133 @@
+// Package history keeps the rolling time series of the decoded signals, for the plots of the monitoring view.
+package history
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Sample is a value of a signal at the time of the frame carrying it.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Key identifies the time series of a signal: the key of its message (see can.Key) and its name.
+type Key struct {
+	Message uint32
+	Signal  string
+}
+
+// series is a bounded ring buffer of samples, growing up to the capacity of the store:
+// once full every new sample overwrites the oldest one
+type series struct {
+	samples []Sample
+	next    int // index of the oldest sample once full, where the next one is written
+}
+
+func (s *series) add(sample Sample, capacity int) {
+	if len(s.samples) < capacity {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+// since returns a copy of the samples taken after t, from the oldest to the newest
+func (s *series) since(t time.Time) []Sample {
+	samples := make([]Sample, 0, len(s.samples))
+	appendAfter := func(src []Sample) {
+		for _, sample := range src {
+			if sample.Time.After(t) {
+				samples = append(samples, sample)
+			}
+		}
+	}
+
+	appendAfter(s.samples[s.next:])
+	appendAfter(s.samples[:s.next])
+
+	return samples
+}
+
+// Store holds the last samples of every signal it sees, it is safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	capacity int
+	series   map[Key]*series
+}
+
+// NewStore returns an empty store keeping up to capacity samples per signal.
+func NewStore(capacity int) *Store {
+	return &Store{
+		capacity: max(capacity, 1),
+		series:   make(map[Key]*series),
+	}
+}
+
+// Add records the value of a signal at time t.
+func (s *Store) Add(key Key, t time.Time, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, ok := s.series[key]
+	if !ok {
+		ser = &series{}
+		s.series[key] = ser
+	}
+	ser.add(Sample{Time: t, Value: value}, s.capacity)
+}
+
+// Since returns a copy of the samples of the signal taken after t, from the oldest to the newest.
+func (s *Store) Since(key Key, t time.Time) []Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, ok := s.series[key]
+	if !ok {
+		return nil
+	}
+	return ser.since(t)
+}
+
+// Reset forgets every sample.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series = make(map[Key]*series)
+}
+
+// Summary holds the minimum, maximum and average of a set of samples.
+type Summary struct {
+	Count int
+	Min   float64
+	Max   float64
+	Avg   float64
+	Last  float64
+}
+
+// Summarize computes the summary of the samples, the zero Summary if there are none.
+func Summarize(samples []Sample) Summary {
+	if len(samples) == 0 {
+		return Summary{}
+	}
+
+	sum := Summary{
+		Count: len(samples),
+		Min:   math.Inf(1),
+		Max:   math.Inf(-1),
+		Last:  samples[len(samples)-1].Value,
+	}
+	total := 0.0
+	for _, sample := range samples {
+		sum.Min = min(sum.Min, sample.Value)
+		sum.Max = max(sum.Max, sample.Value)
+		total += sample.Value
+	}
+	sum.Avg = total / float64(len(samples))
+
+	return sum
+}
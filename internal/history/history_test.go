@@ -0,0 +1,107 @@
+package history
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// values returns the values of the samples
+func values(samples []Sample) []float64 {
+	v := make([]float64, len(samples))
+	for i, sample := range samples {
+		v[i] = sample.Value
+	}
+	return v
+}
+
+// TestStoreCapacity checks that a full series drops its oldest samples, keeping the order
+func TestStoreCapacity(t *testing.T) {
+	store := NewStore(3)
+	key := Key{Message: 0x100, Signal: "speed"}
+	start := time.Now()
+
+	tests := []struct {
+		add  int
+		want []float64
+	}{
+		{2, []float64{0, 1}},
+		{1, []float64{0, 1, 2}},
+		{1, []float64{1, 2, 3}},
+		{4, []float64{5, 6, 7}},
+	}
+	n := 0
+	for _, tt := range tests {
+		for range tt.add {
+			store.Add(key, start.Add(time.Duration(n)*time.Millisecond), float64(n))
+			n++
+		}
+		if got := values(store.Since(key, time.Time{})); !slices.Equal(got, tt.want) {
+			t.Fatalf("after %d samples: %v, want %v", n, got, tt.want)
+		}
+	}
+
+	if got := store.Since(Key{Message: 0x100, Signal: "torque"}, time.Time{}); got != nil {
+		t.Errorf("samples %v of a signal never added", got)
+	}
+	store.Reset()
+	if got := store.Since(key, time.Time{}); got != nil {
+		t.Errorf("samples %v after Reset", got)
+	}
+
+	// A store keeps at least a sample per signal
+	store = NewStore(0)
+	store.Add(key, start, 1)
+	store.Add(key, start, 2)
+	if got := values(store.Since(key, time.Time{})); !slices.Equal(got, []float64{2}) {
+		t.Errorf("store of capacity 0 holds %v, want [2]", got)
+	}
+}
+
+// TestStoreSince checks the time window of the samples returned, also once the ring has wrapped
+func TestStoreSince(t *testing.T) {
+	store := NewStore(4)
+	key := Key{Message: 0x100, Signal: "speed"}
+	start := time.Now()
+	at := func(n int) time.Time {
+		return start.Add(time.Duration(n) * time.Second)
+	}
+	for n := range 6 {
+		store.Add(key, at(n), float64(n))
+	}
+
+	tests := []struct {
+		since time.Time
+		want  []float64
+	}{
+		{time.Time{}, []float64{2, 3, 4, 5}},
+		{at(2), []float64{3, 4, 5}}, // the samples taken at the time itself are left out
+		{at(3).Add(time.Millisecond), []float64{4, 5}},
+		{at(5), []float64{}},
+	}
+	for _, tt := range tests {
+		if got := values(store.Since(key, tt.since)); !slices.Equal(got, tt.want) {
+			t.Errorf("Since(%v) = %v, want %v", tt.since.Sub(start), got, tt.want)
+		}
+	}
+
+	// The samples returned are a copy
+	samples := store.Since(key, time.Time{})
+	samples[0].Value = 100
+	if got := store.Since(key, time.Time{}); got[0].Value != 2 {
+		t.Errorf("store changed through the samples returned: %v", values(got))
+	}
+}
+
+// TestSummarize checks the summary of the samples
+func TestSummarize(t *testing.T) {
+	if sum := Summarize(nil); sum != (Summary{}) {
+		t.Errorf("Summarize(nil) = %+v", sum)
+	}
+
+	samples := []Sample{{Value: 3}, {Value: -1}, {Value: 4}, {Value: 2}}
+	want := Summary{Count: 4, Min: -1, Max: 4, Avg: 2, Last: 2}
+	if sum := Summarize(samples); sum != want {
+		t.Errorf("Summarize() = %+v, want %+v", sum, want)
+	}
+}
@@ -0,0 +1,345 @@
+// Package isotp implements the ISO-TP transport protocol (ISO 15765-2) on top of a [bus.Bus]:
+// payloads longer than a CAN frame are segmented in a first frame and consecutive frames,
+// paced by the flow control frames of the receiver (block size and minimum separation time).
+//
+// Classic CAN frames carry up to 8 bytes, with [Config.FD] the frames sent are CAN FD frames of up to
+// 64 bytes (ISO 15765-2:2016). The frames received can be of both kinds. Only normal addressing is supported.
+package isotp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Protocol control information: the high nibble of the first byte of the frame
+const (
+	pciSingle      = 0x0
+	pciFirst       = 0x1
+	pciConsecutive = 0x2
+	pciFlowControl = 0x3
+)
+
+// Flow status of the flow control frames
+const (
+	flowContinue = 0x0 // clear to send
+	flowWait     = 0x1
+	flowOverflow = 0x2
+)
+
+const (
+	// frameLength is the length of the classic CAN frames carrying the protocol
+	frameLength = 8
+	// MaxPayload is the longest payload that can be sent (first frame with the 32-bit length escape)
+	MaxPayload = 1<<32 - 1
+	// DefaultTimeout is how long to wait for a flow control or a consecutive frame (N_Bs, N_Cr)
+	DefaultTimeout = time.Second
+	// DefaultPadByte is the byte filling the unused bytes of the frames when padding
+	DefaultPadByte = 0xCC
+	// maxWaitFrames is the number of consecutive WAIT flow controls accepted (N_WFTmax)
+	maxWaitFrames = 10
+	// receivedBuffer is the number of payloads received waiting for Receive, the next ones are dropped
+	receivedBuffer = 64
+)
+
+// Errors of the transmissions and receptions.
+var (
+	ErrTimeout  = errors.New("isotp: timeout")
+	ErrOverflow = errors.New("isotp: the receiver cannot take a payload this long")
+	ErrSequence = errors.New("isotp: wrong sequence number")
+	ErrAborted  = errors.New("isotp: reception aborted by a new payload")
+	ErrTooLong  = errors.New("isotp: payload too long")
+	ErrClosed   = errors.New("isotp: connection closed")
+)
+
+// Config configures a connection with a peer.
+type Config struct {
+	TxID     uint32 // ID of the frames sent
+	RxID     uint32 // ID of the frames received
+	Extended bool   // true for 29-bit IDs
+	// BlockSize is the number of consecutive frames the peer can send before waiting
+	// for the next flow control (0 sends them all)
+	BlockSize uint8
+	// STmin is the minimum time the peer must leave between two consecutive frames
+	STmin   time.Duration
+	Padding bool // pad the frames sent to 8 bytes with PadByte
+	PadByte byte
+	// FD sends CAN FD frames of up to 64 bytes, padded to the next valid CAN FD length
+	// (with PadByte, or DefaultPadByte without Padding)
+	FD  bool
+	BRS bool // bit rate switch of the CAN FD frames
+	// Timeout is how long to wait for the flow control and consecutive frames (DefaultTimeout if 0)
+	Timeout time.Duration
+}
+
+func (c Config) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// dataLength returns the length of the frames sent (TX_DL)
+func (c Config) dataLength() int {
+	if c.FD {
+		return bus.MaxFDDataLength
+	}
+	return frameLength
+}
+
+// flowControl is a flow control frame received
+type flowControl struct {
+	status    uint8
+	blockSize uint8
+	stmin     time.Duration
+}
+
+// received is a payload received or a failed reception
+type received struct {
+	payload []byte
+	err     error
+}
+
+// Conn is an ISO-TP connection with a peer, it is safe for concurrent use.
+// One payload is sent at a time, the payloads received are queued for Receive.
+type Conn struct {
+	bus  bus.Bus
+	cfg  Config
+	recv bus.Receiver
+
+	sendMu sync.Mutex // one transmission at a time
+	flow   chan flowControl
+	rx     chan received
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Dial opens a connection on the bus, receiving the frames with the RxID of the configuration.
+func Dial(b bus.Bus, cfg Config) *Conn {
+	c := &Conn{
+		bus:  b,
+		cfg:  cfg,
+		recv: b.Subscribe(),
+		flow: make(chan flowControl, 1),
+		rx:   make(chan received, receivedBuffer),
+		done: make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Config returns the configuration of the connection.
+func (c *Conn) Config() Config {
+	return c.cfg
+}
+
+// Close closes the connection, pending Send and Receive return ErrClosed.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.recv.Close()
+	})
+	return nil
+}
+
+// Receive waits for the next payload received, or the error of a failed reception.
+func (c *Conn) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case r := <-c.rx:
+		return r.payload, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrClosed
+	}
+}
+
+// Send sends a payload, segmenting it if it does not fit in a single frame.
+// It returns once the last frame is sent.
+func (c *Conn) Send(ctx context.Context, payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("isotp: empty payload")
+	}
+	if uint64(len(payload)) > MaxPayload {
+		return ErrTooLong
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	// Single frame, the CAN FD ones longer than 8 bytes have the length in the second byte
+	txDL := c.cfg.dataLength()
+	switch {
+	case len(payload) <= frameLength-1:
+		data := append([]byte{pciSingle<<4 | byte(len(payload))}, payload...)
+		return c.sendFrame(ctx, data)
+	case len(payload) <= txDL-2:
+		data := append([]byte{pciSingle << 4, byte(len(payload))}, payload...)
+		return c.sendFrame(ctx, data)
+	}
+
+	// Forget the flow controls of previous transmissions
+	select {
+	case <-c.flow:
+	default:
+	}
+
+	// First frame, the 12-bit length escapes to 32 bits for the longer payloads
+	var data []byte
+	if len(payload) <= 0xFFF {
+		data = []byte{pciFirst<<4 | byte(len(payload)>>8), byte(len(payload))}
+	} else {
+		n := uint32(len(payload))
+		data = []byte{pciFirst << 4, 0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	sent := txDL - len(data)
+	data = append(data, payload[:sent]...)
+	if err := c.sendFrame(ctx, data); err != nil {
+		return err
+	}
+
+	// Consecutive frames, in blocks paced by the flow controls
+	seq := byte(1)
+	for sent < len(payload) {
+		fc, err := c.waitFlowControl(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; (fc.blockSize == 0 || i < int(fc.blockSize)) && sent < len(payload); i++ {
+			if i > 0 {
+				if err := sleep(ctx, c.done, fc.stmin); err != nil {
+					return err
+				}
+			}
+
+			n := min(txDL-1, len(payload)-sent)
+			data := append([]byte{pciConsecutive<<4 | seq}, payload[sent:sent+n]...)
+			if err := c.sendFrame(ctx, data); err != nil {
+				return err
+			}
+			sent += n
+			seq = (seq + 1) & 0x0F
+		}
+	}
+
+	return nil
+}
+
+// waitFlowControl waits for a flow control allowing to send, the WAIT ones restart the timeout
+func (c *Conn) waitFlowControl(ctx context.Context) (flowControl, error) {
+	for waits := 0; ; waits++ {
+		timer := time.NewTimer(c.cfg.timeout())
+		select {
+		case fc := <-c.flow:
+			timer.Stop()
+			switch fc.status {
+			case flowContinue:
+				return fc, nil
+			case flowWait:
+				if waits >= maxWaitFrames {
+					return flowControl{}, fmt.Errorf("isotp: receiver asked to wait %d times: %w", waits+1, ErrTimeout)
+				}
+				continue
+			case flowOverflow:
+				return flowControl{}, ErrOverflow
+			default:
+				return flowControl{}, fmt.Errorf("isotp: invalid flow status %d", fc.status)
+			}
+		case <-timer.C:
+			return flowControl{}, fmt.Errorf("isotp: no flow control from 0x%X: %w", c.cfg.RxID, ErrTimeout)
+		case <-ctx.Done():
+			timer.Stop()
+			return flowControl{}, ctx.Err()
+		case <-c.done:
+			timer.Stop()
+			return flowControl{}, ErrClosed
+		}
+	}
+}
+
+// sendFrame sends a frame with the TX ID, padded if configured or to a valid CAN FD length
+func (c *Conn) sendFrame(ctx context.Context, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	frame := bus.Frame{ID: c.cfg.TxID, IsExtended: c.cfg.Extended, IsFD: c.cfg.FD, BRS: c.cfg.FD && c.cfg.BRS}
+	copy(frame.Data[:], data)
+
+	length, pad := len(data), byte(DefaultPadByte)
+	if c.cfg.Padding {
+		length, pad = max(length, frameLength), c.cfg.PadByte
+	}
+	if c.cfg.FD {
+		length = bus.ValidLength(length)
+	}
+	for i := len(data); i < length; i++ {
+		frame.Data[i] = pad
+	}
+	frame.Length = uint8(length)
+	return c.bus.Send(ctx, frame)
+}
+
+// sendFlowControl sends a flow control frame with the block size and STmin of the configuration
+func (c *Conn) sendFlowControl(status uint8) {
+	c.sendFrame(context.Background(), []byte{pciFlowControl<<4 | status, c.cfg.BlockSize, EncodeSTmin(c.cfg.STmin)})
+}
+
+// deliver queues a payload or a failed reception for Receive, dropping it if the queue is full
+func (c *Conn) deliver(payload []byte, err error) {
+	select {
+	case c.rx <- received{payload: payload, err: err}:
+	default:
+	}
+}
+
+// sleep waits for d, unless the context or the connection are closed
+func sleep(ctx context.Context, done <-chan struct{}, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return ErrClosed
+	}
+}
+
+// EncodeSTmin returns the STmin byte of a flow control: 0-127 ms, or 100-900 µs.
+// Longer times are capped to 127 ms.
+func EncodeSTmin(d time.Duration) byte {
+	switch {
+	case d <= 0:
+		return 0
+	case d < time.Millisecond:
+		return 0xF0 + byte(max(1, d/(100*time.Microsecond)))
+	default:
+		return byte(min(d/time.Millisecond, 0x7F))
+	}
+}
+
+// DecodeSTmin returns the separation time of the STmin byte of a flow control,
+// the reserved values mean the longest one (127 ms).
+func DecodeSTmin(b byte) time.Duration {
+	switch {
+	case b <= 0x7F:
+		return time.Duration(b) * time.Millisecond
+	case b >= 0xF1 && b <= 0xF9:
+		return time.Duration(b-0xF0) * 100 * time.Microsecond
+	default:
+		return 0x7F * time.Millisecond
+	}
+}
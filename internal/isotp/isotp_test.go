@@ -0,0 +1,421 @@
+package isotp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Test IDs: the tester sends on testTx, the ECU answers on testRx
+const (
+	testTx = 0x7E0
+	testRx = 0x7E8
+)
+
+// testPayload returns a payload of n bytes
+func testPayload(n int) []byte {
+	payload := make([]byte, n)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	return payload
+}
+
+// dialPair opens the connections of the tester and of the ECU on a virtual bus,
+// it also returns a receiver of every frame on the bus
+func dialPair(t *testing.T, tester, ecu Config) (*Conn, *Conn, bus.Receiver) {
+	t.Helper()
+
+	b := bus.NewMem(t.Name())
+	t.Cleanup(func() { b.Close() })
+	frames := b.Subscribe()
+
+	tester.TxID, tester.RxID = testTx, testRx
+	ecu.TxID, ecu.RxID = testRx, testTx
+	testerConn, ecuConn := Dial(b, tester), Dial(b, ecu)
+	t.Cleanup(func() {
+		testerConn.Close()
+		ecuConn.Close()
+	})
+	return testerConn, ecuConn, frames
+}
+
+// pending returns the frames queued in the receiver
+func pending(recv bus.Receiver) []bus.Frame {
+	var frames []bus.Frame
+	done := make(chan struct{})
+	go func() {
+		for recv.Receive() {
+			frames = append(frames, recv.Frame())
+		}
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	recv.Close()
+	<-done
+	return frames
+}
+
+// receive waits for the next payload of the connection
+func receive(t *testing.T, c *Conn) ([]byte, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	payload, err := c.Receive(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("nothing received")
+	}
+	return payload, err
+}
+
+// TestTransfer sends payloads of every length class and checks the frames on the bus
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		tester Config
+		ecu    Config
+		// frames sent by the tester and the ECU
+		single, consecutive, flowControls int
+		frameLengths                      []uint8 // lengths allowed for the frames, 1-8 if empty
+	}{
+		{name: "single frame", length: 7, single: 1},
+		{name: "single frame padded", length: 1, tester: Config{Padding: true, PadByte: 0xAA}, single: 1, frameLengths: []uint8{8}},
+		{name: "first frame only", length: 8, consecutive: 1, flowControls: 1},
+		{name: "multi frame", length: 100, consecutive: 14, flowControls: 1},
+		{name: "block size", length: 100, ecu: Config{BlockSize: 4}, consecutive: 14, flowControls: 4},
+		{name: "block size 1", length: 20, ecu: Config{BlockSize: 1}, consecutive: 2, flowControls: 2},
+		{name: "12-bit length", length: 4095, consecutive: 585, flowControls: 1},
+		{name: "32-bit length", length: 4096, consecutive: 585, flowControls: 1},
+		{name: "sequence wraps", length: 6 + 7*20, ecu: Config{BlockSize: 16}, consecutive: 20, flowControls: 2},
+		{
+			name: "FD single frame", length: 62, tester: Config{FD: true}, single: 1,
+			frameLengths: []uint8{64},
+		},
+		{
+			name: "FD single frame padded to a valid length", length: 9, tester: Config{FD: true, BRS: true}, single: 1,
+			frameLengths: []uint8{12},
+		},
+		{
+			name: "FD classic single frame", length: 3, tester: Config{FD: true}, single: 1,
+			frameLengths: []uint8{4},
+		},
+		{
+			name: "FD multi frame", length: 200, tester: Config{FD: true}, ecu: Config{FD: true},
+			// 62 bytes in the first frame, then 63, 63 and 12
+			consecutive: 3, flowControls: 1, frameLengths: []uint8{3, 16, 64},
+		},
+		{
+			name: "FD 32-bit length", length: 5000, tester: Config{FD: true, Padding: true}, ecu: Config{FD: true, Padding: true, BlockSize: 8},
+			// 58 bytes in the first frame, then 78 of 63 bytes and 28
+			consecutive: 79, flowControls: 10, frameLengths: []uint8{8, 32, 64},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tester, ecu, frames := dialPair(t, tt.tester, tt.ecu)
+			payload := testPayload(tt.length)
+
+			if err := tester.Send(context.Background(), payload); err != nil {
+				t.Fatal(err)
+			}
+			got, err := receive(t, ecu)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("received %d bytes, want %d: % X", len(got), len(payload), got)
+			}
+
+			var single, first, consecutive, flowControls int
+			for _, frame := range pending(frames) {
+				switch frame.Payload()[0] >> 4 {
+				case pciSingle:
+					single++
+				case pciFirst:
+					first++
+				case pciConsecutive:
+					consecutive++
+				case pciFlowControl:
+					flowControls++
+				}
+
+				if frame.IsFD != (frame.ID == testTx && tt.tester.FD || frame.ID == testRx && tt.ecu.FD) {
+					t.Errorf("frame % X: FD %v", frame.Payload(), frame.IsFD)
+				}
+				if frame.IsFD && frame.BRS != tt.tester.BRS {
+					t.Errorf("frame % X: BRS %v", frame.Payload(), frame.BRS)
+				}
+				if len(tt.frameLengths) == 0 {
+					if frame.Length == 0 || frame.Length > frameLength {
+						t.Errorf("frame % X: length %d", frame.Payload(), frame.Length)
+					}
+				} else if !bytes.Contains(tt.frameLengths, []byte{frame.Length}) {
+					t.Errorf("frame % X: length %d, want one of %v", frame.Payload(), frame.Length, tt.frameLengths)
+				}
+			}
+
+			wantFirst := 0
+			if tt.single == 0 {
+				wantFirst = 1
+			}
+			if single != tt.single || first != wantFirst || consecutive != tt.consecutive || flowControls != tt.flowControls {
+				t.Fatalf("SF %d FF %d CF %d FC %d, want SF %d FF %d CF %d FC %d",
+					single, first, consecutive, flowControls, tt.single, wantFirst, tt.consecutive, tt.flowControls)
+			}
+		})
+	}
+}
+
+// TestPadding checks the bytes added to the frames
+func TestPadding(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []byte
+	}{
+		{"no padding", Config{}, []byte{0x02, 0x11, 0x22}},
+		{"padding", Config{Padding: true, PadByte: 0x55}, []byte{0x02, 0x11, 0x22, 0x55, 0x55, 0x55, 0x55, 0x55}},
+		{"FD without padding", Config{FD: true}, []byte{0x02, 0x11, 0x22}},
+		{"FD padding", Config{FD: true, Padding: true, PadByte: 0x55}, []byte{0x02, 0x11, 0x22, 0x55, 0x55, 0x55, 0x55, 0x55}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tester, _, frames := dialPair(t, tt.cfg, Config{})
+			if err := tester.Send(context.Background(), []byte{0x11, 0x22}); err != nil {
+				t.Fatal(err)
+			}
+			sent := pending(frames)
+			if len(sent) != 1 || !bytes.Equal(sent[0].Payload(), tt.want) {
+				t.Fatalf("sent %v, want % X", sent, tt.want)
+			}
+		})
+	}
+
+	// The CAN FD frames longer than 8 bytes are padded to a valid length, with DefaultPadByte without Padding
+	tester, _, frames := dialPair(t, Config{FD: true}, Config{})
+	if err := tester.Send(context.Background(), testPayload(9)); err != nil {
+		t.Fatal(err)
+	}
+	sent := pending(frames)
+	want := append(append([]byte{0x00, 9}, testPayload(9)...), DefaultPadByte)
+	if len(sent) != 1 || !bytes.Equal(sent[0].Payload(), want) {
+		t.Fatalf("sent %v, want % X", sent, want)
+	}
+}
+
+// TestSTminPacing checks that the consecutive frames of a block are separated by STmin
+func TestSTminPacing(t *testing.T) {
+	const stmin = 5 * time.Millisecond
+	tester, ecu, frames := dialPair(t, Config{}, Config{BlockSize: 3, STmin: stmin})
+
+	payload := testPayload(6 + 7*6)
+	if err := tester.Send(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := receive(t, ecu); err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("received % X, %v", got, err)
+	}
+
+	var last time.Time
+	block := 0
+	for _, frame := range pending(frames) {
+		switch frame.Payload()[0] >> 4 {
+		case pciFlowControl:
+			if got := DecodeSTmin(frame.Payload()[2]); got != stmin || frame.Payload()[1] != 3 {
+				t.Fatalf("flow control % X: block size %d, STmin %s", frame.Payload(), frame.Payload()[1], got)
+			}
+			block = 0
+		case pciConsecutive:
+			if block > 0 && frame.Timestamp.Sub(last) < stmin {
+				t.Errorf("consecutive frame % X sent %s after the previous one", frame.Payload(), frame.Timestamp.Sub(last))
+			}
+			last = frame.Timestamp
+			block++
+			if block > 3 {
+				t.Errorf("%d consecutive frames in a block of 3", block)
+			}
+		}
+	}
+}
+
+// sendRaw sends a frame with the ID and data
+func sendRaw(t *testing.T, b bus.Bus, id uint32, data ...byte) {
+	t.Helper()
+
+	frame := bus.Frame{ID: id, Length: uint8(len(data)), IsFD: len(data) > frameLength}
+	copy(frame.Data[:], data)
+	if err := b.Send(context.Background(), frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReceiveErrors feeds a connection with frames of a broken peer
+func TestReceiveErrors(t *testing.T) {
+	first := []byte{0x10, 20, 0, 1, 2, 3, 4, 5}
+	tests := []struct {
+		name   string
+		frames [][]byte
+		want   []error // results of the receptions, nil for a payload
+	}{
+		{
+			name:   "wrong sequence number",
+			frames: [][]byte{first, {0x22, 6, 7, 8, 9, 10, 11, 12}},
+			want:   []error{ErrSequence},
+		},
+		{
+			name:   "consecutive frame missing",
+			frames: [][]byte{first, {0x21, 6, 7, 8, 9, 10, 11, 12}},
+			want:   []error{ErrTimeout},
+		},
+		{
+			name:   "aborted by a single frame",
+			frames: [][]byte{first, {0x01, 0xAA}},
+			want:   []error{ErrAborted, nil},
+		},
+		{
+			name:   "unexpected consecutive frame ignored",
+			frames: [][]byte{{0x21, 1, 2, 3, 4, 5, 6, 7}, {0x01, 0xAA}},
+			want:   []error{nil},
+		},
+		{
+			name:   "FD single frame",
+			frames: [][]byte{append([]byte{0x00, 10}, testPayload(10)...)},
+			want:   []error{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bus.NewMem(t.Name())
+			defer b.Close()
+			c := Dial(b, Config{TxID: testRx, RxID: testTx, Timeout: 50 * time.Millisecond})
+			defer c.Close()
+
+			for _, data := range tt.frames {
+				sendRaw(t, b, testTx, data...)
+			}
+			for _, want := range tt.want {
+				payload, err := receive(t, c)
+				switch {
+				case want == nil && err != nil:
+					t.Fatalf("got %v, want a payload", err)
+				case want != nil && !errors.Is(err, want):
+					t.Fatalf("got % X, %v, want %v", payload, err, want)
+				}
+			}
+		})
+	}
+}
+
+// respond answers the first frames sent on testTx with the flow controls, spaced by a pause
+func respond(b bus.Bus, pause time.Duration, flowControls ...[]byte) {
+	recv := b.Subscribe()
+	go func() {
+		defer recv.Close()
+		for recv.Receive() {
+			frame := recv.Frame()
+			if frame.ID != testTx || frame.Payload()[0]>>4 != pciFirst {
+				continue
+			}
+			for _, data := range flowControls {
+				time.Sleep(pause)
+				fc := bus.Frame{ID: testRx, Length: uint8(len(data))}
+				copy(fc.Data[:], data)
+				b.Send(context.Background(), fc)
+			}
+			return
+		}
+	}()
+}
+
+// TestSendErrors sends payloads to peers answering with the flow controls, or not at all
+func TestSendErrors(t *testing.T) {
+	wait := []byte{0x31, 0, 0}
+	waits := func(n int) [][]byte {
+		frames := make([][]byte, n)
+		for i := range frames {
+			frames[i] = wait
+		}
+		return frames
+	}
+
+	tests := []struct {
+		name         string
+		flowControls [][]byte
+		want         error
+	}{
+		{"no flow control", nil, ErrTimeout},
+		{"overflow", [][]byte{{0x32, 0, 0}}, ErrOverflow},
+		{"wait then continue", append(waits(3), []byte{0x30, 0, 0}), nil},
+		{"too many waits", waits(maxWaitFrames + 1), ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bus.NewMem(t.Name())
+			defer b.Close()
+			respond(b, 10*time.Millisecond, tt.flowControls...)
+			c := Dial(b, Config{TxID: testTx, RxID: testRx, Timeout: 100 * time.Millisecond})
+			defer c.Close()
+
+			err := c.Send(context.Background(), testPayload(20))
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Send() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	b := bus.NewMem(t.Name())
+	defer b.Close()
+	c := Dial(b, Config{TxID: testTx, RxID: testRx})
+	if err := c.Send(context.Background(), nil); err == nil {
+		t.Fatal("empty payload sent")
+	}
+	c.Close()
+	if err := c.Send(context.Background(), []byte{1}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Send() after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+// TestSTmin checks the encoding of the separation times
+func TestSTmin(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		b    byte
+		back time.Duration // decoded time, d if 0
+	}{
+		{0, 0x00, 0},
+		{time.Millisecond, 0x01, 0},
+		{127 * time.Millisecond, 0x7F, 0},
+		{time.Second, 0x7F, 127 * time.Millisecond},
+		{100 * time.Microsecond, 0xF1, 0},
+		{900 * time.Microsecond, 0xF9, 0},
+		{50 * time.Microsecond, 0xF1, 100 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		if got := EncodeSTmin(tt.d); got != tt.b {
+			t.Errorf("EncodeSTmin(%s) = 0x%02X, want 0x%02X", tt.d, got, tt.b)
+		}
+		want := tt.back
+		if want == 0 {
+			want = tt.d
+		}
+		if got := DecodeSTmin(tt.b); got != want {
+			t.Errorf("DecodeSTmin(0x%02X) = %s, want %s", tt.b, got, want)
+		}
+	}
+
+	// Reserved values mean the longest time
+	for _, b := range []byte{0x80, 0xF0, 0xFA, 0xFF} {
+		if got := DecodeSTmin(b); got != 127*time.Millisecond {
+			t.Errorf("DecodeSTmin(0x%02X) = %s, want 127ms", b, got)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package isotp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// reception is a multi-frame payload being reassembled
+type reception struct {
+	payload []byte
+	length  int
+	seq     byte // sequence number of the next consecutive frame
+	block   int  // consecutive frames received since the last flow control
+}
+
+// run reassembles the payloads received and hands the flow controls to Send, until the connection is closed
+func (c *Conn) run() {
+	frames := make(chan bus.Frame, 256)
+	go func() {
+		defer close(frames)
+		for c.recv.Receive() {
+			frame := c.recv.Frame()
+			if frame.ID != c.cfg.RxID || frame.IsExtended != c.cfg.Extended || frame.IsRemote || frame.Length == 0 {
+				continue
+			}
+			select {
+			case frames <- frame:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	var rx *reception
+	timer := time.NewTimer(c.cfg.timeout())
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			rx = c.handle(rx, frame.Payload())
+			if rx != nil {
+				timer.Reset(c.cfg.timeout())
+			} else {
+				timer.Stop()
+			}
+		case <-timer.C:
+			if rx != nil {
+				c.deliver(nil, fmt.Errorf("isotp: consecutive frame %d of %d bytes from 0x%X missing: %w", rx.seq, rx.length, c.cfg.RxID, ErrTimeout))
+				rx = nil
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// handle processes a frame received, it returns the reception still in progress
+func (c *Conn) handle(rx *reception, data []byte) *reception {
+	switch data[0] >> 4 {
+	case pciSingle:
+		length, start := int(data[0]&0x0F), 1
+		if length == 0 && len(data) > frameLength {
+			// CAN FD single frame, the length is in the second byte
+			length, start = int(data[1]), 2
+		}
+		if length == 0 || length > len(data)-start {
+			return rx // invalid, ignored
+		}
+		if rx != nil {
+			c.deliver(nil, ErrAborted)
+		}
+		c.deliver(append([]byte(nil), data[start:start+length]...), nil)
+		return nil
+
+	case pciFirst:
+		if len(data) < frameLength {
+			return rx
+		}
+		length, start := int(data[0]&0x0F)<<8|int(data[1]), 2
+		if length == 0 {
+			length = int(uint32(data[2])<<24 | uint32(data[3])<<16 | uint32(data[4])<<8 | uint32(data[5]))
+			start = 6
+		}
+		if length <= frameLength-1 {
+			return rx // must have been a single frame, ignored
+		}
+		if rx != nil {
+			c.deliver(nil, ErrAborted)
+		}
+
+		payload := make([]byte, 0, min(length, 1<<16)) // grows for the longer ones
+		payload = append(payload, data[start:min(len(data), start+length)]...)
+		c.sendFlowControl(flowContinue)
+		return &reception{payload: payload, length: length, seq: 1}
+
+	case pciConsecutive:
+		if rx == nil {
+			return nil // not expected, ignored
+		}
+		if seq := data[0] & 0x0F; seq != rx.seq {
+			c.deliver(nil, fmt.Errorf("isotp: got consecutive frame %d instead of %d: %w", seq, rx.seq, ErrSequence))
+			return nil
+		}
+
+		n := min(len(data)-1, rx.length-len(rx.payload))
+		rx.payload = append(rx.payload, data[1:1+n]...)
+		if len(rx.payload) == rx.length {
+			c.deliver(rx.payload, nil)
+			return nil
+		}
+
+		rx.seq = (rx.seq + 1) & 0x0F
+		rx.block++
+		if c.cfg.BlockSize > 0 && rx.block == int(c.cfg.BlockSize) {
+			rx.block = 0
+			c.sendFlowControl(flowContinue)
+		}
+		return rx
+
+	case pciFlowControl:
+		if len(data) < 3 {
+			return rx
+		}
+		fc := flowControl{status: data[0] & 0x0F, blockSize: data[1], stmin: DecodeSTmin(data[2])}
+		// Only the last one matters, a stale one is replaced
+		select {
+		case <-c.flow:
+		default:
+		}
+		select {
+		case c.flow <- fc:
+		default:
+		}
+		return rx
+	}
+
+	return rx
+}
@@ -0,0 +1,119 @@
+package j1939
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Node is a node of the bus: one that claimed an address, or a source address seen without a claim.
+type Node struct {
+	Name      Name  // 0 if the node did not claim its address since the table was created
+	Address   uint8 // NullAddress if the node could not claim an address
+	Claims    int   // address claims received
+	LastClaim time.Time
+	// Lost tells whether another node with a lower NAME claimed the same address afterwards
+	Lost     bool
+	Frames   uint64 // frames sent from the address
+	LastSeen time.Time
+}
+
+// Claimed tells whether the node claimed its address.
+func (n Node) Claimed() bool {
+	return n.Claims > 0
+}
+
+// traffic counts the frames sent from an address
+type traffic struct {
+	frames uint64
+	last   time.Time
+}
+
+// AddressTable follows the address claims and the source addresses of the frames seen on the bus.
+// It is not safe for concurrent use.
+type AddressTable struct {
+	claims map[Name]*Node
+	seen   map[uint8]*traffic
+}
+
+// NewAddressTable returns an empty table.
+func NewAddressTable() *AddressTable {
+	return &AddressTable{
+		claims: make(map[Name]*Node),
+		seen:   make(map[uint8]*traffic),
+	}
+}
+
+// Observe records a frame received at the given time, it returns true if it is an address claim.
+func (t *AddressTable) Observe(at time.Time, id ID, data []byte) bool {
+	if id.Source != NullAddress {
+		s, ok := t.seen[id.Source]
+		if !ok {
+			s = &traffic{}
+			t.seen[id.Source] = s
+		}
+		s.frames++
+		s.last = at
+	}
+
+	if id.PGN != PGNAddressClaimed {
+		return false
+	}
+	name, err := ParseName(data)
+	if err != nil {
+		return false
+	}
+
+	node, ok := t.claims[name]
+	if !ok {
+		node = &Node{Name: name}
+		t.claims[name] = node
+	}
+	node.Address = id.Source
+	node.Claims++
+	node.LastClaim = at
+	node.Lost = false
+
+	// Contention: the lower NAME keeps the address
+	if id.Source != NullAddress {
+		for _, other := range t.claims {
+			if other == node || other.Address != id.Source || other.Lost {
+				continue
+			}
+			if other.Name > name {
+				other.Lost = true
+			} else {
+				node.Lost = true
+			}
+		}
+	}
+	return true
+}
+
+// Nodes returns the nodes sorted by address: the ones that claimed an address, then the source
+// addresses of the frames not claimed by any of them.
+func (t *AddressTable) Nodes() []Node {
+	nodes := make([]Node, 0, len(t.claims)+len(t.seen))
+	owned := make(map[uint8]bool)
+	for _, claim := range t.claims {
+		node := *claim
+		if s, ok := t.seen[node.Address]; ok && !node.Lost && node.Address != NullAddress {
+			node.Frames, node.LastSeen = s.frames, s.last
+			owned[node.Address] = true
+		}
+		nodes = append(nodes, node)
+	}
+	for address, s := range t.seen {
+		if !owned[address] {
+			nodes = append(nodes, Node{Address: address, Frames: s.frames, LastSeen: s.last})
+		}
+	}
+
+	slices.SortFunc(nodes, func(a, b Node) int {
+		if c := cmp.Compare(a.Address, b.Address); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return nodes
+}
@@ -0,0 +1,115 @@
+// Package j1939 decodes SAE J1939 traffic: the 29-bit IDs split into priority, PGN, source and
+// destination, the NAMEs of the address claims and the multi-packet messages of the transport
+// protocol (BAM and RTS/CTS).
+package j1939
+
+import "fmt"
+
+// Addresses with a special meaning
+const (
+	// NullAddress is the source of the "cannot claim address" messages
+	NullAddress = 0xFE
+	// GlobalAddress is the destination of the broadcast messages
+	GlobalAddress = 0xFF
+)
+
+// PGNs of the network management and transport protocol
+const (
+	PGNAcknowledgement        = 0xE800
+	PGNRequest                = 0xEA00
+	PGNTPDataTransfer         = 0xEB00
+	PGNTPConnectionManagement = 0xEC00
+	PGNAddressClaimed         = 0xEE00
+	PGNProprietaryA           = 0xEF00
+	PGNCommandedAddress       = 0xFED8
+	PGNProprietaryB           = 0xFF00 // first of the 256 Proprietary B PGNs
+)
+
+const (
+	// pdu2Format is the first PDU format of the broadcast (PDU2) PGNs
+	pdu2Format = 0xF0
+	maxPGN     = 0x3FFFF
+	// maxPriority is the lowest priority, 3 bits
+	maxPriority = 7
+)
+
+// pgnNames are the names of the PGNs shown when the DBC does not declare them
+var pgnNames = map[uint32]string{
+	PGNAcknowledgement:        "Acknowledgement",
+	PGNRequest:                "Request",
+	PGNTPDataTransfer:         "TP.DT",
+	PGNTPConnectionManagement: "TP.CM",
+	PGNAddressClaimed:         "Address Claimed",
+	PGNProprietaryA:           "Proprietary A",
+	PGNCommandedAddress:       "Commanded Address",
+}
+
+// PGNName returns the name of a network management or proprietary PGN, "" for the others.
+func PGNName(pgn uint32) string {
+	if name, ok := pgnNames[pgn]; ok {
+		return name
+	}
+	if pgn&^0xFF == PGNProprietaryB {
+		return "Proprietary B"
+	}
+	return ""
+}
+
+// ID is a J1939 29-bit CAN ID split into its fields.
+type ID struct {
+	Priority    uint8
+	PGN         uint32 // parameter group number, without the destination of the PDU1 ones
+	Source      uint8
+	Destination uint8 // GlobalAddress for the PDU2 (broadcast) PGNs
+}
+
+// ParseID splits an extended CAN ID into priority, PGN, source and destination.
+func ParseID(canID uint32) ID {
+	id := ID{
+		Priority: uint8(canID>>26) & maxPriority,
+		PGN:      canID >> 8 & maxPGN,
+		Source:   uint8(canID),
+	}
+	if IsPDU1(id.PGN) {
+		id.Destination = uint8(id.PGN)
+		id.PGN &^= 0xFF
+	} else {
+		id.Destination = GlobalAddress
+	}
+	return id
+}
+
+// PGN returns the parameter group number of an extended CAN ID.
+func PGN(canID uint32) uint32 {
+	return ParseID(canID).PGN
+}
+
+// IsPDU1 tells whether the PGN is addressed to a destination (PDU format below 240).
+func IsPDU1(pgn uint32) bool {
+	return pgn>>8&0xFF < pdu2Format
+}
+
+// CANID returns the extended CAN ID of the fields.
+func (id ID) CANID() uint32 {
+	canID := uint32(id.Priority&maxPriority)<<26 | (id.PGN&maxPGN)<<8 | uint32(id.Source)
+	if IsPDU1(id.PGN) {
+		canID = canID&^0xFF00 | uint32(id.Destination)<<8
+	}
+	return canID
+}
+
+// String formats the fields as "P6 PGN 0xFEF1 0x00→global".
+func (id ID) String() string {
+	return fmt.Sprintf("P%d PGN 0x%04X %s→%s", id.Priority, id.PGN, FormatAddress(id.Source), FormatAddress(id.Destination))
+}
+
+// FormatAddress formats a source or destination address as hex, with the special ones by name.
+func FormatAddress(address uint8) string {
+	switch address {
+	case GlobalAddress:
+		return "global"
+	case NullAddress:
+		return "null"
+	}
+	return fmt.Sprintf("0x%02X", address)
+}
@@ -0,0 +1,200 @@
+package j1939
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// TestParseID checks the fields of PDU1 and PDU2 IDs and their round trip
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		canID uint32
+		want  ID
+	}{
+		{0x18EA3DF9, ID{Priority: 6, PGN: PGNRequest, Source: 0xF9, Destination: 0x3D}},
+		{0x18EEFF80, ID{Priority: 6, PGN: PGNAddressClaimed, Source: 0x80, Destination: GlobalAddress}},
+		{0x0CFEF100, ID{Priority: 3, PGN: 0xFEF1, Source: 0x00, Destination: GlobalAddress}},
+		{0x1CECFF00, ID{Priority: 7, PGN: PGNTPConnectionManagement, Source: 0x00, Destination: GlobalAddress}},
+		{0x18FF1234, ID{Priority: 6, PGN: 0xFF12, Source: 0x34, Destination: GlobalAddress}},
+		{0x19FECA21, ID{Priority: 6, PGN: 0x1FECA, Source: 0x21, Destination: GlobalAddress}},       // data page 1
+		{0x1BEF0A21, ID{Priority: 6, PGN: 0x3EF00, Source: 0x21, Destination: 0x0A}},                // extended data page
+		{0x00EFF0FE, ID{Priority: 0, PGN: PGNProprietaryA, Source: NullAddress, Destination: 0xF0}}, // PDU1 to 0xF0
+	}
+	for _, tt := range tests {
+		id := ParseID(tt.canID)
+		if id != tt.want {
+			t.Errorf("ParseID(0x%08X) = %+v, want %+v", tt.canID, id, tt.want)
+		}
+		if PGN(tt.canID) != tt.want.PGN {
+			t.Errorf("PGN(0x%08X) = 0x%04X, want 0x%04X", tt.canID, PGN(tt.canID), tt.want.PGN)
+		}
+		if got := id.CANID(); got != tt.canID {
+			t.Errorf("CANID() of %+v = 0x%08X, want 0x%08X", id, got, tt.canID)
+		}
+	}
+
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for range 10000 {
+		canID := rnd.Uint32() & 0x1FFFFFFF
+		if got := ParseID(canID).CANID(); got != canID {
+			t.Fatalf("round trip of 0x%08X gives 0x%08X", canID, got)
+		}
+	}
+}
+
+// TestIDString checks the formatting of the IDs and the names of the PGNs
+func TestIDString(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want string
+	}{
+		{ID{Priority: 6, PGN: 0xFEF1, Source: 0x00, Destination: GlobalAddress}, "P6 PGN 0xFEF1 0x00→global"},
+		{ID{Priority: 6, PGN: PGNAddressClaimed, Source: NullAddress, Destination: GlobalAddress}, "P6 PGN 0xEE00 null→global"},
+		{ID{Priority: 7, PGN: PGNTPDataTransfer, Source: 0xF9, Destination: 0x3D}, "P7 PGN 0xEB00 0xF9→0x3D"},
+	}
+	for _, tt := range tests {
+		if got := tt.id.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+
+	names := map[uint32]string{
+		PGNRequest: "Request",
+		0xFF00:     "Proprietary B",
+		0xFFFF:     "Proprietary B",
+		0x1FF00:    "",
+		0xFEF1:     "",
+	}
+	for pgn, want := range names {
+		if got := PGNName(pgn); got != want {
+			t.Errorf("PGNName(0x%04X) = %q, want %q", pgn, got, want)
+		}
+	}
+}
+
+// testName returns the address claim data of a NAME
+func testName(name Name) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(name))
+}
+
+// TestParseName checks the fields of a NAME
+func TestParseName(t *testing.T) {
+	// Arbitrary address capable, industry group 2, vehicle system instance 9, vehicle system 0x55,
+	// function 0x81, function instance 0x13, ECU instance 5, manufacturer 0x2AB, identity 0x12345
+	want := Name(1<<63 | 2<<60 | 9<<56 | 0x55<<49 | 0x81<<40 | 0x13<<35 | 5<<32 | 0x2AB<<21 | 0x12345)
+
+	name, err := ParseName(append(testName(want), 0xFF))
+	if err != nil || name != want {
+		t.Fatalf("ParseName() = %v, %v, want %v", name, err, want)
+	}
+	fields := []struct {
+		field     string
+		got, want uint64
+	}{
+		{"IdentityNumber", uint64(name.IdentityNumber()), 0x12345},
+		{"ManufacturerCode", uint64(name.ManufacturerCode()), 0x2AB},
+		{"ECUInstance", uint64(name.ECUInstance()), 5},
+		{"FunctionInstance", uint64(name.FunctionInstance()), 0x13},
+		{"Function", uint64(name.Function()), 0x81},
+		{"VehicleSystem", uint64(name.VehicleSystem()), 0x55},
+		{"VehicleSystemInstance", uint64(name.VehicleSystemInstance()), 9},
+		{"IndustryGroup", uint64(name.IndustryGroup()), 2},
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			t.Errorf("%s() = 0x%X, want 0x%X", f.field, f.got, f.want)
+		}
+	}
+	if !name.ArbitraryAddressCapable() {
+		t.Error("not arbitrary address capable")
+	}
+	if s := name.String(); s != "A9AA819D55612345" {
+		t.Errorf("String() = %q, want %q", s, "A9AA819D55612345")
+	}
+
+	if _, err := ParseName([]byte{1, 2, 3}); err == nil {
+		t.Error("NAME of 3 bytes parsed")
+	}
+}
+
+// claim returns the ID of an address claim from the address
+func claim(address uint8) ID {
+	return ID{Priority: 6, PGN: PGNAddressClaimed, Source: address, Destination: GlobalAddress}
+}
+
+// TestAddressTable checks the claims, the contention for an address and the addresses seen without a claim
+func TestAddressTable(t *testing.T) {
+	const (
+		high Name = 0x200
+		low  Name = 0x100
+	)
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		order []Name // claims of the address 0x80, in order
+	}{
+		{"lower NAME claims later", []Name{high, low}},
+		{"lower NAME claims first", []Name{low, high}},
+		{"lower NAME claims again", []Name{low, high, low}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := NewAddressTable()
+			for i, name := range tt.order {
+				if !table.Observe(start.Add(time.Duration(i)*time.Millisecond), claim(0x80), testName(name)) {
+					t.Fatal("address claim not recognized")
+				}
+			}
+			// Traffic of the owner of 0x80 and of a node that never claimed
+			data := ID{Priority: 3, PGN: 0xFEF1, Source: 0x80, Destination: GlobalAddress}
+			table.Observe(start.Add(time.Second), data, make([]byte, 8))
+			data.Source = 0x21
+			if table.Observe(start.Add(time.Second), data, make([]byte, 8)) {
+				t.Fatal("data frame taken as an address claim")
+			}
+
+			nodes := table.Nodes()
+			if len(nodes) != 3 {
+				t.Fatalf("%d nodes, want 3: %+v", len(nodes), nodes)
+			}
+			unclaimed, winner, loser := nodes[0], nodes[1], nodes[2]
+			if unclaimed.Address != 0x21 || unclaimed.Claimed() || unclaimed.Frames != 1 {
+				t.Errorf("node 0x21 = %+v, want 1 frame without a claim", unclaimed)
+			}
+			if winner.Name != low || winner.Lost || winner.Address != 0x80 || winner.Frames != uint64(len(tt.order)+1) {
+				t.Errorf("winner = %+v, want NAME %v keeping 0x80 with its frames", winner, low)
+			}
+			if loser.Name != high || !loser.Lost || loser.Frames != 0 {
+				t.Errorf("loser = %+v, want NAME %v lost", loser, high)
+			}
+		})
+	}
+
+	t.Run("cannot claim", func(t *testing.T) {
+		table := NewAddressTable()
+		table.Observe(start, claim(0x80), testName(low))
+		table.Observe(start, claim(NullAddress), testName(high))
+		// The node that could not claim 0x80 claims another address afterwards
+		table.Observe(start, claim(0x81), testName(high))
+		table.Observe(start, claim(0x82), []byte{1, 2})
+
+		// The malformed claim only counts as a frame of 0x82
+		nodes := table.Nodes()
+		if len(nodes) != 3 || nodes[1].Name != high || nodes[1].Address != 0x81 || nodes[1].Lost || nodes[1].Claims != 2 {
+			t.Fatalf("nodes %+v, want %v at 0x81 after 2 claims", nodes, high)
+		}
+		if nodes[2].Address != 0x82 || nodes[2].Claimed() {
+			t.Fatalf("node %+v, want 0x82 without a claim", nodes[2])
+		}
+
+		table.Observe(start, claim(NullAddress), testName(high))
+		nodes = table.Nodes()
+		last := nodes[len(nodes)-1]
+		if last.Name != high || last.Address != NullAddress || last.Lost {
+			t.Fatalf("node %+v, want %v at the null address", last, high)
+		}
+	})
+}
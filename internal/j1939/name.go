@@ -0,0 +1,50 @@
+package j1939
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Name is the 64-bit NAME of a node, sent in its address claims. When two nodes claim
+// the same address the one with the lower NAME keeps it.
+type Name uint64
+
+// ParseName reads a NAME from the data of an address claim (8 bytes, little endian).
+func ParseName(data []byte) (Name, error) {
+	if len(data) < 8 {
+		return 0, fmt.Errorf("NAME of %d bytes instead of 8", len(data))
+	}
+	return Name(binary.LittleEndian.Uint64(data)), nil
+}
+
+// IdentityNumber is the serial number of the node (21 bits).
+func (n Name) IdentityNumber() uint32 { return uint32(n) & 0x1FFFFF }
+
+// ManufacturerCode is the manufacturer assigned by SAE (11 bits).
+func (n Name) ManufacturerCode() uint16 { return uint16(n>>21) & 0x7FF }
+
+// ECUInstance tells the ECUs of a node apart (3 bits).
+func (n Name) ECUInstance() uint8 { return uint8(n>>32) & 0x07 }
+
+// FunctionInstance tells the nodes with the same function apart (5 bits).
+func (n Name) FunctionInstance() uint8 { return uint8(n>>35) & 0x1F }
+
+// Function is what the node does, e.g. 0 engine, its meaning above 127 depends on the vehicle system.
+func (n Name) Function() uint8 { return uint8(n >> 40) }
+
+// VehicleSystem is the part of the vehicle the node belongs to (7 bits).
+func (n Name) VehicleSystem() uint8 { return uint8(n>>49) & 0x7F }
+
+// VehicleSystemInstance tells the vehicle systems of the same type apart (4 bits).
+func (n Name) VehicleSystemInstance() uint8 { return uint8(n>>56) & 0x0F }
+
+// IndustryGroup is the industry of the vehicle system, e.g. 1 on-highway, 2 agricultural (3 bits).
+func (n Name) IndustryGroup() uint8 { return uint8(n>>60) & 0x07 }
+
+// ArbitraryAddressCapable tells whether the node can claim another address after losing its own.
+func (n Name) ArbitraryAddressCapable() bool { return n>>63 != 0 }
+
+// String formats the NAME as 16 hex digits.
+func (n Name) String() string {
+	return fmt.Sprintf("%016X", uint64(n))
+}
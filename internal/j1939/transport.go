@@ -0,0 +1,211 @@
+package j1939
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Control bytes of the TP.CM messages
+const (
+	tpRTS         = 16
+	tpCTS         = 17
+	tpEndOfMsgAck = 19
+	tpBAM         = 32
+	tpAbort       = 255
+)
+
+const (
+	// MaxTransportSize is the longest message of the transport protocol: 255 packets of 7 bytes
+	MaxTransportSize = 1785
+	// DefaultTransportTimeout is how long a transfer waits for its next packet (T1/T2 of J1939-21)
+	DefaultTransportTimeout = 1250 * time.Millisecond
+	// packetSize is the number of bytes of a message in each TP.DT
+	packetSize = 7
+)
+
+var (
+	ErrTransportAborted    = errors.New("transfer aborted")
+	ErrTransportSequence   = errors.New("packet out of sequence")
+	ErrTransportTimeout    = errors.New("transfer timed out")
+	ErrTransportIncomplete = errors.New("transfer restarted before its end")
+)
+
+// Message is a message reassembled from the packets of the transport protocol.
+type Message struct {
+	ID    ID   // PGN transported, sender and receiver (GlobalAddress for BAM)
+	BAM   bool // broadcast, false for the RTS/CTS transfers
+	Data  []byte
+	Start time.Time // announcement of the transfer
+	End   time.Time // last packet
+}
+
+// transfer is a multi-packet message being received
+type transfer struct {
+	id      ID
+	bam     bool
+	size    int
+	packets int
+	data    []byte
+	next    int // sequence number of the next packet
+	start   time.Time
+	last    time.Time
+}
+
+// sessionKey identifies the transfers: only one at a time goes from a sender to a receiver
+type sessionKey struct {
+	source, destination uint8
+}
+
+// Reassembler follows the BAM and RTS/CTS transfers seen on the bus and rebuilds their messages.
+// It only listens: the CTS and acknowledgements come from the receivers. It is not safe for concurrent use.
+type Reassembler struct {
+	Timeout   time.Duration // DefaultTransportTimeout if 0
+	transfers map[sessionKey]*transfer
+}
+
+// NewReassembler returns a reassembler without transfers in progress.
+func NewReassembler() *Reassembler {
+	return &Reassembler{transfers: make(map[sessionKey]*transfer)}
+}
+
+// Handle processes a frame received at the given time: it returns the message completed by it (nil if none),
+// the error reports the transfers aborted, timed out or broken by a packet out of sequence.
+// Every frame drops the transfers that waited too long for their next packet, reporting their timeout.
+func (r *Reassembler) Handle(at time.Time, id ID, data []byte) (*Message, error) {
+	expired := r.prune(at)
+
+	var msg *Message
+	var err error
+	switch id.PGN {
+	case PGNTPConnectionManagement:
+		if len(data) >= 8 {
+			err = r.handleControl(at, id, data)
+		}
+	case PGNTPDataTransfer:
+		if len(data) >= 2 {
+			msg, err = r.handleData(at, id, data)
+		}
+	}
+	return msg, errors.Join(append(expired, err)...)
+}
+
+// prune drops the transfers that expired, it returns their timeouts sorted by sender and receiver
+func (r *Reassembler) prune(at time.Time) []error {
+	var keys []sessionKey
+	for key, t := range r.transfers {
+		if r.expired(t, at) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b sessionKey) int {
+		if c := cmp.Compare(a.source, b.source); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.destination, b.destination)
+	})
+
+	errs := make([]error, 0, len(keys))
+	for _, key := range keys {
+		t := r.transfers[key]
+		delete(r.transfers, key)
+		errs = append(errs, fmt.Errorf("PGN 0x%04X from %s: %w after %d of %d packets", t.id.PGN, FormatAddress(key.source), ErrTransportTimeout, t.next-1, t.packets))
+	}
+	return errs
+}
+
+// handleControl starts, paces and ends the transfers
+func (r *Reassembler) handleControl(at time.Time, id ID, data []byte) error {
+	pgn := uint32(data[5]) | uint32(data[6])<<8 | uint32(data[7])<<16
+	// The CTS, acknowledgements and aborts of the receivers go the other way
+	reverse := sessionKey{source: id.Destination, destination: id.Source}
+
+	switch data[0] {
+	case tpRTS, tpBAM:
+		key := sessionKey{source: id.Source, destination: id.Destination}
+		bam := data[0] == tpBAM
+		if bam {
+			key.destination = GlobalAddress
+		}
+		size := int(binary.LittleEndian.Uint16(data[1:3]))
+		packets := int(data[3])
+		if size <= 8 || size > MaxTransportSize || packets != (size+packetSize-1)/packetSize {
+			return fmt.Errorf("PGN 0x%04X from %s: invalid announcement of %d bytes in %d packets", pgn, FormatAddress(id.Source), size, packets)
+		}
+
+		var err error
+		if old, ok := r.transfers[key]; ok {
+			err = fmt.Errorf("PGN 0x%04X from %s: %w (%d of %d packets)", old.id.PGN, FormatAddress(id.Source), ErrTransportIncomplete, old.next-1, old.packets)
+		}
+		r.transfers[key] = &transfer{
+			id:      ID{Priority: id.Priority, PGN: pgn, Source: id.Source, Destination: key.destination},
+			bam:     bam,
+			size:    size,
+			packets: packets,
+			data:    make([]byte, packets*packetSize),
+			next:    1,
+			start:   at,
+			last:    at,
+		}
+		return err
+
+	case tpCTS:
+		if t, ok := r.transfers[reverse]; ok && !t.bam {
+			if data[1] > 0 {
+				// The receiver can ask again for packets already sent
+				t.next = int(data[2])
+			}
+			t.last = at
+		}
+
+	case tpEndOfMsgAck:
+		// The message is complete at its last packet
+		delete(r.transfers, reverse)
+
+	case tpAbort:
+		key := sessionKey{source: id.Source, destination: id.Destination}
+		if _, ok := r.transfers[key]; !ok {
+			key = reverse
+		}
+		if _, ok := r.transfers[key]; ok {
+			delete(r.transfers, key)
+			return fmt.Errorf("PGN 0x%04X from %s: %w by %s (reason %d)", pgn, FormatAddress(key.source), ErrTransportAborted, FormatAddress(id.Source), data[1])
+		}
+	}
+	return nil
+}
+
+// handleData adds a packet to its transfer
+func (r *Reassembler) handleData(at time.Time, id ID, data []byte) (*Message, error) {
+	key := sessionKey{source: id.Source, destination: id.Destination}
+	t, ok := r.transfers[key]
+	if !ok {
+		return nil, nil // transfer started before listening, or expired
+	}
+	seq := int(data[0])
+	if seq != t.next || seq > t.packets {
+		delete(r.transfers, key)
+		return nil, fmt.Errorf("PGN 0x%04X from %s: %w (%d instead of %d)", t.id.PGN, FormatAddress(id.Source), ErrTransportSequence, seq, t.next)
+	}
+	copy(t.data[(seq-1)*packetSize:seq*packetSize], data[1:])
+	t.next++
+	t.last = at
+
+	if seq < t.packets {
+		return nil, nil
+	}
+	delete(r.transfers, key)
+	return &Message{ID: t.id, BAM: t.bam, Data: t.data[:t.size], Start: t.start, End: at}, nil
+}
+
+// expired tells whether the transfer waited too long for its next packet
+func (r *Reassembler) expired(t *transfer, at time.Time) bool {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTransportTimeout
+	}
+	return at.Sub(t.last) > timeout
+}
@@ -0,0 +1,255 @@
+package j1939
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Addresses and PGN of the transfers of the tests
+const (
+	testSender   = 0x00
+	testReceiver = 0x3D
+	testPGN      = 0xFECA // DM1
+)
+
+// step is a frame seen by the reassembler, after the previous one
+type step struct {
+	after time.Duration
+	id    ID
+	data  []byte
+}
+
+// testPayload returns a message of n bytes
+func testPayload(n int) []byte {
+	payload := make([]byte, n)
+	for i := range payload {
+		payload[i] = byte(i + 1)
+	}
+	return payload
+}
+
+// control returns a TP.CM frame, the PGN transported is testPGN
+func control(source, destination uint8, b0, b1, b2, b3, b4 byte) step {
+	pgn := uint32(testPGN)
+	return step{
+		id:   ID{Priority: 7, PGN: PGNTPConnectionManagement, Source: source, Destination: destination},
+		data: []byte{b0, b1, b2, b3, b4, byte(pgn), byte(pgn >> 8), byte(pgn >> 16)},
+	}
+}
+
+// bam announces a broadcast of size bytes from the source
+func bam(source uint8, size int) step {
+	return control(source, GlobalAddress, tpBAM, byte(size), byte(size>>8), byte((size+6)/7), 0xFF)
+}
+
+// rts announces a transfer of size bytes from testSender to testReceiver
+func rts(size int) step {
+	return control(testSender, testReceiver, tpRTS, byte(size), byte(size>>8), byte((size+6)/7), 0xFF)
+}
+
+// cts asks testSender for n packets from next
+func cts(n, next byte) step {
+	return control(testReceiver, testSender, tpCTS, n, next, 0xFF, 0xFF)
+}
+
+// ack acknowledges the transfer of size bytes to testSender
+func ack(size int) step {
+	return control(testReceiver, testSender, tpEndOfMsgAck, byte(size), byte(size>>8), byte((size+6)/7), 0xFF)
+}
+
+// abort aborts the transfer between testSender and testReceiver from the source
+func abort(source, destination uint8) step {
+	return control(source, destination, tpAbort, 3, 0xFF, 0xFF, 0xFF)
+}
+
+// packet returns the TP.DT of the packet seq of the payload, padded with 0xFF
+func packet(source, destination uint8, seq int, payload []byte) step {
+	data := append([]byte{byte(seq)}, bytes.Repeat([]byte{0xFF}, packetSize)...)
+	copy(data[1:], payload[min(len(payload), (seq-1)*packetSize):min(len(payload), seq*packetSize)])
+	return step{
+		id:   ID{Priority: 7, PGN: PGNTPDataTransfer, Source: source, Destination: destination},
+		data: data,
+	}
+}
+
+// later delays a step
+func later(d time.Duration, s step) step {
+	s.after = d
+	return s
+}
+
+// TestReassembler checks the BAM and RTS/CTS transfers, and the ones broken or restarted
+func TestReassembler(t *testing.T) {
+	payload := testPayload(20) // 3 packets
+	broadcast := ID{Priority: 7, PGN: testPGN, Source: testSender, Destination: GlobalAddress}
+	toReceiver := ID{Priority: 7, PGN: testPGN, Source: testSender, Destination: testReceiver}
+	dt := func(seq int) step { return packet(testSender, testReceiver, seq, payload) }
+	bdt := func(seq int) step { return packet(testSender, GlobalAddress, seq, payload) }
+	other := step{id: ID{Priority: 3, PGN: 0xFEF1, Source: 0x21, Destination: GlobalAddress}, data: make([]byte, 8)}
+
+	tests := []struct {
+		name     string
+		steps    []step
+		messages []ID    // messages completed, with the payload
+		errs     []error // in order
+	}{
+		{
+			name:     "BAM",
+			steps:    []step{bam(testSender, 20), bdt(1), bdt(2), later(50*time.Millisecond, bdt(3))},
+			messages: []ID{broadcast},
+		},
+		{
+			name:     "RTS/CTS",
+			steps:    []step{rts(20), cts(2, 1), dt(1), dt(2), cts(1, 3), dt(3), ack(20)},
+			messages: []ID{toReceiver},
+		},
+		{
+			name:     "CTS asking again",
+			steps:    []step{rts(20), cts(2, 1), dt(1), dt(2), cts(2, 2), dt(2), dt(3), ack(20)},
+			messages: []ID{toReceiver},
+		},
+		{
+			name:     "CTS holding the transfer",
+			steps:    []step{rts(20), cts(3, 1), dt(1), later(time.Second, cts(0, 0xFF)), later(time.Second, cts(2, 2)), dt(2), dt(3)},
+			messages: []ID{toReceiver},
+		},
+		{
+			name:  "aborted by the receiver",
+			steps: []step{rts(20), cts(2, 1), dt(1), abort(testReceiver, testSender), dt(2), dt(3)},
+			errs:  []error{ErrTransportAborted},
+		},
+		{
+			name:  "aborted by the sender",
+			steps: []step{rts(20), cts(2, 1), dt(1), abort(testSender, testReceiver)},
+			errs:  []error{ErrTransportAborted},
+		},
+		{
+			name:  "out of sequence",
+			steps: []step{bam(testSender, 20), bdt(1), bdt(3), bdt(2)},
+			errs:  []error{ErrTransportSequence},
+		},
+		{
+			name:  "packet past the end",
+			steps: []step{rts(20), cts(4, 1), dt(1), dt(2), dt(3), dt(4)},
+			// The third packet completes the message, the fourth has no transfer
+			messages: []ID{toReceiver},
+		},
+		{
+			name:  "timeout at the next packet",
+			steps: []step{bam(testSender, 20), bdt(1), later(DefaultTransportTimeout+time.Millisecond, bdt(2)), bdt(3)},
+			errs:  []error{ErrTransportTimeout},
+		},
+		{
+			name:  "timeout at another frame",
+			steps: []step{bam(testSender, 20), bam(0x21, 20), bdt(1), later(2*time.Second, other), bdt(2)},
+			errs:  []error{ErrTransportTimeout, ErrTransportTimeout},
+		},
+		{
+			name:     "restarted before the end",
+			steps:    []step{bam(testSender, 20), bdt(1), bam(testSender, 20), bdt(1), bdt(2), bdt(3)},
+			messages: []ID{broadcast},
+			errs:     []error{ErrTransportIncomplete},
+		},
+		{
+			name:     "restarted after the timeout",
+			steps:    []step{bam(testSender, 20), bdt(1), later(2*time.Second, bam(testSender, 20)), bdt(1), bdt(2), bdt(3)},
+			messages: []ID{broadcast},
+			errs:     []error{ErrTransportTimeout},
+		},
+		{
+			name:     "started before listening",
+			steps:    []step{bdt(2), bdt(3), dt(1)},
+			messages: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReassembler()
+			at := time.Now()
+			var messages []*Message
+			var errs []error
+			for _, s := range tt.steps {
+				at = at.Add(s.after)
+				msg, err := r.Handle(at, s.id, s.data)
+				if msg != nil {
+					messages = append(messages, msg)
+				}
+				if joined, ok := err.(interface{ Unwrap() []error }); ok {
+					errs = append(errs, joined.Unwrap()...)
+				} else if err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			if len(messages) != len(tt.messages) {
+				t.Fatalf("%d messages, want %d", len(messages), len(tt.messages))
+			}
+			for i, msg := range messages {
+				if msg.ID != tt.messages[i] || msg.BAM != (msg.ID.Destination == GlobalAddress) || !bytes.Equal(msg.Data, payload) {
+					t.Errorf("message %+v", msg)
+				}
+			}
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("errors %v, want %v", errs, tt.errs)
+			}
+			for i, err := range errs {
+				if !errors.Is(err, tt.errs[i]) {
+					t.Errorf("error %d = %v, want %v", i, err, tt.errs[i])
+				}
+			}
+			if len(r.transfers) != 0 {
+				t.Errorf("%d transfers left", len(r.transfers))
+			}
+		})
+	}
+}
+
+// TestReassemblerAnnouncements checks the limits of the announcements of the transfers
+func TestReassemblerAnnouncements(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		packets byte
+		valid   bool
+	}{
+		{"9 bytes", 9, 2, true},
+		{"longest", MaxTransportSize, 255, true},
+		{"single frame", 8, 2, false},
+		{"too long", MaxTransportSize + 1, 255, false},
+		{"wrong packets", 20, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReassembler()
+			s := bam(testSender, tt.size)
+			s.data[3] = tt.packets
+
+			_, err := r.Handle(time.Now(), s.id, s.data)
+			if valid := err == nil && len(r.transfers) == 1; valid != tt.valid {
+				t.Fatalf("announcement of %d bytes in %d packets: %v, want valid %v", tt.size, tt.packets, err, tt.valid)
+			}
+		})
+	}
+
+	// The longest message, through every sequence number
+	r := NewReassembler()
+	payload := testPayload(MaxTransportSize)
+	s := bam(testSender, MaxTransportSize)
+	at := time.Now()
+	r.Handle(at, s.id, s.data)
+	var msg *Message
+	for seq := 1; seq <= 255; seq++ {
+		s := packet(testSender, GlobalAddress, seq, payload)
+		at = at.Add(50 * time.Millisecond)
+		var err error
+		if msg, err = r.Handle(at, s.id, s.data); err != nil {
+			t.Fatalf("packet %d: %v", seq, err)
+		}
+	}
+	if msg == nil || !bytes.Equal(msg.Data, payload) {
+		t.Fatal("longest message not reassembled")
+	}
+}
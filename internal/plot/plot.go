@@ -0,0 +1,173 @@
+// Package plot draws line charts in the terminal with braille characters,
+// every character cell holds a grid of 2x4 dots.
+package plot
+
+import (
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// braille dots of a cell, indexed by [row][column] of the dot
+var dotBits = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// brailleBase is the empty braille character, the dots are added to it
+const brailleBase = 0x2800
+
+// Point is a point of a series, in the units of the chart ranges.
+type Point struct {
+	X, Y float64
+}
+
+// Series is a line of the chart, drawn with its style (e.g. its color).
+type Series struct {
+	Points []Point
+	Style  lipgloss.Style
+}
+
+// Chart is a line chart of Width x Height character cells showing
+// the points within [XMin, XMax] and [YMin, YMax].
+type Chart struct {
+	Width, Height int
+	XMin, XMax    float64
+	YMin, YMax    float64
+}
+
+// Render draws the series, consecutive points are joined by a line.
+// Where the series overlap the cell takes the style of the last one.
+// It returns the Height lines of the chart, each Width cells wide.
+func (c Chart) Render(series ...Series) []string {
+	width, height := max(c.Width, 1), max(c.Height, 1)
+	cells := make([][]rune, height)
+	owners := make([][]int, height)
+	for y := range cells {
+		cells[y] = make([]rune, width)
+		owners[y] = make([]int, width)
+		for x := range owners[y] {
+			owners[y][x] = -1
+		}
+	}
+
+	set := func(dx, dy, owner int) {
+		cx, cy := dx/2, dy/4
+		cells[cy][cx] |= dotBits[dy%4][dx%2]
+		owners[cy][cx] = owner
+	}
+
+	for i, s := range series {
+		prevX, prevY := -1, -1
+		for _, p := range s.Points {
+			dx, dy, ok := c.dot(p, width, height)
+			if !ok {
+				prevX, prevY = -1, -1
+				continue
+			}
+			if prevX < 0 {
+				set(dx, dy, i)
+			} else {
+				line(prevX, prevY, dx, dy, func(x, y int) { set(x, y, i) })
+			}
+			prevX, prevY = dx, dy
+		}
+	}
+
+	lines := make([]string, height)
+	for y := range cells {
+		var b strings.Builder
+		run := []rune{}
+		owner := -1
+		flush := func() {
+			if len(run) == 0 {
+				return
+			}
+			if owner >= 0 {
+				b.WriteString(series[owner].Style.Render(string(run)))
+			} else {
+				b.WriteString(string(run))
+			}
+			run = run[:0]
+		}
+
+		for x, bits := range cells[y] {
+			if owners[y][x] != owner {
+				flush()
+				owner = owners[y][x]
+			}
+			if bits == 0 {
+				run = append(run, ' ')
+			} else {
+				run = append(run, brailleBase+bits)
+			}
+		}
+		flush()
+		lines[y] = b.String()
+	}
+
+	return lines
+}
+
+// dot returns the position of the dot of a point, false if it is not a number.
+// Points out of the ranges are clamped to the border of the chart
+func (c Chart) dot(p Point, width, height int) (int, int, bool) {
+	if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.Y, 0) {
+		return 0, 0, false
+	}
+
+	dotsX, dotsY := width*2, height*4
+	dx := scale(p.X, c.XMin, c.XMax, dotsX)
+	// The y axis grows upwards
+	dy := dotsY - 1 - scale(p.Y, c.YMin, c.YMax, dotsY)
+	return dx, dy, true
+}
+
+// scale maps v in [lo, hi] to one of n positions, a point range maps to the middle
+func scale(v, lo, hi float64, n int) int {
+	if hi <= lo {
+		return n / 2
+	}
+	pos := int(math.Round((v - lo) / (hi - lo) * float64(n-1)))
+	return max(0, min(pos, n-1))
+}
+
+// line calls set for every dot of the segment from (x0, y0) to (x1, y1) (Bresenham's algorithm)
+func line(x0, y0, x1, y1 int, set func(x, y int)) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		set(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,102 @@
+package plot
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// TestRender checks the braille characters drawn for the series
+func TestRender(t *testing.T) {
+	nan := math.NaN()
+
+	tests := []struct {
+		name   string
+		chart  Chart
+		points []Point
+		want   []string
+	}{
+		{
+			// The line at mid height is on the bottom row of dots of the first cell row
+			name:   "flat",
+			chart:  Chart{Width: 4, Height: 2, XMax: 3, YMax: 10},
+			points: []Point{{0, 5}, {1, 5}, {2, 5}, {3, 5}},
+			want:   []string{"⣀⣀⣀⣀", "    "},
+		},
+		{
+			name:   "ramp",
+			chart:  Chart{Width: 2, Height: 1, XMax: 3, YMax: 3},
+			points: []Point{{0, 0}, {3, 3}},
+			want:   []string{"⡠⠊"},
+		},
+		{
+			name:   "ramp on two rows",
+			chart:  Chart{Width: 1, Height: 2, XMax: 1, YMax: 7},
+			points: []Point{{0, 0}, {1, 7}},
+			want:   []string{"⢸", "⡇"},
+		},
+		{
+			name:   "joined",
+			chart:  Chart{Width: 2, Height: 1, XMax: 3, YMax: 1},
+			points: []Point{{0, 0}, {3, 0}},
+			want:   []string{"⣀⣀"},
+		},
+		{
+			// A value that is not a number breaks the line
+			name:   "NaN",
+			chart:  Chart{Width: 2, Height: 1, XMax: 3, YMax: 1},
+			points: []Point{{0, 0}, {2, nan}, {3, 0}},
+			want:   []string{"⡀⢀"},
+		},
+		{
+			name:   "only NaN and infinity",
+			chart:  Chart{Width: 2, Height: 1, XMax: 1, YMax: 1},
+			points: []Point{{nan, 0}, {0, nan}, {1, math.Inf(1)}},
+			want:   []string{"  "},
+		},
+		{
+			name:  "empty",
+			chart: Chart{Width: 3, Height: 2, XMax: 1, YMax: 1},
+			want:  []string{"   ", "   "},
+		},
+		{
+			// Points out of the ranges are clamped to the borders
+			name:   "clamped",
+			chart:  Chart{Width: 1, Height: 1, XMax: 1, YMax: 1},
+			points: []Point{{-5, 10}},
+			want:   []string{"⠁"},
+		},
+		{
+			// A range of a single value maps to the middle
+			name:   "point range",
+			chart:  Chart{Width: 1, Height: 1, XMin: 1, XMax: 1, YMin: 2, YMax: 2},
+			points: []Point{{1, 2}},
+			want:   []string{"⠐"},
+		},
+		{
+			name:  "no size",
+			chart: Chart{},
+			want:  []string{" "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.chart.Render(Series{Points: tt.points})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderSeries checks that every series is drawn on the same chart
+func TestRenderSeries(t *testing.T) {
+	chart := Chart{Width: 2, Height: 1, XMax: 1, YMax: 1}
+	got := chart.Render(
+		Series{Points: []Point{{0, 0}}},
+		Series{Points: []Point{{1, 1}}},
+	)
+	if want := []string{"⡀⠈"}; !slices.Equal(got, want) {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package replay
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+)
+
+// IDSet is a set of CAN IDs, keyed by [canDebug.Key] so that standard and extended IDs are kept apart.
+type IDSet map[uint32]struct{}
+
+// ParseIDSet parses a list of hex CAN IDs separated by commas or spaces (e.g. "100, 1A0 0x18FF0010").
+// Like candump, IDs written with more than 3 hex digits are extended (e.g. 00000100), as are the ones not fitting in 11 bits.
+func ParseIDSet(s string) (IDSet, error) {
+	set := make(IDSet)
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == ';'
+	})
+	for _, field := range fields {
+		field = strings.TrimPrefix(strings.ToLower(field), "0x")
+		id, err := strconv.ParseUint(field, 16, 32)
+		if err != nil || id > canDebug.MaxExtendedID {
+			return nil, fmt.Errorf("invalid CAN ID %q (use hex IDs, e.g. 1A0)", field)
+		}
+		extended := len(field) > 3 || id > canDebug.MaxStandardID
+		set[canDebug.Key(uint32(id), extended)] = struct{}{}
+	}
+
+	return set, nil
+}
+
+// String returns the IDs of the set as sorted hex values, extended IDs with 8 digits.
+func (s IDSet) String() string {
+	keys := make([]uint32, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	strs := make([]string, len(keys))
+	for i, key := range keys {
+		if key&canDebug.ExtendedFlag != 0 {
+			strs[i] = fmt.Sprintf("%08X", key&canDebug.MaxExtendedID)
+		} else {
+			strs[i] = fmt.Sprintf("%X", key)
+		}
+	}
+	return strings.Join(strs, ",")
+}
+
+// Filter selects the frames to replay by their ID.
+type Filter struct {
+	Include IDSet // if not empty, only these IDs are replayed
+	Exclude IDSet // these IDs are never replayed
+}
+
+// Allows reports whether a frame with the given ID passes the filter.
+func (f Filter) Allows(id uint32, extended bool) bool {
+	key := canDebug.Key(id, extended)
+	if _, ok := f.Exclude[key]; ok {
+		return false
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	_, ok := f.Include[key]
+	return ok
+}
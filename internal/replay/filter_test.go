@@ -0,0 +1,146 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+)
+
+// TestParseIDSet checks the parsing of the ID lists, written back by String
+func TestParseIDSet(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // String of the set, "" for an error
+	}{
+		{"", ""},
+		{"100, 1A0 0x7ff", "100,1A0,7FF"},
+		{"1a0;1A0", "1A0"},
+		{"00000100 100", "100,00000100"},
+		{"0x18FF0010", "18FF0010"},
+		{"800", "00000800"},
+		{"1FFFFFFF", "1FFFFFFF"},
+	}
+	for _, tt := range tests {
+		set, err := ParseIDSet(tt.input)
+		if err != nil {
+			t.Errorf("ParseIDSet(%q): %v", tt.input, err)
+			continue
+		}
+		if got := set.String(); got != tt.want {
+			t.Errorf("ParseIDSet(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		// The IDs written by String parse to the same set
+		if again, err := ParseIDSet(set.String()); err != nil || again.String() != tt.want {
+			t.Errorf("ParseIDSet(%q) = %q, %v after String", set.String(), again.String(), err)
+		}
+	}
+
+	for _, input := range []string{"12G", "20000000", "100,,x"} {
+		if _, err := ParseIDSet(input); err == nil {
+			t.Errorf("ParseIDSet(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+// TestFilterAllows checks that standard and extended frames with the same numeric ID are filtered apart
+func TestFilterAllows(t *testing.T) {
+	parse := func(s string) IDSet {
+		set, err := ParseIDSet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+
+	type frame struct {
+		id       uint32
+		extended bool
+	}
+	tests := []struct {
+		name    string
+		filter  Filter
+		allowed []frame
+		blocked []frame
+	}{
+		{
+			name:    "no filter",
+			allowed: []frame{{0x100, false}, {0x100, true}},
+		},
+		{
+			name:    "include standard",
+			filter:  Filter{Include: parse("100")},
+			allowed: []frame{{0x100, false}},
+			blocked: []frame{{0x100, true}, {0x101, false}},
+		},
+		{
+			name:    "include extended",
+			filter:  Filter{Include: parse("00000100")},
+			allowed: []frame{{0x100, true}},
+			blocked: []frame{{0x100, false}},
+		},
+		{
+			name:    "exclude extended",
+			filter:  Filter{Exclude: parse("00000100")},
+			allowed: []frame{{0x100, false}, {0x200, true}},
+			blocked: []frame{{0x100, true}},
+		},
+		{
+			name:    "exclude wins",
+			filter:  Filter{Include: parse("100 200"), Exclude: parse("200")},
+			allowed: []frame{{0x100, false}},
+			blocked: []frame{{0x200, false}, {0x300, false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, f := range tt.allowed {
+				if !tt.filter.Allows(f.id, f.extended) {
+					t.Errorf("0x%X (extended %v) blocked", f.id, f.extended)
+				}
+			}
+			for _, f := range tt.blocked {
+				if tt.filter.Allows(f.id, f.extended) {
+					t.Errorf("0x%X (extended %v) allowed", f.id, f.extended)
+				}
+			}
+		})
+	}
+}
+
+// TestPlayerFilter replays a log with an exclude filter on a virtual bus
+func TestPlayerFilter(t *testing.T) {
+	b := bus.NewMem(t.Name())
+	defer b.Close()
+	recv := b.Subscribe()
+
+	start := time.Unix(1000, 0)
+	entries := []candump.Entry{
+		{Frame: bus.Frame{Timestamp: start, ID: 0x100}},
+		{Frame: bus.Frame{Timestamp: start.Add(time.Millisecond), ID: 0x100, IsExtended: true}},
+		{Frame: bus.Frame{Timestamp: start.Add(2 * time.Millisecond), ID: 0x200}},
+	}
+	exclude, _ := ParseIDSet("00000100")
+
+	p := NewPlayer(b, entries)
+	p.SetFilter(Filter{Exclude: exclude})
+	p.Start()
+	defer p.Stop()
+
+	var got []bus.Frame
+	for len(got) < 2 && recv.Receive() {
+		got = append(got, recv.Frame())
+	}
+	if len(got) != 2 || got[0].ID != 0x100 || got[0].IsExtended || got[1].ID != 0x200 {
+		t.Fatalf("replayed %+v, want the standard 0x100 and 0x200", got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !p.Status().Finished && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if status := p.Status(); status.Sent != 2 {
+		t.Fatalf("%d frames sent, want 2", status.Sent)
+	}
+}
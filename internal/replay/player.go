@@ -0,0 +1,315 @@
+// Package replay transmits the frames of a candump log on a bus,
+// keeping the original inter-frame timing.
+package replay
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+)
+
+// Status is a snapshot of the state of a [Player].
+type Status struct {
+	Position time.Duration // position in the log, relative to the first frame
+	Duration time.Duration // total duration of the log
+	Index    int           // index of the next frame to replay
+	Count    int           // number of frames in the log
+	Sent     int           // number of frames transmitted
+	Loops    int           // number of completed loops
+	Speed    float64
+	Loop     bool
+	Filter   Filter
+	Paused   bool
+	Finished bool
+	Last     *bus.Frame // last transmitted frame (nil if none)
+	Err      error      // last transmission error
+}
+
+// Player replays the frames of a log on a bus.
+// All its methods are safe for concurrent use.
+type Player struct {
+	bus     bus.Bus
+	entries []candump.Entry
+	offsets []time.Duration // offset of each entry from the first one
+
+	mu       sync.Mutex
+	speed    float64
+	loop     bool
+	filter   Filter
+	paused   bool
+	finished bool
+	next     int
+	position time.Duration // log position when the clock was anchored
+	anchor   time.Time     // wall time the clock was anchored at
+	sent     int
+	loops    int
+	last     *bus.Frame
+	err      error
+
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPlayer returns a paused player for the given log entries.
+func NewPlayer(b bus.Bus, entries []candump.Entry) *Player {
+	offsets := make([]time.Duration, len(entries))
+	for i, e := range entries {
+		offsets[i] = e.Frame.Timestamp.Sub(entries[0].Frame.Timestamp)
+	}
+
+	return &Player{
+		bus:     b,
+		entries: entries,
+		offsets: offsets,
+		speed:   1,
+		paused:  true,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start starts the replay goroutine, the player begins playing immediately.
+func (p *Player) Start() {
+	go p.run()
+	p.Resume()
+}
+
+// Stop stops the replay goroutine and waits for it to return.
+func (p *Player) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+// duration returns the total duration of the log
+func (p *Player) duration() time.Duration {
+	if len(p.offsets) == 0 {
+		return 0
+	}
+	return p.offsets[len(p.offsets)-1]
+}
+
+// currentPosition returns the position in the log at the given wall time, p.mu must be held
+func (p *Player) currentPosition(now time.Time) time.Duration {
+	if p.paused || p.finished {
+		return p.position
+	}
+	return p.position + time.Duration(float64(now.Sub(p.anchor))*p.speed)
+}
+
+// reanchor moves the anchor of the clock to now, p.mu must be held
+func (p *Player) reanchor(now time.Time) {
+	p.position = p.currentPosition(now)
+	p.anchor = now
+}
+
+// notify wakes up the replay goroutine after a change of the controls
+func (p *Player) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks for d (forever if d < 0) or until the controls change, it returns false if the player is stopped
+func (p *Player) wait(d time.Duration) bool {
+	var timeout <-chan time.Time
+	if d >= 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-p.stop:
+		return false
+	case <-p.wake:
+	case <-timeout:
+	}
+	return true
+}
+
+// run transmits the frames when they are due until the player is stopped
+func (p *Player) run() {
+	defer close(p.done)
+
+	for {
+		p.mu.Lock()
+
+		if p.paused || p.finished {
+			p.mu.Unlock()
+			if !p.wait(-1) {
+				return
+			}
+			continue
+		}
+
+		now := time.Now()
+		if p.next >= len(p.entries) {
+			if p.loop && len(p.entries) > 0 {
+				p.next = 0
+				p.position = 0
+				p.anchor = now
+				p.loops++
+			} else {
+				p.position = p.duration()
+				p.finished = true
+			}
+			p.mu.Unlock()
+			continue
+		}
+
+		if due := p.offsets[p.next] - p.currentPosition(now); due > 0 {
+			delay := time.Duration(float64(due) / p.speed)
+			p.mu.Unlock()
+			if !p.wait(delay) {
+				return
+			}
+			continue
+		}
+
+		frame := p.entries[p.next].Frame
+		p.next++
+		allowed := p.filter.Allows(frame.ID, frame.IsExtended)
+		p.mu.Unlock()
+
+		if !allowed {
+			continue
+		}
+
+		frame.Timestamp = time.Time{}
+		err := p.bus.Send(context.Background(), frame)
+
+		p.mu.Lock()
+		if err != nil {
+			p.err = err
+		} else {
+			p.sent++
+			p.last = &frame
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Pause pauses the replay.
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reanchor(time.Now())
+	p.paused = true
+	p.notify()
+}
+
+// Resume resumes the replay, if the replay is finished it restarts from the beginning.
+func (p *Player) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		p.seekTo(0)
+	}
+	p.paused = false
+	p.anchor = time.Now()
+	p.notify()
+}
+
+// TogglePause pauses a playing replay and resumes a paused one.
+func (p *Player) TogglePause() {
+	p.mu.Lock()
+	paused := p.paused
+	p.mu.Unlock()
+
+	if paused {
+		p.Resume()
+	} else {
+		p.Pause()
+	}
+}
+
+// seekTo moves the position in the log, p.mu must be held
+func (p *Player) seekTo(position time.Duration) {
+	position = max(0, min(position, p.duration()))
+
+	p.next = sort.Search(len(p.offsets), func(i int) bool {
+		return p.offsets[i] >= position
+	})
+	p.position = position
+	p.anchor = time.Now()
+	p.finished = false
+}
+
+// Seek moves the position in the log by delta (negative to go back).
+func (p *Player) Seek(delta time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seekTo(p.currentPosition(time.Now()) + delta)
+	p.notify()
+}
+
+// SeekTo moves to the given position in the log.
+func (p *Player) SeekTo(position time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seekTo(position)
+	p.notify()
+}
+
+// SetSpeed sets the speed factor of the replay (2 = twice as fast as recorded).
+func (p *Player) SetSpeed(speed float64) {
+	if speed <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reanchor(time.Now())
+	p.speed = speed
+	p.notify()
+}
+
+// SetLoop sets whether the replay restarts from the beginning once finished.
+func (p *Player) SetLoop(loop bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loop = loop
+	if loop && p.finished {
+		p.seekTo(0)
+	}
+	p.notify()
+}
+
+// SetFilter sets the ID filter applied to the frames still to be replayed.
+func (p *Player) SetFilter(filter Filter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filter = filter
+}
+
+// Status returns a snapshot of the state of the player.
+func (p *Player) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return Status{
+		Position: min(p.currentPosition(time.Now()), p.duration()),
+		Duration: p.duration(),
+		Index:    p.next,
+		Count:    len(p.entries),
+		Sent:     p.sent,
+		Loops:    p.loops,
+		Speed:    p.speed,
+		Loop:     p.loop,
+		Filter:   p.filter,
+		Paused:   p.paused,
+		Finished: p.finished,
+		Last:     p.last,
+		Err:      p.err,
+	}
+}
@@ -0,0 +1,189 @@
+package replay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+)
+
+// timingSlack is how late a frame may be replayed
+const timingSlack = 50 * time.Millisecond
+
+// testLog returns a log with a frame at each offset, their IDs are 1, 2, 3...
+func testLog(offsets ...time.Duration) []candump.Entry {
+	start := time.Unix(1000, 0)
+	entries := make([]candump.Entry, len(offsets))
+	for i, offset := range offsets {
+		entries[i] = candump.Entry{Frame: bus.Frame{Timestamp: start.Add(offset), ID: uint32(i + 1)}, Interface: "vcan0"}
+	}
+	return entries
+}
+
+// startPlayer returns a paused player of the entries on a virtual bus, with the channel of the frames it replays
+func startPlayer(t *testing.T, entries []candump.Entry) (*Player, <-chan bus.Frame) {
+	t.Helper()
+
+	b := bus.NewMem(t.Name())
+	recv := b.Subscribe()
+	frames := make(chan bus.Frame, 64)
+	go func() {
+		defer close(frames)
+		for recv.Receive() {
+			frames <- recv.Frame()
+		}
+	}()
+
+	p := NewPlayer(b, entries)
+	t.Cleanup(func() {
+		p.Stop()
+		recv.Close()
+		b.Close()
+	})
+	return p, frames
+}
+
+// receiveFrames returns the next n frames replayed
+func receiveFrames(t *testing.T, frames <-chan bus.Frame, n int) []bus.Frame {
+	t.Helper()
+
+	got := make([]bus.Frame, 0, n)
+	timeout := time.After(2 * time.Second)
+	for len(got) < n {
+		select {
+		case frame := <-frames:
+			got = append(got, frame)
+		case <-timeout:
+			t.Fatalf("%d frames replayed, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+// checkIDs checks the IDs of the frames replayed, in order
+func checkIDs(t *testing.T, frames []bus.Frame, ids ...uint32) {
+	t.Helper()
+
+	for i, frame := range frames {
+		if frame.ID != ids[i] {
+			t.Fatalf("frame %d has ID %d, want %d", i, frame.ID, ids[i])
+		}
+	}
+}
+
+// waitFinished waits for the end of the replay
+func waitFinished(t *testing.T, p *Player) Status {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := p.Status(); status.Finished {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("replay not finished")
+	return Status{}
+}
+
+// TestPlayerTiming checks the order of the frames and their timing at different speeds
+func TestPlayerTiming(t *testing.T) {
+	offsets := []time.Duration{0, 40 * time.Millisecond, 80 * time.Millisecond, 200 * time.Millisecond}
+
+	for _, speed := range []float64{1, 4} {
+		t.Run(fmt.Sprintf("speed %g", speed), func(t *testing.T) {
+			p, frames := startPlayer(t, testLog(offsets...))
+			p.SetSpeed(speed)
+			p.Start()
+
+			got := receiveFrames(t, frames, len(offsets))
+			checkIDs(t, got, 1, 2, 3, 4)
+			for i, frame := range got {
+				want := time.Duration(float64(offsets[i]) / speed)
+				if elapsed := frame.Timestamp.Sub(got[0].Timestamp); elapsed < want-2*time.Millisecond || elapsed > want+timingSlack {
+					t.Errorf("frame %d replayed after %v, want %v", i, elapsed, want)
+				}
+			}
+
+			status := waitFinished(t, p)
+			if status.Sent != len(offsets) || status.Index != len(offsets) || status.Position != status.Duration || status.Duration != offsets[3] {
+				t.Fatalf("status %+v after the replay", status)
+			}
+		})
+	}
+}
+
+// TestPlayerPause checks that a paused replay sends nothing and resumes where it stopped
+func TestPlayerPause(t *testing.T) {
+	p, frames := startPlayer(t, testLog(0, 50*time.Millisecond, 100*time.Millisecond))
+	p.Start()
+	checkIDs(t, receiveFrames(t, frames, 1), 1)
+
+	p.Pause()
+	position := p.Status().Position
+	time.Sleep(150 * time.Millisecond)
+	if status := p.Status(); !status.Paused || status.Position != position || status.Sent != 1 || len(frames) != 0 {
+		t.Fatalf("status %+v while paused at %v, %d frames replayed", status, position, len(frames))
+	}
+
+	resumed := time.Now()
+	p.TogglePause()
+	got := receiveFrames(t, frames, 2)
+	checkIDs(t, got, 2, 3)
+	// The second frame is due 50 ms into the log: the time spent paused does not count
+	if wait := got[0].Timestamp.Sub(resumed); wait < 50*time.Millisecond-position {
+		t.Errorf("second frame replayed %v after the resume from %v", wait, position)
+	}
+	waitFinished(t, p)
+
+	// Resuming a finished replay starts it again
+	p.Resume()
+	checkIDs(t, receiveFrames(t, frames, 3), 1, 2, 3)
+}
+
+// TestPlayerSeek checks the positions reached by seeking and the frames replayed from there
+func TestPlayerSeek(t *testing.T) {
+	p, frames := startPlayer(t, testLog(0, 10*time.Millisecond, 100*time.Millisecond, 110*time.Millisecond))
+
+	steps := []struct {
+		seek     func()
+		position time.Duration
+		index    int
+	}{
+		{func() { p.SeekTo(100 * time.Millisecond) }, 100 * time.Millisecond, 2},
+		{func() { p.Seek(-95 * time.Millisecond) }, 5 * time.Millisecond, 1},
+		{func() { p.Seek(-time.Second) }, 0, 0},
+		{func() { p.SeekTo(time.Hour) }, 110 * time.Millisecond, 3},
+		{func() { p.SeekTo(50 * time.Millisecond) }, 50 * time.Millisecond, 2},
+	}
+	for i, step := range steps {
+		step.seek()
+		if status := p.Status(); status.Position != step.position || status.Index != step.index {
+			t.Fatalf("step %d: position %v at frame %d, want %v at frame %d", i, status.Position, status.Index, step.position, step.index)
+		}
+	}
+
+	// The replay goes on from the position reached
+	p.Start()
+	checkIDs(t, receiveFrames(t, frames, 2), 3, 4)
+	if status := waitFinished(t, p); status.Sent != 2 {
+		t.Fatalf("%d frames sent, want 2", status.Sent)
+	}
+}
+
+// TestPlayerLoop checks that a looping replay starts again from the first frame
+func TestPlayerLoop(t *testing.T) {
+	p, frames := startPlayer(t, testLog(0, 10*time.Millisecond))
+	p.SetLoop(true)
+	p.Start()
+
+	checkIDs(t, receiveFrames(t, frames, 6), 1, 2, 1, 2, 1, 2)
+	if status := p.Status(); status.Loops < 2 || !status.Loop || status.Finished {
+		t.Fatalf("status %+v after 3 rounds", status)
+	}
+
+	p.SetLoop(false)
+	waitFinished(t, p)
+}
@@ -0,0 +1,150 @@
+// Package stats measures the reception of the CAN messages: how many frames
+// arrived, at which period and with how much jitter, and the last one received.
+package stats
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Message holds the reception statistics of a CAN ID.
+type Message struct {
+	Frame      bus.Frame     // last frame received
+	Count      uint64        // frames received
+	First      time.Time     // time of the first frame
+	Last       time.Time     // time of the last frame
+	LastPeriod time.Duration // time between the last two frames
+	MinPeriod  time.Duration
+	MaxPeriod  time.Duration
+	AvgPeriod  time.Duration
+	// Jitter is the standard deviation of the period
+	Jitter time.Duration
+
+	// running variance of the period (Welford's algorithm), in seconds
+	mean float64
+	m2   float64
+}
+
+// Age returns the time elapsed since the last frame.
+func (s *Message) Age(now time.Time) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return now.Sub(s.Last)
+}
+
+// Rate returns the average number of frames received per second.
+func (s *Message) Rate() float64 {
+	elapsed := s.Last.Sub(s.First)
+	if s.Count < 2 || elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Periods()) / elapsed.Seconds()
+}
+
+// Periods returns the number of periods measured (one less than the frames received).
+func (s *Message) Periods() uint64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Count - 1
+}
+
+// observe updates the statistics with a frame received at t
+func (s *Message) observe(t time.Time) {
+	s.Count++
+	if s.Count == 1 {
+		s.First = t
+		s.Last = t
+		return
+	}
+
+	period := t.Sub(s.Last)
+	s.Last = t
+	s.LastPeriod = period
+	if s.Count == 2 || period < s.MinPeriod {
+		s.MinPeriod = period
+	}
+	if period > s.MaxPeriod {
+		s.MaxPeriod = period
+	}
+
+	n := float64(s.Periods())
+	x := period.Seconds()
+	delta := x - s.mean
+	s.mean += delta / n
+	s.m2 += delta * (x - s.mean)
+
+	s.AvgPeriod = seconds(s.mean)
+	if n > 1 {
+		s.Jitter = seconds(math.Sqrt(s.m2 / (n - 1)))
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Tracker collects the statistics of every CAN ID it sees, it is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	messages map[uint32]*Message
+}
+
+// NewTracker returns an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		messages: make(map[uint32]*Message),
+	}
+}
+
+// Observe records a frame with the given key (see can.Key), received at its timestamp.
+func (t *Tracker) Observe(key uint32, frame bus.Frame) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.messages[key]
+	if !ok {
+		s = &Message{}
+		t.messages[key] = s
+	}
+	s.Frame = frame
+	s.observe(frame.Timestamp)
+}
+
+// Keys returns the sorted keys of the frames received.
+func (t *Tracker) Keys() []uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]uint32, 0, len(t.messages))
+	for key := range t.messages {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Get returns a copy of the statistics of the key, false if no frame was received.
+func (t *Tracker) Get(key uint32) (Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.messages[key]
+	if !ok {
+		return Message{}, false
+	}
+	return *s, true
+}
+
+// Reset forgets every statistic.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = make(map[uint32]*Message)
+}
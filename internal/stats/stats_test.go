@@ -0,0 +1,144 @@
+package stats
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// ms returns n milliseconds
+func ms(n float64) time.Duration {
+	return time.Duration(n * float64(time.Millisecond))
+}
+
+// observed returns the statistics of frames received after the periods
+func observed(start time.Time, periods []time.Duration) Message {
+	var s Message
+	s.observe(start)
+	at := start
+	for _, period := range periods {
+		at = at.Add(period)
+		s.observe(at)
+	}
+	return s
+}
+
+// TestMessagePeriods checks the period, its extremes, the jitter and the rate of the frames received
+func TestMessagePeriods(t *testing.T) {
+	tests := []struct {
+		name     string
+		periods  []time.Duration
+		min, max time.Duration
+		avg      time.Duration
+		jitter   time.Duration // sample standard deviation of the periods
+		rate     float64
+	}{
+		{
+			name:    "one period",
+			periods: []time.Duration{ms(100)},
+			min:     ms(100), max: ms(100), avg: ms(100), jitter: 0, rate: 10,
+		},
+		{
+			name:    "regular",
+			periods: []time.Duration{ms(10), ms(10), ms(10), ms(10)},
+			min:     ms(10), max: ms(10), avg: ms(10), jitter: 0, rate: 100,
+		},
+		{
+			// Mean 5 ms, squared deviations summing to 32 ms²: variance 32/7 ms²
+			name:    "known variance",
+			periods: []time.Duration{ms(2), ms(4), ms(4), ms(4), ms(5), ms(5), ms(7), ms(9)},
+			min:     ms(2), max: ms(9), avg: ms(5), jitter: ms(math.Sqrt(32.0 / 7)), rate: 200,
+		},
+		{
+			name:    "gap",
+			periods: []time.Duration{ms(100), ms(100), ms(700), ms(100)},
+			min:     ms(100), max: ms(700), avg: ms(250), jitter: ms(300), rate: 4,
+		},
+	}
+
+	// near tells whether two durations are within a microsecond, the running mean has rounding errors
+	near := func(a, b time.Duration) bool {
+		return (a - b).Abs() <= time.Microsecond
+	}
+	start := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := observed(start, tt.periods)
+
+			if s.Count != uint64(len(tt.periods)+1) || s.Periods() != uint64(len(tt.periods)) {
+				t.Fatalf("%d frames and %d periods, want %d and %d", s.Count, s.Periods(), len(tt.periods)+1, len(tt.periods))
+			}
+			if s.LastPeriod != tt.periods[len(tt.periods)-1] {
+				t.Errorf("last period %v, want %v", s.LastPeriod, tt.periods[len(tt.periods)-1])
+			}
+			if s.MinPeriod != tt.min || s.MaxPeriod != tt.max {
+				t.Errorf("period between %v and %v, want %v and %v", s.MinPeriod, s.MaxPeriod, tt.min, tt.max)
+			}
+			if !near(s.AvgPeriod, tt.avg) || !near(s.Jitter, tt.jitter) {
+				t.Errorf("period %v ± %v, want %v ± %v", s.AvgPeriod, s.Jitter, tt.avg, tt.jitter)
+			}
+			if math.Abs(s.Rate()-tt.rate) > 1e-9 {
+				t.Errorf("Rate() = %g, want %g", s.Rate(), tt.rate)
+			}
+		})
+	}
+}
+
+// TestMessageFewFrames checks the statistics before any period is measured
+func TestMessageFewFrames(t *testing.T) {
+	now := time.Now()
+
+	var s Message
+	if s.Rate() != 0 || s.Periods() != 0 || s.Age(now) != 0 {
+		t.Fatalf("no frames: rate %g, %d periods, age %v", s.Rate(), s.Periods(), s.Age(now))
+	}
+
+	s.observe(now)
+	if s.Rate() != 0 || s.Periods() != 0 || s.Jitter != 0 || s.AvgPeriod != 0 || !s.First.Equal(now) || !s.Last.Equal(now) {
+		t.Fatalf("1 frame: %+v, rate %g", s, s.Rate())
+	}
+	if age := s.Age(now.Add(time.Second)); age != time.Second {
+		t.Fatalf("Age() = %v, want 1s", age)
+	}
+
+	// Two frames at the same time measure a period of 0
+	s.observe(now)
+	if s.Rate() != 0 || s.Periods() != 1 || s.MinPeriod != 0 {
+		t.Fatalf("2 frames at once: %+v, rate %g", s, s.Rate())
+	}
+}
+
+// TestTracker checks that the statistics are kept by key, standard and extended IDs apart
+func TestTracker(t *testing.T) {
+	const extendedFlag = 1 << 31 // see can.Key
+	tracker := NewTracker()
+	start := time.Now()
+
+	for i := range 3 {
+		at := start.Add(time.Duration(i) * 10 * time.Millisecond)
+		tracker.Observe(0x100, bus.Frame{ID: 0x100, Length: 1, Data: [bus.MaxFDDataLength]byte{byte(i)}, Timestamp: at})
+	}
+	tracker.Observe(0x100|extendedFlag, bus.Frame{ID: 0x100, IsExtended: true, Timestamp: start})
+
+	if keys := tracker.Keys(); !slices.Equal(keys, []uint32{0x100, 0x100 | extendedFlag}) {
+		t.Fatalf("Keys() = %X", keys)
+	}
+	s, ok := tracker.Get(0x100)
+	if !ok || s.Count != 3 || s.Frame.Data[0] != 2 || s.AvgPeriod != 10*time.Millisecond {
+		t.Fatalf("Get(0x100) = %+v, %v", s, ok)
+	}
+	if s, ok := tracker.Get(0x100 | extendedFlag); !ok || s.Count != 1 || !s.Frame.IsExtended {
+		t.Fatalf("Get(extended 0x100) = %+v, %v", s, ok)
+	}
+	if _, ok := tracker.Get(0x200); ok {
+		t.Fatal("statistics of an ID never received")
+	}
+
+	tracker.Reset()
+	if keys := tracker.Keys(); len(keys) != 0 {
+		t.Fatalf("Keys() = %X after Reset", keys)
+	}
+}
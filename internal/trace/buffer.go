@@ -0,0 +1,139 @@
+// Package trace keeps the last frames seen on the bus for the raw trace view.
+package trace
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// Buffer is a bounded ring buffer of frames, it is safe for concurrent use.
+// Once full, every new frame overwrites the oldest one.
+type Buffer struct {
+	mu     sync.Mutex
+	frames []bus.Frame
+	next   int    // index where the next frame is written
+	full   bool   // true once the buffer wrapped around
+	total  uint64 // frames added since the creation
+}
+
+// NewBuffer returns a buffer holding up to capacity frames.
+func NewBuffer(capacity int) *Buffer {
+	return &Buffer{
+		frames: make([]bus.Frame, max(capacity, 1)),
+	}
+}
+
+// Add appends a frame, dropping the oldest one if the buffer is full.
+func (b *Buffer) Add(frame bus.Frame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.frames[b.next] = frame
+	b.next++
+	if b.next == len(b.frames) {
+		b.next = 0
+		b.full = true
+	}
+	b.total++
+}
+
+// Len returns the number of frames held.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.held()
+}
+
+// held returns the number of frames held, b.mu must be held
+func (b *Buffer) held() int {
+	if b.full {
+		return len(b.frames)
+	}
+	return b.next
+}
+
+// at returns the i-th newest frame (0 is the last one added), b.mu must be held
+func (b *Buffer) at(i int) bus.Frame {
+	return b.frames[(b.next-1-i+len(b.frames))%len(b.frames)]
+}
+
+// Total returns the number of frames added since the creation, the dropped ones included.
+func (b *Buffer) Total() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.total
+}
+
+// Count returns the number of frames held that satisfy keep (all if nil).
+func (b *Buffer) Count(keep func(bus.Frame) bool) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if keep == nil {
+		return b.held()
+	}
+	count := 0
+	for i := range b.held() {
+		if keep(b.at(i)) {
+			count++
+		}
+	}
+	return count
+}
+
+// Window returns up to n of the frames that satisfy keep (all if nil), skipping the newest skip ones,
+// from the oldest to the newest. Only the frames up to the window are visited, nothing else is copied.
+func (b *Buffer) Window(skip, n int, keep func(bus.Frame) bool) []bus.Frame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	held := b.held()
+	frames := make([]bus.Frame, 0, max(0, min(n, held-skip)))
+	if keep == nil {
+		// The window is indexed directly in the ring
+		for i := max(skip, 0); i < held && len(frames) < n; i++ {
+			frames = append(frames, b.at(i))
+		}
+	} else {
+		skipped := 0
+		for i := 0; i < held && len(frames) < n; i++ {
+			frame := b.at(i)
+			switch {
+			case !keep(frame):
+			case skipped < skip:
+				skipped++
+			default:
+				frames = append(frames, frame)
+			}
+		}
+	}
+
+	slices.Reverse(frames)
+	return frames
+}
+
+// Clone returns a copy of the buffer, e.g. to freeze a view while the buffer keeps recording.
+func (b *Buffer) Clone() *Buffer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return &Buffer{
+		frames: slices.Clone(b.frames),
+		next:   b.next,
+		full:   b.full,
+		total:  b.total,
+	}
+}
+
+// Clear removes every frame.
+func (b *Buffer) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.next = 0
+	b.full = false
+}
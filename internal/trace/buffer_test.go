@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+)
+
+// ids returns the IDs of the frames
+func ids(frames []bus.Frame) string {
+	s := ""
+	for i, frame := range frames {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf("%X", frame.ID)
+	}
+	return s
+}
+
+// TestWindow checks the windows of a buffer that wrapped around, with and without a filter
+func TestWindow(t *testing.T) {
+	b := NewBuffer(5)
+	for id := uint32(1); id <= 8; id++ {
+		b.Add(bus.Frame{ID: id, IsExtended: id%2 == 0})
+	}
+	extended := func(frame bus.Frame) bool { return frame.IsExtended }
+
+	tests := []struct {
+		name    string
+		skip, n int
+		keep    func(bus.Frame) bool
+		want    string
+	}{
+		{"last frames", 0, 3, nil, "6,7,8"},
+		{"scrolled back", 2, 2, nil, "5,6"},
+		{"oldest", 3, 5, nil, "4,5"},
+		{"larger than the buffer", 0, 10, nil, "4,5,6,7,8"},
+		{"scrolled past the oldest", 5, 3, nil, ""},
+		{"filtered", 0, 5, extended, "4,6,8"},
+		{"filtered scrolled back", 1, 1, extended, "6"},
+		{"filtered past the oldest", 3, 1, extended, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(b.Window(tt.skip, tt.n, tt.keep)); got != tt.want {
+				t.Fatalf("Window(%d, %d) = %s, want %s", tt.skip, tt.n, got, tt.want)
+			}
+		})
+	}
+
+	if got := b.Count(nil); got != 5 {
+		t.Errorf("Count(nil) = %d, want 5", got)
+	}
+	if got := b.Count(extended); got != 3 {
+		t.Errorf("Count(extended) = %d, want 3", got)
+	}
+}
+
+// TestClone checks that a clone does not see the frames added afterwards
+func TestClone(t *testing.T) {
+	b := NewBuffer(3)
+	b.Add(bus.Frame{ID: 1})
+	b.Add(bus.Frame{ID: 2})
+
+	clone := b.Clone()
+	b.Add(bus.Frame{ID: 3})
+	b.Add(bus.Frame{ID: 4})
+
+	if got := ids(clone.Window(0, 10, nil)); got != "1,2" {
+		t.Fatalf("clone holds %s, want 1,2", got)
+	}
+	if got := ids(b.Window(0, 10, nil)); got != "2,3,4" {
+		t.Fatalf("buffer holds %s, want 2,3,4", got)
+	}
+	if clone.Total() != 2 || b.Total() != 4 {
+		t.Fatalf("totals %d and %d, want 2 and 4", clone.Total(), b.Total())
+	}
+}
@@ -0,0 +1,47 @@
+package uds
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DTC is a diagnostic trouble code with its status byte.
+type DTC struct {
+	Code   uint32 // 3 bytes: the SAE J2012 code and the failure type
+	Status byte
+}
+
+// dtcSystems are the letters of the system of a DTC (first two bits)
+var dtcSystems = [4]byte{'P', 'C', 'B', 'U'}
+
+// String returns the code in the SAE J2012 form with its failure type, e.g. "P0A1B-12".
+func (d DTC) String() string {
+	high := d.Code >> 8
+	return fmt.Sprintf("%c%d%03X-%02X", dtcSystems[high>>14], (high>>12)&0x3, high&0xFFF, d.Code&0xFF)
+}
+
+// dtcStatusBits are the names of the bits of the DTC status, from bit 0
+var dtcStatusBits = [8]string{
+	"testFailed",
+	"testFailedThisCycle",
+	"pending",
+	"confirmed",
+	"testNotCompletedSinceClear",
+	"testFailedSinceClear",
+	"testNotCompletedThisCycle",
+	"warningIndicator",
+}
+
+// StatusText returns the names of the bits set in the status, e.g. "testFailed|confirmed".
+func (d DTC) StatusText() string {
+	var names []string
+	for bit, name := range dtcStatusBits {
+		if d.Status&(1<<bit) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, "|")
+}
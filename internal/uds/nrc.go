@@ -0,0 +1,81 @@
+package uds
+
+import "fmt"
+
+// NRC is a negative response code.
+type NRC byte
+
+// Negative response codes of ISO 14229-1.
+const (
+	NRCGeneralReject                          NRC = 0x10
+	NRCServiceNotSupported                    NRC = 0x11
+	NRCSubFunctionNotSupported                NRC = 0x12
+	NRCIncorrectMessageLength                 NRC = 0x13
+	NRCResponseTooLong                        NRC = 0x14
+	NRCBusyRepeatRequest                      NRC = 0x21
+	NRCConditionsNotCorrect                   NRC = 0x22
+	NRCRequestSequenceError                   NRC = 0x24
+	NRCNoResponseFromSubnet                   NRC = 0x25
+	NRCFailurePreventsExecution               NRC = 0x26
+	NRCRequestOutOfRange                      NRC = 0x31
+	NRCSecurityAccessDenied                   NRC = 0x33
+	NRCInvalidKey                             NRC = 0x35
+	NRCExceededNumberOfAttempts               NRC = 0x36
+	NRCRequiredTimeDelayNotExpired            NRC = 0x37
+	NRCUploadDownloadNotAccepted              NRC = 0x70
+	NRCTransferDataSuspended                  NRC = 0x71
+	NRCGeneralProgrammingFailure              NRC = 0x72
+	NRCWrongBlockSequenceCounter              NRC = 0x73
+	NRCResponsePending                        NRC = 0x78
+	NRCSubFunctionNotSupportedInActiveSession NRC = 0x7E
+	NRCServiceNotSupportedInActiveSession     NRC = 0x7F
+	NRCVoltageTooHigh                         NRC = 0x92
+	NRCVoltageTooLow                          NRC = 0x93
+)
+
+// nrcTexts are the descriptions of the negative response codes
+var nrcTexts = map[NRC]string{
+	NRCGeneralReject:                          "general reject",
+	NRCServiceNotSupported:                    "service not supported",
+	NRCSubFunctionNotSupported:                "sub-function not supported",
+	NRCIncorrectMessageLength:                 "incorrect message length or invalid format",
+	NRCResponseTooLong:                        "response too long",
+	NRCBusyRepeatRequest:                      "busy, repeat request",
+	NRCConditionsNotCorrect:                   "conditions not correct",
+	NRCRequestSequenceError:                   "request sequence error",
+	NRCNoResponseFromSubnet:                   "no response from subnet component",
+	NRCFailurePreventsExecution:               "failure prevents execution of requested action",
+	NRCRequestOutOfRange:                      "request out of range",
+	NRCSecurityAccessDenied:                   "security access denied",
+	NRCInvalidKey:                             "invalid key",
+	NRCExceededNumberOfAttempts:               "exceeded number of attempts",
+	NRCRequiredTimeDelayNotExpired:            "required time delay not expired",
+	NRCUploadDownloadNotAccepted:              "upload/download not accepted",
+	NRCTransferDataSuspended:                  "transfer data suspended",
+	NRCGeneralProgrammingFailure:              "general programming failure",
+	NRCWrongBlockSequenceCounter:              "wrong block sequence counter",
+	NRCResponsePending:                        "request correctly received, response pending",
+	NRCSubFunctionNotSupportedInActiveSession: "sub-function not supported in active session",
+	NRCServiceNotSupportedInActiveSession:     "service not supported in active session",
+	NRCVoltageTooHigh:                         "voltage too high",
+	NRCVoltageTooLow:                          "voltage too low",
+}
+
+// String returns the description of the code with its value, e.g. "security access denied (0x33)".
+func (n NRC) String() string {
+	text, ok := nrcTexts[n]
+	switch {
+	case ok:
+	case n >= 0x38 && n <= 0x4F:
+		text = "reserved by extended data link security"
+	case n >= 0x81 && n <= 0x91:
+		text = "specific condition not correct (engine, speed, gear, brake...)"
+	case n >= 0x94 && n <= 0xEF:
+		text = "reserved for specific conditions not correct"
+	case n >= 0xF0 && n <= 0xFE:
+		text = "condition not correct (vehicle manufacturer specific)"
+	default:
+		text = "unknown negative response"
+	}
+	return fmt.Sprintf("%s (0x%02X)", text, byte(n))
+}
@@ -0,0 +1,55 @@
+package uds
+
+import (
+	"slices"
+	"sync"
+)
+
+// KeyFunc computes the key of the seed sent by the ECU for a security level.
+type KeyFunc func(level byte, seed []byte) ([]byte, error)
+
+var (
+	keyAlgorithmsMu sync.RWMutex
+	keyAlgorithms   = map[string]KeyFunc{
+		// The key is the seed, as with development firmwares
+		"seed": func(level byte, seed []byte) ([]byte, error) {
+			return slices.Clone(seed), nil
+		},
+		// The key is the bitwise complement of the seed
+		"not": func(level byte, seed []byte) ([]byte, error) {
+			key := make([]byte, len(seed))
+			for i, b := range seed {
+				key[i] = ^b
+			}
+			return key, nil
+		},
+	}
+)
+
+// RegisterKeyAlgorithm makes the key algorithm of an ECU available to [KeyAlgorithm] under a name.
+func RegisterKeyAlgorithm(name string, key KeyFunc) {
+	keyAlgorithmsMu.Lock()
+	defer keyAlgorithmsMu.Unlock()
+	keyAlgorithms[name] = key
+}
+
+// KeyAlgorithm returns the key algorithm registered with the name.
+func KeyAlgorithm(name string) (KeyFunc, bool) {
+	keyAlgorithmsMu.RLock()
+	defer keyAlgorithmsMu.RUnlock()
+	key, ok := keyAlgorithms[name]
+	return key, ok
+}
+
+// KeyAlgorithms returns the names of the registered key algorithms, sorted.
+func KeyAlgorithms() []string {
+	keyAlgorithmsMu.RLock()
+	defer keyAlgorithmsMu.RUnlock()
+
+	names := make([]string, 0, len(keyAlgorithms))
+	for name := range keyAlgorithms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,188 @@
+package uds
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Diagnostic sessions of DiagnosticSessionControl.
+const (
+	SessionDefault     = 0x01
+	SessionProgramming = 0x02
+	SessionExtended    = 0x03
+)
+
+// Reset types of ECUReset.
+const (
+	ResetHard     = 0x01
+	ResetKeyOffOn = 0x02
+	ResetSoft     = 0x03
+)
+
+const (
+	// dtcAllGroups is the group of ClearDiagnosticInformation clearing all the DTCs
+	dtcAllGroups = 0xFFFFFF
+	// reportByStatus is the reportDTCByStatusMask sub-function of ReadDTCInformation
+	reportByStatus = 0x02
+)
+
+// DefaultKeepAliveInterval is the period of TesterPresent of KeepAlive, well within the
+// 5s the ECUs wait before going back to the default session (S3 server)
+const DefaultKeepAliveInterval = 2 * time.Second
+
+// SessionTiming are the timeouts of the session reported by the ECU.
+type SessionTiming struct {
+	P2     time.Duration // response time
+	P2Star time.Duration // response time after a "response pending"
+}
+
+// DiagnosticSessionControl switches the ECU to a diagnostic session.
+// The ECUs reporting their timing get it returned, the zero SessionTiming otherwise.
+func (c *Client) DiagnosticSessionControl(ctx context.Context, session byte) (SessionTiming, error) {
+	response, err := c.Request(ctx, ServiceDiagnosticSessionControl, session)
+	if err != nil {
+		return SessionTiming{}, err
+	}
+	if len(response) < 6 {
+		return SessionTiming{}, nil
+	}
+	return SessionTiming{
+		P2:     time.Duration(uint16(response[2])<<8|uint16(response[3])) * time.Millisecond,
+		P2Star: time.Duration(uint16(response[4])<<8|uint16(response[5])) * 10 * time.Millisecond,
+	}, nil
+}
+
+// TesterPresent tells the ECU that the tester is still connected, keeping the session active.
+// With suppress the ECU does not answer.
+func (c *Client) TesterPresent(ctx context.Context, suppress bool) error {
+	if suppress {
+		return c.send(ctx, ServiceTesterPresent, suppressPositiveResponse)
+	}
+	_, err := c.Request(ctx, ServiceTesterPresent, 0x00)
+	return err
+}
+
+// KeepAlive sends TesterPresent (with suppressed response) every interval until the context is done.
+// It returns the first failed transmission, nil when the context is done.
+func (c *Client) KeepAlive(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.TesterPresent(ctx, true); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// ECUReset resets the ECU, resetType is one of the Reset* constants.
+func (c *Client) ECUReset(ctx context.Context, resetType byte) error {
+	_, err := c.Request(ctx, ServiceECUReset, resetType)
+	return err
+}
+
+// ReadDataByIdentifier reads the data of an identifier (DID).
+func (c *Client) ReadDataByIdentifier(ctx context.Context, did uint16) ([]byte, error) {
+	response, err := c.Request(ctx, ServiceReadDataByIdentifier, byte(did>>8), byte(did))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLength(response, 3); err != nil {
+		return nil, err
+	}
+	if got := uint16(response[1])<<8 | uint16(response[2]); got != did {
+		return nil, fmt.Errorf("%w: got DID 0x%04X instead of 0x%04X", ErrInvalidResponse, got, did)
+	}
+	return response[3:], nil
+}
+
+// WriteDataByIdentifier writes the data of an identifier (DID).
+func (c *Client) WriteDataByIdentifier(ctx context.Context, did uint16, data []byte) error {
+	request := append([]byte{ServiceWriteDataByIdentifier, byte(did >> 8), byte(did)}, data...)
+	_, err := c.Request(ctx, request...)
+	return err
+}
+
+// ClearDiagnosticInformation clears the DTCs of a group, 0xFFFFFF clears all of them.
+func (c *Client) ClearDiagnosticInformation(ctx context.Context, group uint32) error {
+	_, err := c.Request(ctx, ServiceClearDiagnosticInfo, byte(group>>16), byte(group>>8), byte(group))
+	return err
+}
+
+// ClearAllDTCs clears all the DTCs of the ECU.
+func (c *Client) ClearAllDTCs(ctx context.Context) error {
+	return c.ClearDiagnosticInformation(ctx, dtcAllGroups)
+}
+
+// ReadDTCsByStatus reads the DTCs whose status matches the mask (ReadDTCInformation, reportDTCByStatusMask).
+func (c *Client) ReadDTCsByStatus(ctx context.Context, mask byte) ([]DTC, error) {
+	response, err := c.Request(ctx, ServiceReadDTCInformation, reportByStatus, mask)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLength(response, 3); err != nil {
+		return nil, err
+	}
+
+	records := response[3:]
+	if len(records)%4 != 0 {
+		return nil, fmt.Errorf("%w: DTC records of %d bytes", ErrInvalidResponse, len(records))
+	}
+	dtcs := make([]DTC, 0, len(records)/4)
+	for i := 0; i < len(records); i += 4 {
+		dtcs = append(dtcs, DTC{
+			Code:   uint32(records[i])<<16 | uint32(records[i+1])<<8 | uint32(records[i+2]),
+			Status: records[i+3],
+		})
+	}
+	return dtcs, nil
+}
+
+// SecurityAccess unlocks a security level (odd, e.g. 0x01): it requests the seed,
+// computes the key with the algorithm and sends it. A seed of only zero bytes means already unlocked.
+func (c *Client) SecurityAccess(ctx context.Context, level byte, key KeyFunc) error {
+	if level%2 == 0 || level > 0x7F {
+		return fmt.Errorf("uds: invalid security level 0x%02X, the seeds are requested with odd levels", level)
+	}
+
+	response, err := c.Request(ctx, ServiceSecurityAccess, level)
+	if err != nil {
+		return err
+	}
+	if err := checkLength(response, 2); err != nil {
+		return err
+	}
+	if response[1] != level {
+		return fmt.Errorf("%w: seed of level 0x%02X instead of 0x%02X", ErrInvalidResponse, response[1], level)
+	}
+	seed := response[2:]
+	if len(seed) == 0 {
+		return fmt.Errorf("%w: empty seed of level 0x%02X", ErrInvalidResponse, level)
+	}
+
+	unlocked := true
+	for _, b := range seed {
+		if b != 0 {
+			unlocked = false
+		}
+	}
+	if unlocked {
+		return nil
+	}
+
+	keyBytes, err := key(level, seed)
+	if err != nil {
+		return fmt.Errorf("uds: computing the key of level 0x%02X: %w", level, err)
+	}
+	_, err = c.Request(ctx, append([]byte{ServiceSecurityAccess, level + 1}, keyBytes...)...)
+	return err
+}
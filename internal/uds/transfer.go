@@ -0,0 +1,92 @@
+package uds
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sub-functions of RoutineControl.
+const (
+	RoutineStart   = 0x01
+	RoutineStop    = 0x02
+	RoutineResults = 0x03
+)
+
+// addressAndLength is the addressAndLengthFormatIdentifier of the requests: 4 bytes of size and 4 of address
+const addressAndLength = 0x44
+
+// RoutineControl starts, stops or asks the results of a routine, it returns the routine status record.
+func (c *Client) RoutineControl(ctx context.Context, control byte, routine uint16, option []byte) ([]byte, error) {
+	request := append([]byte{ServiceRoutineControl, control, byte(routine >> 8), byte(routine)}, option...)
+	response, err := c.Request(ctx, request...)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLength(response, 4); err != nil {
+		return nil, err
+	}
+	if got := uint16(response[2])<<8 | uint16(response[3]); got != routine {
+		return nil, fmt.Errorf("%w: got routine 0x%04X instead of 0x%04X", ErrInvalidResponse, got, routine)
+	}
+	return response[4:], nil
+}
+
+// AddressAndSize encodes a memory address and size as the requests of this client do (4 bytes each).
+func AddressAndSize(address, size uint32) []byte {
+	return []byte{
+		byte(address >> 24), byte(address >> 16), byte(address >> 8), byte(address),
+		byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size),
+	}
+}
+
+// RequestDownload announces the download of size bytes at address, with no compression nor encryption.
+// It returns the longest TransferData request accepted by the ECU (service and counter included).
+func (c *Client) RequestDownload(ctx context.Context, address, size uint32) (int, error) {
+	request := append([]byte{ServiceRequestDownload, 0x00, addressAndLength}, AddressAndSize(address, size)...)
+	response, err := c.Request(ctx, request...)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkLength(response, 2); err != nil {
+		return 0, err
+	}
+
+	n := int(response[1] >> 4)
+	if n == 0 || n > 4 || len(response) < 2+n {
+		return 0, fmt.Errorf("%w: RequestDownload response with a max block length of %d bytes", ErrInvalidResponse, n)
+	}
+	maxLength := 0
+	for _, b := range response[2 : 2+n] {
+		maxLength = maxLength<<8 | int(b)
+	}
+	if maxLength < 3 {
+		return 0, fmt.Errorf("%w: max block length %d", ErrInvalidResponse, maxLength)
+	}
+	return maxLength, nil
+}
+
+// TransferData sends a block of the download, counter starts at 1 and wraps from 0xFF to 0x00.
+// A block sent again with the same counter is acknowledged by the ECU without writing it twice,
+// so a late acknowledgement of the previous block (sent again after no response) is skipped.
+func (c *Client) TransferData(ctx context.Context, counter byte, data []byte) error {
+	previous := func(response []byte) bool {
+		return len(response) >= 2 && response[1] == counter-1
+	}
+	response, err := c.request(ctx, previous, append([]byte{ServiceTransferData, counter}, data...)...)
+	if err != nil {
+		return err
+	}
+	if err := checkLength(response, 2); err != nil {
+		return err
+	}
+	if response[1] != counter {
+		return fmt.Errorf("%w: block %d acknowledged instead of %d", ErrInvalidResponse, response[1], counter)
+	}
+	return nil
+}
+
+// RequestTransferExit ends the download.
+func (c *Client) RequestTransferExit(ctx context.Context) error {
+	_, err := c.Request(ctx, ServiceRequestTransferExit)
+	return err
+}
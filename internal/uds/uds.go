@@ -0,0 +1,197 @@
+// Package uds implements a client of the Unified Diagnostic Services (ISO 14229-1) over an [isotp.Conn]:
+// the requests are sent to an ECU and its responses decoded, the negative ones as [*NegativeResponseError].
+package uds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+)
+
+// Service identifiers of the requests.
+const (
+	ServiceDiagnosticSessionControl = 0x10
+	ServiceECUReset                 = 0x11
+	ServiceClearDiagnosticInfo      = 0x14
+	ServiceReadDTCInformation       = 0x19
+	ServiceReadDataByIdentifier     = 0x22
+	ServiceSecurityAccess           = 0x27
+	ServiceWriteDataByIdentifier    = 0x2E
+	ServiceRoutineControl           = 0x31
+	ServiceRequestDownload          = 0x34
+	ServiceTransferData             = 0x36
+	ServiceRequestTransferExit      = 0x37
+	ServiceTesterPresent            = 0x3E
+
+	// negativeResponse is the first byte of the negative responses: 0x7F, service, NRC
+	negativeResponse = 0x7F
+	// positiveOffset is added to the service identifier in the positive responses
+	positiveOffset = 0x40
+	// suppressPositiveResponse is the bit of the sub-function asking the ECU not to answer
+	suppressPositiveResponse = 0x80
+)
+
+// serviceNames are the names of the services, for the messages
+var serviceNames = map[byte]string{
+	ServiceDiagnosticSessionControl: "DiagnosticSessionControl",
+	ServiceECUReset:                 "ECUReset",
+	ServiceClearDiagnosticInfo:      "ClearDiagnosticInformation",
+	ServiceReadDTCInformation:       "ReadDTCInformation",
+	ServiceReadDataByIdentifier:     "ReadDataByIdentifier",
+	ServiceSecurityAccess:           "SecurityAccess",
+	ServiceWriteDataByIdentifier:    "WriteDataByIdentifier",
+	ServiceRoutineControl:           "RoutineControl",
+	ServiceRequestDownload:          "RequestDownload",
+	ServiceTransferData:             "TransferData",
+	ServiceRequestTransferExit:      "RequestTransferExit",
+	ServiceTesterPresent:            "TesterPresent",
+}
+
+// ServiceName returns the name of a service, or its identifier in hex if unknown.
+func ServiceName(sid byte) string {
+	if name, ok := serviceNames[sid]; ok {
+		return name
+	}
+	return fmt.Sprintf("service 0x%02X", sid)
+}
+
+const (
+	// DefaultTimeout is how long the client waits for a response (P2 client)
+	DefaultTimeout = time.Second
+	// DefaultPendingTimeout is how long the client waits after a "response pending" (P2* client)
+	DefaultPendingTimeout = 5 * time.Second
+)
+
+// Errors of the requests.
+var (
+	ErrNoResponse      = errors.New("uds: no response")
+	ErrInvalidResponse = errors.New("uds: invalid response")
+)
+
+// NegativeResponseError is a negative response of the ECU to a request.
+type NegativeResponseError struct {
+	Service byte
+	Code    NRC
+}
+
+func (e *NegativeResponseError) Error() string {
+	return fmt.Sprintf("%s rejected: %s", ServiceName(e.Service), e.Code)
+}
+
+// IsNRC tells whether err is a negative response with the given code.
+func IsNRC(err error, code NRC) bool {
+	var nrc *NegativeResponseError
+	return errors.As(err, &nrc) && nrc.Code == code
+}
+
+// Client sends requests to an ECU, one at a time: it is safe for concurrent use.
+type Client struct {
+	conn *isotp.Conn
+
+	mu sync.Mutex // one request at a time, the responses are not tagged
+	// Timeout and PendingTimeout are the P2 and P2* timeouts of the client
+	Timeout        time.Duration
+	PendingTimeout time.Duration
+}
+
+// NewClient returns a client sending its requests on the connection.
+func NewClient(conn *isotp.Conn) *Client {
+	return &Client{
+		conn:           conn,
+		Timeout:        DefaultTimeout,
+		PendingTimeout: DefaultPendingTimeout,
+	}
+}
+
+// Conn returns the ISO-TP connection of the client.
+func (c *Client) Conn() *isotp.Conn {
+	return c.conn
+}
+
+// Request sends a request and returns the positive response, the "response pending" ones
+// extend the wait to PendingTimeout. The responses to other services are skipped (late ones).
+func (c *Client) Request(ctx context.Context, request ...byte) ([]byte, error) {
+	return c.request(ctx, nil, request...)
+}
+
+// request is Request also skipping the positive responses for which stale returns true
+// (late responses to a previous request of the same service), stale may be nil
+func (c *Client) request(ctx context.Context, stale func(response []byte) bool, request ...byte) ([]byte, error) {
+	if len(request) == 0 {
+		return nil, fmt.Errorf("uds: empty request")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sid := request[0]
+	if err := c.conn.Send(ctx, request); err != nil {
+		return nil, fmt.Errorf("uds: sending %s: %w", ServiceName(sid), err)
+	}
+
+	timeout := c.Timeout
+	for {
+		response, err := c.receive(ctx, timeout)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return nil, fmt.Errorf("%w to %s within %s", ErrNoResponse, ServiceName(sid), timeout)
+			}
+			return nil, err
+		}
+
+		switch {
+		case response[0] == sid+positiveOffset:
+			if stale != nil && stale(response) {
+				continue
+			}
+			return response, nil
+		case response[0] == negativeResponse && len(response) >= 3 && response[1] == sid:
+			code := NRC(response[2])
+			if code == NRCResponsePending {
+				timeout = c.PendingTimeout
+				continue
+			}
+			return nil, &NegativeResponseError{Service: sid, Code: code}
+		}
+		// Response to another request, skipped
+	}
+}
+
+// receive waits for the next payload of the connection for up to timeout
+func (c *Client) receive(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		response, err := c.conn.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(response) > 0 {
+			return response, nil
+		}
+	}
+}
+
+// send sends a request not expecting a response
+func (c *Client) send(ctx context.Context, request ...byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.conn.Send(ctx, request); err != nil {
+		return fmt.Errorf("uds: sending %s: %w", ServiceName(request[0]), err)
+	}
+	return nil
+}
+
+// checkLength checks that a positive response has at least n bytes
+func checkLength(response []byte, n int) error {
+	if len(response) < n {
+		return fmt.Errorf("%w: %s response of %d bytes, expected at least %d", ErrInvalidResponse, ServiceName(response[0]-positiveOffset), len(response), n)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package uds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+)
+
+// Timeouts of the test clients
+const (
+	testTimeout        = 100 * time.Millisecond
+	testPendingTimeout = 300 * time.Millisecond
+)
+
+// reply is a response of the fake ECU, sent after delay
+type reply struct {
+	delay time.Duration
+	data  []byte
+}
+
+// serveECU starts a fake ECU answering each request with the replies of handle,
+// it returns a client talking to it
+func serveECU(t *testing.T, handle func(request []byte) []reply) *Client {
+	t.Helper()
+
+	b := bus.NewMem(t.Name())
+	ecu := isotp.Dial(b, isotp.Config{TxID: 0x7E8, RxID: 0x7E0})
+	tester := isotp.Dial(b, isotp.Config{TxID: 0x7E0, RxID: 0x7E8})
+	t.Cleanup(func() {
+		tester.Close()
+		ecu.Close()
+		b.Close()
+	})
+
+	go func() {
+		for {
+			request, err := ecu.Receive(context.Background())
+			if errors.Is(err, isotp.ErrClosed) {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			for _, r := range handle(request) {
+				time.Sleep(r.delay)
+				ecu.Send(context.Background(), r.data)
+			}
+		}
+	}()
+
+	c := NewClient(tester)
+	c.Timeout, c.PendingTimeout = testTimeout, testPendingTimeout
+	return c
+}
+
+// TestRequest checks the responses pending, late and to other services
+func TestRequest(t *testing.T) {
+	pending := []byte{negativeResponse, ServiceReadDataByIdentifier, byte(NRCResponsePending)}
+	positive := []byte{ServiceReadDataByIdentifier + positiveOffset, 0xF1, 0x90, 0x42}
+
+	tests := []struct {
+		name    string
+		replies []reply
+		want    error // nil for the positive response
+		elapsed time.Duration
+	}{
+		{name: "positive", replies: []reply{{data: positive}}},
+		{
+			name:    "pending extends the timeout",
+			replies: []reply{{data: pending}, {delay: 2 * testTimeout, data: positive}},
+			elapsed: 2 * testTimeout,
+		},
+		{
+			name:    "pending again restarts the pending timeout",
+			replies: []reply{{data: pending}, {delay: testPendingTimeout / 2, data: pending}, {delay: testPendingTimeout * 3 / 4, data: positive}},
+			elapsed: testPendingTimeout * 5 / 4,
+		},
+		{
+			name:    "no response after pending",
+			replies: []reply{{data: pending}},
+			want:    ErrNoResponse,
+			elapsed: testPendingTimeout,
+		},
+		{
+			name:    "no response",
+			replies: []reply{{delay: 2 * testTimeout, data: positive}},
+			want:    ErrNoResponse,
+			elapsed: testTimeout,
+		},
+		{
+			name: "responses to other services skipped",
+			replies: []reply{
+				{data: []byte{ServiceTesterPresent + positiveOffset, 0x00}},
+				{data: []byte{negativeResponse, ServiceECUReset, byte(NRCConditionsNotCorrect)}},
+				{data: positive},
+			},
+		},
+		{
+			name:    "negative response",
+			replies: []reply{{data: []byte{negativeResponse, ServiceReadDataByIdentifier, byte(NRCRequestOutOfRange)}}},
+			want:    &NegativeResponseError{Service: ServiceReadDataByIdentifier, Code: NRCRequestOutOfRange},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := serveECU(t, func([]byte) []reply { return tt.replies })
+
+			start := time.Now()
+			response, err := c.Request(context.Background(), ServiceReadDataByIdentifier, 0xF1, 0x90)
+			elapsed := time.Since(start)
+
+			var nrc *NegativeResponseError
+			switch {
+			case tt.want == nil && err != nil:
+				t.Fatalf("Request() = %v", err)
+			case tt.want == nil && !bytes.Equal(response, positive):
+				t.Fatalf("Request() = % X, want % X", response, positive)
+			case errors.As(tt.want, &nrc):
+				if !IsNRC(err, nrc.Code) {
+					t.Fatalf("Request() = %v, want %v", err, tt.want)
+				}
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Fatalf("Request() = %v, want %v", err, tt.want)
+			}
+			if elapsed < tt.elapsed {
+				t.Errorf("returned after %s, before %s", elapsed, tt.elapsed)
+			}
+		})
+	}
+}
+
+// TestIsNRC checks the matching of the negative responses
+func TestIsNRC(t *testing.T) {
+	err := &NegativeResponseError{Service: ServiceSecurityAccess, Code: NRCInvalidKey}
+	wrapped := fmt.Errorf("unlocking: %w", err)
+
+	if !IsNRC(err, NRCInvalidKey) || !IsNRC(wrapped, NRCInvalidKey) {
+		t.Error("negative response not matched")
+	}
+	if IsNRC(err, NRCSecurityAccessDenied) || IsNRC(nil, NRCInvalidKey) || IsNRC(ErrNoResponse, NRCInvalidKey) {
+		t.Error("other errors matched")
+	}
+	if want := "SecurityAccess rejected: invalid key (0x35)"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+// TestSecurityAccess unlocks a level of a fake ECU with the key algorithms
+func TestSecurityAccess(t *testing.T) {
+	not, _ := KeyAlgorithm("not")
+	seedKey, _ := KeyAlgorithm("seed")
+	failing := func(byte, []byte) ([]byte, error) { return nil, errors.New("no key") }
+
+	tests := []struct {
+		name     string
+		level    byte
+		seed     []byte
+		echo     byte // level of the seed response, the requested one if 0
+		key      KeyFunc
+		want     error
+		requests int // requests received by the ECU
+	}{
+		{name: "unlocked", level: 0x01, seed: []byte{0x12, 0x34}, key: not, requests: 2},
+		{name: "invalid key", level: 0x01, seed: []byte{0x12, 0x34}, key: seedKey, want: &NegativeResponseError{Code: NRCInvalidKey}, requests: 2},
+		{name: "zero seed", level: 0x03, seed: []byte{0x00, 0x00}, key: failing, requests: 1},
+		{name: "key error", level: 0x01, seed: []byte{0x01}, key: failing, want: errors.New("no key"), requests: 1},
+		{name: "even level", level: 0x02, key: not, want: errors.New("invalid security level"), requests: 0},
+		{name: "seed of another level", level: 0x01, seed: []byte{0x12, 0x34}, echo: 0x03, key: not, want: ErrInvalidResponse, requests: 1},
+		{name: "empty seed", level: 0x01, key: not, want: ErrInvalidResponse, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests [][]byte
+			c := serveECU(t, func(request []byte) []reply {
+				requests = append(requests, request)
+				if request[1]%2 == 1 {
+					echo := request[1]
+					if tt.echo != 0 {
+						echo = tt.echo
+					}
+					return []reply{{data: append([]byte{request[0] + positiveOffset, echo}, tt.seed...)}}
+				}
+				// The expected key is the complement of the seed
+				for i, b := range request[2:] {
+					if b != ^tt.seed[i] {
+						return []reply{{data: []byte{negativeResponse, request[0], byte(NRCInvalidKey)}}}
+					}
+				}
+				return []reply{{data: []byte{request[0] + positiveOffset, request[1]}}}
+			})
+
+			err := c.SecurityAccess(context.Background(), tt.level, tt.key)
+			var nrc *NegativeResponseError
+			switch {
+			case tt.want == nil && err != nil:
+				t.Fatalf("SecurityAccess() = %v", err)
+			case tt.want == nil:
+			case errors.As(tt.want, &nrc):
+				if !IsNRC(err, nrc.Code) {
+					t.Fatalf("SecurityAccess() = %v, want %v", err, tt.want)
+				}
+			case errors.Is(err, tt.want):
+			case err == nil || !bytes.Contains([]byte(err.Error()), []byte(tt.want.Error())):
+				t.Fatalf("SecurityAccess() = %v, want %v", err, tt.want)
+			}
+
+			if len(requests) != tt.requests {
+				t.Fatalf("%d requests sent, want %d: % X", len(requests), tt.requests, requests)
+			}
+			if len(requests) == 2 && (requests[1][1] != tt.level+1 || len(requests[1]) != 2+len(tt.seed)) {
+				t.Fatalf("key sent as % X", requests[1])
+			}
+		})
+	}
+}
+
+// TestRequestDownload checks the parsing of the max block length
+func TestRequestDownload(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  []byte
+		maxLength int // 0 for an invalid response
+	}{
+		{"1 byte", []byte{0x74, 0x10, 0x82}, 0x82},
+		{"2 bytes", []byte{0x74, 0x20, 0x0F, 0xFF}, 0xFFF},
+		{"4 bytes", []byte{0x74, 0x40, 0x00, 0x01, 0x00, 0x02}, 0x10002},
+		{"compression nibble ignored", []byte{0x74, 0x2F, 0x01, 0x00}, 0x100},
+		{"shortest", []byte{0x74, 0x10, 0x03}, 3},
+		{"too short for a block", []byte{0x74, 0x10, 0x02}, 0},
+		{"no length", []byte{0x74, 0x00}, 0},
+		{"length of 5 bytes", []byte{0x74, 0x50, 0, 0, 0, 1, 0}, 0},
+		{"truncated", []byte{0x74, 0x20, 0x01}, 0},
+		{"missing format", []byte{0x74}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request []byte
+			c := serveECU(t, func(r []byte) []reply {
+				request = r
+				return []reply{{data: tt.response}}
+			})
+
+			got, err := c.RequestDownload(context.Background(), 0x08004000, 0x1234)
+			if tt.maxLength == 0 {
+				if !errors.Is(err, ErrInvalidResponse) {
+					t.Fatalf("RequestDownload() = %d, %v, want %v", got, err, ErrInvalidResponse)
+				}
+				return
+			}
+			if err != nil || got != tt.maxLength {
+				t.Fatalf("RequestDownload() = %d, %v, want %d", got, err, tt.maxLength)
+			}
+			want := []byte{ServiceRequestDownload, 0x00, 0x44, 0x08, 0x00, 0x40, 0x00, 0x00, 0x00, 0x12, 0x34}
+			if !bytes.Equal(request, want) {
+				t.Fatalf("request % X, want % X", request, want)
+			}
+		})
+	}
+}
+
+// TestTransferData checks the counters acknowledged, a late acknowledgement of the previous block is skipped
+func TestTransferData(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter byte
+		acks    []byte // counters acknowledged, in order
+		ok      bool
+	}{
+		{"acknowledged", 2, []byte{2}, true},
+		{"late acknowledgement first", 2, []byte{1, 2}, true},
+		{"wrapping counter", 0x00, []byte{0xFF, 0x00}, true},
+		{"another block", 2, []byte{3}, false},
+		{"only the previous block", 2, []byte{1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := serveECU(t, func([]byte) []reply {
+				var replies []reply
+				for _, ack := range tt.acks {
+					replies = append(replies, reply{data: []byte{ServiceTransferData + positiveOffset, ack}})
+				}
+				return replies
+			})
+
+			err := c.TransferData(context.Background(), tt.counter, []byte{1, 2, 3})
+			switch {
+			case tt.ok && err != nil:
+				t.Fatalf("TransferData() = %v", err)
+			case !tt.ok && err == nil:
+				t.Fatal("TransferData() succeeded")
+			}
+		})
+	}
+}
+
+// TestReadDataByIdentifier checks that the DID of the response is the one requested
+func TestReadDataByIdentifier(t *testing.T) {
+	did := uint16(0xF190)
+	c := serveECU(t, func(request []byte) []reply {
+		if request[2] == 0x90 {
+			return []reply{{data: []byte{0x62, 0xF1, 0x90, 'V', 'I', 'N'}}}
+		}
+		return []reply{{data: []byte{0x62, 0xF1, 0x90}}}
+	})
+
+	data, err := c.ReadDataByIdentifier(context.Background(), did)
+	if err != nil || string(data) != "VIN" {
+		t.Fatalf("ReadDataByIdentifier() = %q, %v", data, err)
+	}
+	if _, err := c.ReadDataByIdentifier(context.Background(), 0xF191); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("ReadDataByIdentifier() of another DID = %v, want %v", err, ErrInvalidResponse)
+	}
+}
+
+// TestDTCString checks the SAE J2012 form of the codes
+func TestDTCString(t *testing.T) {
+	tests := []struct {
+		dtc        DTC
+		want       string
+		wantStatus string
+	}{
+		{DTC{Code: 0x0A1B12, Status: 0x09}, "P0A1B-12", "testFailed|confirmed"},
+		{DTC{Code: 0x4123FF}, "C0123-FF", "-"},
+		{DTC{Code: 0x800000, Status: 0x80}, "B0000-00", "warningIndicator"},
+		{DTC{Code: 0xC1F001, Status: 0x04}, "U01F0-01", "pending"},
+		{DTC{Code: 0x3FFF00}, "P3FFF-00", "-"},
+		{DTC{Code: 0xFFFFFF, Status: 0xFF}, "U3FFF-FF", "testFailed|testFailedThisCycle|pending|confirmed|testNotCompletedSinceClear|testFailedSinceClear|testNotCompletedThisCycle|warningIndicator"},
+	}
+	for _, tt := range tests {
+		if got := tt.dtc.String(); got != tt.want {
+			t.Errorf("DTC(0x%06X).String() = %q, want %q", tt.dtc.Code, got, tt.want)
+		}
+		if got := tt.dtc.StatusText(); got != tt.wantStatus {
+			t.Errorf("DTC status 0x%02X = %q, want %q", tt.dtc.Status, got, tt.wantStatus)
+		}
+	}
+}
+
+// TestNRCString checks the descriptions of the defined codes and of the ranges
+func TestNRCString(t *testing.T) {
+	tests := []struct {
+		code NRC
+		want string
+	}{
+		{NRCSecurityAccessDenied, "security access denied (0x33)"},
+		{NRCResponsePending, "request correctly received, response pending (0x78)"},
+		{NRCVoltageTooLow, "voltage too low (0x93)"},
+		{0x00, "unknown negative response (0x00)"},
+		{0x38, "reserved by extended data link security (0x38)"},
+		{0x4F, "reserved by extended data link security (0x4F)"},
+		{0x50, "unknown negative response (0x50)"},
+		{0x81, "specific condition not correct (engine, speed, gear, brake...) (0x81)"},
+		{0x91, "specific condition not correct (engine, speed, gear, brake...) (0x91)"},
+		{0x94, "reserved for specific conditions not correct (0x94)"},
+		{0xEF, "reserved for specific conditions not correct (0xEF)"},
+		{0xF0, "condition not correct (vehicle manufacturer specific) (0xF0)"},
+		{0xFE, "condition not correct (vehicle manufacturer specific) (0xFE)"},
+		{0xFF, "unknown negative response (0xFF)"},
+	}
+	for _, tt := range tests {
+		if got := tt.code.String(); got != tt.want {
+			t.Errorf("NRC(0x%02X).String() = %q, want %q", byte(tt.code), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,597 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/canopen"
+)
+
+// Fields of the CANopen screen
+const (
+	canopenFieldNode = iota
+	canopenFieldObject
+	canopenFieldValue
+	canopenFieldNMT
+	canopenFieldEDS
+	canopenFieldCount
+)
+
+// Panels of the CANopen screen, switched with Ctrl+P
+const (
+	canopenPanelSDO = iota
+	canopenPanelPDO
+	canopenPanelCount
+)
+
+const (
+	// canopenLogSize is the number of SDO transfers kept in the log
+	canopenLogSize = 100
+	// canopenSDOTimeout bounds a whole SDO transfer, each response has canopen.DefaultSDOTimeout
+	canopenSDOTimeout = 30 * time.Second
+	// canopenHeartbeatLoss is how many heartbeat periods without one mark a node as lost
+	canopenHeartbeatLoss = 3
+)
+
+// canopenEntry is an SDO transfer of the log
+type canopenEntry struct {
+	Time     time.Time
+	Node     uint8
+	Index    uint16
+	SubIndex uint8
+	Download bool
+	Data     []byte
+	Err      error
+	Elapsed  time.Duration
+}
+
+// canopenPDO is the last frame of a PDO
+type canopenPDO struct {
+	Frame bus.Frame
+	Count uint64
+	Last  time.Time
+}
+
+// CANopenSDOMsg reports the outcome of an SDO transfer
+type CANopenSDOMsg struct {
+	monitor *canopenMonitor
+	Entry   canopenEntry
+}
+
+// canopenMonitor follows the heartbeats, emergencies and PDOs in its own goroutine. It never touches
+// the model: the UI reads its state at every tick, under its mutex
+type canopenMonitor struct {
+	mu    sync.Mutex // protects the fields below
+	nodes *canopen.NodeTable
+	pdos  map[uint32]*canopenPDO
+}
+
+func newCANopenMonitor() *canopenMonitor {
+	return &canopenMonitor{
+		nodes: canopen.NewNodeTable(),
+		pdos:  make(map[uint32]*canopenPDO),
+	}
+}
+
+// run receives the frames until the receiver is closed, it is intended as a goroutine
+func (c *canopenMonitor) run(recv bus.Receiver) {
+	defer recv.Close()
+
+	for recv.Receive() {
+		c.handle(recv.Frame())
+	}
+}
+
+// handle records a heartbeat, an emergency or a PDO
+func (c *canopenMonitor) handle(frame bus.Frame) {
+	if frame.IsExtended || frame.IsRemote {
+		return
+	}
+	t := frame.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nodes.Observe(t, frame) || !canopen.IsPDO(frame.ID) {
+		return
+	}
+	pdo, ok := c.pdos[frame.ID]
+	if !ok {
+		pdo = &canopenPDO{}
+		c.pdos[frame.ID] = pdo
+	}
+	pdo.Frame = frame
+	pdo.Count++
+	pdo.Last = t
+}
+
+// snapshot returns the nodes and the PDOs sorted by COB-ID
+func (c *canopenMonitor) snapshot() ([]canopen.Node, []canopenPDO) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pdos := make([]canopenPDO, 0, len(c.pdos))
+	for _, pdo := range c.pdos {
+		pdos = append(pdos, *pdo)
+	}
+	slices.SortFunc(pdos, func(a, b canopenPDO) int {
+		return cmp.Compare(a.Frame.ID, b.Frame.ID)
+	})
+	return c.nodes.Nodes(), pdos
+}
+
+// setupCANopen prepares the CANopen screen and starts following the nodes
+func (m *Model) setupCANopen() {
+	if m.CANopenInputs == nil {
+		m.CANopenInputs = make(map[int]*textinput.Model)
+		inputs := []struct {
+			field       int
+			placeholder string
+			width       int
+		}{
+			{canopenFieldNode, "node ID 1-127 (0 = every node for NMT)", 40},
+			{canopenFieldObject, "index:sub-index in hex, e.g. 1018:01", 40},
+			{canopenFieldValue, "value of the EDS data type, or hex bytes", 60},
+			{canopenFieldEDS, "path of the .eds file (optional)", 60},
+		}
+		for _, in := range inputs {
+			ti := textinput.New()
+			ti.Placeholder = in.placeholder
+			ti.CharLimit = 512
+			ti.Width = in.width
+			m.CANopenInputs[in.field] = &ti
+		}
+		m.CANopenInputs[canopenFieldNode].SetValue("1")
+		m.CANopenInputs[canopenFieldObject].SetValue("1000:00")
+		m.CANopenField = canopenFieldObject
+	}
+	m.focusCANopenField(m.CANopenField)
+	m.CANopenNodes = nil
+	m.CANopenPDOs = nil
+
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - CANopen disabled")
+		return
+	}
+	m.Err = nil
+	m.canopenReceiver = m.Bus.Subscribe()
+	m.canopenMonitor = newCANopenMonitor()
+	go m.canopenMonitor.run(m.canopenReceiver)
+}
+
+// closeCANopen stops following the nodes, the SDO transfers in progress are ignored
+func (m *Model) closeCANopen() {
+	if m.canopenReceiver != nil {
+		m.canopenReceiver.Close()
+		m.canopenReceiver = nil
+	}
+	m.canopenMonitor = nil
+	m.canopenBusy = false
+}
+
+// focusCANopenField moves the focus to a field of the CANopen screen
+func (m *Model) focusCANopenField(field int) {
+	m.CANopenField = (field + canopenFieldCount) % canopenFieldCount
+	for i, input := range m.CANopenInputs {
+		if i == m.CANopenField {
+			input.Focus()
+		} else {
+			input.Blur()
+		}
+	}
+}
+
+// updateCANopenNodes refreshes the nodes and PDOs from the traffic followed so far, called at every tick
+func (m *Model) updateCANopenNodes() {
+	if m.canopenMonitor == nil {
+		return
+	}
+	m.CANopenNodes, m.CANopenPDOs = m.canopenMonitor.snapshot()
+}
+
+// canopenNode parses the node ID of the screen, 0 is allowed only if broadcast is
+func (m *Model) canopenNode(broadcast bool) (uint8, error) {
+	text := strings.TrimSpace(m.CANopenInputs[canopenFieldNode].Value())
+	node, err := strconv.ParseUint(text, 0, 8)
+	if err != nil || node > canopen.MaxNodeID || node == 0 && !broadcast {
+		return 0, fmt.Errorf("invalid node ID %q: it must be between 1 and %d", text, canopen.MaxNodeID)
+	}
+	return uint8(node), nil
+}
+
+// parseCANopenObject parses an object as index:sub-index in hex, e.g. 1018:01, 1018sub1 or 1018 (sub-index 0)
+func parseCANopenObject(s string) (uint16, uint8, error) {
+	s = strings.NewReplacer("sub", ":", ".", ":", "0x", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+	indexText, subText, _ := strings.Cut(s, ":")
+	index, err := strconv.ParseUint(indexText, 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid object %q: type index:sub-index in hex, e.g. 1018:01", s)
+	}
+	sub := uint64(0)
+	if subText != "" {
+		if sub, err = strconv.ParseUint(subText, 16, 8); err != nil {
+			return 0, 0, fmt.Errorf("invalid sub-index %q", subText)
+		}
+	}
+	return uint16(index), uint8(sub), nil
+}
+
+// canopenObjectName returns the name and type of an object in the EDS, "" if not described
+func (m *Model) canopenObjectName(index uint16, sub uint8) string {
+	object, ok := m.CANopenEDS.Object(index, sub)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s, %s)", object.Name, object.DataType, object.AccessType)
+}
+
+// sendSDO starts an upload or a download of the object of the screen, the outcome is posted with CANopenSDOMsg
+func (m *Model) sendSDO(download bool) tea.Cmd {
+	if m.canopenMonitor == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - CANopen disabled")
+		return nil
+	}
+	if m.canopenBusy {
+		m.SendStatus = "⏳ Wait for the SDO transfer in progress"
+		return nil
+	}
+	node, err := m.canopenNode(false)
+	if err != nil {
+		m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+		return nil
+	}
+	index, sub, err := parseCANopenObject(m.CANopenInputs[canopenFieldObject].Value())
+	if err != nil {
+		m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+		return nil
+	}
+
+	var data []byte
+	if download {
+		value := m.CANopenInputs[canopenFieldValue].Value()
+		if object, ok := m.CANopenEDS.Object(index, sub); ok && (object.DataType.Size() > 0 || object.DataType == canopen.VisibleString) {
+			data, err = canopen.ParseValue(object.DataType, value)
+		} else {
+			data, err = parseHexBytes(value)
+		}
+		if err != nil {
+			m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+			return nil
+		}
+	}
+
+	b := m.Bus
+	monitor := m.canopenMonitor
+	m.canopenBusy = true
+	m.SendStatus = fmt.Sprintf("⏳ SDO %04X:%02X of node %d...", index, sub, node)
+	return func() tea.Msg {
+		entry := canopenEntry{Time: time.Now(), Node: node, Index: index, SubIndex: sub, Download: download, Data: data}
+		client, err := canopen.NewClient(b, node)
+		if err != nil {
+			entry.Err = err
+			return CANopenSDOMsg{monitor: monitor, Entry: entry}
+		}
+		defer client.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), canopenSDOTimeout)
+		defer cancel()
+		if download {
+			entry.Err = client.Download(ctx, index, sub, data)
+		} else {
+			entry.Data, entry.Err = client.Upload(ctx, index, sub)
+		}
+		entry.Elapsed = time.Since(entry.Time)
+		return CANopenSDOMsg{monitor: monitor, Entry: entry}
+	}
+}
+
+// handleCANopenSDO logs the outcome of an SDO transfer
+func (m *Model) handleCANopenSDO(msg CANopenSDOMsg) {
+	if msg.monitor != m.canopenMonitor {
+		return // the screen was closed in the meantime
+	}
+	m.canopenBusy = false
+	m.CANopenLog = append(m.CANopenLog, msg.Entry)
+	if len(m.CANopenLog) > canopenLogSize {
+		m.CANopenLog = m.CANopenLog[len(m.CANopenLog)-canopenLogSize:]
+	}
+
+	entry := msg.Entry
+	switch {
+	case entry.Err != nil:
+		m.SendStatus = fmt.Sprintf("❌ %v", entry.Err)
+	case entry.Download:
+		m.SendStatus = fmt.Sprintf("✅ %d bytes written to %04X:%02X of node %d", len(entry.Data), entry.Index, entry.SubIndex, entry.Node)
+	default:
+		m.SendStatus = fmt.Sprintf("✅ %04X:%02X of node %d = %s", entry.Index, entry.SubIndex, entry.Node, m.formatCANopenValue(entry))
+	}
+}
+
+// formatCANopenValue renders the data of a transfer with the data type of the EDS, in hex otherwise
+func (m *Model) formatCANopenValue(entry canopenEntry) string {
+	if object, ok := m.CANopenEDS.Object(entry.Index, entry.SubIndex); ok {
+		if value, ok := canopen.FormatValue(object.DataType, entry.Data); ok {
+			return value
+		}
+	}
+	return formatDiagData(entry.Data)
+}
+
+// sendNMT sends the chosen NMT command to the node of the screen (0 for every node)
+func (m *Model) sendNMT() {
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available")
+		return
+	}
+	node, err := m.canopenNode(true)
+	if err != nil {
+		m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+		return
+	}
+	cmd := canopen.NMTCommands[m.CANopenNMT]
+	if err := canopen.SendNMT(context.Background(), m.Bus, cmd, node); err != nil {
+		m.Err = fmt.Errorf("error sending the NMT command: %w", err)
+		return
+	}
+
+	target := fmt.Sprintf("node %d", node)
+	if node == 0 {
+		target = "every node"
+	}
+	m.Err = nil
+	m.SendStatus = fmt.Sprintf("📨 NMT %s sent to %s", cmd, target)
+}
+
+// loadCANopenEDS loads the EDS file typed on the screen, an empty path forgets the current one
+func (m *Model) loadCANopenEDS() {
+	path := strings.TrimSpace(m.CANopenInputs[canopenFieldEDS].Value())
+	if path == "" {
+		m.CANopenEDS = nil
+		m.SendStatus = "📖 No EDS: the values are shown in hex"
+		return
+	}
+	eds, err := canopen.LoadEDS(path)
+	if err != nil {
+		m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+		return
+	}
+	m.CANopenEDS = eds
+	m.SendStatus = fmt.Sprintf("📖 %s: %d objects", path, eds.Len())
+	if eds.Product != "" {
+		m.SendStatus += " of " + eds.Product
+	}
+}
+
+// updateCANopen handles the keys of the CANopen screen
+func (m *Model) updateCANopen(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "shift+tab":
+		m.focusCANopenField(m.CANopenField - 1)
+		return nil
+	case "down":
+		m.focusCANopenField(m.CANopenField + 1)
+		return nil
+	case "ctrl+p":
+		m.CANopenPanel = (m.CANopenPanel + 1) % canopenPanelCount
+		return nil
+	case "ctrl+l":
+		m.CANopenLog = nil
+		return nil
+	case "enter":
+		switch m.CANopenField {
+		case canopenFieldNode, canopenFieldObject:
+			return m.sendSDO(false)
+		case canopenFieldValue:
+			return m.sendSDO(true)
+		case canopenFieldNMT:
+			m.sendNMT()
+		case canopenFieldEDS:
+			m.loadCANopenEDS()
+		}
+		return nil
+	case "left", "right", "<", ">":
+		if m.CANopenField == canopenFieldNMT {
+			delta := 1
+			if keyMsg.String() == "left" || keyMsg.String() == "<" {
+				delta = -1
+			}
+			m.CANopenNMT = (m.CANopenNMT + delta + len(canopen.NMTCommands)) % len(canopen.NMTCommands)
+			return nil
+		}
+	}
+
+	if input, ok := m.CANopenInputs[m.CANopenField]; ok {
+		var cmd tea.Cmd
+		*input, cmd = input.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// canopenView renders the CANopen screen
+func (m Model) canopenView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("🔧 CANopen (CiA 301)"))
+	s.WriteString("\n\n")
+	s.WriteString("↑/↓ field • Enter on node/object: SDO upload • Enter on value: SDO download • Enter on NMT: send (←/→ command) • Enter on EDS: load\n")
+	s.WriteString("Ctrl+P switch SDO log/PDOs • Ctrl+L clear log • Tab back to mode selection • Ctrl+C quit")
+	s.WriteString("\n\n")
+
+	object := ""
+	if index, sub, err := parseCANopenObject(m.CANopenInputs[canopenFieldObject].Value()); err == nil {
+		object = m.canopenObjectName(index, sub)
+	}
+	eds := "(none)"
+	if m.CANopenEDS != nil {
+		eds = fmt.Sprintf("%d objects", m.CANopenEDS.Len())
+		if m.CANopenEDS.Product != "" {
+			eds += " of " + m.CANopenEDS.Product
+		}
+	}
+
+	fields := []struct {
+		label string
+		value string
+	}{
+		canopenFieldNode:   {"Node", m.CANopenInputs[canopenFieldNode].View()},
+		canopenFieldObject: {"Object", m.CANopenInputs[canopenFieldObject].View() + " " + object},
+		canopenFieldValue:  {"Value", m.CANopenInputs[canopenFieldValue].View()},
+		canopenFieldNMT:    {"NMT", "◀ " + canopen.NMTCommands[m.CANopenNMT].String() + " ▶"},
+		canopenFieldEDS:    {"EDS", m.CANopenInputs[canopenFieldEDS].View() + " " + eds},
+	}
+	for i, field := range fields {
+		cursor := "  "
+		if i == m.CANopenField {
+			cursor = "▶ "
+		}
+		s.WriteString(fmt.Sprintf("%s%-7s %s\n", cursor, field.label+":", field.value))
+	}
+	s.WriteString("\n")
+
+	// Nodes seen through their heartbeats and emergencies
+	now := time.Now()
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("%-5s  %-22s  %-10s  %-8s  %-8s  %s", "Node", "State", "Period", "Age", "Boot-ups", "Last EMCY")))
+	s.WriteString("\n")
+	if len(m.CANopenNodes) == 0 {
+		s.WriteString("(no heartbeat yet)\n")
+	}
+	for _, node := range m.CANopenNodes {
+		state, period, age := "-", "-", "-"
+		if node.Heartbeats > 0 {
+			state = canopenStateLabel(node.State)
+			age = formatAge(now.Sub(node.Last))
+			if node.Period > 0 {
+				period = fmt.Sprintf("%dms", node.Period.Milliseconds())
+				if now.Sub(node.Last) > canopenHeartbeatLoss*node.Period {
+					state = "⛔ heartbeat lost"
+				}
+			}
+		}
+		emergency := "-"
+		if node.Emergencies > 0 {
+			emergency = fmt.Sprintf("0x%04X reg 0x%02X (%s ago, %d total)", node.EmergencyCode, node.EmergencyRegister, formatAge(now.Sub(node.LastEmergency)), node.Emergencies)
+			if node.EmergencyCode == 0 {
+				emergency = fmt.Sprintf("✅ errors reset (%s ago)", formatAge(now.Sub(node.LastEmergency)))
+			}
+		}
+		s.WriteString(fmt.Sprintf("%-5d  %-22s  %-10s  %-8s  %-8d  %s\n", node.ID, state, period, age, node.BootUps, emergency))
+	}
+	s.WriteString("\n")
+
+	var lines []string
+	switch m.CANopenPanel {
+	case canopenPanelSDO:
+		s.WriteString(lipgloss.NewStyle().Bold(true).Render("SDO transfers"))
+		s.WriteString("\n")
+		lines = m.canopenLogLines()
+	case canopenPanelPDO:
+		s.WriteString(lipgloss.NewStyle().Bold(true).Render("PDOs (decoded with the DBC)"))
+		s.WriteString("\n")
+		lines = m.canopenPDOLines(now)
+	}
+	rows := max(3, m.Height-canopenFieldCount-len(m.CANopenNodes)-17)
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	s.WriteString(strings.Join(lines, "\n") + "\n")
+
+	if m.Err != nil {
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", m.wrapStatus(fmt.Sprintf("⚠️ %v", m.Err), m.Width)))
+	} else if m.SendStatus != "" {
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", m.wrapStatus(m.SendStatus, m.Width)))
+	}
+
+	return s.String()
+}
+
+// canopenStateLabel returns the NMT state with its emoji
+func canopenStateLabel(state canopen.State) string {
+	switch state {
+	case canopen.StateOperational:
+		return "🟢 " + state.String()
+	case canopen.StatePreOperational:
+		return "🟡 " + state.String()
+	case canopen.StateStopped:
+		return "🔴 " + state.String()
+	case canopen.StateBootUp:
+		return "🔄 " + state.String()
+	}
+	return "❔ " + state.String()
+}
+
+// canopenLogLines renders the SDO transfers, the newest at the bottom
+func (m Model) canopenLogLines() []string {
+	if len(m.CANopenLog) == 0 {
+		return []string{"(no SDO transfer yet)"}
+	}
+
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+	lines := make([]string, 0, 2*len(m.CANopenLog))
+	for _, entry := range m.CANopenLog {
+		direction := "⬆️  Upload  "
+		if entry.Download {
+			direction = "⬇️  Download"
+		}
+		line := fmt.Sprintf("%s  %s node %d %04X:%02X", entry.Time.Format("15:04:05.000"), direction, entry.Node, entry.Index, entry.SubIndex)
+		if name := m.canopenObjectName(entry.Index, entry.SubIndex); name != "" {
+			line += " " + name
+		}
+		lines = append(lines, line)
+
+		switch {
+		case entry.Err != nil:
+			lines = append(lines, "              "+errorStyle.Render(fmt.Sprintf("❌ %v", entry.Err)))
+		case entry.Download:
+			lines = append(lines, fmt.Sprintf("              ✅ %s written in %s", formatPayload(entry.Data, isotpShownBytes), entry.Elapsed.Round(time.Millisecond)))
+		default:
+			lines = append(lines, fmt.Sprintf("              ✅ %s (%d bytes in %s)", m.formatCANopenValue(entry), len(entry.Data), entry.Elapsed.Round(time.Millisecond)))
+		}
+	}
+	return lines
+}
+
+// canopenPDOLines renders the last frame of each PDO with its signals, if the DBC declares its COB-ID
+func (m Model) canopenPDOLines(now time.Time) []string {
+	if len(m.CANopenPDOs) == 0 {
+		return []string{"(no PDO yet)"}
+	}
+
+	lines := make([]string, 0, len(m.CANopenPDOs))
+	for _, pdo := range m.CANopenPDOs {
+		service, node := canopen.ParseCOBID(pdo.Frame.ID)
+		line := fmt.Sprintf("0x%03X  %-5s node %-3d  %6d frames  %8s  ", pdo.Frame.ID, service, node, pdo.Count, formatAge(now.Sub(pdo.Last)))
+
+		if m.Decoder == nil {
+			lines = append(lines, line+formatPayload(pdo.Frame.Payload(), 8))
+			continue
+		}
+		msg, ok := m.Decoder.Lookup(pdo.Frame.ID, false)
+		if !ok {
+			lines = append(lines, line+formatPayload(pdo.Frame.Payload(), 8)+" (not in the DBC)")
+			continue
+		}
+		signals := formatSignals(m.Decoder.Decode(context.Background(), pdo.Frame.ID, false, pdo.Frame.Payload()))
+		lines = append(lines, line+msg.Name()+": "+signals)
+	}
+	return lines
+}
@@ -0,0 +1,643 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+	"github.com/squadracorsepolito/can-debug/internal/uds"
+)
+
+// Fields of the diagnostics screen
+const (
+	diagFieldTarget = iota
+	diagFieldTx
+	diagFieldRx
+	diagFieldService
+	diagFieldArgs
+	diagFieldKey
+	diagFieldCount
+)
+
+const (
+	// diagLogSize is the number of requests kept in the log of the diagnostics screen
+	diagLogSize = 100
+	// diagRequestTimeout bounds a request, "response pending" waits included
+	diagRequestTimeout = 30 * time.Second
+)
+
+// diagService is a service that can be requested from the diagnostics screen
+type diagService struct {
+	SID         byte
+	Name        string
+	Placeholder string // help of the arguments
+	Default     string // arguments set when the service is chosen
+}
+
+// diagServices are the services of the diagnostics screen, in the order they are chosen
+var diagServices = []diagService{
+	{uds.ServiceDiagnosticSessionControl, "DiagnosticSessionControl", "session: 01 default, 02 programming, 03 extended", "03"},
+	{uds.ServiceTesterPresent, "TesterPresent", "no arguments", ""},
+	{uds.ServiceReadDataByIdentifier, "ReadDataByIdentifier", "DID, e.g. F190", "F190"},
+	{uds.ServiceWriteDataByIdentifier, "WriteDataByIdentifier", "DID and data, e.g. F190 01 02 03", "F190 "},
+	{uds.ServiceReadDTCInformation, "ReadDTCInformation", "status mask, e.g. FF (all) or 08 (confirmed)", "FF"},
+	{uds.ServiceClearDiagnosticInfo, "ClearDiagnosticInformation", "DTC group, FFFFFF = all", "FFFFFF"},
+	{uds.ServiceECUReset, "ECUReset", "01 hard, 02 key off/on, 03 soft", "01"},
+	{uds.ServiceSecurityAccess, "SecurityAccess", "level (odd), e.g. 01", "01"},
+	{0, "Raw request", "hex bytes, e.g. 22 F1 90", ""},
+}
+
+// diagEntry is a request of the diagnostics screen with its outcome
+type diagEntry struct {
+	Time    time.Time
+	Request string
+	Lines   []string // decoded positive response
+	Err     error
+}
+
+// DiagResultMsg carries the outcome of a diagnostic request
+type DiagResultMsg struct {
+	client  *uds.Client
+	Service byte
+	Arg     byte // first argument of the request (session, reset type...)
+	Entry   diagEntry
+}
+
+// DiagKeepAliveMsg reports that the TesterPresent keep-alive of a client stopped
+type DiagKeepAliveMsg struct {
+	client *uds.Client
+	Err    error
+}
+
+// setupDiagnostics prepares the diagnostics screen, on the first visit the target is the first
+// ECU reachable by the DIAG_TOOL node of the DBC
+func (m *Model) setupDiagnostics() tea.Cmd {
+	if m.DiagInputs == nil {
+		m.DiagTargets, m.DiagMixed = can.DiagTargets(m.Messages)
+
+		m.DiagInputs = make(map[int]*textinput.Model)
+		for _, field := range []int{diagFieldTx, diagFieldRx, diagFieldArgs} {
+			ti := textinput.New()
+			ti.CharLimit = 12
+			ti.Width = 30
+			m.DiagInputs[field] = &ti
+		}
+		m.DiagInputs[diagFieldTx].Placeholder = "hex ID, e.g. 7E0"
+		m.DiagInputs[diagFieldRx].Placeholder = "hex ID, e.g. 7E8"
+		m.DiagInputs[diagFieldArgs].CharLimit = 3 * 4095
+		m.DiagInputs[diagFieldArgs].Width = 60
+
+		m.DiagInputs[diagFieldTx].SetValue("7E0")
+		m.DiagInputs[diagFieldRx].SetValue("7E8")
+		if len(m.DiagTargets) > 0 {
+			m.selectDiagTarget(0)
+		}
+		m.selectDiagService(0)
+		m.DiagField = diagFieldService
+	}
+	m.focusDiagField(m.DiagField)
+
+	return m.openDiagnostics()
+}
+
+// focusDiagField moves the focus to a field of the diagnostics screen
+func (m *Model) focusDiagField(field int) {
+	m.DiagField = (field + diagFieldCount) % diagFieldCount
+	for i, input := range m.DiagInputs {
+		if i == m.DiagField {
+			input.Focus()
+		} else {
+			input.Blur()
+		}
+	}
+}
+
+// setTargetIDs fills the TX/RX ID inputs with the IDs of a target of the DBC
+func setTargetIDs(target can.DiagTarget, tx, rx *textinput.Model) {
+	tx.SetValue(strings.TrimPrefix(can.FormatID(target.TxID, target.Extended), "0x"))
+	rx.SetValue(strings.TrimPrefix(can.FormatID(target.RxID, target.Extended), "0x"))
+}
+
+// targetIndex returns the index of the target of the DBC with the IDs of the inputs, -1 if none
+func targetIndex(targets []can.DiagTarget, tx, rx *textinput.Model) int {
+	txID, _ := parseHex(tx.Value(), 29)
+	rxID, _ := parseHex(rx.Value(), 29)
+	for i, target := range targets {
+		if uint64(target.TxID) == txID && uint64(target.RxID) == rxID {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextTarget returns the target chosen by ←/→ from the current one: from custom IDs the first one
+func nextTarget(targets []can.DiagTarget, current, delta int) int {
+	if current < 0 {
+		return 0
+	}
+	return (current + delta + len(targets)) % len(targets)
+}
+
+// targetConfig parses the TX/RX ID inputs into the ISO-TP configuration of the tester,
+// the IDs above 0x7FF are extended and both IDs must have the same format
+func targetConfig(tx, rx *textinput.Model) (isotp.Config, error) {
+	txID, err := parseHex(tx.Value(), 29)
+	if err != nil {
+		return isotp.Config{}, fmt.Errorf("invalid TX ID %q", tx.Value())
+	}
+	rxID, err := parseHex(rx.Value(), 29)
+	if err != nil {
+		return isotp.Config{}, fmt.Errorf("invalid RX ID %q", rx.Value())
+	}
+	if txID == rxID {
+		return isotp.Config{}, fmt.Errorf("TX and RX IDs must be different")
+	}
+	extended := txID > 0x7FF
+	if extended != (rxID > 0x7FF) {
+		return isotp.Config{}, fmt.Errorf("TX and RX IDs must be both standard (up to 7FF) or both extended")
+	}
+
+	return isotp.Config{
+		TxID:     uint32(txID),
+		RxID:     uint32(rxID),
+		Extended: extended,
+		Padding:  true,
+		PadByte:  isotp.DefaultPadByte,
+	}, nil
+}
+
+// mixedTargetsNote returns the line reporting the ECUs of the DBC that are not targets
+// because their pair mixes standard and extended IDs, "" if none
+func mixedTargetsNote(mixed []string) string {
+	if len(mixed) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  ⚠️  Not targets, their messages with %s mix standard and extended IDs: %s\n",
+		can.DiagTool, strings.Join(mixed, ", "))
+}
+
+// selectDiagTarget sets the IDs of a target of the DBC
+func (m *Model) selectDiagTarget(i int) {
+	setTargetIDs(m.DiagTargets[i], m.DiagInputs[diagFieldTx], m.DiagInputs[diagFieldRx])
+}
+
+// diagTarget returns the index of the target of the DBC with the IDs of the screen, -1 if none
+func (m *Model) diagTarget() int {
+	return targetIndex(m.DiagTargets, m.DiagInputs[diagFieldTx], m.DiagInputs[diagFieldRx])
+}
+
+// selectDiagService chooses the service to request and sets its default arguments
+func (m *Model) selectDiagService(i int) {
+	m.DiagService = (i + len(diagServices)) % len(diagServices)
+	service := diagServices[m.DiagService]
+	m.DiagInputs[diagFieldArgs].Placeholder = service.Placeholder
+	m.DiagInputs[diagFieldArgs].SetValue(service.Default)
+	m.DiagInputs[diagFieldArgs].CursorEnd()
+}
+
+// openDiagnostics (re)opens the connection with the ECU with the IDs of the screen
+func (m *Model) openDiagnostics() tea.Cmd {
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - diagnostics disabled")
+		return nil
+	}
+
+	cfg, err := targetConfig(m.DiagInputs[diagFieldTx], m.DiagInputs[diagFieldRx])
+	if err != nil {
+		m.Err = err
+		return nil
+	}
+
+	m.closeDiagnostics()
+	m.Err = nil
+	m.diagClient = uds.NewClient(isotp.Dial(m.Bus, cfg))
+
+	target := "custom IDs"
+	if i := m.diagTarget(); i >= 0 {
+		target = m.DiagTargets[i].Node
+	}
+	m.SendStatus = fmt.Sprintf("🩺 Talking to %s: requests on %s, responses on %s",
+		target, can.FormatID(cfg.TxID, cfg.Extended), can.FormatID(cfg.RxID, cfg.Extended))
+
+	return nil
+}
+
+// closeDiagnostics stops the keep-alive and closes the connection with the ECU (if any)
+func (m *Model) closeDiagnostics() {
+	m.stopDiagKeepAlive()
+	if m.diagClient == nil {
+		return
+	}
+	m.diagClient.Conn().Close()
+	m.diagClient = nil
+}
+
+// startDiagKeepAlive starts sending TesterPresent periodically, to keep a non-default session active
+func (m *Model) startDiagKeepAlive() tea.Cmd {
+	if m.diagClient == nil || m.diagKeepAlive != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.diagKeepAlive = cancel
+
+	client := m.diagClient
+	return func() tea.Msg {
+		err := client.KeepAlive(ctx, uds.DefaultKeepAliveInterval)
+		return DiagKeepAliveMsg{client: client, Err: err}
+	}
+}
+
+// stopDiagKeepAlive stops the periodic TesterPresent (if running)
+func (m *Model) stopDiagKeepAlive() {
+	if m.diagKeepAlive == nil {
+		return
+	}
+	m.diagKeepAlive()
+	m.diagKeepAlive = nil
+}
+
+// toggleDiagKeepAlive starts/stops the periodic TesterPresent
+func (m *Model) toggleDiagKeepAlive() tea.Cmd {
+	if m.diagKeepAlive != nil {
+		m.stopDiagKeepAlive()
+		m.SendStatus = "💤 TesterPresent keep-alive stopped"
+		return nil
+	}
+	m.SendStatus = fmt.Sprintf("💓 TesterPresent sent every %s", uds.DefaultKeepAliveInterval)
+	return m.startDiagKeepAlive()
+}
+
+// diagRun is a parsed request of the diagnostics screen, run in the background
+type diagRun func(ctx context.Context, client *uds.Client) ([]string, error)
+
+// parseDiagRequest parses the arguments of the chosen service into the request to run
+func (m *Model) parseDiagRequest() (string, byte, diagRun, error) {
+	service := diagServices[m.DiagService]
+	args, err := parseHexBytes(m.DiagInputs[diagFieldArgs].Value())
+	if err != nil {
+		return "", 0, nil, err
+	}
+	arg := byte(0)
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	request := strings.TrimSpace(service.Name + " " + formatPayload(args, len(args)))
+
+	needs := func(n int, what string) error {
+		if len(args) != n {
+			return fmt.Errorf("%s expects %s", service.Name, what)
+		}
+		return nil
+	}
+
+	switch service.SID {
+	case uds.ServiceDiagnosticSessionControl:
+		if err := needs(1, "the session (1 byte)"); err != nil {
+			return "", 0, nil, err
+		}
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			timing, err := client.DiagnosticSessionControl(ctx, arg)
+			if err != nil {
+				return nil, err
+			}
+			line := fmt.Sprintf("✅ Session 0x%02X active", arg)
+			if timing != (uds.SessionTiming{}) {
+				line += fmt.Sprintf(" (P2 %s, P2* %s)", timing.P2, timing.P2Star)
+			}
+			return []string{line}, nil
+		}, nil
+
+	case uds.ServiceTesterPresent:
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			if err := client.TesterPresent(ctx, false); err != nil {
+				return nil, err
+			}
+			return []string{"✅ ECU present"}, nil
+		}, nil
+
+	case uds.ServiceReadDataByIdentifier:
+		if err := needs(2, "the DID (2 bytes)"); err != nil {
+			return "", 0, nil, err
+		}
+		did := uint16(args[0])<<8 | uint16(args[1])
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			data, err := client.ReadDataByIdentifier(ctx, did)
+			if err != nil {
+				return nil, err
+			}
+			return []string{fmt.Sprintf("0x%04X = %s", did, formatDiagData(data))}, nil
+		}, nil
+
+	case uds.ServiceWriteDataByIdentifier:
+		if len(args) < 3 {
+			return "", 0, nil, fmt.Errorf("%s expects the DID (2 bytes) and the data", service.Name)
+		}
+		did := uint16(args[0])<<8 | uint16(args[1])
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			if err := client.WriteDataByIdentifier(ctx, did, args[2:]); err != nil {
+				return nil, err
+			}
+			return []string{fmt.Sprintf("✅ 0x%04X written (%d bytes)", did, len(args)-2)}, nil
+		}, nil
+
+	case uds.ServiceReadDTCInformation:
+		if err := needs(1, "the status mask (1 byte)"); err != nil {
+			return "", 0, nil, err
+		}
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			dtcs, err := client.ReadDTCsByStatus(ctx, arg)
+			if err != nil {
+				return nil, err
+			}
+			lines := []string{fmt.Sprintf("✅ %d DTCs", len(dtcs))}
+			for _, dtc := range dtcs {
+				lines = append(lines, fmt.Sprintf("%s  status 0x%02X %s", dtc, dtc.Status, dtc.StatusText()))
+			}
+			return lines, nil
+		}, nil
+
+	case uds.ServiceClearDiagnosticInfo:
+		if err := needs(3, "the DTC group (3 bytes)"); err != nil {
+			return "", 0, nil, err
+		}
+		group := uint32(args[0])<<16 | uint32(args[1])<<8 | uint32(args[2])
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			if err := client.ClearDiagnosticInformation(ctx, group); err != nil {
+				return nil, err
+			}
+			return []string{"✅ DTCs cleared"}, nil
+		}, nil
+
+	case uds.ServiceECUReset:
+		if err := needs(1, "the reset type (1 byte)"); err != nil {
+			return "", 0, nil, err
+		}
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			if err := client.ECUReset(ctx, arg); err != nil {
+				return nil, err
+			}
+			return []string{"✅ ECU reset"}, nil
+		}, nil
+
+	case uds.ServiceSecurityAccess:
+		if err := needs(1, "the security level (1 byte)"); err != nil {
+			return "", 0, nil, err
+		}
+		algorithms := uds.KeyAlgorithms()
+		name := algorithms[m.DiagKeyAlgorithm%len(algorithms)]
+		key, _ := uds.KeyAlgorithm(name)
+		request += " (key: " + name + ")"
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			if err := client.SecurityAccess(ctx, arg, key); err != nil {
+				return nil, err
+			}
+			return []string{fmt.Sprintf("🔓 Level 0x%02X unlocked", arg)}, nil
+		}, nil
+
+	default:
+		if len(args) == 0 {
+			return "", 0, nil, fmt.Errorf("type the bytes of the request")
+		}
+		return request, arg, func(ctx context.Context, client *uds.Client) ([]string, error) {
+			response, err := client.Request(ctx, args...)
+			if err != nil {
+				return nil, err
+			}
+			return []string{"✅ " + formatPayload(response, isotpShownBytes)}, nil
+		}, nil
+	}
+}
+
+// sendDiagRequest sends the request of the screen in the background
+func (m *Model) sendDiagRequest() tea.Cmd {
+	if m.diagClient == nil {
+		m.SendStatus = "⚠️ No connection with the ECU, check the IDs and press Enter on them"
+		return nil
+	}
+
+	request, arg, run, err := m.parseDiagRequest()
+	if err != nil {
+		m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+		return nil
+	}
+
+	client := m.diagClient
+	sid := diagServices[m.DiagService].SID
+	m.SendStatus = fmt.Sprintf("⏳ %s...", request)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), diagRequestTimeout)
+		defer cancel()
+
+		entry := diagEntry{Time: time.Now(), Request: request}
+		entry.Lines, entry.Err = run(ctx, client)
+		return DiagResultMsg{client: client, Service: sid, Arg: arg, Entry: entry}
+	}
+}
+
+// handleDiagResult logs the outcome of a request, the sessions other than the default one
+// start the TesterPresent keep-alive and the default session or a reset stop it
+func (m *Model) handleDiagResult(msg DiagResultMsg) tea.Cmd {
+	if msg.client != m.diagClient {
+		return nil // the connection was reopened in the meantime
+	}
+
+	m.DiagLog = append(m.DiagLog, msg.Entry)
+	if len(m.DiagLog) > diagLogSize {
+		m.DiagLog = m.DiagLog[len(m.DiagLog)-diagLogSize:]
+	}
+
+	if msg.Entry.Err != nil {
+		m.SendStatus = fmt.Sprintf("❌ %v", msg.Entry.Err)
+		return nil
+	}
+	m.SendStatus = msg.Entry.Lines[0]
+
+	switch {
+	case msg.Service == uds.ServiceDiagnosticSessionControl && msg.Arg != uds.SessionDefault:
+		if m.diagKeepAlive == nil {
+			m.SendStatus += fmt.Sprintf(" • 💓 TesterPresent sent every %s", uds.DefaultKeepAliveInterval)
+		}
+		return m.startDiagKeepAlive()
+	case msg.Service == uds.ServiceDiagnosticSessionControl, msg.Service == uds.ServiceECUReset:
+		m.stopDiagKeepAlive()
+	}
+	return nil
+}
+
+// handleDiagKeepAlive reports a keep-alive stopped by an error
+func (m *Model) handleDiagKeepAlive(msg DiagKeepAliveMsg) {
+	if msg.client != m.diagClient || msg.Err == nil {
+		return // stopped on purpose
+	}
+	m.stopDiagKeepAlive()
+	m.SendStatus = fmt.Sprintf("⚠️ TesterPresent keep-alive stopped: %v", msg.Err)
+}
+
+// updateDiagnostics handles the keys of the diagnostics screen
+func (m *Model) updateDiagnostics(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "shift+tab":
+		m.focusDiagField(m.DiagField - 1)
+		return nil
+	case "down":
+		m.focusDiagField(m.DiagField + 1)
+		return nil
+	case "ctrl+t":
+		return m.toggleDiagKeepAlive()
+	case "ctrl+l":
+		m.DiagLog = nil
+		return nil
+	case "enter":
+		switch m.DiagField {
+		case diagFieldTarget, diagFieldTx, diagFieldRx:
+			return m.openDiagnostics()
+		default:
+			return m.sendDiagRequest()
+		}
+	case "left", "right", "<", ">":
+		delta := 1
+		if keyMsg.String() == "left" || keyMsg.String() == "<" {
+			delta = -1
+		}
+		switch m.DiagField {
+		case diagFieldTarget:
+			if len(m.DiagTargets) == 0 {
+				return nil
+			}
+			m.selectDiagTarget(nextTarget(m.DiagTargets, m.diagTarget(), delta))
+			return m.openDiagnostics()
+		case diagFieldService:
+			m.selectDiagService(m.DiagService + delta)
+			return nil
+		case diagFieldKey:
+			m.DiagKeyAlgorithm = (m.DiagKeyAlgorithm + delta + len(uds.KeyAlgorithms())) % len(uds.KeyAlgorithms())
+			return nil
+		}
+	}
+
+	if input, ok := m.DiagInputs[m.DiagField]; ok {
+		var cmd tea.Cmd
+		*input, cmd = input.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// parseHex parses an unsigned number written in hex, with or without the 0x prefix
+func parseHex(s string, bits int) (uint64, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	return strconv.ParseUint(s, 16, bits)
+}
+
+// formatDiagData renders the data of a DID in hex, followed by its text when printable
+func formatDiagData(data []byte) string {
+	if len(data) == 0 {
+		return "(empty)"
+	}
+	s := formatPayload(data, isotpShownBytes)
+
+	printable := true
+	for _, b := range data {
+		if b < 0x20 || b > 0x7E {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		s += fmt.Sprintf(" %q", data)
+	}
+	return s
+}
+
+// diagnosticsView renders the diagnostics screen
+func (m Model) diagnosticsView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("🩺 UDS diagnostics (ISO 14229)"))
+	s.WriteString("\n\n")
+
+	s.WriteString("↑/↓ field • ←/→ choose target/service/key algorithm • Enter on the target/IDs: connect • Enter on the service/arguments: send\n")
+	s.WriteString("Ctrl+T TesterPresent keep-alive • Ctrl+L clear log • Tab back to mode selection • Ctrl+C quit")
+	s.WriteString("\n\n")
+
+	target := "custom IDs"
+	if i := m.diagTarget(); i >= 0 {
+		target = fmt.Sprintf("%s (%d of %d in the DBC)", m.DiagTargets[i].Node, i+1, len(m.DiagTargets))
+	} else if len(m.DiagTargets) == 0 {
+		target = fmt.Sprintf("custom IDs (no ECU exchanges messages with %s in the DBC)", can.DiagTool)
+	}
+	algorithms := uds.KeyAlgorithms()
+
+	fields := []struct {
+		label string
+		value string
+	}{
+		diagFieldTarget:  {"Target", "◀ " + target + " ▶"},
+		diagFieldTx:      {"TX ID", m.DiagInputs[diagFieldTx].View()},
+		diagFieldRx:      {"RX ID", m.DiagInputs[diagFieldRx].View()},
+		diagFieldService: {"Service", "◀ " + diagServices[m.DiagService].Name + " ▶"},
+		diagFieldArgs:    {"Arguments", m.DiagInputs[diagFieldArgs].View()},
+		diagFieldKey:     {"Key algo", "◀ " + algorithms[m.DiagKeyAlgorithm%len(algorithms)] + " ▶ (SecurityAccess)"},
+	}
+	for i, field := range fields {
+		cursor := "  "
+		if i == m.DiagField {
+			cursor = "▶ "
+		}
+		s.WriteString(fmt.Sprintf("%s%-10s %s\n", cursor, field.label+":", field.value))
+	}
+	mixed := mixedTargetsNote(m.DiagMixed)
+	s.WriteString(mixed)
+
+	keepAlive := "off (Ctrl+T to start)"
+	if m.diagKeepAlive != nil {
+		keepAlive = fmt.Sprintf("💓 every %s", uds.DefaultKeepAliveInterval)
+	}
+	s.WriteString(fmt.Sprintf("  %-10s %s\n\n", "Keep-alive:", keepAlive))
+
+	// Last requests, the newest at the bottom
+	var lines []string
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+	for _, entry := range m.DiagLog {
+		lines = append(lines, fmt.Sprintf("%s  ➡️  %s", entry.Time.Format("15:04:05.000"), entry.Request))
+		if entry.Err != nil {
+			lines = append(lines, "              "+errorStyle.Render(fmt.Sprintf("❌ %v", entry.Err)))
+			continue
+		}
+		for _, line := range entry.Lines {
+			lines = append(lines, "              "+line)
+		}
+	}
+	rows := max(3, m.Height-diagFieldCount-14-strings.Count(mixed, "\n"))
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "(no request yet)")
+	}
+	s.WriteString(strings.Join(lines, "\n") + "\n")
+
+	if m.Err != nil {
+		wrappedStatus := m.wrapStatus(fmt.Sprintf("⚠️ %v", m.Err), m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	} else if m.SendStatus != "" {
+		wrappedStatus := m.wrapStatus(m.SendStatus, m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	}
+
+	return s.String()
+}
@@ -0,0 +1,210 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+)
+
+// Defaults of the timeout detection of the monitored messages
+const (
+	// DefaultTimeoutFactor is the number of DBC cycle times without frames after which a message is stale
+	DefaultTimeoutFactor = 3.0
+	// DefaultMessageTimeout is the timeout of the messages without a DBC cycle time
+	DefaultMessageTimeout = time.Second
+)
+
+// maxEvents is the number of events kept in the event log, eventLogLines the ones shown
+const (
+	maxEvents     = 200
+	eventLogLines = 5
+)
+
+// staleMarker prefixes the ID of the stale messages in the monitoring table
+const staleMarker = "⛔ "
+
+// staleColor is the color of the rows of the stale messages in the monitoring table
+var staleColor = lipgloss.Color("#FF3030")
+
+// MonitorEvent is an entry of the event log of the monitoring view
+type MonitorEvent struct {
+	Time time.Time
+	Text string
+}
+
+// logEvent appends an event to the event log, dropping the oldest ones
+func (m *Model) logEvent(t time.Time, format string, args ...any) {
+	m.EventLog = append(m.EventLog, MonitorEvent{Time: t, Text: fmt.Sprintf(format, args...)})
+	if len(m.EventLog) > maxEvents {
+		m.EventLog = m.EventLog[len(m.EventLog)-maxEvents:]
+	}
+}
+
+// messageTimeout returns after how long without frames the message is stale (0 never)
+func (m *Model) messageTimeout(msg CANMessage) time.Duration {
+	if spec := msg.Message.CycleTime(); spec > 0 {
+		return time.Duration(m.TimeoutFactor * float64(time.Duration(spec)*time.Millisecond))
+	}
+	return m.DefaultTimeout
+}
+
+// checkTimeouts marks stale the monitored messages that stopped arriving,
+// marks their rows and logs the timeout and recovery events. Called at every tick while monitoring
+func (m *Model) checkTimeouts(now time.Time) {
+	if m.Stats == nil {
+		return
+	}
+
+	changed := false
+	for _, msg := range m.SelectedMessages {
+		key := msg.Key()
+		timeout := m.messageTimeout(msg)
+		since, stale := m.StaleMessages[key]
+		s, ok := m.Stats.Get(key)
+		if !ok {
+			// Never received: only the messages with a cycle time are expected from the start
+			if !stale && timeout > 0 && msg.Message.CycleTime() > 0 && now.Sub(m.monitorStart) > timeout {
+				m.StaleMessages[key] = m.monitorStart
+				changed = true
+				m.logEvent(now, "⛔ %s (%s) never received in %s (timeout %s)",
+					msg.Message.Name(), canDebug.FormatID(msg.ID, msg.Extended), formatAge(now.Sub(m.monitorStart)), timeout)
+			}
+			continue
+		}
+
+		switch {
+		case !stale && timeout > 0 && s.Age(now) > timeout:
+			m.StaleMessages[key] = s.Last
+			changed = true
+			m.logEvent(now, "⛔ %s (%s) timed out: no frame for %s (timeout %s)",
+				msg.Message.Name(), canDebug.FormatID(msg.ID, msg.Extended), formatAge(s.Age(now)), timeout)
+		case stale && s.Last.After(since):
+			delete(m.StaleMessages, key)
+			changed = true
+			m.logEvent(now, "✅ %s (%s) recovered after %s",
+				msg.Message.Name(), canDebug.FormatID(msg.ID, msg.Extended), formatAge(s.Last.Sub(since)))
+		}
+	}
+
+	if changed {
+		m.markStaleRows()
+	}
+}
+
+// monitorIDLabel returns the ID column of the monitoring table for the message, marked if it is stale
+func (m *Model) monitorIDLabel(msg CANMessage) string {
+	id := canDebug.FormatID(msg.ID, msg.Extended)
+	if _, stale := m.StaleMessages[msg.Key()]; stale {
+		return staleMarker + id
+	}
+	return id
+}
+
+// markStaleRows updates the ID column of the monitoring table after a timeout or a recovery
+func (m *Model) markStaleRows() {
+	rows := m.MonitoringTable.Rows()
+	if len(rows) != len(m.MonitorRows) {
+		return // the table was rebuilt
+	}
+
+	labels := make(map[uint32]string, len(m.SelectedMessages))
+	for _, msg := range m.SelectedMessages {
+		labels[msg.Key()] = m.monitorIDLabel(msg)
+	}
+	for i, row := range m.MonitorRows {
+		if label, ok := labels[row.key]; ok {
+			rows[i][1] = label
+		}
+	}
+	m.MonitoringTable.SetRows(rows)
+}
+
+// visibleTop returns the first row shown by a table of height rows, scrolling as little as possible
+// from top to keep the cursor visible
+func visibleTop(top, cursor, height int) int {
+	return max(min(top, cursor), cursor-height+1, 0)
+}
+
+// monitorRowStyle returns the style of a row of the monitoring table: the one of the cursor,
+// red for the stale messages
+func (m Model) monitorRowStyle(i int, styles table.Styles) lipgloss.Style {
+	stale := false
+	if i < len(m.MonitorRows) {
+		_, stale = m.StaleMessages[m.MonitorRows[i].key]
+	}
+	switch {
+	case i == m.MonitoringTable.Cursor() && stale:
+		return styles.Selected.Foreground(staleColor)
+	case i == m.MonitoringTable.Cursor():
+		return styles.Selected
+	case stale:
+		return lipgloss.NewStyle().Foreground(staleColor)
+	}
+	return lipgloss.NewStyle()
+}
+
+// monitoringTableView renders the monitoring table as table.Model.View does, with the rows
+// of the stale messages in red: table.Model has a style for the row of the cursor only
+func (m Model) monitoringTableView() string {
+	styles := table.DefaultStyles()
+	columns := m.MonitoringTable.Columns()
+	cell := func(value string, width int) string {
+		return lipgloss.NewStyle().Width(width).MaxWidth(width).Inline(true).Render(runewidth.Truncate(value, width, "…"))
+	}
+
+	headers := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col.Width > 0 {
+			headers = append(headers, styles.Header.Render(cell(col.Title, col.Width)))
+		}
+	}
+
+	rows := m.MonitoringTable.Rows()
+	height := m.MonitoringTable.Height()
+	top := visibleTop(m.monitorTop, m.MonitoringTable.Cursor(), height)
+	lines := make([]string, 0, height)
+	for i := top; i < min(len(rows), top+height); i++ {
+		cells := make([]string, 0, len(columns))
+		for j, value := range rows[i] {
+			if j < len(columns) && columns[j].Width > 0 {
+				cells = append(cells, styles.Cell.Render(cell(value, columns[j].Width)))
+			}
+		}
+		lines = append(lines, m.monitorRowStyle(i, styles).Render(lipgloss.JoinHorizontal(lipgloss.Top, cells...)))
+	}
+
+	width := m.MonitoringTable.Width()
+	body := lipgloss.NewStyle().Width(width).MaxWidth(width).Height(height).MaxHeight(height).Render(strings.Join(lines, "\n"))
+	return lipgloss.JoinHorizontal(lipgloss.Top, headers...) + "\n" + body
+}
+
+// eventLogView renders the last events of the event log
+func (m Model) eventLogView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("📋 Events"))
+	if m.TimeoutFactor > 0 {
+		s.WriteString(fmt.Sprintf(" (timeout: %g× DBC cycle time, %s without cycle time)", m.TimeoutFactor, m.DefaultTimeout))
+	}
+	s.WriteString("\n")
+
+	if len(m.EventLog) == 0 {
+		s.WriteString("No events")
+		return s.String()
+	}
+
+	events := m.EventLog[max(0, len(m.EventLog)-eventLogLines):]
+	for i, event := range events {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		s.WriteString(fmt.Sprintf("%s %s", event.Time.Format("15:04:05.000"), event.Text))
+	}
+	return s.String()
+}
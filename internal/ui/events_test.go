@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/stats"
+)
+
+// TestStaleRows checks that the rows of a message that stopped arriving are marked and red, the one under the cursor included,
+// and that they are restored when it recovers
+func TestStaleRows(t *testing.T) {
+	m := newTestMonitor(t, nil)
+	m.Stats = stats.NewTracker()
+	m.StaleMessages = make(map[uint32]time.Time)
+
+	stale := m.SelectedMessages[0]
+	start := time.Now()
+	for _, msg := range m.SelectedMessages {
+		m.Stats.Observe(msg.Key(), bus.Frame{ID: msg.ID, IsExtended: msg.Extended, Timestamp: start})
+	}
+	// Every message but the first keeps arriving
+	later := start.Add(time.Hour)
+	for _, msg := range m.SelectedMessages[1:] {
+		m.Stats.Observe(msg.Key(), bus.Frame{ID: msg.ID, IsExtended: msg.Extended, Timestamp: later})
+	}
+	m.checkTimeouts(later)
+
+	id := canDebug.FormatID(stale.ID, stale.Extended)
+	// marked returns the rows marked and red, and how many of them are not of the stale message
+	marked := func() (n, others int) {
+		for i, row := range m.MonitoringTable.Rows() {
+			red := m.monitorRowStyle(i, table.DefaultStyles()).GetForeground() == staleColor
+			if strings.HasPrefix(row[1], staleMarker) != red {
+				t.Fatalf("row %d marked %q but red %v", i, row[1], red)
+			}
+			if red {
+				if m.MonitorRows[i].key != stale.Key() {
+					others++
+				}
+				n++
+			}
+		}
+		return n, others
+	}
+	if n, others := marked(); n == 0 || others != 0 {
+		t.Fatalf("%d rows marked, %d of other messages", n, others)
+	}
+	if m.MonitoringTable.Cursor() != 0 || !strings.Contains(m.View(), staleMarker+id) {
+		t.Fatal("the row under the cursor is not marked in the view")
+	}
+
+	m.Stats.Observe(stale.Key(), bus.Frame{ID: stale.ID, IsExtended: stale.Extended, Timestamp: later.Add(time.Millisecond)})
+	m.checkTimeouts(later.Add(time.Millisecond))
+	if n, _ := marked(); n != 0 {
+		t.Fatalf("%d rows still marked after the recovery", n)
+	}
+}
+
+// TestNeverReceived checks that the messages with a cycle time never received time out from the start of the monitoring
+func TestNeverReceived(t *testing.T) {
+	m := newTestMonitor(t, nil)
+	m.Stats = stats.NewTracker()
+	m.StaleMessages = make(map[uint32]time.Time)
+	m.monitorStart = time.Now()
+
+	var cyclic, other *CANMessage
+	for i, msg := range m.SelectedMessages {
+		switch {
+		case cyclic == nil && msg.Message.CycleTime() > 0:
+			cyclic = &m.SelectedMessages[i]
+		case other == nil && msg.Message.CycleTime() == 0:
+			other = &m.SelectedMessages[i]
+		}
+	}
+	if cyclic == nil || other == nil {
+		t.Fatal("no message with and without cycle time in the test DBC")
+	}
+	timeout := m.messageTimeout(*cyclic)
+
+	m.checkTimeouts(m.monitorStart.Add(timeout / 2))
+	if len(m.StaleMessages) != 0 {
+		t.Fatalf("%d messages stale before their timeout", len(m.StaleMessages))
+	}
+	m.checkTimeouts(m.monitorStart.Add(timeout + time.Millisecond))
+	if _, ok := m.StaleMessages[cyclic.Key()]; !ok {
+		t.Fatalf("%s never received but not stale", cyclic.Message.Name())
+	}
+	if _, ok := m.StaleMessages[other.Key()]; ok {
+		t.Fatalf("%s without cycle time stale", other.Message.Name())
+	}
+	if len(m.EventLog) == 0 || !strings.Contains(m.EventLog[0].Text, "never received") {
+		t.Fatalf("events %v", m.EventLog)
+	}
+
+	m.Stats.Observe(cyclic.Key(), bus.Frame{ID: cyclic.ID, IsExtended: cyclic.Extended, Timestamp: m.monitorStart.Add(time.Hour)})
+	m.checkTimeouts(m.monitorStart.Add(time.Hour))
+	if _, ok := m.StaleMessages[cyclic.Key()]; ok {
+		t.Fatalf("%s still stale after its first frame", cyclic.Message.Name())
+	}
+}
+
+// TestMonitoringTableView checks that the monitoring table renders as table.Model.View while scrolling
+func TestMonitoringTableView(t *testing.T) {
+	m := newTestMonitor(t, nil)
+	m.MonitoringTable.SetWidth(m.Width)
+	height := m.MonitoringTable.Height()
+	if len(m.MonitoringTable.Rows()) < 2*height+5 {
+		t.Fatalf("%d rows for a table of %d", len(m.MonitoringTable.Rows()), height)
+	}
+
+	keys := []tea.KeyType{}
+	for range 2*height + 3 {
+		keys = append(keys, tea.KeyDown)
+	}
+	for range height + 2 {
+		keys = append(keys, tea.KeyUp)
+	}
+	for i, key := range append([]tea.KeyType{tea.KeyHome}, keys...) {
+		m.Update(tea.KeyMsg{Type: key})
+		if got, want := m.monitoringTableView(), m.MonitoringTable.View(); got != want {
+			t.Fatalf("key %d, cursor %d: view\n%s\nwant\n%s", i, m.MonitoringTable.Cursor(), got, want)
+		}
+	}
+	if cursor := m.MonitoringTable.Cursor(); cursor != height+1 {
+		t.Fatalf("cursor on row %d, want %d", cursor, height+1)
+	}
+}
@@ -0,0 +1,473 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	"github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/flash"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+	"github.com/squadracorsepolito/can-debug/internal/uds"
+)
+
+// Fields of the flashing screen
+const (
+	flashFieldTarget = iota
+	flashFieldTx
+	flashFieldRx
+	flashFieldFile
+	flashFieldBase
+	flashFieldLevel
+	flashFieldKey
+	flashFieldSimulate
+	flashFieldCount
+)
+
+// flashLogSize is the number of lines kept in the log of the flashing screen
+const flashLogSize = 100
+
+// FlashProgressMsg carries the progress of the running download
+type FlashProgressMsg struct {
+	session  *flashSession
+	Progress flash.Progress
+}
+
+// FlashDoneMsg reports the end of a download, Err is nil if it succeeded
+type FlashDoneMsg struct {
+	session *flashSession
+	Err     error
+}
+
+// flashSession is a download running in its own goroutine, it posts its progress to the UI
+type flashSession struct {
+	cancel   context.CancelFunc
+	progress chan flash.Progress // last progress not delivered yet
+	done     chan error
+}
+
+// run downloads the image, it is intended as a goroutine
+func (s *flashSession) run(ctx context.Context, canBus bus.Bus, cfg isotp.Config, img *flash.Image, opts flash.Options) {
+	conn := isotp.Dial(canBus, cfg)
+	defer conn.Close()
+
+	err := flash.Flash(ctx, uds.NewClient(conn), img, opts, func(p flash.Progress) {
+		// Only the last progress matters, replace the one not delivered yet
+		select {
+		case <-s.progress:
+		default:
+		}
+		s.progress <- p
+	})
+	s.done <- err
+}
+
+// wait returns the command delivering the next progress of the download, or its end
+func (s *flashSession) wait() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case err := <-s.done:
+			return FlashDoneMsg{session: s, Err: err}
+		case p := <-s.progress:
+			return FlashProgressMsg{session: s, Progress: p}
+		}
+	}
+}
+
+// setupFlash prepares the flashing screen, on the first visit the target is the first
+// ECU reachable by the DIAG_TOOL node of the DBC
+func (m *Model) setupFlash() {
+	if m.FlashInputs != nil {
+		m.focusFlashField(m.FlashField)
+		return
+	}
+	if m.DiagTargets == nil {
+		m.DiagTargets, m.DiagMixed = can.DiagTargets(m.Messages)
+	}
+
+	m.FlashInputs = make(map[int]*textinput.Model)
+	inputs := []struct {
+		field       int
+		placeholder string
+		value       string
+		width       int
+	}{
+		{flashFieldTx, "hex ID, e.g. 7E0", "7E0", 30},
+		{flashFieldRx, "hex ID, e.g. 7E8", "7E8", 30},
+		{flashFieldFile, "path of the .hex or .bin image", "", 60},
+		{flashFieldBase, "hex address of the .bin images", fmt.Sprintf("%08X", flash.DefaultMemoryAddress), 30},
+		{flashFieldLevel, "hex, 00 = no SecurityAccess", "01", 30},
+	}
+	for _, in := range inputs {
+		ti := textinput.New()
+		ti.Placeholder = in.placeholder
+		ti.SetValue(in.value)
+		ti.CharLimit = 12
+		if in.field == flashFieldFile {
+			ti.CharLimit = 512
+		}
+		ti.Width = in.width
+		m.FlashInputs[in.field] = &ti
+	}
+	if len(m.DiagTargets) > 0 {
+		setTargetIDs(m.DiagTargets[0], m.FlashInputs[flashFieldTx], m.FlashInputs[flashFieldRx])
+	}
+	m.focusFlashField(flashFieldFile)
+}
+
+// focusFlashField moves the focus to a field of the flashing screen
+func (m *Model) focusFlashField(field int) {
+	m.FlashField = (field + flashFieldCount) % flashFieldCount
+	for i, input := range m.FlashInputs {
+		if i == m.FlashField {
+			input.Focus()
+		} else {
+			input.Blur()
+		}
+	}
+}
+
+// flashKey returns the name and the function of the chosen key algorithm
+func (m *Model) flashKey() (string, uds.KeyFunc) {
+	algorithms := uds.KeyAlgorithms()
+	name := algorithms[m.FlashKeyAlgorithm%len(algorithms)]
+	key, _ := uds.KeyAlgorithm(name)
+	return name, key
+}
+
+// flashLevel parses the security level of the screen
+func (m *Model) flashLevel() (byte, error) {
+	level, err := parseHex(m.FlashInputs[flashFieldLevel].Value(), 8)
+	if err != nil || level != 0 && level%2 == 0 {
+		return 0, fmt.Errorf("invalid security level %q, the seeds are requested with odd levels (00 for none)", m.FlashInputs[flashFieldLevel].Value())
+	}
+	return byte(level), nil
+}
+
+// logFlash adds a line to the log of the flashing screen
+func (m *Model) logFlash(format string, args ...any) {
+	line := time.Now().Format("15:04:05.000") + "  " + fmt.Sprintf(format, args...)
+	m.FlashLog = append(m.FlashLog, line)
+	if len(m.FlashLog) > flashLogSize {
+		m.FlashLog = m.FlashLog[len(m.FlashLog)-flashLogSize:]
+	}
+}
+
+// startFlash loads the image and starts downloading it in the background
+func (m *Model) startFlash() tea.Cmd {
+	if m.flashSession != nil {
+		return nil // already running
+	}
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - flashing disabled")
+		return nil
+	}
+
+	cfg, err := targetConfig(m.FlashInputs[flashFieldTx], m.FlashInputs[flashFieldRx])
+	if err != nil {
+		m.Err = err
+		return nil
+	}
+	base, err := parseHex(m.FlashInputs[flashFieldBase].Value(), 32)
+	if err != nil {
+		m.Err = fmt.Errorf("invalid base address %q", m.FlashInputs[flashFieldBase].Value())
+		return nil
+	}
+	level, err := m.flashLevel()
+	if err != nil {
+		m.Err = err
+		return nil
+	}
+	path := strings.TrimSpace(m.FlashInputs[flashFieldFile].Value())
+	if path == "" {
+		m.Err = fmt.Errorf("type the path of the image to download")
+		return nil
+	}
+	img, err := flash.LoadImage(path, uint32(base))
+	if err != nil {
+		m.Err = err
+		return nil
+	}
+
+	m.Err = nil
+	keyName, key := m.flashKey()
+	opts := flash.Options{SecurityLevel: level, Key: key}
+
+	m.FlashLog = nil
+	m.logFlash("📂 %s: %d bytes in %d segments", path, img.Size(), len(img.Segments))
+	for _, segment := range img.Segments {
+		m.logFlash("   0x%08X-0x%08X  %d bytes  CRC-32 0x%08X", segment.Address, segment.End(), len(segment.Data), segment.CRC())
+	}
+	if level != 0 {
+		m.logFlash("🔐 Security level 0x%02X with the %q key algorithm", level, keyName)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.flashSession = &flashSession{
+		cancel:   cancel,
+		progress: make(chan flash.Progress, 1),
+		done:     make(chan error, 1),
+	}
+	m.FlashProgress = flash.Progress{Total: img.Size(), Segments: len(img.Segments)}
+	m.FlashStart = time.Now()
+	m.FlashErr = nil
+	m.SendStatus = fmt.Sprintf("⚡ Flashing %s to %s...", path, can.FormatID(cfg.TxID, cfg.Extended))
+
+	go m.flashSession.run(ctx, m.Bus, cfg, img, opts)
+	return m.flashSession.wait()
+}
+
+// cancelFlash stops the running download (if any)
+func (m *Model) cancelFlash() {
+	if m.flashSession == nil {
+		return
+	}
+	m.flashSession.cancel()
+}
+
+// handleFlashProgress shows the progress of the download, logging the steps
+func (m *Model) handleFlashProgress(msg FlashProgressMsg) tea.Cmd {
+	if msg.session != m.flashSession {
+		return nil
+	}
+
+	p := msg.Progress
+	if p.Step != m.FlashProgress.Step || p.Segment != m.FlashProgress.Segment {
+		switch p.Step {
+		case flash.StepErase, flash.StepDownload, flash.StepVerify:
+			m.logFlash("▶️  %s segment %d/%d", p.Step, p.Segment+1, p.Segments)
+		default:
+			m.logFlash("▶️  %s", p.Step)
+		}
+	}
+	if p.Retries > m.FlashProgress.Retries {
+		m.logFlash("🔁 Block not acknowledged, sent again (%d retries so far)", p.Retries)
+	}
+	m.FlashProgress = p
+
+	return msg.session.wait()
+}
+
+// handleFlashDone reports the end of the download
+func (m *Model) handleFlashDone(msg FlashDoneMsg) {
+	if msg.session != m.flashSession {
+		return
+	}
+	m.flashSession = nil
+	m.FlashEnd = time.Now()
+	elapsed := m.FlashEnd.Sub(m.FlashStart).Round(time.Millisecond)
+
+	switch {
+	case msg.Err == nil:
+		m.FlashProgress.Step = flash.StepDone
+		m.logFlash("✅ %d bytes flashed and verified in %s", m.FlashProgress.Total, elapsed)
+		m.SendStatus = fmt.Sprintf("✅ Flashing completed in %s", elapsed)
+	case errors.Is(msg.Err, context.Canceled):
+		m.FlashErr = msg.Err
+		m.logFlash("⏹️  Cancelled during %q", m.FlashProgress.Step)
+		m.SendStatus = "⏹️ Flashing cancelled, the ECU stays in the bootloader: Enter starts again"
+	default:
+		m.FlashErr = msg.Err
+		m.logFlash("❌ %v", msg.Err)
+		m.SendStatus = fmt.Sprintf("❌ Flashing failed during %q: Enter starts again (the segments are erased and downloaded again)", m.FlashProgress.Step)
+	}
+}
+
+// toggleFlashBootloader starts/stops a simulated bootloader answering to the IDs of the screen
+func (m *Model) toggleFlashBootloader() {
+	if m.flashBootloader != nil {
+		m.closeFlashBootloader()
+		m.SendStatus = "🧪 Simulated bootloader stopped"
+		return
+	}
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - simulation disabled")
+		return
+	}
+
+	cfg, err := targetConfig(m.FlashInputs[flashFieldTx], m.FlashInputs[flashFieldRx])
+	if err != nil {
+		m.Err = err
+		return
+	}
+	level, err := m.flashLevel()
+	if err != nil {
+		m.Err = err
+		return
+	}
+	_, key := m.flashKey()
+
+	// The bootloader receives on the TX ID of the tester and answers on its RX ID
+	cfg.TxID, cfg.RxID = cfg.RxID, cfg.TxID
+	m.Err = nil
+	m.flashBootloader = flash.NewBootloader(m.Bus, flash.BootloaderConfig{
+		Conn:          cfg,
+		SecurityLevel: level,
+		Key:           key,
+		DropEvery:     50,
+	})
+	m.SendStatus = fmt.Sprintf("🧪 Simulated bootloader answering on %s: 1 MiB of flash at 0x%08X, drops one TransferData response every 50",
+		can.FormatID(cfg.TxID, cfg.Extended), flash.DefaultMemoryAddress)
+}
+
+// closeFlashBootloader stops the simulated bootloader (if any)
+func (m *Model) closeFlashBootloader() {
+	if m.flashBootloader == nil {
+		return
+	}
+	m.flashBootloader.Close()
+	m.flashBootloader = nil
+}
+
+// closeFlash cancels the download and stops the simulated bootloader
+func (m *Model) closeFlash() {
+	m.cancelFlash()
+	m.flashSession = nil
+	m.closeFlashBootloader()
+}
+
+// updateFlash handles the keys of the flashing screen
+func (m *Model) updateFlash(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case "esc":
+		m.cancelFlash()
+		return nil
+	case "up", "shift+tab":
+		m.focusFlashField(m.FlashField - 1)
+		return nil
+	case "down":
+		m.focusFlashField(m.FlashField + 1)
+		return nil
+	case "enter":
+		if m.FlashField == flashFieldSimulate {
+			m.toggleFlashBootloader()
+			return nil
+		}
+		return m.startFlash()
+	case "left", "right", "<", ">":
+		delta := 1
+		if keyMsg.String() == "left" || keyMsg.String() == "<" {
+			delta = -1
+		}
+		switch m.FlashField {
+		case flashFieldTarget:
+			if len(m.DiagTargets) > 0 {
+				current := targetIndex(m.DiagTargets, m.FlashInputs[flashFieldTx], m.FlashInputs[flashFieldRx])
+				setTargetIDs(m.DiagTargets[nextTarget(m.DiagTargets, current, delta)], m.FlashInputs[flashFieldTx], m.FlashInputs[flashFieldRx])
+			}
+			return nil
+		case flashFieldKey:
+			m.FlashKeyAlgorithm = (m.FlashKeyAlgorithm + delta + len(uds.KeyAlgorithms())) % len(uds.KeyAlgorithms())
+			return nil
+		case flashFieldSimulate:
+			m.toggleFlashBootloader()
+			return nil
+		}
+	}
+
+	if input, ok := m.FlashInputs[m.FlashField]; ok {
+		var cmd tea.Cmd
+		*input, cmd = input.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// flashView renders the flashing screen
+func (m Model) flashView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("⚡ Flash firmware over UDS"))
+	s.WriteString("\n\n")
+
+	s.WriteString("↑/↓ field • ←/→ choose target/key algorithm • Enter start flashing (on Simulated: start/stop it) • Esc cancel\n")
+	s.WriteString("Tab back to mode selection • Ctrl+C quit")
+	s.WriteString("\n\n")
+
+	target := "custom IDs"
+	if i := targetIndex(m.DiagTargets, m.FlashInputs[flashFieldTx], m.FlashInputs[flashFieldRx]); i >= 0 {
+		target = fmt.Sprintf("%s (%d of %d in the DBC)", m.DiagTargets[i].Node, i+1, len(m.DiagTargets))
+	}
+	keyName, _ := m.flashKey()
+	simulated := "off"
+	if m.flashBootloader != nil {
+		simulated = "on"
+	}
+
+	fields := []struct {
+		label string
+		value string
+	}{
+		flashFieldTarget:   {"Target", "◀ " + target + " ▶"},
+		flashFieldTx:       {"TX ID", m.FlashInputs[flashFieldTx].View()},
+		flashFieldRx:       {"RX ID", m.FlashInputs[flashFieldRx].View()},
+		flashFieldFile:     {"Image", m.FlashInputs[flashFieldFile].View()},
+		flashFieldBase:     {"Base (.bin)", m.FlashInputs[flashFieldBase].View()},
+		flashFieldLevel:    {"Security", m.FlashInputs[flashFieldLevel].View()},
+		flashFieldKey:      {"Key algo", "◀ " + keyName + " ▶"},
+		flashFieldSimulate: {"Simulated", "◀ " + simulated + " ▶ (bootloader on this bus, for tests)"},
+	}
+	for i, field := range fields {
+		cursor := "  "
+		if i == m.FlashField {
+			cursor = "▶ "
+		}
+		s.WriteString(fmt.Sprintf("%s%-12s %s\n", cursor, field.label+":", field.value))
+	}
+	s.WriteString(mixedTargetsNote(m.DiagMixed))
+	s.WriteString("\n")
+
+	// Progress of the current or last download
+	if p := m.FlashProgress; p.Total > 0 {
+		end := m.FlashEnd
+		if m.flashSession != nil {
+			end = time.Now()
+		}
+		elapsed := end.Sub(m.FlashStart)
+		speed := 0.0
+		if elapsed > 0 {
+			speed = float64(p.Sent) / 1024 / elapsed.Seconds()
+		}
+
+		state := "⚡ " + p.Step
+		if m.flashSession == nil && m.FlashErr != nil {
+			state = "❌ failed during " + p.Step
+		}
+		s.WriteString(fmt.Sprintf("State:    %s (segment %d/%d)\n", state, p.Segment+1, p.Segments))
+		s.WriteString(fmt.Sprintf("Progress: %s %3.0f%%\n", progressBar(float64(p.Sent)/float64(p.Total), 40), 100*float64(p.Sent)/float64(p.Total)))
+		s.WriteString(fmt.Sprintf("Bytes:    %d / %d • %.1f KiB/s • %s • %d retries\n\n",
+			p.Sent, p.Total, speed, elapsed.Round(100*time.Millisecond), p.Retries))
+	}
+
+	lines := m.FlashLog
+	rows := max(3, m.Height-flashFieldCount-18)
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	if len(lines) == 0 {
+		lines = []string{"(choose the image and press Enter)"}
+	}
+	s.WriteString(strings.Join(lines, "\n") + "\n")
+
+	if m.Err != nil {
+		wrappedStatus := m.wrapStatus(fmt.Sprintf("⚠️ %v", m.Err), m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	} else if m.SendStatus != "" {
+		wrappedStatus := m.wrapStatus(m.SendStatus, m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	}
+
+	return s.String()
+}
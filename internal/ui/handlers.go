@@ -3,20 +3,24 @@ package ui
 import (
 	"context"
 	"fmt"
-	"os"
 	"regexp"
-	"strings"
+	"slices"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/squadracorsepolito/acmelib"
-	"go.einride.tech/can"
-	"go.einride.tech/can/pkg/socketcan"
 
+	"github.com/squadracorsepolito/can-debug/internal/bus"
 	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+	"github.com/squadracorsepolito/can-debug/internal/history"
+	"github.com/squadracorsepolito/can-debug/internal/stats"
+	"github.com/squadracorsepolito/can-debug/internal/trace"
 )
 
 // validateDecimalInput validates that input contains only decimal numbers (including negative)
@@ -36,22 +40,14 @@ func validateDecimalInput(s string) error {
 // loadDBC loads the DBC file
 func (m *Model) loadDBC() error {
 	// Use acmelib to load the DBC file
-	file, err := os.Open(m.DBCPath)
+	bus, err := canDebug.LoadDBC(m.DBCPath)
 	if err != nil {
-		return fmt.Errorf("error in opening DCB file: %w", err)
-	}
-	defer file.Close()
-
-	bus, err := acmelib.ImportDBCFile("debug_bus", file)
-	if err != nil {
-		return fmt.Errorf("error in loading DCB file: %w", err)
+		return err
 	}
 
 	// Collect all messages from the bus
-	m.Messages = make([]*acmelib.Message, 0)
-	for _, nodeInt := range bus.NodeInterfaces() {
-		m.Messages = append(m.Messages, nodeInt.SentMessages()...)
-	}
+	m.Messages = canDebug.Messages(bus)
+	m.Nodes = canDebug.Nodes(bus)
 
 	m.Decoder = canDebug.NewDecoder(m.Messages)
 
@@ -69,8 +65,9 @@ func (m *Model) setupMessageList() {
 	for _, msg := range m.Messages {
 		// Check if this message is selected
 		isSelected := false
+		key := canDebug.Key(canDebug.MessageID(msg))
 		for _, selected := range m.SelectedMessages {
-			if selected.ID == uint32(msg.GetCANID()) {
+			if selected.Key() == key {
 				isSelected = true
 				break
 			}
@@ -78,17 +75,12 @@ func (m *Model) setupMessageList() {
 
 		//check if the message is being sent
 		cycleMessage := ""
-		mex, ok := m.ActiveMessages[int(msg.GetCANID())]
+		mex, ok := m.ActiveMessages[int(key)]
 		if ok {
 			cycleMessage = fmt.Sprintf(" - (Currently being send every %dms)", mex.frequency)
 		}
 
-		canMsg := CANMessage{
-			ID:       uint32(msg.GetCANID()),
-			Name:     fmt.Sprint(msg.Name(), cycleMessage),
-			Selected: isSelected,
-			Message:  msg,
-		}
+		canMsg := newCANMessage(msg, fmt.Sprint(msg.Name(), cycleMessage), isSelected)
 		items = append(items, canMsg)
 	}
 
@@ -115,29 +107,22 @@ func (m *Model) setupMessageList() {
 // toggleMessageSelection toggles the selection of a message
 func (m *Model) toggleMessageSelection() {
 	if selectedItem, ok := m.MessageList.SelectedItem().(CANMessage); ok {
-		if m.SendReceiveChoice == 0 {
-			// Send mode - solo un messaggio alla volta
-			m.SelectedMessages = []CANMessage{} // Clear all selections first
-			selectedItem.Selected = true
-			m.SelectedMessages = append(m.SelectedMessages, selectedItem)
-		} else {
-			// Receive mode - selezione multipla come prima
-			// Check if the message is already selected
-			found := false
-			for i, msg := range m.SelectedMessages {
-				if msg.ID == selectedItem.ID {
-					// Remove from selection
-					m.SelectedMessages = append(m.SelectedMessages[:i], m.SelectedMessages[i+1:]...)
-					found = true
-					break
-				}
+		// Multiple selection, both in send and receive mode
+		// Check if the message is already selected
+		found := false
+		for i, msg := range m.SelectedMessages {
+			if msg.Key() == selectedItem.Key() {
+				// Remove from selection
+				m.SelectedMessages = append(m.SelectedMessages[:i], m.SelectedMessages[i+1:]...)
+				found = true
+				break
 			}
+		}
 
-			if !found {
-				// Add to selection
-				selectedItem.Selected = true
-				m.SelectedMessages = append(m.SelectedMessages, selectedItem)
-			}
+		if !found {
+			// Add to selection
+			selectedItem.Selected = true
+			m.SelectedMessages = append(m.SelectedMessages, selectedItem)
 		}
 
 		// Update the list items
@@ -157,8 +142,9 @@ func (m *Model) updateMessageListItems() {
 	for _, msg := range m.Messages {
 		// Check if this message is selected
 		isSelected := false
+		key := canDebug.Key(canDebug.MessageID(msg))
 		for _, selected := range m.SelectedMessages {
-			if selected.ID == uint32(msg.GetCANID()) {
+			if selected.Key() == key {
 				isSelected = true
 				break
 			}
@@ -166,17 +152,12 @@ func (m *Model) updateMessageListItems() {
 
 		//check if the message is being sent
 		cycleMessage := ""
-		mex, ok := m.ActiveMessages[int(msg.GetCANID())]
+		mex, ok := m.ActiveMessages[int(key)]
 		if ok {
 			cycleMessage = fmt.Sprintf(" - (Currently being send every %dms)", mex.frequency)
 		}
 
-		canMsg := CANMessage{
-			ID:       uint32(msg.GetCANID()),
-			Name:     fmt.Sprint(msg.Name(), cycleMessage),
-			Selected: isSelected,
-			Message:  msg,
-		}
+		canMsg := newCANMessage(msg, fmt.Sprint(msg.Name(), cycleMessage), isSelected)
 		items = append(items, canMsg)
 	}
 
@@ -194,7 +175,7 @@ func (m *Model) updateMessageListItems() {
 func (m *Model) setupMonitoringTable() {
 	columns := []table.Column{
 		{Title: "Message", Width: 25},
-		{Title: "ID", Width: 8},
+		{Title: "ID", Width: 14}, // room for the stale marker of extended IDs
 		{Title: "Signal", Width: 35},
 		{Title: "Value", Width: 20},
 		{Title: "Raw", Width: 15},
@@ -207,36 +188,46 @@ func (m *Model) setupMonitoringTable() {
 		table.WithColumns(columns),
 		table.WithRows(rows),
 		table.WithFocused(true),
-		table.WithHeight(m.Height-8),
+		table.WithHeight(m.monitoringTableHeight()),
 	)
 
 	// Ensure the table is properly focused
 	m.MonitoringTable.Focus()
 }
 
-// initializes the table with all signals and value from selected DBC messages
+// monitoringTableHeight returns the height of the monitoring table,
+// leaving room for the statistics table of the selected messages and the event log
+func (m *Model) monitoringTableHeight() int {
+	return max(5, m.Height-19-len(m.SelectedMessages))
+}
+
+// initializes the table with all signals and value from selected DBC messages,
+// the multiplexed signals follow their muxor
 func (m *Model) initializesTableDBCSignals() {
 	if len(m.SelectedMessages) == 0 {
 		return
 	}
 
 	rows := []table.Row{}
+	m.MonitorRows = []monitorRow{}
+	m.monitorIndex = make(rowIndex)
 // qui !
 	for _, msg := range m.SelectedMessages {
-		// Get all signals for this message from the DBC
-		signals := msg.Message.Signals()
+		// Get all signals for this message from the DBC, the multiplexed ones included
+		signals := messageSignals(msg.Message)
 
 		if len(signals) == 0 {
 			// If no signals, show the message itself
 			row := table.Row{
 				msg.Name,
-				fmt.Sprintf("0x%X", msg.ID),
+				m.monitorIDLabel(msg),
 				"[No signal]",
 				"--",
 				"--",
 				"--",
 			}
 			rows = append(rows, row)
+			m.MonitorRows = append(m.MonitorRows, monitorRow{key: msg.Key()})
 		} else {
 			// Show each signal
 			for _, signal := range signals {
@@ -247,19 +238,33 @@ func (m *Model) initializesTableDBCSignals() {
 					signalInfo += fmt.Sprintf(" (%s)", stdSignal.Unit().Name())
 				}
 
+				monitorRow := monitorRow{
+					key:     msg.Key(),
+					signal:  signal.Name(),
+					label:   signalInfo,
+					muxor:   signal.Muxor,
+					layouts: signal.Layouts,
+					depth:   signal.Depth,
+				}
+
 				// Show signal bit position and length
 				startPos := signal.StartPos()
 				size := signal.Size()
 
 				row := table.Row{
 					msg.Name,
-					fmt.Sprintf("0x%X", msg.ID),
-					signalInfo,
+					m.monitorIDLabel(msg),
+					monitorRow.signalLabel("└"),
 					"[In attesa dati]",
 					fmt.Sprintf("bit %d:%d", startPos, startPos+size-1),
 					m.getSignalTypeString(signal),
 				}
+				if m.monitorIndex[monitorRow.key] == nil {
+					m.monitorIndex[monitorRow.key] = make(map[string]int)
+				}
+				m.monitorIndex[monitorRow.key][monitorRow.signal] = len(rows)
 				rows = append(rows, row)
+				m.MonitorRows = append(m.MonitorRows, monitorRow)
 			}
 		}
 	}
@@ -267,6 +272,12 @@ func (m *Model) initializesTableDBCSignals() {
 	m.MonitoringTable.SetRows(rows)
 }
 
+// signalLabel returns the text of the signal column, multiplexed signals are marked
+// by their layout state (e.g. "●" if present in the last frame, "○" if not)
+func (r monitorRow) signalLabel(marker string) string {
+	return muxLabel(r.label, r.muxor, r.layouts, r.depth, marker)
+}
+
 // getSignalTypeString returns a string representation of the signal type
 func (m *Model) getSignalTypeString(signal acmelib.Signal) string {
 	if stdSignal, err := signal.ToStandard(); err == nil {
@@ -287,139 +298,220 @@ func (m *Model) getSignalTypeString(signal acmelib.Signal) string {
 	return fmt.Sprintf("unknown (%d bit)", signal.Size())
 }
 
-// this function is intended as a goroutine,
-// it will keep receving messages from the Can Network, only saving them when in the "StateMonitoring" State
-func (m *Model) startReceavingMessages() {
+// startMonitoring subscribes to the CAN bus and starts the goroutine receiving the messages,
+// it returns the command delivering the updates of the table
+func (m *Model) startMonitoring() tea.Cmd {
+	// Reception statistics start from scratch at every monitoring session
+	m.Stats = stats.NewTracker()
+	m.StaleMessages = make(map[uint32]time.Time)
+	m.monitorStart = time.Now()
+	m.EventLog = nil
+	m.setupStatsTable()
+	m.Trace = trace.NewBuffer(traceCapacity)
+	m.History = history.NewStore(historyCapacity)
+	m.PlotPinned = nil
+	m.resumeTrace()
+	m.setupUnknownTable()
+
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - monitoring disabled")
+		return nil
+	}
+
+	m.MonitorReceiver = m.Bus.Subscribe()
+	m.monitor = newMonitorPipeline(m.Decoder, m.monitorIndex, m.Trace, m.Stats, m.History)
+	go m.monitor.run(m.MonitorReceiver)
+	return m.monitor.wait()
+}
 
-	if m.CanNetwork == nil {
-		m.Err = fmt.Errorf("no SocketCAN connection available - monitoring disabled")
-		return
+// stopReceivingMessages closes the receiver used by the monitoring goroutine, which then exits
+func (m *Model) stopReceivingMessages() {
+	if m.MonitorReceiver != nil {
+		m.MonitorReceiver.Close()
+		m.MonitorReceiver = nil
 	}
+	m.monitor = nil
+}
 
-	recv := socketcan.NewReceiver(m.CanNetwork)
-	for recv.Receive() {
-		if m.State != StateMonitoring {
-			break
-		}
+// toggleRecording starts or stops recording every received frame to a candump log file
+func (m *Model) toggleRecording() {
+	if m.Recorder != nil {
+		m.stopRecording()
+		return
+	}
 
-		frame := recv.Frame()
-		decodedSignals := m.Decoder.Decode(context.Background(), frame.ID, frame.Data[:])
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - recording disabled")
+		return
+	}
 
-		for _, sgn := range decodedSignals {
-			m.updateTable(sgn, frame.ID)
-		}
+	rec, err := candump.StartRecording(m.Bus, candump.DefaultLogName())
+	if err != nil {
+		m.Err = err
+		return
 	}
-	recv.Close()
+	m.Recorder = rec
 }
 
-// given a signal and its ID, updates the table with the corrisponding value (if the signal it's present in the monitoring table)
-func (m *Model) updateTable(sgn *acmelib.SignalDecoding, sgnID uint32) {
+// stopRecording stops the active recording (if any)
+func (m *Model) stopRecording() {
+	if m.Recorder == nil {
+		return
+	}
+	if err := m.Recorder.Stop(); err != nil {
+		m.Err = fmt.Errorf("error writing log file %s: %w", m.Recorder.Path(), err)
+	}
+	m.Recorder = nil
+}
 
-	sgnIDhex := fmt.Sprintf("0x%X", sgnID)
-	rows := m.MonitoringTable.Rows()
-	for i := range rows {
-		if rows[i][1] == sgnIDhex && strings.Contains(rows[i][2], sgn.Signal.Name()) {
-			rows[i][3] = fmt.Sprintf("%v", sgn.Value)
-			break
-		}
+// formatSignalValue formats a decoded value, enum signals show their DBC label with the raw value
+func formatSignalValue(sgn *acmelib.SignalDecoding) string {
+	if sgn.ValueType == acmelib.SignalValueTypeEnum {
+		return fmt.Sprintf("%v (%d)", sgn.Value, sgn.RawValue)
 	}
-	m.MonitoringTable.SetRows(rows)
+	return fmt.Sprintf("%v", sgn.Value)
 }
 
 // setupSendConfiguration prepares the send configuration table and signals
 func (m *Model) setupSendConfiguration() {
-	m.SendSignals = make([]SendSignal, 0)
-
-	// Create send signals from selected messages
-	m.CycleTime = rangeMs
-	msg := m.SelectedMessages[0]
-	for _, signal := range msg.Message.Signals() {
-		sendSignal := SendSignal{
-			SignalName: signal.Name(),
+	m.SendMessages = make([]*SendMessage, 0, len(m.SelectedMessages))
+
+	// Create a section with the send signals of each selected message
+	for _, msg := range m.SelectedMessages {
+		sendMessage := &SendMessage{
+			CANMessage: msg,
+			CycleTime:  rangeMs,
+			DLC:        -1,
+		}
+		// Start from the cycle time declared in the DBC (GenMsgCycleTime)
+		if spec := sendMessage.SpecCycleTime(); spec > 0 {
+			sendMessage.CycleTime = spec
 		}
+		sendMessage.Name = msg.Message.Name() // the list item name may show the sending status
 
-		// Extract unit information from the signal
-		if stdSignal, err := signal.ToStandard(); err == nil && stdSignal.Unit() != nil {
-			sendSignal.Unit = stdSignal.Unit().Name()
+		// Keep the cycle time of the messages already being sent
+		if mex, ok := m.ActiveMessages[int(msg.Key())]; ok {
+			sendMessage.CycleTime = mex.frequency
 		}
 
-		// Create text input for this signal
-		ti := textinput.New()
-		ti.Placeholder = "0"
-		ti.CharLimit = 20
-		ti.Width = 15
-		// Set validation function for decimal numbers
-		ti.Validate = validateDecimalInput
-		sendSignal.TextInput = ti
+		// The multiplexed signals are configured too, only the ones of the selected layouts are shown
+		for _, signal := range messageSignals(msg.Message) {
+			sendSignal := SendSignal{
+				SignalName: signal.Name(),
+				Muxor:      signal.Muxor,
+				Layouts:    signal.Layouts,
+				Depth:      signal.Depth,
+				MuxCount:   signal.MuxCount,
+			}
+
+			// Extract unit information from the signal
+			if stdSignal, err := signal.ToStandard(); err == nil && stdSignal.Unit() != nil {
+				sendSignal.Unit = stdSignal.Unit().Name()
+			}
+
+			// Create text input for this signal
+			ti := textinput.New()
+			ti.Placeholder = "0"
+			ti.CharLimit = 20
+			ti.Width = 15
+			// Set validation function for decimal numbers
+			ti.Validate = validateDecimalInput
+			// Start from the initial value declared in the DBC (GenSigStartValue)
+			ti.SetValue(initialSignalValue(signal))
+			sendSignal.TextInput = ti
+
+			// Enum signals are set by choosing one of their labels
+			if enumSignal, err := signal.ToEnum(); err == nil && enumSignal.Enum() != nil {
+				sendSignal.EnumValues = enumSignal.Enum().Values()
+				for i, enumValue := range sendSignal.EnumValues {
+					if float64(enumValue.Index()) == signal.StartValue() {
+						sendSignal.EnumIndex = i
+					}
+				}
+			}
+
+			sendMessage.Signals = append(sendMessage.Signals, sendSignal)
+		}
 
-		m.SendSignals = append(m.SendSignals, sendSignal)
+		m.SendMessages = append(m.SendMessages, sendMessage)
 	}
+	m.buildSendRows()
 
 	// Setup the send table
 	m.setupSendTable()
 
-	// Focus the first input if available
-	if len(m.SendSignals) > 0 {
-		m.CurrentInputIndex = 0
-		m.SendSignals[0].TextInput.Focus()
-		m.SendTable.SetCursor(0) // Set cursor to first row
-		// Make sure all other inputs are blurred
-		for i := 1; i < len(m.SendSignals); i++ {
-			m.SendSignals[i].TextInput.Blur()
-		}
+	// Focus the first row if available
+	m.CurrentInputIndex = -1
+	if len(m.SendRows) > 0 {
+		m.focusSendRow(0)
 	}
 }
 
-// setupSendTable configures the table for send configuration
-func (m *Model) setupSendTable() {
-	columns := []table.Column{
-		{Title: "Message", Width: 25},
-		{Title: "ID", Width: 8},
-		{Title: "Signal", Width: 35},
-		{Title: "Cycle(ms)", Width: 10},
-		{Title: "Status", Width: 15},
-		{Title: "Value", Width: 25},
+// initialSignalValue returns the physical value to show in the input of the signal,
+// computed from its raw start value declared in the DBC ("" if there is none)
+func initialSignalValue(signal acmelib.Signal) string {
+	start := signal.StartValue()
+	if start == 0 {
+		return ""
 	}
 
-	var status string
-	_, ok := m.ActiveMessages[int(m.SelectedMessages[0].ID)]
-	if ok {
-		status = "▶️  sending"
-	} else {
-		status = "⏸️  stopped"
+	if stdSignal, err := signal.ToStandard(); err == nil && stdSignal.Type() != nil {
+		typ := stdSignal.Type()
+		value := max(typ.Min(), min(start*typ.Scale()+typ.Offset(), typ.Max()))
+		return strconv.FormatFloat(value, 'g', -1, 64)
 	}
 
-	rows := make([]table.Row, len(m.SendSignals))
-	for i, signal := range m.SendSignals {
-		signalWithUnit := signal.SignalName
-		if signal.Unit != "" {
-			signalWithUnit += " (" + signal.Unit + ")"
+	// Enum and muxor signals take the raw value
+	return strconv.FormatFloat(start, 'f', 0, 64)
+}
+
+// buildSendRows lists the rows of the send table: the signals of each message sent
+// with the current muxor values, a single row for the messages without signals
+func (m *Model) buildSendRows() {
+	m.SendRows = make([]sendRow, 0)
+	for i, msg := range m.SendMessages {
+		// Messages without signals still need a row to be sent
+		if len(msg.Signals) == 0 {
+			m.SendRows = append(m.SendRows, sendRow{message: i, signal: -1})
+			continue
 		}
 
-		// Format cycle time with padding for alignment - show "-" for single shot
-		var cycleStr string
-		if signal.IsSingleShot {
-			cycleStr = fmt.Sprintf("%-8s", "-")
-		} else {
-			cycleStr = fmt.Sprintf("%-8d", m.CycleTime)
+		for j := range msg.Signals {
+			if msg.IsSent(j) {
+				m.SendRows = append(m.SendRows, sendRow{message: i, signal: j})
+			}
 		}
+	}
+}
 
-		// Format status with padding for alignment
-		statusStr := fmt.Sprintf("%-8s", status)
+// refreshSendRows rebuilds the rows of the send table after a muxor value changed,
+// the cursor stays on the signal being edited
+func (m *Model) refreshSendRows() {
+	var current sendRow
+	if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendRows) {
+		current = m.SendRows[m.CurrentInputIndex]
+	}
 
-		rows[i] = table.Row{
-			m.SelectedMessages[0].Name,
-			fmt.Sprintf("0x%-6X", m.SelectedMessages[0].ID), // Left-aligned with padding
-			signalWithUnit,
-			cycleStr,
-			statusStr,
-			signal.TextInput.View(),
-		}
+	m.buildSendRows()
+	m.CurrentInputIndex = max(0, slices.Index(m.SendRows, current))
+	m.updateSendTableRows()
+}
+
+// setupSendTable configures the table for send configuration
+func (m *Model) setupSendTable() {
+	columns := []table.Column{
+		{Title: "Message", Width: 25},
+		{Title: "ID", Width: 12},
+		{Title: "DLC", Width: 12},
+		{Title: "Signal", Width: 35},
+		{Title: "Cycle(ms)", Width: 18},
+		{Title: "Status", Width: 15},
+		{Title: "Value", Width: 25},
 	}
 
 	t := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
+		table.WithRows(m.sendTableRows()),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
@@ -428,53 +520,87 @@ func (m *Model) setupSendTable() {
 
 	// Ensure the table is properly focused and cursor is visible
 	m.SendTable.Focus()
-	if len(m.SendSignals) > 0 {
+	if len(m.SendRows) > 0 {
 		m.SendTable.SetCursor(0)
 	}
 }
 
-// updateSendTableRows updates the send table with current input values
-func (m *Model) updateSendTableRows() {
-	rows := make([]table.Row, len(m.SendSignals))
+// sendTableRows builds the rows of the send table from the current input values,
+// the message, its period and status are shown on the first row of its section
+func (m *Model) sendTableRows() []table.Row {
+	rows := make([]table.Row, len(m.SendRows))
+	for i, row := range m.SendRows {
+		msg := m.SendMessages[row.message]
+
+		signalWithUnit := "[No signal]"
+		value := "--"
+		if row.signal >= 0 {
+			signal := msg.Signals[row.signal]
+			signalWithUnit = signal.SignalName
+			if signal.Unit != "" {
+				signalWithUnit += " (" + signal.Unit + ")"
+			}
+			// Multiplexed signals show the mux values they are sent with
+			signalWithUnit = muxLabel(signalWithUnit, signal.Muxor, signal.Layouts, signal.Depth, "└")
+			value = signal.View()
+		}
 
-	var status string
-	_, ok := m.ActiveMessages[int(m.SelectedMessages[0].ID)]
-	if ok {
-		status = "▶️  sending"
-	} else {
-		status = "⏸️  stopped"
-	}
+		if i > 0 && m.SendRows[i-1].message == row.message {
+			// Following rows of the section only show the signal
+			rows[i] = table.Row{"", "", "", "  " + signalWithUnit, "", "", value}
+			continue
+		}
+
+		var status string
+		_, ok := m.ActiveMessages[int(msg.Key())]
+		if ok {
+			status = "▶️  sending"
+		} else {
+			status = "⏸️  stopped"
+		}
 
-	for i, signal := range m.SendSignals {
-		signalWithUnit := signal.SignalName
-		if signal.Unit != "" {
-			signalWithUnit += " (" + signal.Unit + ")"
+		// Show the DLC sent, with the DBC one when it is overridden
+		dlcStr := fmt.Sprintf("%d", msg.SendDLC())
+		if msg.DLC >= 0 {
+			dlcStr = fmt.Sprintf("%d (DBC %d)", msg.DLC, msg.DeclaredDLC())
 		}
 
-		// Format cycle time with padding for alignment - show "-" for single shot
+		// Format cycle time with padding for alignment - show "-" for single shot,
+		// the DBC period is shown next to the chosen one and flagged when they differ
 		var cycleStr string
-		if signal.IsSingleShot {
+		if msg.SingleShot {
 			cycleStr = fmt.Sprintf("%-8s", "-")
+		} else if spec := msg.SpecCycleTime(); spec > 0 && msg.CycleTime != spec {
+			cycleStr = fmt.Sprintf("⚠️ %d (spec %d)", msg.CycleTime, spec)
+		} else if spec > 0 {
+			cycleStr = fmt.Sprintf("%d (spec)", msg.CycleTime)
 		} else {
-			cycleStr = fmt.Sprintf("%-8d", m.CycleTime)
+			cycleStr = fmt.Sprintf("%-8d", msg.CycleTime)
 		}
 
 		// Format status with padding for alignment
 		statusStr := fmt.Sprintf("%-8s", status)
 
 		rows[i] = table.Row{
-			m.SelectedMessages[0].Name,
-			fmt.Sprintf("0x%-6X", m.SelectedMessages[0].ID), // Left-aligned with padding
+			msg.Name,
+			fmt.Sprintf("%-10s", canDebug.FormatID(msg.ID, msg.Extended)), // Left-aligned with padding
+			dlcStr,
 			signalWithUnit,
 			cycleStr,
 			statusStr,
-			signal.TextInput.View(),
+			value,
 		}
 	}
-	m.SendTable.SetRows(rows)
+
+	return rows
+}
+
+// updateSendTableRows updates the send table with current input values
+func (m *Model) updateSendTableRows() {
+	m.SendTable.SetRows(m.sendTableRows())
 
 	// Set cursor to the current input index to highlight the correct row
-	if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
+	if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendRows) {
 		m.SendTable.SetCursor(m.CurrentInputIndex)
 	}
 
@@ -482,13 +608,63 @@ func (m *Model) updateSendTableRows() {
 	m.ensureTableCursorVisible()
 }
 
+// focusSendRow moves the focus to the row at index, the input of its signal (if any) gets the focus
+func (m *Model) focusSendRow(index int) {
+	if index < 0 || index >= len(m.SendRows) {
+		return
+	}
+
+	// Remove focus from current input
+	if signal := m.currentSendSignal(); signal != nil {
+		signal.TextInput.Blur()
+	}
+
+	m.CurrentInputIndex = index
+	m.SendTable.SetCursor(index)
+
+	// Set focus to new input
+	if signal := m.currentSendSignal(); signal != nil {
+		signal.TextInput.Focus()
+	}
+}
+
+// currentSendMessage returns the message of the section the cursor is in (nil if there is none)
+func (m *Model) currentSendMessage() *SendMessage {
+	if m.CurrentInputIndex < 0 || m.CurrentInputIndex >= len(m.SendRows) {
+		return nil
+	}
+	return m.SendMessages[m.SendRows[m.CurrentInputIndex].message]
+}
+
+// currentSendSignal returns the signal of the row the cursor is on (nil for messages without signals)
+func (m *Model) currentSendSignal() *SendSignal {
+	msg := m.currentSendMessage()
+	if msg == nil {
+		return nil
+	}
+	row := m.SendRows[m.CurrentInputIndex]
+	if row.signal < 0 {
+		return nil
+	}
+	return &msg.Signals[row.signal]
+}
+
 //m.SendStatus = fmt.Sprintf("✅  Sent signals: %s", strings.Join(signalNames, ", "))
 
+// toggleCyclicalSending starts or stops the cyclical sending of the message
+func (m *Model) toggleCyclicalSending(msg *SendMessage) {
+	_, ok := m.ActiveMessages[int(msg.Key())]
+	if ok {
+		m.stopCyclicalSending(msg)
+	} else {
+		m.startCyclicalSending(msg)
+	}
+}
 
-// This starts a goroutine to send the current selected message cyclically
-func (m *Model) startCyclicalSending() {
+// This starts a goroutine to send the message cyclically
+func (m *Model) startCyclicalSending(msg *SendMessage) {
 	
-	frame, ok := m.GenarateFrame()
+	frame, ok := m.GenarateFrame(msg)
 	if !ok {
 		return // Error message already set in GenarateFrame
 	}
@@ -496,7 +672,7 @@ func (m *Model) startCyclicalSending() {
 	//try to send the first frame immediately to catch errors before starting the goroutine
 	err := m.sendFrame(frame)
 	if err != nil {
-		m.SendStatus = fmt.Sprintf("⚠️ SocketCAN error: %v", err)
+		m.SendStatus = fmt.Sprintf("⚠️ CAN bus error: %v", err)
 		return
 	} 
 
@@ -504,42 +680,103 @@ func (m *Model) startCyclicalSending() {
 	ctx, cancel := context.WithCancel(context.Background())
 	mex := infoSending{
 		stop:      cancel,
-		frequency: m.CycleTime,
+		frequency: msg.CycleTime,
+		frame:     &atomic.Pointer[bus.Frame]{},
 	}
-	m.ActiveMessages[int(frame.ID)] = mex
+	mex.frame.Store(&frame)
+	m.ActiveMessages[int(canDebug.Key(frame.ID, frame.IsExtended))] = mex
 
-	//this goroutine sends a message every 'interval' of time, ctx is used to stop
-	go func(interval time.Duration, ctx context.Context, frame can.Frame) {
+	//this goroutine sends the current frame every 'interval' of time, ctx is used to stop.
+	//It only reads the frame, the errors are posted to the UI (the first one of each streak of failures)
+	go func(interval time.Duration, ctx context.Context, frame *atomic.Pointer[bus.Frame], canBus bus.Bus, errs chan<- SendErrorMsg, key uint32, name string) {
 		tick := time.NewTicker(interval)
 		defer tick.Stop()
+		failing := false
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-tick.C:
-				m.sendFrame(frame)
+				err := canBus.Send(ctx, *frame.Load())
+				if err != nil && !failing && ctx.Err() == nil {
+					select {
+					case errs <- SendErrorMsg{Key: key, Name: name, Err: err}:
+					default:
 			}
 		}
-	}(time.Duration(mex.frequency)*time.Millisecond, ctx, frame)
+				failing = err != nil
+			}
+		}
+	}(time.Duration(mex.frequency)*time.Millisecond, ctx, mex.frame, m.Bus, m.sendErrors, msg.Key(), msg.Name)
 
-	m.SendStatus = fmt.Sprintf("🔄  Message '%s': Cyclical sending started (interval: %dms).", m.SelectedMessages[0].Name, m.CycleTime)
+	m.SendStatus = fmt.Sprintf("🔄  Message '%s': Cyclical sending started (interval: %dms).", msg.Name, msg.CycleTime)
 	// Update the table to reflect the new status
 	m.updateSendTableRows()
 }
 
-// stopCyclicalSending stops cyclical sending of the current selected message
-func (m *Model) stopCyclicalSending() {
-	messageID := m.SelectedMessages[0].ID //ID in decimal
-	messageName := m.SelectedMessages[0].Name
+// stopCyclicalSending stops cyclical sending of the message
+func (m *Model) stopCyclicalSending(msg *SendMessage) {
+	messageID := msg.Key() //ID in decimal (see CANMessage.Key)
 
 	//stop goroutine that is sending the message
-	mex := m.ActiveMessages[int(messageID)]
+	mex, ok := m.ActiveMessages[int(messageID)]
+	if !ok {
+		return
+	}
 	mex.stop()
 	delete(m.ActiveMessages, int(messageID))
-	m.SendStatus = fmt.Sprintf("🛑 Message '%s': Cyclical sending stopped", messageName)
+	m.SendStatus = fmt.Sprintf("🛑 Message '%s': Cyclical sending stopped", msg.Name)
 	m.updateSendTableRows()
 }
 
+// refreshCyclicalFrame regenerates the frame of the message if it is being sent cyclically,
+// so that the next cycles transmit the current values. It returns false if the message is not active
+// or the values cannot be encoded (the last valid frame keeps being sent)
+func (m *Model) refreshCyclicalFrame(msg *SendMessage) bool {
+	mex, ok := m.ActiveMessages[int(msg.Key())]
+	if !ok {
+		return false
+	}
+
+	frame, ok := m.GenarateFrame(msg)
+	if !ok {
+		return false // Error message already set in GenarateFrame
+	}
+	mex.frame.Store(&frame)
+
+	return true
+}
+
+// updateSignalValue confirms the value edited in the input of the signal,
+// and applies it to the cyclical sending of the message if active
+func (m *Model) updateSignalValue(msg *SendMessage, signal *SendSignal) {
+	value := signal.Value()
+	if signal.Unit != "" {
+		value += " " + signal.Unit
+	}
+
+	// The muxor value selects the layout of the signals shown and sent
+	if signal.IsMuxor() {
+		m.refreshSendRows()
+		if _, ok := signal.MuxValue(); !ok {
+			m.SendStatus = fmt.Sprintf("⚠️  Muxor '%s' of '%s' must select a layout between 0 and %d", signal.SignalName, msg.Name, signal.MuxCount-1)
+			return
+		}
+	}
+
+	mex, active := m.ActiveMessages[int(msg.Key())]
+	if !active {
+		m.SendStatus = fmt.Sprintf("✏️  Signal '%s' of '%s' set to %s", signal.SignalName, msg.Name, value)
+		return
+	}
+
+	if !m.refreshCyclicalFrame(msg) {
+		m.SendStatus += " (still sending the last valid values)"
+		return
+	}
+	m.SendStatus = fmt.Sprintf("✏️  Signal '%s' of '%s' set to %s: sent from the next cycle (every %dms)", signal.SignalName, msg.Name, value, mex.frequency)
+}
+
 func (m *Model) stopAllMessages() {
 	for id, mex := range m.ActiveMessages {
 		mex.stop()
@@ -549,43 +786,34 @@ func (m *Model) stopAllMessages() {
 	m.updateSendTableRows()
 }
 
-// sendSingleMessage sends all signals of a message once
-func (m *Model) sendSingleMessage() {
+// sendSingleMessage sends all signals of a message once,
+// it returns the command resetting the single shot mark
+func (m *Model) sendSingleMessage(msg *SendMessage) tea.Cmd {
 	// Generate and send the CAN frame
-	frame, ok := m.GenarateFrame()
+	frame, ok := m.GenarateFrame(msg)
 	if !ok {
-		return // Error message already set in GenarateFrame
+		return nil // Error message already set in GenarateFrame
 	}
 	err := m.sendFrame(frame)
 	if err != nil {
-		m.SendStatus = fmt.Sprintf("⚠️ SocketCAN error: %v", err)
-		return
+		m.SendStatus = fmt.Sprintf("⚠️ CAN bus error: %v", err)
+		return nil
 	} 
 
-	// Find all signals for this message and send them
-	sentCount := 0
-	for i := range m.SendSignals {
-		signal := &m.SendSignals[i]
-		signal.IsSingleShot = true
-		sentCount++
-	}
-	m.SendStatus = fmt.Sprintf("📤 Sent message '%s' (%d signals) once: %v", m.SelectedMessages[0].Name, sentCount, frame.Data)
+	msg.SingleShot = true
+	m.SendStatus = fmt.Sprintf("📤 Sent message '%s' (%d signals) once: %v", msg.Name, msg.sentSignals(), frame.Payload())
 	
-	// Update display and reset single shot flags after a brief moment (blink effect)
+	// Update display and reset single shot flag after a brief moment (blink effect)
 	m.updateSendTableRows()
-	go func() {
-		time.Sleep(2 * time.Second)
-		for i := range m.SendSignals {
-			m.SendSignals[i].IsSingleShot = false
-		}
-		m.updateSendTableRows()
-	}()
+	return tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+		return SingleShotDoneMsg{Message: msg}
+	})
 }
 
-// adjustMessageCycleTime adjusts cycle time for all signals of a message
-func (m *Model) adjustMessageCycleTime(delta int) {
+// adjustMessageCycleTime adjusts cycle time of a message
+func (m *Model) adjustMessageCycleTime(msg *SendMessage, delta int) {
 	// Calculate new cycle time with bounds checking
-	newCycleTime := m.CycleTime + delta
+	newCycleTime := msg.CycleTime + delta
 	if newCycleTime < rangeMs {
 		newCycleTime = rangeMs // rangeMs is a constant that rapresents the minimum interval for the cycle
 	}
@@ -593,18 +821,68 @@ func (m *Model) adjustMessageCycleTime(delta int) {
 		newCycleTime = 10000 // max 10 seconds
 	}
 
-	m.CycleTime = newCycleTime
+	msg.CycleTime = newCycleTime
+	// Restart the cyclical sending with the new period
+	if mex, ok := m.ActiveMessages[int(msg.Key())]; ok && mex.frequency != newCycleTime {
+		m.stopCyclicalSending(msg)
+		m.startCyclicalSending(msg)
+	}
 	// Update display
 	m.updateSendTableRows()
 
-	// Show status
-	m.SendStatus = fmt.Sprintf("🔄 Set cycle time to %dms for message '%s'", newCycleTime, m.SelectedMessages[0].Name)
+	// Show status, warning if the period deviates from the DBC one
+	m.SendStatus = fmt.Sprintf("🔄 Set cycle time to %dms for message '%s'", newCycleTime, msg.Name)
+	if spec := msg.SpecCycleTime(); spec > 0 && newCycleTime != spec {
+		m.SendStatus += fmt.Sprintf(" ⚠️  the DBC specifies %dms", spec)
+	}
+}
+
+// adjustDLC changes the DLC override of the message by delta, the DLC ranges from 0 to 15
+// (classic frames with a DLC of 9-15 carry 8 bytes)
+func (m *Model) adjustDLC(msg *SendMessage, delta int) {
+	msg.DLC = max(0, min(int(msg.SendDLC())+delta, 15))
+	m.updateSendTableRows()
+	m.refreshCyclicalFrame(msg)
+
+	declared := msg.DeclaredDLC()
+	if msg.DLC == int(declared) {
+		m.SendStatus = fmt.Sprintf("📏 DLC of message '%s' set to %d (as declared in the DBC)", msg.Name, msg.DLC)
+	} else {
+		m.SendStatus = fmt.Sprintf("⚠️  DLC of message '%s' overridden to %d (DBC declares %d): fault injection, receivers may reject the frames", msg.Name, msg.DLC, declared)
+	}
+	if !msg.FD && msg.DLC > bus.MaxDataLength {
+		m.SendStatus += " • classic frames with a DLC over 8 carry 8 bytes"
+	}
+}
+
+// resetDLC removes the DLC override, the frames are sent with the DLC declared in the DBC
+func (m *Model) resetDLC(msg *SendMessage) {
+	msg.DLC = -1
+	m.updateSendTableRows()
+	m.refreshCyclicalFrame(msg)
+	m.SendStatus = fmt.Sprintf("📏 DLC of message '%s' reset to the DBC value (%d)", msg.Name, msg.DeclaredDLC())
+}
+
+// toggleBRS toggles the bit rate switch of the CAN FD frames sent
+func (m *Model) toggleBRS(msg *SendMessage) {
+	if !msg.FD {
+		m.SendStatus = fmt.Sprintf("⚠️  Message '%s' is not a CAN FD message: BRS not available", msg.Name)
+		return
+	}
+
+	msg.BRS = !msg.BRS
+	m.refreshCyclicalFrame(msg)
+	state := "off"
+	if msg.BRS {
+		state = "on"
+	}
+	m.SendStatus = fmt.Sprintf("⚡ Bit rate switch %s for message '%s' (applies from the next frame sent)", state, msg.Name)
 }
 
 // ensureTableCursorVisible ensures the table cursor remains visible during scroll
 func (m *Model) ensureTableCursorVisible() {
 	// For send table
-	if m.State == StateSendConfiguration && m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
+	if m.State == StateSendConfiguration && m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendRows) {
 		// Force the table to focus and set cursor position
 		m.SendTable.Focus()
 		m.SendTable.SetCursor(m.CurrentInputIndex)
@@ -617,15 +895,19 @@ func (m *Model) ensureTableCursorVisible() {
 	}
 }
 
-// GenarateFrame creates a CAN frame from the current SendSignals values
-func (m *Model) GenarateFrame() (can.Frame, bool) {
-	frame := can.Frame{}
-	mex := m.SelectedMessages[0].Message
+// GenarateFrame creates a CAN frame from the current values of the signals of the message
+func (m *Model) GenarateFrame(msg *SendMessage) (bus.Frame, bool) {
+	frame := bus.Frame{}
+	mex := msg.Message
 	
-	// for each signal
-	for _, signal := range mex.Signals() {
+	// for each signal sent with the selected layouts
+	for _, signal := range messageSignals(mex) {
+		if i := msg.signalIndex(signal.Name()); i >= 0 && !msg.IsSent(i) {
+			continue
+		}
+
 		//find inserted value
-		value, err := m.getInsertedValue(signal)
+		value, err := m.getInsertedValue(msg, signal.Signal)
 		if err != nil {
 			m.SendStatus = fmt.Sprintf("⚠️  Error getting signal %s: %s", signal.Name(), err.Error())
 			return frame, false
@@ -660,17 +942,29 @@ func (m *Model) GenarateFrame() (can.Frame, bool) {
 	//build and return the frame
 	data := mex.SignalLayout().Encode()
 	copy(frame.Data[:], data)
-	frame.ID = uint32(mex.GetCANID())
-	frame.Length = uint8(len(frame.Data))
+	frame.ID, frame.IsExtended = canDebug.MessageID(mex)
+	frame.IsFD = msg.FD
+	if frame.IsFD {
+		frame.BRS = msg.BRS
+	}
+	// Bytes beyond the encoded data are sent as zeros, the extra ones are truncated
+	frame.Length = bus.DLCToLength(msg.SendDLC(), frame.IsFD)
+	if dlc := msg.SendDLC(); !frame.IsFD && dlc > bus.MaxDataLength {
+		frame.Len8DLC = dlc
+	}
 
 	return frame, true
 }
 
 // getInsertedValue cheks if the signal passed is currently selected, if it is it return the value inserted in input
-func (m *Model) getInsertedValue(signal acmelib.Signal) (float64, error){
+func (m *Model) getInsertedValue(msg *SendMessage, signal acmelib.Signal) (float64, error) {
 
-	for _, SendSig := range m.SendSignals{
+	for _, SendSig := range msg.Signals {
 		if SendSig.SignalName == signal.Name(){
+			// Enum signals send the raw value of the selected label
+			if SendSig.IsEnum() {
+				return float64(SendSig.EnumValues[SendSig.EnumIndex].Index()), nil
+			}
 			value := SendSig.TextInput.Value()
 			//if value is empty assign 0
 			if value == ""{
@@ -683,11 +977,11 @@ func (m *Model) getInsertedValue(signal acmelib.Signal) (float64, error){
 	return 0, fmt.Errorf("segnale non trovato")
 }
 
-// sendWithSocketCAN sends a message using SocketCAN (Linux)
-func (m *Model) sendFrame(frame can.Frame) error{
+// sendFrame sends a frame on the CAN bus
+func (m *Model) sendFrame(frame bus.Frame) error {
 	// Send the frame
-	if m.CanNetwork == nil {
-		return fmt.Errorf("⚠️  No SocketCAN transmitter available")
+	if m.Bus == nil {
+		return fmt.Errorf("⚠️  No CAN bus available")
 	}
-	return m.Transmitter.TransmitFrame(context.Background(), frame)
+	return m.Bus.Send(context.Background(), frame)
 }
\ No newline at end of file
@@ -0,0 +1,365 @@
+package ui
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+)
+
+// Fields of the ISO-TP screen (indexes of ISOTPInputs)
+const (
+	isotpFieldTx = iota
+	isotpFieldRx
+	isotpFieldBlockSize
+	isotpFieldSTmin
+	isotpFieldPayload
+	isotpFieldCount
+)
+
+const (
+	// isotpLogSize is the number of transfers kept in the log of the ISO-TP screen
+	isotpLogSize = 200
+	// isotpShownBytes is how many bytes of a payload the log shows
+	isotpShownBytes = 32
+	// isotpSendTimeout bounds a transmission, flow control waits included
+	isotpSendTimeout = 10 * time.Second
+)
+
+// isotpEntry is a payload sent or received (or a failed transfer) in the log of the ISO-TP screen
+type isotpEntry struct {
+	Time    time.Time
+	Tx      bool
+	Payload []byte
+	Err     error
+	Elapsed time.Duration // duration of the transmission
+}
+
+// ISOTPReceivedMsg carries a payload received by the ISO-TP connection (nil once it is closed)
+type ISOTPReceivedMsg struct {
+	conn    *isotp.Conn
+	Payload []byte
+	Err     error
+}
+
+// ISOTPSentMsg reports the end of the transmission of a payload
+type ISOTPSentMsg struct {
+	conn    *isotp.Conn
+	Payload []byte
+	Err     error
+	Elapsed time.Duration
+}
+
+// setupISOTP prepares the ISO-TP screen, the inputs keep the values of the previous visit
+func (m *Model) setupISOTP() tea.Cmd {
+	if len(m.ISOTPInputs) == 0 {
+		m.ISOTPInputs = make([]textinput.Model, isotpFieldCount)
+		defaults := []struct{ placeholder, value string }{
+			isotpFieldTx:        {"hex ID, e.g. 7E0", "7E0"},
+			isotpFieldRx:        {"hex ID, e.g. 7E8", "7E8"},
+			isotpFieldBlockSize: {"0-255, 0 = no limit", "0"},
+			isotpFieldSTmin:     {"ms, 0-127", "0"},
+			isotpFieldPayload:   {"hex bytes, e.g. 22 F1 90", ""},
+		}
+		for i, d := range defaults {
+			ti := textinput.New()
+			ti.Placeholder = d.placeholder
+			ti.SetValue(d.value)
+			ti.CharLimit = 12
+			ti.Width = 30
+			if i == isotpFieldPayload {
+				ti.CharLimit = 3 * 4095
+				ti.Width = 60
+			}
+			m.ISOTPInputs[i] = ti
+		}
+		m.ISOTPField = isotpFieldPayload
+	}
+	m.focusISOTPField(m.ISOTPField)
+
+	return m.openISOTP()
+}
+
+// focusISOTPField moves the focus to a field of the ISO-TP screen
+func (m *Model) focusISOTPField(field int) {
+	m.ISOTPField = (field + isotpFieldCount) % isotpFieldCount
+	for i := range m.ISOTPInputs {
+		if i == m.ISOTPField {
+			m.ISOTPInputs[i].Focus()
+		} else {
+			m.ISOTPInputs[i].Blur()
+		}
+	}
+}
+
+// isotpConfig parses the fields of the ISO-TP screen
+func (m *Model) isotpConfig() (isotp.Config, error) {
+	parseID := func(field int, name string) (uint32, bool, error) {
+		value := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(m.ISOTPInputs[field].Value())), "0x")
+		id, err := strconv.ParseUint(value, 16, 32)
+		if err != nil || id > 0x1FFFFFFF {
+			return 0, false, fmt.Errorf("invalid %s ID %q", name, m.ISOTPInputs[field].Value())
+		}
+		return uint32(id), id > 0x7FF, nil
+	}
+
+	tx, txExtended, err := parseID(isotpFieldTx, "TX")
+	if err != nil {
+		return isotp.Config{}, err
+	}
+	rx, rxExtended, err := parseID(isotpFieldRx, "RX")
+	if err != nil {
+		return isotp.Config{}, err
+	}
+	if tx == rx {
+		return isotp.Config{}, fmt.Errorf("TX and RX IDs must be different")
+	}
+
+	bs, err := strconv.ParseUint(strings.TrimSpace(m.ISOTPInputs[isotpFieldBlockSize].Value()), 10, 8)
+	if err != nil {
+		return isotp.Config{}, fmt.Errorf("invalid block size %q (0-255)", m.ISOTPInputs[isotpFieldBlockSize].Value())
+	}
+	stmin, err := strconv.ParseUint(strings.TrimSpace(m.ISOTPInputs[isotpFieldSTmin].Value()), 10, 8)
+	if err != nil || stmin > 127 {
+		return isotp.Config{}, fmt.Errorf("invalid STmin %q (0-127 ms)", m.ISOTPInputs[isotpFieldSTmin].Value())
+	}
+
+	return isotp.Config{
+		TxID:      tx,
+		RxID:      rx,
+		Extended:  txExtended || rxExtended,
+		BlockSize: uint8(bs),
+		STmin:     time.Duration(stmin) * time.Millisecond,
+		Padding:   true,
+		PadByte:   isotp.DefaultPadByte,
+		FD:        m.ISOTPFD,
+		BRS:       m.ISOTPFD,
+	}, nil
+}
+
+// openISOTP (re)opens the ISO-TP connection with the IDs of the screen,
+// it returns the command delivering the payloads received
+func (m *Model) openISOTP() tea.Cmd {
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - ISO-TP disabled")
+		return nil
+	}
+
+	cfg, err := m.isotpConfig()
+	if err != nil {
+		m.Err = err
+		return nil
+	}
+
+	m.closeISOTP()
+	m.Err = nil
+	m.isotpConn = isotp.Dial(m.Bus, cfg)
+	frames := "classic frames"
+	if cfg.FD {
+		frames = "CAN FD frames"
+	}
+	m.SendStatus = fmt.Sprintf("🔌 Listening on %s, sending on %s (BS %d, STmin %s, %s)",
+		can.FormatID(cfg.RxID, cfg.Extended), can.FormatID(cfg.TxID, cfg.Extended), cfg.BlockSize, cfg.STmin, frames)
+
+	return waitISOTP(m.isotpConn)
+}
+
+// closeISOTP closes the ISO-TP connection (if any)
+func (m *Model) closeISOTP() {
+	if m.isotpConn == nil {
+		return
+	}
+	m.isotpConn.Close()
+	m.isotpConn = nil
+}
+
+// waitISOTP returns the command delivering the next payload received by the connection
+func waitISOTP(conn *isotp.Conn) tea.Cmd {
+	return func() tea.Msg {
+		payload, err := conn.Receive(context.Background())
+		if err == isotp.ErrClosed {
+			return nil
+		}
+		return ISOTPReceivedMsg{conn: conn, Payload: payload, Err: err}
+	}
+}
+
+// sendISOTP sends the payload of the screen in the background
+func (m *Model) sendISOTP() tea.Cmd {
+	if m.isotpConn == nil {
+		m.SendStatus = "⚠️ No ISO-TP connection, check the IDs and press Enter on them"
+		return nil
+	}
+
+	payload, err := parseHexBytes(m.ISOTPInputs[isotpFieldPayload].Value())
+	if err != nil {
+		m.SendStatus = fmt.Sprintf("⚠️ %v", err)
+		return nil
+	}
+	if len(payload) == 0 {
+		m.SendStatus = "⚠️ Type the payload to send"
+		return nil
+	}
+
+	conn := m.isotpConn
+	m.SendStatus = fmt.Sprintf("⏳ Sending %d bytes...", len(payload))
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), isotpSendTimeout)
+		defer cancel()
+
+		start := time.Now()
+		err := conn.Send(ctx, payload)
+		return ISOTPSentMsg{conn: conn, Payload: payload, Err: err, Elapsed: time.Since(start)}
+	}
+}
+
+// logISOTP adds a transfer to the log of the ISO-TP screen
+func (m *Model) logISOTP(entry isotpEntry) {
+	m.ISOTPLog = append(m.ISOTPLog, entry)
+	if len(m.ISOTPLog) > isotpLogSize {
+		m.ISOTPLog = m.ISOTPLog[len(m.ISOTPLog)-isotpLogSize:]
+	}
+}
+
+// updateISOTP handles the keys of the ISO-TP screen
+func (m *Model) updateISOTP(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "shift+tab":
+		m.focusISOTPField(m.ISOTPField - 1)
+		return nil
+	case "down":
+		m.focusISOTPField(m.ISOTPField + 1)
+		return nil
+	case "enter":
+		if m.ISOTPField == isotpFieldPayload {
+			return m.sendISOTP()
+		}
+		// Apply the IDs and the flow control parameters
+		return m.openISOTP()
+	case "ctrl+l":
+		m.ISOTPLog = nil
+		return nil
+	case "ctrl+f":
+		// The connection is reopened with the new frames
+		m.ISOTPFD = !m.ISOTPFD
+		return m.openISOTP()
+	}
+
+	var cmd tea.Cmd
+	m.ISOTPInputs[m.ISOTPField], cmd = m.ISOTPInputs[m.ISOTPField].Update(msg)
+	return cmd
+}
+
+// parseHexBytes parses bytes written in hex, with or without separators (e.g. "22 F1 90", "22F190", "22:f1:90")
+func parseHexBytes(s string) ([]byte, error) {
+	s = strings.NewReplacer(" ", "", ":", "", ",", "", "0x", "", "0X", "").Replace(s)
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("odd number of hex digits in the payload")
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex payload: %w", err)
+	}
+	return data, nil
+}
+
+// formatPayload renders the first bytes of a payload in hex, with its length if truncated
+func formatPayload(payload []byte, limit int) string {
+	shown := payload[:min(len(payload), limit)]
+	s := strings.ToUpper(hex.EncodeToString(shown))
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i += 2 {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(s[i : i+2])
+	}
+	if len(payload) > limit {
+		sb.WriteString(fmt.Sprintf(" … (%d bytes)", len(payload)))
+	}
+	return sb.String()
+}
+
+// isotpView renders the ISO-TP screen
+func (m Model) isotpView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("📦 ISO-TP (ISO 15765-2)"))
+	s.WriteString("\n\n")
+
+	s.WriteString("↑/↓ field • Enter on the IDs/flow control: apply • Enter on the payload: send • Ctrl+F classic/CAN FD • Ctrl+L clear log • Tab back to mode selection • Ctrl+C quit")
+	s.WriteString("\n\n")
+
+	labels := []string{
+		isotpFieldTx:        "TX ID",
+		isotpFieldRx:        "RX ID",
+		isotpFieldBlockSize: "Block size",
+		isotpFieldSTmin:     "STmin (ms)",
+		isotpFieldPayload:   "Payload",
+	}
+	for i, input := range m.ISOTPInputs {
+		cursor := "  "
+		if i == m.ISOTPField {
+			cursor = "▶ "
+		}
+		s.WriteString(fmt.Sprintf("%s%-11s %s\n", cursor, labels[i]+":", input.View()))
+	}
+	frames := "classic (8 bytes)"
+	if m.ISOTPFD {
+		frames = "CAN FD (64 bytes, BRS)"
+	}
+	s.WriteString(fmt.Sprintf("  %-11s %s\n", "Frames:", frames))
+	s.WriteString("\n")
+
+	// Last transfers, the newest at the bottom
+	header := lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("%-12s  %-3s  %s", "Time", "Dir", "Payload"))
+	s.WriteString(header + "\n")
+
+	rows := max(3, m.Height-len(m.ISOTPInputs)-13)
+	start := max(0, len(m.ISOTPLog)-rows)
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+	for _, entry := range m.ISOTPLog[start:] {
+		dir := "RX"
+		if entry.Tx {
+			dir = "TX"
+		}
+		line := fmt.Sprintf("%-12s  %-3s  ", entry.Time.Format("15:04:05.000"), dir)
+		if entry.Err != nil {
+			s.WriteString(line + errorStyle.Render(fmt.Sprintf("⚠️ %v", entry.Err)) + "\n")
+			continue
+		}
+		line += formatPayload(entry.Payload, isotpShownBytes)
+		if entry.Tx {
+			line += fmt.Sprintf(" [%d bytes, %s]", len(entry.Payload), entry.Elapsed.Round(time.Microsecond))
+		}
+		s.WriteString(line + "\n")
+	}
+	if len(m.ISOTPLog) == 0 {
+		s.WriteString("(no payload yet)\n")
+	}
+
+	if m.Err != nil {
+		wrappedStatus := m.wrapStatus(fmt.Sprintf("⚠️ %v", m.Err), m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	} else if m.SendStatus != "" {
+		wrappedStatus := m.wrapStatus(m.SendStatus, m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	}
+
+	return s.String()
+}
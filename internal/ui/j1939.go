@@ -0,0 +1,482 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/squadracorsepolito/acmelib"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/j1939"
+)
+
+// Views of the J1939 screen, switched with v
+const (
+	j1939ViewPGNs = iota
+	j1939ViewTransport
+	j1939ViewAddresses
+	j1939ViewCount
+)
+
+const (
+	// j1939LogSize is the number of reassembled messages kept
+	j1939LogSize = 200
+	// j1939ShownBytes is the number of bytes of the reassembled messages shown
+	j1939ShownBytes = 24
+	// j1939ToolAddress is the source address of the requests, the one of the off-board diagnostic tools
+	j1939ToolAddress = 0xF9
+)
+
+// j1939Key identifies the rows of the PGN table
+type j1939Key struct {
+	pgn                 uint32
+	source, destination uint8
+}
+
+// j1939Traffic counts the frames of a PGN from a source to a destination
+type j1939Traffic struct {
+	ID    j1939.ID
+	Frame bus.Frame // last frame
+	Count uint64
+	First time.Time
+	Last  time.Time
+}
+
+// j1939Transfer is a message of the transport protocol, or the error of a broken transfer
+type j1939Transfer struct {
+	Time    time.Time
+	Message *j1939.Message
+	Err     error
+}
+
+// j1939Monitor follows the J1939 traffic in its own goroutine. It never touches the model:
+// the UI reads its state at every tick, under its mutex
+type j1939Monitor struct {
+	mu          sync.Mutex // protects the fields below
+	traffic     map[j1939Key]*j1939Traffic
+	reassembler *j1939.Reassembler
+	addresses   *j1939.AddressTable
+	transfers   []j1939Transfer
+	frames      uint64
+}
+
+func newJ1939Monitor() *j1939Monitor {
+	return &j1939Monitor{
+		traffic:     make(map[j1939Key]*j1939Traffic),
+		reassembler: j1939.NewReassembler(),
+		addresses:   j1939.NewAddressTable(),
+	}
+}
+
+// run receives the frames until the receiver is closed, it is intended as a goroutine
+func (j *j1939Monitor) run(recv bus.Receiver) {
+	defer recv.Close()
+
+	for recv.Receive() {
+		j.handle(recv.Frame())
+	}
+}
+
+// handle records an extended frame, the standard ones are not J1939
+func (j *j1939Monitor) handle(frame bus.Frame) {
+	if !frame.IsExtended || frame.IsRemote {
+		return
+	}
+	t := frame.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+	id := j1939.ParseID(frame.ID)
+	data := frame.Payload()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.frames++
+	key := j1939Key{pgn: id.PGN, source: id.Source, destination: id.Destination}
+	traffic, ok := j.traffic[key]
+	if !ok {
+		traffic = &j1939Traffic{ID: id, First: t}
+		j.traffic[key] = traffic
+	}
+	traffic.ID = id
+	traffic.Frame = frame
+	traffic.Count++
+	traffic.Last = t
+
+	j.addresses.Observe(t, id, data)
+
+	msg, err := j.reassembler.Handle(t, id, data)
+	// The timeouts of several transfers can come with the frame, one line each
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		if err != nil {
+			j.transfers = append(j.transfers, j1939Transfer{Time: t, Err: err})
+		}
+	}
+	if msg != nil {
+		j.transfers = append(j.transfers, j1939Transfer{Time: t, Message: msg})
+	}
+	if len(j.transfers) > j1939LogSize {
+		j.transfers = j.transfers[len(j.transfers)-j1939LogSize:]
+	}
+}
+
+// snapshot returns a copy of the traffic sorted by PGN and source, the reassembled messages and the nodes
+func (j *j1939Monitor) snapshot() ([]j1939Traffic, []j1939Transfer, []j1939.Node, uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	traffic := make([]j1939Traffic, 0, len(j.traffic))
+	for _, t := range j.traffic {
+		traffic = append(traffic, *t)
+	}
+	slices.SortFunc(traffic, func(a, b j1939Traffic) int {
+		if c := cmp.Compare(a.ID.PGN, b.ID.PGN); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.ID.Source, b.ID.Source); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID.Destination, b.ID.Destination)
+	})
+	return traffic, slices.Clone(j.transfers), j.addresses.Nodes(), j.frames
+}
+
+// setupJ1939 creates the tables of the J1939 screen and starts following the traffic
+func (m *Model) setupJ1939() {
+	m.J1939PGNTable = table.New(
+		table.WithColumns([]table.Column{
+			{Title: "Prio", Width: 4},
+			{Title: "PGN", Width: 16},
+			{Title: "Name", Width: 26},
+			{Title: "SA", Width: 6},
+			{Title: "DA", Width: 6},
+			{Title: "Count", Width: 8},
+			{Title: "Rate(Hz)", Width: 8},
+			{Title: "Data", Width: 23},
+			{Title: "Age", Width: 8},
+		}),
+		table.WithFocused(true),
+		table.WithHeight(m.j1939TableHeight()),
+	)
+	m.J1939AddressTable = table.New(
+		table.WithColumns([]table.Column{
+			{Title: "SA", Width: 5},
+			{Title: "NAME", Width: 16},
+			{Title: "Identity", Width: 8},
+			{Title: "Manuf.", Width: 6},
+			{Title: "Function", Width: 8},
+			{Title: "ECU/Fn inst", Width: 11},
+			{Title: "Industry", Width: 8},
+			{Title: "Arbitrary", Width: 9},
+			{Title: "Frames", Width: 8},
+			{Title: "Age", Width: 8},
+			{Title: "State", Width: 24},
+		}),
+		table.WithFocused(true),
+		table.WithHeight(m.j1939TableHeight()),
+	)
+	m.J1939Transfers = nil
+	m.J1939Frames = 0
+	m.j1939Messages = j1939Messages(m.Messages)
+
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - J1939 monitoring disabled")
+		return
+	}
+	m.Err = nil
+	m.SendStatus = ""
+	m.j1939Receiver = m.Bus.Subscribe()
+	m.j1939Monitor = newJ1939Monitor()
+	go m.j1939Monitor.run(m.j1939Receiver)
+}
+
+// closeJ1939 stops following the traffic, the receiving goroutine then exits
+func (m *Model) closeJ1939() {
+	if m.j1939Receiver != nil {
+		m.j1939Receiver.Close()
+		m.j1939Receiver = nil
+	}
+	m.j1939Monitor = nil
+}
+
+// j1939TableHeight returns the height of the tables, they take the screen below the help
+func (m *Model) j1939TableHeight() int {
+	return max(5, m.Height-14)
+}
+
+// updateJ1939Tables refreshes the tables from the traffic followed so far, called at every tick
+func (m *Model) updateJ1939Tables(now time.Time) {
+	if m.j1939Monitor == nil {
+		return
+	}
+	traffic, transfers, nodes, frames := m.j1939Monitor.snapshot()
+	m.J1939Traffic = traffic
+	m.J1939Transfers = transfers
+	m.J1939Frames = frames
+
+	rows := make([]table.Row, 0, len(traffic))
+	for _, t := range traffic {
+		rate := "-"
+		if elapsed := t.Last.Sub(t.First); t.Count > 1 && elapsed > 0 {
+			rate = fmt.Sprintf("%.1f", float64(t.Count-1)/elapsed.Seconds())
+		}
+		rows = append(rows, table.Row{
+			fmt.Sprintf("%d", t.ID.Priority),
+			fmt.Sprintf("0x%04X (%d)", t.ID.PGN, t.ID.PGN),
+			m.j1939Name(t.Frame.ID),
+			j1939.FormatAddress(t.ID.Source),
+			j1939.FormatAddress(t.ID.Destination),
+			fmt.Sprintf("%d", t.Count),
+			rate,
+			formatPayload(t.Frame.Payload(), 8),
+			formatAge(now.Sub(t.Last)),
+		})
+	}
+	m.J1939PGNTable.SetRows(rows)
+
+	rows = make([]table.Row, 0, len(nodes))
+	for _, node := range nodes {
+		row := table.Row{j1939.FormatAddress(node.Address), "-", "-", "-", "-", "-", "-", "-"}
+		if node.Claimed() {
+			arbitrary := "no"
+			if node.Name.ArbitraryAddressCapable() {
+				arbitrary = "yes"
+			}
+			row = table.Row{
+				j1939.FormatAddress(node.Address),
+				node.Name.String(),
+				fmt.Sprintf("%d", node.Name.IdentityNumber()),
+				fmt.Sprintf("%d", node.Name.ManufacturerCode()),
+				fmt.Sprintf("%d", node.Name.Function()),
+				fmt.Sprintf("%d/%d", node.Name.ECUInstance(), node.Name.FunctionInstance()),
+				fmt.Sprintf("%d", node.Name.IndustryGroup()),
+				arbitrary,
+			}
+		}
+
+		age := "-"
+		if !node.LastSeen.IsZero() {
+			age = formatAge(now.Sub(node.LastSeen))
+		}
+		state := "✅ claimed"
+		switch {
+		case !node.Claimed():
+			state = "❔ no claim seen"
+		case node.Address == j1939.NullAddress:
+			state = "❌ cannot claim"
+		case node.Lost:
+			state = "⚠️ lost to a lower NAME"
+		}
+		rows = append(rows, append(row, fmt.Sprintf("%d", node.Frames), age, state))
+	}
+	m.J1939AddressTable.SetRows(rows)
+}
+
+// j1939Messages returns the extended messages of the DBC by PGN, the first one of each PGN
+func j1939Messages(messages []*acmelib.Message) map[uint32]*acmelib.Message {
+	byPGN := make(map[uint32]*acmelib.Message)
+	for _, msg := range messages {
+		id, extended := canDebug.MessageID(msg)
+		if !extended {
+			continue
+		}
+		if _, ok := byPGN[j1939.PGN(id)]; !ok {
+			byPGN[j1939.PGN(id)] = msg
+		}
+	}
+	return byPGN
+}
+
+// j1939Message returns the DBC message of an extended ID: the one with this exact ID or else
+// the one with the same PGN, so that the messages decode whatever their source address,
+// priority and (for PDU1 PGNs) destination. Only the J1939 screen matches by PGN
+func (m *Model) j1939Message(canID uint32) (*acmelib.Message, bool) {
+	if m.Decoder != nil {
+		if msg, ok := m.Decoder.Lookup(canID, true); ok {
+			return msg, true
+		}
+	}
+	msg, ok := m.j1939Messages[j1939.PGN(canID)]
+	return msg, ok
+}
+
+// j1939Name returns the name of the DBC message with the PGN of the ID, or of the standard PGN
+func (m *Model) j1939Name(canID uint32) string {
+	if msg, ok := m.j1939Message(canID); ok {
+		return msg.Name()
+	}
+	if name := j1939.PGNName(j1939.PGN(canID)); name != "" {
+		return name
+	}
+	return "-"
+}
+
+// requestAddressClaims asks every node to send its address claim
+func (m *Model) requestAddressClaims() {
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available")
+		return
+	}
+	id := j1939.ID{Priority: 6, PGN: j1939.PGNRequest, Source: j1939ToolAddress, Destination: j1939.GlobalAddress}
+	frame := bus.Frame{ID: id.CANID(), IsExtended: true, Length: 3}
+	// The data is the PGN requested, little endian
+	pgn := uint32(j1939.PGNAddressClaimed)
+	copy(frame.Data[:], []byte{byte(pgn), byte(pgn >> 8), byte(pgn >> 16)})
+
+	if err := m.Bus.Send(context.Background(), frame); err != nil {
+		m.Err = fmt.Errorf("error sending the request for address claimed: %w", err)
+		return
+	}
+	m.Err = nil
+	m.SendStatus = fmt.Sprintf("📨 Request for Address Claimed sent to every node from %s", j1939.FormatAddress(j1939ToolAddress))
+}
+
+// updateJ1939 handles the keys of the J1939 screen
+func (m *Model) updateJ1939(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case "v":
+		m.J1939View = (m.J1939View + 1) % j1939ViewCount
+		return nil
+	case "r":
+		m.requestAddressClaims()
+		return nil
+	case "ctrl+l":
+		// Start from scratch
+		m.closeJ1939()
+		m.setupJ1939()
+		return nil
+	}
+
+	var cmd tea.Cmd
+	switch m.J1939View {
+	case j1939ViewPGNs:
+		m.J1939PGNTable, cmd = m.J1939PGNTable.Update(msg)
+	case j1939ViewAddresses:
+		m.J1939AddressTable, cmd = m.J1939AddressTable.Update(msg)
+	}
+	return cmd
+}
+
+// formatSignals formats decoded signals as "name=value unit", separated by commas
+func formatSignals(decodings []*acmelib.SignalDecoding) string {
+	values := make([]string, 0, len(decodings))
+	for _, sgn := range decodings {
+		value := sgn.Signal.Name() + "=" + formatSignalValue(sgn)
+		if sgn.Unit != "" {
+			value += " " + sgn.Unit
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, ", ")
+}
+
+// j1939View renders the J1939 screen
+func (m Model) j1939View() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("🚜 J1939 monitor"))
+	s.WriteString("\n\n")
+	s.WriteString("v switch view (PGNs → transport protocol → address claims) • ↑/↓ scroll • r request address claims • Ctrl+L clear\n")
+	s.WriteString("Tab back to mode selection • q quit")
+	s.WriteString("\n\n")
+
+	views := []string{
+		j1939ViewPGNs:      "📊 PGNs",
+		j1939ViewTransport: "🧩 Transport protocol",
+		j1939ViewAddresses: "🏷️  Address claims",
+	}
+	s.WriteString(fmt.Sprintf("%s • %d extended frames received\n\n", views[m.J1939View], m.J1939Frames))
+
+	switch m.J1939View {
+	case j1939ViewPGNs:
+		if len(m.J1939PGNTable.Rows()) == 0 {
+			s.WriteString("(no extended frames received yet)\n")
+			break
+		}
+		s.WriteString(m.J1939PGNTable.View())
+		s.WriteString("\n")
+
+		// Signals of the row under the cursor, the DBC messages match any source address
+		if i := m.J1939PGNTable.Cursor(); i >= 0 && i < len(m.J1939Traffic) {
+			frame := m.J1939Traffic[i].Frame
+			if msg, ok := m.j1939Message(frame.ID); ok {
+				signals := formatSignals(msg.SignalLayout().Decode(frame.Payload()))
+				s.WriteString(m.wrapStatus(fmt.Sprintf("🔎 %s: %s", msg.Name(), signals), m.Width))
+				s.WriteString("\n")
+			}
+		}
+
+	case j1939ViewTransport:
+		transfers := m.J1939Transfers
+		rows := max(3, m.Height-12)
+		if len(transfers) > rows {
+			transfers = transfers[len(transfers)-rows:]
+		}
+		if len(transfers) == 0 {
+			s.WriteString("(no BAM or RTS/CTS transfers yet)\n")
+		}
+		for _, t := range transfers {
+			s.WriteString(m.formatJ1939Transfer(t))
+			s.WriteString("\n")
+		}
+
+	case j1939ViewAddresses:
+		if len(m.J1939AddressTable.Rows()) == 0 {
+			s.WriteString("(no nodes seen yet, r asks them to claim their address)\n")
+			break
+		}
+		s.WriteString(m.J1939AddressTable.View())
+		s.WriteString("\n")
+	}
+
+	if m.Err != nil {
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", m.wrapStatus(fmt.Sprintf("⚠️ %v", m.Err), m.Width)))
+	} else if m.SendStatus != "" {
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", m.wrapStatus(m.SendStatus, m.Width)))
+	}
+
+	return s.String()
+}
+
+// formatJ1939Transfer renders a reassembled message with its signals, if the DBC declares its PGN
+func (m Model) formatJ1939Transfer(t j1939Transfer) string {
+	timestamp := t.Time.Format("15:04:05.000")
+	if t.Err != nil {
+		return fmt.Sprintf("%s  ⚠️  %v", timestamp, t.Err)
+	}
+
+	msg := t.Message
+	kind := "RTS/CTS"
+	if msg.BAM {
+		kind = "BAM"
+	}
+	line := fmt.Sprintf("%s  %-7s PGN 0x%04X %s→%s  %4d bytes in %s  %s  %s",
+		timestamp, kind, msg.ID.PGN, j1939.FormatAddress(msg.ID.Source), j1939.FormatAddress(msg.ID.Destination),
+		len(msg.Data), msg.End.Sub(msg.Start).Round(time.Millisecond), m.j1939Name(msg.ID.CANID()), formatPayload(msg.Data, j1939ShownBytes))
+
+	if dbcMsg, ok := m.j1939Message(msg.ID.CANID()); ok {
+		if decodings := dbcMsg.SignalLayout().Decode(msg.Data); len(decodings) > 0 {
+			line += "\n    " + formatSignals(decodings)
+		}
+	}
+	return line
+}
@@ -1,16 +1,16 @@
 package ui
 
 import (
-	"net"
 	"time"
 
 	"github.com/charmbracelet/bubbles/filepicker"
 	tea "github.com/charmbracelet/bubbletea"
-	"go.einride.tech/can/pkg/socketcan"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
 )
 
 // NewModel create a new model for the UI
-func NewModel(CanNet net.Conn) Model {
+func NewModel(canBus bus.Bus) Model {
 	fp := filepicker.New()
 	fp.AllowedTypes = []string{".dbc"}
 	fp.CurrentDirectory = "."
@@ -23,19 +23,23 @@ func NewModel(CanNet net.Conn) Model {
 		FilePicker:                fp,
 		SelectedMessages:          make([]CANMessage, 0),
 		LastUpdate:                time.Now(),
-		CanNetwork:                CanNet,
-		Transmitter:               socketcan.NewTransmitter(CanNet),
+		Bus:                       canBus,
 		SendReceiveChoice:         0,
 		PreviousSendReceiveChoice: 0, // Initialize to same as current
-		SendSignals:               make([]SendSignal, 0),
+		SendMessages:              make([]*SendMessage, 0),
 		CurrentInputIndex:         -1,
 		ActiveMessages:            make(map[int]infoSending),
+		sendErrors:                make(chan SendErrorMsg, sendErrorsBuffer),
+		TimeoutFactor:             DefaultTimeoutFactor,
+		DefaultTimeout:            DefaultMessageTimeout,
+		StaleMessages:             make(map[uint32]time.Time),
+		PlotWindow:                defaultPlotWindow,
 	}
 }
 
 // NewModelWithDBC creates a new model with the specified DBC file
-func NewModelWithDBC(dbcPath string, CanNet net.Conn) Model {
-	m := NewModel(CanNet)
+func NewModelWithDBC(dbcPath string, canBus bus.Bus) Model {
+	m := NewModel(canBus)
 
 	if dbcPath != "" {
 		m.DBCPath = dbcPath
@@ -61,5 +65,6 @@ func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.FilePicker.Init(),
 		TickCmd(),
+		m.waitSendError(),
 	)
 }
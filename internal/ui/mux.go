@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// muxSignal is a signal of a message with the multiplexing it depends on
+type muxSignal struct {
+	acmelib.Signal
+	Muxor   string // name of the muxor selecting the signal, "" if always present
+	Layouts []int  // mux values (layout IDs) the signal is present in
+	Depth   int    // nesting level of the multiplexed layer, 0 if always present
+	// MuxCount is the number of layouts selected by a muxor signal (0 for the other signals)
+	MuxCount int
+}
+
+// messageSignals returns every signal of the message, the multiplexed ones included:
+// the signals of a multiplexed layer follow its muxor, in the order of their first layout
+func messageSignals(msg *acmelib.Message) []muxSignal {
+	return layoutSignals(msg.SignalLayout(), "", nil, 0)
+}
+
+// layoutSignals returns the signals of a layout and, recursively, of its multiplexed layers
+func layoutSignals(layout *acmelib.SignalLayout, muxor string, layouts []int, depth int) []muxSignal {
+	signals := []muxSignal{}
+	for _, signal := range layout.Signals() {
+		signals = appendMuxor(signals, muxSignal{Signal: signal, Muxor: muxor, Layouts: layouts, Depth: depth}, layout)
+	}
+	return signals
+}
+
+// layerSignals returns the signals of the layouts of a multiplexed layer,
+// a signal present in more layouts is returned once with all of them
+func layerSignals(layer *acmelib.MultiplexedLayer, depth int) []muxSignal {
+	muxor := layer.Muxor().Name()
+
+	signals := []muxSignal{}
+	index := make(map[string]int)
+	for layoutID, layout := range layer.Layouts() {
+		for _, signal := range layout.Signals() {
+			if i, ok := index[signal.Name()]; ok {
+				signals[i].Layouts = append(signals[i].Layouts, layoutID)
+				continue
+			}
+			index[signal.Name()] = len(signals)
+			signals = append(signals, muxSignal{Signal: signal, Muxor: muxor, Layouts: []int{layoutID}, Depth: depth})
+		}
+	}
+
+	// Nested multiplexed layers follow their muxor
+	result := make([]muxSignal, 0, len(signals))
+	for _, signal := range signals {
+		result = appendMuxor(result, signal, layer.Layouts()...)
+	}
+	return result
+}
+
+// appendMuxor appends the signal and, if it is the muxor of a multiplexed layer
+// of the layouts, the signals of the layer
+func appendMuxor(signals []muxSignal, signal muxSignal, layouts ...*acmelib.SignalLayout) []muxSignal {
+	layer := muxorLayer(signal, layouts...)
+	if layer == nil {
+		return append(signals, signal)
+	}
+
+	signal.MuxCount = layer.GetLayoutCount()
+	signals = append(signals, signal)
+	return append(signals, layerSignals(layer, signal.Depth+1)...)
+}
+
+// muxorLayer returns the multiplexed layer selected by a muxor signal among the ones of the layouts,
+// nil if the signal is not a muxor
+func muxorLayer(signal acmelib.Signal, layouts ...*acmelib.SignalLayout) *acmelib.MultiplexedLayer {
+	if signal.Kind() != acmelib.SignalKindMuxor {
+		return nil
+	}
+	for _, layout := range layouts {
+		for _, layer := range layout.MultiplexedLayers() {
+			if layer.Muxor().Name() == signal.Name() {
+				return layer
+			}
+		}
+	}
+	return nil
+}
+
+// inLayout reports whether a signal with the given layouts is present when the muxor has value mux
+func inLayout(layouts []int, mux int) bool {
+	return slices.Contains(layouts, mux)
+}
+
+// formatLayouts formats the mux values of a signal, consecutive values are shown as a range (e.g. "0-3,5")
+func formatLayouts(layouts []int) string {
+	parts := []string{}
+	for i := 0; i < len(layouts); {
+		j := i
+		for j+1 < len(layouts) && layouts[j+1] == layouts[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", layouts[i], layouts[j]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", layouts[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// muxLabel returns the label of a multiplexed signal: indented by its nesting level,
+// with a marker and the mux values of the muxor it is present in
+func muxLabel(label, muxor string, layouts []int, depth int, marker string) string {
+	if muxor == "" {
+		return label
+	}
+	return fmt.Sprintf("%s%s %s [%s=%s]", strings.Repeat("  ", depth-1), marker, label, muxor, formatLayouts(layouts))
+}
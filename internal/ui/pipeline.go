@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/history"
+	"github.com/squadracorsepolito/can-debug/internal/stats"
+	"github.com/squadracorsepolito/can-debug/internal/trace"
+)
+
+// monitorFlushInterval is how long the updates of the monitoring table pile up before being
+// posted to the UI, so that the table is rendered at most once per interval whatever the bus load
+const monitorFlushInterval = 50 * time.Millisecond
+
+// sendErrorsBuffer is the number of errors of the cyclical sending waiting for the UI
+const sendErrorsBuffer = 16
+
+// FramesMsg carries the updates of the monitoring table received since the previous one
+type FramesMsg struct {
+	pipeline *monitorPipeline
+	Batch    frameBatch
+}
+
+// SendErrorMsg reports a frame that the cyclical sending of a message could not send
+type SendErrorMsg struct {
+	Key  uint32 // key of the message (see CANMessage.Key)
+	Name string
+	Err  error
+}
+
+// SingleShotDoneMsg ends the single shot mark of a message sent once
+type SingleShotDoneMsg struct {
+	Message *SendMessage
+}
+
+// rowIndex maps the key of a message (see CANMessage.Key) and the name of a signal
+// to the row of the monitoring table showing it
+type rowIndex map[uint32]map[string]int
+
+// frameBatch holds the updates of the monitoring table coalesced between two flushes,
+// only the last value of each signal is kept
+type frameBatch struct {
+	Frames int            // frames of the monitored messages received
+	Values map[int]string // row of the monitoring table -> last value of its signal
+	// Present tells whether the signal of a row was in the last frame of its message
+	// (the multiplexed signals are only in the frames of their layout)
+	Present map[int]bool
+}
+
+func newFrameBatch() frameBatch {
+	return frameBatch{
+		Values:  make(map[int]string),
+		Present: make(map[int]bool),
+	}
+}
+
+// monitorPipeline decodes the frames received while monitoring in its own goroutine.
+// It never touches the model: the stores it records to are safe for concurrent use,
+// the updates of the table are posted to the UI with FramesMsg and applied by Update
+type monitorPipeline struct {
+	decoder *canDebug.Decoder
+	index   rowIndex // read only while the pipeline runs
+	trace   *trace.Buffer
+	stats   *stats.Tracker
+	history *history.Store
+
+	mu     sync.Mutex // protects batch
+	batch  frameBatch
+	notify chan struct{} // signals that the batch has updates
+	done   chan struct{} // closed when the receiving goroutine exits
+}
+
+func newMonitorPipeline(decoder *canDebug.Decoder, index rowIndex, trace *trace.Buffer, stats *stats.Tracker, history *history.Store) *monitorPipeline {
+	return &monitorPipeline{
+		decoder: decoder,
+		index:   index,
+		trace:   trace,
+		stats:   stats,
+		history: history,
+		batch:   newFrameBatch(),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// run receives the frames until the receiver is closed, it is intended as a goroutine
+func (p *monitorPipeline) run(recv bus.Receiver) {
+	defer close(p.done)
+	defer recv.Close()
+
+	for recv.Receive() {
+		p.handle(recv.Frame())
+	}
+}
+
+// handle records a frame and, if its message is monitored, adds its decoded signals to the batch
+func (p *monitorPipeline) handle(frame bus.Frame) {
+	key := canDebug.Key(frame.ID, frame.IsExtended)
+	p.trace.Add(frame)
+	p.stats.Observe(key, frame)
+
+	rows, ok := p.index[key]
+	if !ok {
+		return // not shown by the table, no need to decode it
+	}
+	decodedSignals := p.decoder.Decode(context.Background(), frame.ID, frame.IsExtended, frame.Payload())
+
+	t := frame.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	p.mu.Lock()
+	for _, row := range rows {
+		p.batch.Present[row] = false
+	}
+	for _, sgn := range decodedSignals {
+		row, ok := rows[sgn.Signal.Name()]
+		if !ok {
+			continue
+		}
+		p.batch.Values[row] = formatSignalValue(sgn)
+		p.batch.Present[row] = true
+	}
+	p.batch.Frames++
+	p.mu.Unlock()
+
+	for _, sgn := range decodedSignals {
+		if _, ok := rows[sgn.Signal.Name()]; ok {
+			p.history.Add(history.Key{Message: key, Signal: sgn.Signal.Name()}, t, signalNumber(sgn))
+		}
+	}
+
+	// Wake up the UI, unless it already has to flush
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the updates piled up and starts a new batch
+func (p *monitorPipeline) take() frameBatch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch := p.batch
+	p.batch = newFrameBatch()
+	return batch
+}
+
+// wait returns the command delivering the next updates of the table, nil once the pipeline stopped.
+// The updates arriving during monitorFlushInterval are delivered together
+func (p *monitorPipeline) wait() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-p.notify:
+		case <-p.done:
+			return nil
+		}
+		time.Sleep(monitorFlushInterval)
+		return FramesMsg{pipeline: p, Batch: p.take()}
+	}
+}
+
+// applyFrames updates the monitoring table with a batch of decoded values, the rows are set once.
+// The multiplexed signals keep their last value when their layout is not in the frame, their rows show whether it is the active one
+func (m *Model) applyFrames(batch frameBatch) {
+	rows := m.MonitoringTable.Rows()
+	if len(rows) != len(m.MonitorRows) {
+		return // the table was rebuilt
+	}
+
+	for i, value := range batch.Values {
+		rows[i][3] = value
+	}
+	for i, present := range batch.Present {
+		row := m.MonitorRows[i]
+		if row.muxor == "" {
+			continue
+		}
+		marker := "○"
+		if present {
+			marker = "●"
+		}
+		rows[i][2] = row.signalLabel(marker)
+	}
+
+	if len(batch.Values) > 0 || len(batch.Present) > 0 {
+		m.MonitoringTable.SetRows(rows)
+	}
+}
+
+// waitSendError returns the command delivering the next error of the cyclical sending
+func (m *Model) waitSendError() tea.Cmd {
+	errs := m.sendErrors
+	return func() tea.Msg {
+		return <-errs
+	}
+}
@@ -0,0 +1,213 @@
+package ui
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/history"
+	"github.com/squadracorsepolito/can-debug/internal/stats"
+	"github.com/squadracorsepolito/can-debug/internal/trace"
+)
+
+// testDBC is the DBC of the tests and the benchmarks
+const testDBC = "../test/MCB.dbc"
+
+// newTestMonitor returns a model monitoring every message of the test DBC
+func newTestMonitor(tb testing.TB, canBus bus.Bus) *Model {
+	tb.Helper()
+
+	m := NewModelWithDBC(testDBC, canBus)
+	if m.Err != nil {
+		tb.Fatal(m.Err)
+	}
+	m.Width, m.Height = 200, 60
+	for _, msg := range m.Messages {
+		m.SelectedMessages = append(m.SelectedMessages, newCANMessage(msg, msg.Name(), true))
+	}
+	m.setupMonitoringTable()
+	m.initializesTableDBCSignals()
+	m.State = StateMonitoring
+	return &m
+}
+
+// testFrames returns a frame of every message of the test DBC with a random payload
+func testFrames(m *Model, rnd *rand.Rand) []bus.Frame {
+	frames := make([]bus.Frame, len(m.Messages))
+	for i, msg := range m.Messages {
+		frame := bus.Frame{Length: uint8(msg.SizeByte()), IsFD: canDebug.IsFD(msg)}
+		frame.ID, frame.IsExtended = canDebug.MessageID(msg)
+		for j := range frame.Length {
+			frame.Data[j] = byte(rnd.IntN(256))
+		}
+		frames[i] = frame
+	}
+	return frames
+}
+
+// TestSendMonitorRoundTrip sends a frame on a virtual bus and checks that the monitoring table shows its decoded signals
+func TestSendMonitorRoundTrip(t *testing.T) {
+	canBus := bus.NewMem(t.Name())
+	defer canBus.Close()
+
+	m := newTestMonitor(t, canBus)
+	cmd := m.startMonitoring()
+	if cmd == nil {
+		t.Fatal(m.Err)
+	}
+	defer m.stopReceivingMessages()
+
+	frame := testFrames(m, rand.New(rand.NewPCG(1, 2)))[0]
+	if err := canBus.Send(context.Background(), frame); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ok := cmd().(FramesMsg)
+	if !ok {
+		t.Fatal("the pipeline stopped before delivering the frame")
+	}
+	if msg.Batch.Frames != 1 {
+		t.Fatalf("%d frames in the batch, want 1", msg.Batch.Frames)
+	}
+	m.Update(msg)
+
+	key := canDebug.Key(frame.ID, frame.IsExtended)
+	want := make(map[string]string)
+	for _, sgn := range m.Decoder.Decode(context.Background(), frame.ID, frame.IsExtended, frame.Payload()) {
+		want[sgn.Signal.Name()] = formatSignalValue(sgn)
+	}
+	rows := m.MonitoringTable.Rows()
+	checked := 0
+	for i, row := range m.MonitorRows {
+		value, ok := want[row.signal]
+		if row.key != key || !ok {
+			continue
+		}
+		if rows[i][3] != value {
+			t.Errorf("signal %s: row shows %q, want %q", row.signal, rows[i][3], value)
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Fatal("no row shows the signals of the frame sent")
+	}
+	if m.Trace.Len() != 1 {
+		t.Errorf("%d frames in the trace, want 1", m.Trace.Len())
+	}
+}
+
+// TestMonitoringUnderLoad floods the bus while the UI handles the updates, keys and renders,
+// run it with -race: only Update must touch the model
+func TestMonitoringUnderLoad(t *testing.T) {
+	canBus := bus.NewMem(t.Name())
+	defer canBus.Close()
+
+	m := newTestMonitor(t, canBus)
+	cmd := m.startMonitoring()
+	if cmd == nil {
+		t.Fatal(m.Err)
+	}
+	frames := testFrames(m, rand.New(rand.NewPCG(1, 2)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	// Stop the pipeline at the end of the load, as leaving the monitoring view would
+	recv := m.MonitorReceiver
+	go func() {
+		<-ctx.Done()
+		recv.Close()
+	}()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				for _, frame := range frames {
+					canBus.Send(ctx, frame)
+				}
+			}
+		}()
+	}
+
+	keys := []tea.KeyMsg{
+		{Type: tea.KeyDown},
+		{Type: tea.KeyRunes, Runes: []rune("p")},
+		{Type: tea.KeyRunes, Runes: []rune("o")},
+		{Type: tea.KeyRunes, Runes: []rune("v")},
+	}
+	received := 0
+	for i := 0; ; i++ {
+		msg := cmd()
+		if msg == nil {
+			break // the pipeline stopped
+		}
+		received += msg.(FramesMsg).Batch.Frames
+		_, cmd = m.Update(msg)
+
+		m.Update(TickMsg(time.Now()))
+		m.Update(keys[i%len(keys)])
+		m.View()
+	}
+	wg.Wait()
+
+	if received == 0 {
+		t.Fatal("no frames received")
+	}
+	updated := 0
+	for _, row := range m.MonitoringTable.Rows() {
+		if row[3] != "[In attesa dati]" {
+			updated++
+		}
+	}
+	if updated == 0 {
+		t.Fatal("no row of the monitoring table updated")
+	}
+	t.Logf("%d frames received, %d of %d rows updated", received, updated, len(m.MonitorRows))
+}
+
+// BenchmarkMonitorPipeline measures the decoding of a frame up to the batch of the table updates
+func BenchmarkMonitorPipeline(b *testing.B) {
+	m := newTestMonitor(b, nil)
+	p := newMonitorPipeline(m.Decoder, m.monitorIndex, trace.NewBuffer(traceCapacity), stats.NewTracker(), history.NewStore(historyCapacity))
+	frames := testFrames(m, rand.New(rand.NewPCG(1, 2)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.handle(frames[i%len(frames)])
+		if i%len(frames) == len(frames)-1 {
+			p.take()
+		}
+	}
+}
+
+// BenchmarkTableUpdate measures the update of the monitoring table with a batch holding
+// a frame of every message of the DBC, as coalesced between two renders under load
+func BenchmarkTableUpdate(b *testing.B) {
+	m := newTestMonitor(b, nil)
+	p := newMonitorPipeline(m.Decoder, m.monitorIndex, trace.NewBuffer(traceCapacity), stats.NewTracker(), history.NewStore(historyCapacity))
+	frames := testFrames(m, rand.New(rand.NewPCG(1, 2)))
+
+	batches := make([]frameBatch, 16)
+	for i := range batches {
+		for _, frame := range frames {
+			p.handle(frame)
+		}
+		batches[i] = p.take()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.applyFrames(batches[i%len(batches)])
+	}
+}
@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/squadracorsepolito/acmelib"
+
+	"github.com/squadracorsepolito/can-debug/internal/history"
+	"github.com/squadracorsepolito/can-debug/internal/plot"
+)
+
+// historyCapacity is the number of samples kept for each signal of the monitoring table
+const historyCapacity = 10000
+
+// plotWindows are the time spans the plot can show, defaultPlotWindow the index of the initial one
+var plotWindows = []time.Duration{
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+}
+
+const defaultPlotWindow = 2
+
+// plotColors are the colors of the signals overlaid in the plot
+var plotColors = []lipgloss.Color{"#00D7FF", "#FFAF00", "#5FFF5F", "#FF5FD7", "#AF87FF", "#FF5F5F"}
+
+// plotLabelWidth is the width of the labels of the y axis
+const plotLabelWidth = 10
+
+// signalNumber returns the value of a decoded signal to plot: the physical value,
+// 0/1 for flags and the raw value for enum signals
+func signalNumber(sgn *acmelib.SignalDecoding) float64 {
+	switch v := sgn.Value.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return float64(sgn.RawValue)
+}
+
+// historyKey returns the key of the time series of the signal shown by the row
+func (r monitorRow) historyKey() history.Key {
+	return history.Key{Message: r.key, Signal: r.signal}
+}
+
+// togglePlot shows or hides the plot pane of the decoded view
+func (m *Model) togglePlot() {
+	m.PlotVisible = !m.PlotVisible
+}
+
+// togglePlotPin adds the signal under the cursor to the signals overlaid in the plot, or removes it
+func (m *Model) togglePlotPin() {
+	row, ok := m.cursorMonitorRow()
+	if !ok {
+		return
+	}
+
+	key := row.historyKey()
+	if i := slices.Index(m.PlotPinned, key); i >= 0 {
+		m.PlotPinned = slices.Delete(m.PlotPinned, i, i+1)
+		return
+	}
+	if len(m.PlotPinned) < len(plotColors)-1 {
+		m.PlotPinned = append(m.PlotPinned, key)
+	}
+	m.PlotVisible = true
+}
+
+// adjustPlotWindow selects a longer (positive delta) or shorter time span of the plot
+func (m *Model) adjustPlotWindow(delta int) {
+	m.PlotWindow = max(0, min(m.PlotWindow+delta, len(plotWindows)-1))
+}
+
+// cursorMonitorRow returns the signal under the cursor of the monitoring table, false for rows without signals
+func (m Model) cursorMonitorRow() (monitorRow, bool) {
+	cursor := m.MonitoringTable.Cursor()
+	if cursor < 0 || cursor >= len(m.MonitorRows) || m.MonitorRows[cursor].signal == "" {
+		return monitorRow{}, false
+	}
+	return m.MonitorRows[cursor], true
+}
+
+// plotRows returns the signals shown by the plot: the pinned ones and the one under the cursor
+func (m Model) plotRows() []monitorRow {
+	rows := []monitorRow{}
+	for _, key := range m.PlotPinned {
+		for _, row := range m.MonitorRows {
+			if row.historyKey() == key {
+				rows = append(rows, row)
+				break
+			}
+		}
+	}
+
+	if row, ok := m.cursorMonitorRow(); ok && !slices.Contains(m.PlotPinned, row.historyKey()) {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// plotHeight returns the height of the chart, the plot pane takes the room
+// of the reception statistics and the event log
+func (m Model) plotHeight(signals int) int {
+	return max(4, len(m.SelectedMessages)+8-signals)
+}
+
+// plotView renders the chart of the signals over the last seconds, with their minimum, maximum and average
+func (m Model) plotView() string {
+	var s strings.Builder
+
+	window := plotWindows[m.PlotWindow]
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("📈 Last %s", formatWindow(window))))
+	s.WriteString(" (+/- change time span • o pin/unpin the signal to overlay it • p hide)\n")
+
+	rows := m.plotRows()
+	if len(rows) == 0 {
+		s.WriteString("Move the cursor on a signal to plot it")
+		return s.String()
+	}
+
+	now := m.LastUpdate
+	samples := make([][]history.Sample, len(rows))
+	summaries := make([]history.Summary, len(rows))
+	total := history.Summary{}
+	for i, row := range rows {
+		if m.History != nil {
+			samples[i] = m.History.Since(row.historyKey(), now.Add(-window))
+		}
+		summaries[i] = history.Summarize(samples[i])
+		if summaries[i].Count == 0 {
+			continue
+		}
+		if total.Count == 0 || summaries[i].Min < total.Min {
+			total.Min = summaries[i].Min
+		}
+		if total.Count == 0 || summaries[i].Max > total.Max {
+			total.Max = summaries[i].Max
+		}
+		total.Count += summaries[i].Count
+	}
+
+	// Constant signals are drawn in the middle of the chart
+	if total.Min == total.Max {
+		total.Min--
+		total.Max++
+	}
+
+	chart := plot.Chart{
+		Width:  max(20, m.Width-plotLabelWidth-3),
+		Height: m.plotHeight(len(rows)),
+		XMin:   -window.Seconds(),
+		XMax:   0,
+		YMin:   total.Min,
+		YMax:   total.Max,
+	}
+
+	series := make([]plot.Series, len(rows))
+	for i := range rows {
+		points := make([]plot.Point, len(samples[i]))
+		for j, sample := range samples[i] {
+			points[j] = plot.Point{X: sample.Time.Sub(now).Seconds(), Y: sample.Value}
+		}
+		series[i] = plot.Series{Points: points, Style: lipgloss.NewStyle().Foreground(plotColors[i%len(plotColors)])}
+	}
+
+	// Chart with the maximum and minimum on the y axis, the time on the x axis
+	for i, line := range chart.Render(series...) {
+		label := ""
+		switch i {
+		case 0:
+			label = formatPlotValue(total.Max)
+		case chart.Height - 1:
+			label = formatPlotValue(total.Min)
+		}
+		s.WriteString(fmt.Sprintf("%*s ┤%s\n", plotLabelWidth, label, line))
+	}
+	s.WriteString(fmt.Sprintf("%*s └%s\n", plotLabelWidth, "", strings.Repeat("─", chart.Width)))
+	axis := "-" + formatWindow(window)
+	s.WriteString(fmt.Sprintf("%*s  %s%*s\n", plotLabelWidth, "", axis, chart.Width-len(axis), "now"))
+
+	// Legend with the statistics of each signal over the time span
+	for i, row := range rows {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		legend := series[i].Style.Render("━━")
+		name := fmt.Sprintf("%s %s", m.messageName(row.key), row.label)
+		sum := summaries[i]
+		if sum.Count == 0 {
+			s.WriteString(fmt.Sprintf("%s %s: no samples", legend, name))
+			continue
+		}
+		s.WriteString(fmt.Sprintf("%s %s: last %s • min %s • max %s • avg %s (%d samples)",
+			legend, name, formatPlotValue(sum.Last), formatPlotValue(sum.Min), formatPlotValue(sum.Max), formatPlotValue(sum.Avg), sum.Count))
+	}
+
+	return s.String()
+}
+
+// messageName returns the name of the selected message with the given key
+func (m Model) messageName(key uint32) string {
+	for _, msg := range m.SelectedMessages {
+		if msg.Key() == key {
+			return msg.Message.Name()
+		}
+	}
+	return ""
+}
+
+// formatWindow formats the time span of the plot (e.g. "30s", "2m")
+func formatWindow(d time.Duration) string {
+	if d >= time.Minute && d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// formatPlotValue formats a value of the plot with 4 significant digits
+func formatPlotValue(v float64) string {
+	return fmt.Sprintf("%.4g", v)
+}
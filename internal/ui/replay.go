@@ -0,0 +1,268 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+	"github.com/squadracorsepolito/can-debug/internal/replay"
+)
+
+// replaySeekStep is how much ←/→ move the replay position
+const replaySeekStep = 5 * time.Second
+
+// replay speed limits for the +/- keys
+const (
+	replayMinSpeed = 0.125
+	replayMaxSpeed = 64
+)
+
+// setupReplayFilePicker configures the file picker for the candump log files,
+// it returns the command that reads the current directory
+func (m *Model) setupReplayFilePicker() tea.Cmd {
+	fp := filepicker.New()
+	fp.AllowedTypes = []string{".log"}
+	fp.CurrentDirectory = "."
+	fp.ShowHidden = false
+	fp.DirAllowed = true
+	fp.FileAllowed = true
+	if m.Height > 4 {
+		fp.Height = m.Height - 4
+	}
+
+	m.ReplayPicker = fp
+	return m.ReplayPicker.Init()
+}
+
+// startReplay loads the log file and starts replaying it on the bus
+func (m *Model) startReplay(path string) {
+	if m.Bus == nil {
+		m.Err = fmt.Errorf("no CAN bus connection available - replay disabled")
+		return
+	}
+
+	entries, err := candump.ReadLogFile(path)
+	if err != nil {
+		m.Err = err
+		return
+	}
+	if len(entries) == 0 {
+		m.Err = fmt.Errorf("the log file %s contains no frames", path)
+		return
+	}
+
+	m.Err = nil
+	m.ReplayPath = path
+	m.Player = replay.NewPlayer(m.Bus, entries)
+	m.Player.Start()
+	m.ReplayFilterEdit = 0
+	m.State = StateReplay
+}
+
+// stopReplay stops the running replay (if any)
+func (m *Model) stopReplay() {
+	if m.Player == nil {
+		return
+	}
+	m.Player.Stop()
+	m.Player = nil
+}
+
+// editReplayFilter opens the input for the include (1) or exclude (2) ID filter
+func (m *Model) editReplayFilter(target int) {
+	filter := m.Player.Status().Filter
+
+	ti := textinput.New()
+	ti.Placeholder = "hex IDs, e.g. 100,1A0"
+	ti.CharLimit = 200
+	ti.Width = 50
+	if target == 1 {
+		ti.SetValue(filter.Include.String())
+	} else {
+		ti.SetValue(filter.Exclude.String())
+	}
+	ti.Focus()
+
+	m.ReplayFilterInput = ti
+	m.ReplayFilterEdit = target
+}
+
+// applyReplayFilter applies the ID filter being edited to the player
+func (m *Model) applyReplayFilter() {
+	ids, err := replay.ParseIDSet(m.ReplayFilterInput.Value())
+	if err != nil {
+		m.Err = err
+		return
+	}
+
+	filter := m.Player.Status().Filter
+	if m.ReplayFilterEdit == 1 {
+		filter.Include = ids
+	} else {
+		filter.Exclude = ids
+	}
+	m.Player.SetFilter(filter)
+
+	m.Err = nil
+	m.ReplayFilterEdit = 0
+}
+
+// updateReplay handles the keys of the replay screen
+func (m *Model) updateReplay(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || m.Player == nil {
+		return nil
+	}
+
+	// Editing a filter: the keys go to the input
+	if m.ReplayFilterEdit != 0 {
+		switch keyMsg.String() {
+		case "enter":
+			m.applyReplayFilter()
+		case "esc":
+			m.ReplayFilterEdit = 0
+		default:
+			var cmd tea.Cmd
+			m.ReplayFilterInput, cmd = m.ReplayFilterInput.Update(msg)
+			return cmd
+		}
+		return nil
+	}
+
+	status := m.Player.Status()
+	switch keyMsg.String() {
+	case " ":
+		m.Player.TogglePause()
+	case "right", "l":
+		m.Player.Seek(replaySeekStep)
+	case "left", "h":
+		m.Player.Seek(-replaySeekStep)
+	case "home", "0":
+		m.Player.SeekTo(0)
+	case "+", "=":
+		m.Player.SetSpeed(min(status.Speed*2, replayMaxSpeed))
+	case "-":
+		m.Player.SetSpeed(max(status.Speed/2, replayMinSpeed))
+	case "o":
+		m.Player.SetLoop(!status.Loop)
+	case "i":
+		m.editReplayFilter(1)
+	case "e":
+		m.editReplayFilter(2)
+	}
+
+	return nil
+}
+
+// replayFilePickerView renders the log file selection of the replay mode
+func (m Model) replayFilePickerView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("🔁 Replay - Select a candump log file"))
+	s.WriteString("\n\n")
+
+	s.WriteString("↑/k up • ↓/j down • Enter: open directory/select .log file • Tab back to mode selection • q quit")
+	s.WriteString("\n\n")
+
+	if m.Err != nil {
+		s.WriteString(fmt.Sprintf("Error: %v", m.Err))
+		s.WriteString("\n\n")
+	}
+
+	s.WriteString(m.ReplayPicker.View())
+
+	return s.String()
+}
+
+// replayProgressBar renders a progress bar of the given width
+func replayProgressBar(position, duration time.Duration, width int) string {
+	fraction := 1.0
+	if duration > 0 {
+		fraction = float64(position) / float64(duration)
+	}
+	return progressBar(fraction, width)
+}
+
+// replayView renders the replay screen
+func (m Model) replayView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("🔁 Replay"))
+	s.WriteString(fmt.Sprintf(" (Log: %s)", m.ReplayPath))
+	s.WriteString("\n\n")
+
+	s.WriteString("Controls: Space pause/resume • ←/→ seek ∓5s • 0 restart • +/- speed • o loop\n")
+	s.WriteString("Filters: i edit include IDs • e edit exclude IDs • Tab back to log selection • q quit")
+	s.WriteString("\n\n")
+
+	if m.Player == nil {
+		return s.String()
+	}
+	status := m.Player.Status()
+
+	state := "▶️  playing"
+	if status.Finished {
+		state = "⏹️  finished"
+	} else if status.Paused {
+		state = "⏸️  paused"
+	}
+	loop := "off"
+	if status.Loop {
+		loop = fmt.Sprintf("on (%d completed)", status.Loops)
+	}
+
+	s.WriteString(fmt.Sprintf("State:    %s\n", state))
+	s.WriteString(fmt.Sprintf("Position: %s %s / %s\n",
+		replayProgressBar(status.Position, status.Duration, 40),
+		status.Position.Truncate(time.Millisecond), status.Duration.Truncate(time.Millisecond)))
+	s.WriteString(fmt.Sprintf("Frames:   %d / %d in log • %d sent\n", status.Index, status.Count, status.Sent))
+	s.WriteString(fmt.Sprintf("Speed:    x%g • Loop: %s\n", status.Speed, loop))
+
+	include, exclude := status.Filter.Include.String(), status.Filter.Exclude.String()
+	if include == "" {
+		include = "all"
+	}
+	if exclude == "" {
+		exclude = "none"
+	}
+	s.WriteString(fmt.Sprintf("Include:  %s\n", include))
+	s.WriteString(fmt.Sprintf("Exclude:  %s\n", exclude))
+
+	if status.Last != nil {
+		last := candump.FormatFrame(*status.Last, "")
+		// The frames are sent with no timestamp, keep only "ID#DATA"
+		last = last[strings.LastIndex(last, " ")+1:]
+		if m.Decoder != nil {
+			if msg, ok := m.Decoder.Lookup(status.Last.ID, status.Last.IsExtended); ok {
+				last += " (" + msg.Name() + ")"
+			}
+		}
+		s.WriteString(fmt.Sprintf("Last:     %s\n", last))
+	}
+
+	if m.ReplayFilterEdit != 0 {
+		label := "Include IDs"
+		if m.ReplayFilterEdit == 2 {
+			label = "Exclude IDs"
+		}
+		s.WriteString("\n")
+		s.WriteString(fmt.Sprintf("%s: %s\n", label, m.ReplayFilterInput.View()))
+		s.WriteString("Enter apply • Esc cancel")
+	}
+
+	if status.Err != nil {
+		wrappedStatus := m.wrapStatus(fmt.Sprintf("⚠️ CAN bus error: %v", status.Err), m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	} else if m.Err != nil {
+		wrappedStatus := m.wrapStatus(fmt.Sprintf("⚠️ %v", m.Err), m.Width)
+		s.WriteString(fmt.Sprintf("\n💬 Status: %s", wrappedStatus))
+	}
+
+	return s.String()
+}
@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// nodeItem is an entry of the node list of the simulation mode
+type nodeItem struct {
+	node *acmelib.NodeInterface
+}
+
+func (n nodeItem) Title() string { return n.node.Node().Name() }
+
+func (n nodeItem) Description() string {
+	messages := n.node.SentMessages()
+	cyclic := 0
+	for _, msg := range messages {
+		if msg.CycleTime() > 0 {
+			cyclic++
+		}
+	}
+	return fmt.Sprintf("%d messages sent, %d with a cycle time", len(messages), cyclic)
+}
+
+func (n nodeItem) FilterValue() string { return n.node.Node().Name() }
+
+// setupNodeList configures the list of the nodes that can be simulated
+func (m *Model) setupNodeList() {
+	items := make([]list.Item, 0, len(m.Nodes))
+	for _, node := range m.Nodes {
+		items = append(items, nodeItem{node: node})
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = true
+
+	// Set the width and height of the node list
+	width := m.Width
+	height := m.Height - 6
+	if width == 0 {
+		width = 80 // default width
+	}
+	if height <= 0 {
+		height = 15
+	}
+
+	m.NodeList = list.New(items, delegate, width, height)
+	m.NodeList.SetShowTitle(false)
+	m.NodeList.SetShowHelp(false)
+	m.NodeList.SetShowStatusBar(false)
+	m.NodeList.SetFilteringEnabled(true)
+}
+
+// startNodeSimulation opens the send configuration with all the messages sent by the node,
+// and starts sending cyclically the ones with a cycle time declared in the DBC (GenMsgCycleTime)
+func (m *Model) startNodeSimulation(node *acmelib.NodeInterface) {
+	m.SimulatedNode = node.Node().Name()
+
+	m.SelectedMessages = make([]CANMessage, 0)
+	for _, msg := range node.SentMessages() {
+		m.SelectedMessages = append(m.SelectedMessages, newCANMessage(msg, msg.Name(), true))
+	}
+
+	m.State = StateSendConfiguration
+	m.setupSendConfiguration()
+
+	started := 0
+	for _, msg := range m.SendMessages {
+		if msg.SpecCycleTime() <= 0 {
+			continue // event messages are sent with Enter
+		}
+		if _, ok := m.ActiveMessages[int(msg.Key())]; ok {
+			continue // already simulated
+		}
+
+		// setupSendConfiguration already set the cycle time declared in the DBC
+		m.startCyclicalSending(msg)
+		if _, ok := m.ActiveMessages[int(msg.Key())]; !ok {
+			return // Error message already set in startCyclicalSending
+		}
+		started++
+	}
+
+	m.updateSendTableRows()
+	m.SendStatus = fmt.Sprintf("🤖 Simulating node '%s': %d of %d messages sent cyclically at their DBC cycle time", m.SimulatedNode, started, len(m.SendMessages))
+}
+
+// updateNodeSelector handles the messages while choosing the node to simulate
+func (m *Model) updateNodeSelector(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "enter" && m.NodeList.FilterState() != list.Filtering {
+		if item, ok := m.NodeList.SelectedItem().(nodeItem); ok {
+			m.startNodeSimulation(item.node)
+		}
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.NodeList, cmd = m.NodeList.Update(msg)
+	return cmd
+}
+
+// nodeSelectorView renders the list of the nodes that can be simulated
+func (m Model) nodeSelectorView() string {
+	var s strings.Builder
+
+	s.WriteString(lipgloss.NewStyle().Bold(true).Render("🤖 Select the node to simulate"))
+	s.WriteString(fmt.Sprintf(" (File: %s)", m.DBCPath))
+	s.WriteString("\n\n")
+
+	s.WriteString("Navigation: ↑/k up • ↓/j down • / filter • Tab back to mode selection • q quit\n")
+	s.WriteString("Actions: Enter send all the messages of the node at their DBC cycle time\n")
+	if len(m.ActiveMessages) > 0 {
+		s.WriteString(fmt.Sprintf("🔄 %d messages are being sent (s in the send table stops all)\n", len(m.ActiveMessages)))
+	}
+	s.WriteString("\n")
+
+	if len(m.Nodes) == 0 {
+		s.WriteString("No node of the DBC sends messages")
+		return s.String()
+	}
+
+	s.WriteString(m.NodeList.View())
+
+	return s.String()
+}
@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/stats"
+)
+
+// statsPeriodTolerance is the relative deviation of the average period
+// from the DBC cycle time still considered on spec
+const statsPeriodTolerance = 0.1
+
+// setupStatsTable configures the table with the reception statistics of the selected messages
+func (m *Model) setupStatsTable() {
+	columns := []table.Column{
+		{Title: "Message", Width: 25},
+		{Title: "ID", Width: 12},
+		{Title: "Count", Width: 8},
+		{Title: "Last(ms)", Width: 9},
+		{Title: "Avg(ms)", Width: 9},
+		{Title: "Min(ms)", Width: 9},
+		{Title: "Max(ms)", Width: 9},
+		{Title: "Jitter(ms)", Width: 10},
+		{Title: "Age", Width: 9},
+		{Title: "Spec(ms)", Width: 9},
+		{Title: "Check", Width: 14},
+	}
+
+	// The statistics are not navigable, no row is highlighted
+	styles := table.DefaultStyles()
+	styles.Selected = lipgloss.NewStyle()
+
+	m.StatsTable = table.New(
+		table.WithColumns(columns),
+		table.WithHeight(len(m.SelectedMessages)+1),
+		table.WithStyles(styles),
+	)
+	m.updateStatsTable(time.Now())
+}
+
+// updateStatsTable refreshes the reception statistics, called at every tick while monitoring
+func (m *Model) updateStatsTable(now time.Time) {
+	rows := make([]table.Row, 0, len(m.SelectedMessages))
+	for _, msg := range m.SelectedMessages {
+		spec := msg.Message.CycleTime()
+		specStr := "-"
+		if spec > 0 {
+			specStr = fmt.Sprintf("%d", spec)
+		}
+
+		var s stats.Message
+		ok := false
+		if m.Stats != nil {
+			s, ok = m.Stats.Get(msg.Key())
+		}
+		if !ok {
+			rows = append(rows, table.Row{
+				msg.Message.Name(),
+				canDebug.FormatID(msg.ID, msg.Extended),
+				"0", "-", "-", "-", "-", "-", "-",
+				specStr,
+				"⏳ waiting",
+			})
+			continue
+		}
+
+		periods := []string{"-", "-", "-", "-", "-"}
+		if s.Periods() > 0 {
+			periods = []string{
+				formatMs(s.LastPeriod),
+				formatMs(s.AvgPeriod),
+				formatMs(s.MinPeriod),
+				formatMs(s.MaxPeriod),
+				formatMs(s.Jitter),
+			}
+		}
+
+		row := table.Row{
+			msg.Message.Name(),
+			canDebug.FormatID(msg.ID, msg.Extended),
+			fmt.Sprintf("%d", s.Count),
+		}
+		row = append(row, periods...)
+		check := periodCheck(s, spec)
+		if _, stale := m.StaleMessages[msg.Key()]; stale {
+			check = "⛔ timeout"
+		}
+		row = append(row,
+			formatAge(s.Age(now)),
+			specStr,
+			check,
+		)
+		rows = append(rows, row)
+	}
+
+	m.StatsTable.SetRows(rows)
+}
+
+// periodCheck compares the average period with the DBC cycle time (in ms)
+func periodCheck(s stats.Message, spec int) string {
+	if spec <= 0 {
+		return "-"
+	}
+	if s.Periods() == 0 {
+		return "⏳ measuring"
+	}
+
+	specPeriod := time.Duration(spec) * time.Millisecond
+	deviation := float64(s.AvgPeriod-specPeriod) / float64(specPeriod)
+	if math.Abs(deviation) <= statsPeriodTolerance {
+		return "✅ on spec"
+	}
+	return fmt.Sprintf("⚠️ %+.0f%%", deviation*100)
+}
+
+// formatMs formats a duration in milliseconds
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
+
+// formatAge formats the time since the last frame
+func formatAge(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
@@ -0,0 +1,246 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/squadracorsepolito/can-debug/internal/bus"
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/replay"
+	"github.com/squadracorsepolito/can-debug/internal/trace"
+)
+
+// traceCapacity is the number of frames kept for the scroll-back of the trace view
+const traceCapacity = 10000
+
+// MonitorView is the view shown while monitoring
+type MonitorView int
+
+const (
+	MonitorViewDecoded MonitorView = iota // decoded signals of the selected messages
+	MonitorViewTrace                      // raw trace of every frame on the bus
+	MonitorViewUnknown                    // IDs seen on the bus but not declared in the DBC
+)
+
+// toggleMonitorView switches to the next view of the monitoring screen
+func (m *Model) toggleMonitorView() {
+	switch m.MonitorView {
+	case MonitorViewDecoded:
+		m.MonitorView = MonitorViewTrace
+	case MonitorViewTrace:
+		m.MonitorView = MonitorViewUnknown
+	default:
+		m.MonitorView = MonitorViewDecoded
+	}
+}
+
+// pauseTrace freezes the trace view on the frames received so far, the buffer keeps recording
+func (m *Model) pauseTrace() {
+	if m.TracePaused || m.Trace == nil {
+		return
+	}
+	m.TracePaused = true
+	m.TraceSnapshot = m.Trace.Clone()
+}
+
+// resumeTrace goes back to the live trace
+func (m *Model) resumeTrace() {
+	m.TracePaused = false
+	m.TraceSnapshot = nil
+	m.TraceScroll = 0
+}
+
+// scrollTrace moves the trace view back (positive delta) or forward in time,
+// scrolling back pauses the trace so that the lines stay still
+func (m *Model) scrollTrace(delta int) {
+	if delta > 0 {
+		m.pauseTrace()
+	}
+	count := 0
+	if buffer := m.traceBuffer(); buffer != nil {
+		count = buffer.Count(m.traceKeep)
+	}
+	m.TraceScroll = max(0, min(m.TraceScroll+delta, count-1))
+}
+
+// traceBuffer returns the frames shown by the trace view: the snapshot while paused, the live buffer otherwise
+func (m *Model) traceBuffer() *trace.Buffer {
+	if m.TracePaused {
+		return m.TraceSnapshot
+	}
+	return m.Trace
+}
+
+// traceKeep tells whether a frame passes the ID filter of the trace view
+func (m *Model) traceKeep(frame bus.Frame) bool {
+	return m.TraceFilter.Allows(frame.ID, frame.IsExtended)
+}
+
+// editTraceFilter opens the input for the ID filter of the trace
+func (m *Model) editTraceFilter() {
+	ti := textinput.New()
+	ti.Placeholder = "hex IDs, e.g. 100,1A0 (empty shows all)"
+	ti.CharLimit = 200
+	ti.Width = 50
+	ti.SetValue(m.TraceFilter.Include.String())
+	ti.Focus()
+
+	m.TraceFilterInput = ti
+	m.TraceFilterEdit = true
+}
+
+// applyTraceFilter applies the ID filter being edited
+func (m *Model) applyTraceFilter() {
+	ids, err := replay.ParseIDSet(m.TraceFilterInput.Value())
+	if err != nil {
+		// Keep editing, the error is shown under the input
+		m.TraceFilterInput.Err = err
+		return
+	}
+
+	m.TraceFilter.Include = ids
+	m.TraceScroll = 0
+	m.TraceFilterEdit = false
+}
+
+// updateTrace handles the keys of the trace view
+func (m *Model) updateTrace(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	// Editing the filter: the keys go to the input
+	if m.TraceFilterEdit {
+		switch keyMsg.String() {
+		case "enter":
+			m.applyTraceFilter()
+		case "esc":
+			m.TraceFilterEdit = false
+		default:
+			var cmd tea.Cmd
+			m.TraceFilterInput.Err = nil
+			m.TraceFilterInput, cmd = m.TraceFilterInput.Update(msg)
+			return cmd
+		}
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case " ":
+		if m.TracePaused {
+			m.resumeTrace()
+		} else {
+			m.pauseTrace()
+		}
+	case "up", "k":
+		m.scrollTrace(1)
+	case "down", "j":
+		m.scrollTrace(-1)
+	case "pgup":
+		m.scrollTrace(m.traceHeight())
+	case "pgdown":
+		m.scrollTrace(-m.traceHeight())
+	case "home", "g":
+		m.scrollTrace(traceCapacity)
+	case "end", "G":
+		m.resumeTrace()
+	case "f":
+		m.editTraceFilter()
+	}
+
+	return nil
+}
+
+// traceHeight returns the number of frames shown by the trace view
+func (m *Model) traceHeight() int {
+	return max(5, m.Height-12)
+}
+
+// formatTraceLine formats a frame candump-style: time, ID, DLC, data bytes and DBC message name
+func (m Model) formatTraceLine(frame bus.Frame) string {
+	var s strings.Builder
+
+	s.WriteString(frame.Timestamp.Format("15:04:05.000000"))
+	s.WriteString(fmt.Sprintf("  %-10s", canDebug.FormatID(frame.ID, frame.IsExtended)))
+
+	flags := ""
+	switch {
+	case frame.IsFD && frame.BRS:
+		flags = "FD BRS"
+	case frame.IsFD:
+		flags = "FD"
+	case frame.IsRemote:
+		flags = "RTR"
+	}
+	s.WriteString(fmt.Sprintf("  %-6s [%2d] ", flags, frame.DLC()))
+
+	data := make([]string, 0, frame.Length)
+	for _, b := range frame.Payload() {
+		data = append(data, fmt.Sprintf("%02X", b))
+	}
+	s.WriteString(fmt.Sprintf(" %-23s", strings.Join(data, " ")))
+
+	if m.Decoder != nil {
+		if msg, ok := m.Decoder.Lookup(frame.ID, frame.IsExtended); ok {
+			s.WriteString("  " + msg.Name())
+		}
+	}
+
+	return s.String()
+}
+
+// traceView renders the raw trace of the frames on the bus
+func (m Model) traceView() string {
+	var s strings.Builder
+
+	// Only the visible frames are taken from the buffer
+	var frames []bus.Frame
+	shown := 0
+	if buffer := m.traceBuffer(); buffer != nil {
+		frames = buffer.Window(m.TraceScroll, m.traceHeight(), m.traceKeep)
+		shown = buffer.Count(m.traceKeep)
+	}
+
+	state := "🟢 live"
+	if m.TracePaused {
+		state = "⏸️  paused"
+	}
+	filter := m.TraceFilter.Include.String()
+	if filter == "" {
+		filter = "all"
+	}
+	total := uint64(0)
+	if m.Trace != nil {
+		total = m.Trace.Total()
+	}
+	s.WriteString(fmt.Sprintf("%s • IDs: %s • %d frames shown of %d received (last %d kept)", state, filter, shown, total, traceCapacity))
+	if m.TraceScroll > 0 {
+		s.WriteString(fmt.Sprintf(" • scrolled back %d frames", m.TraceScroll))
+	}
+	s.WriteString("\n\n")
+
+	header := lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("%-15s  %-10s  %-6s %-4s  %-23s  %s", "Time", "ID", "Flags", "DLC", "Data", "Message"))
+	s.WriteString(header)
+	s.WriteString("\n")
+
+	for _, frame := range frames {
+		s.WriteString(m.formatTraceLine(frame))
+		s.WriteString("\n")
+	}
+
+	if m.TraceFilterEdit {
+		s.WriteString("\n")
+		s.WriteString(fmt.Sprintf("Show IDs: %s\n", m.TraceFilterInput.View()))
+		if m.TraceFilterInput.Err != nil {
+			s.WriteString(fmt.Sprintf("⚠️  %v\n", m.TraceFilterInput.Err))
+		}
+		s.WriteString("Enter apply • Esc cancel")
+	}
+
+	return s.String()
+}
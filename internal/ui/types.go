@@ -3,7 +3,8 @@ package ui
 import (
 	"context"
 	"fmt"
-	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/filepicker"
@@ -12,9 +13,18 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/squadracorsepolito/acmelib"
-	"go.einride.tech/can/pkg/socketcan"
 
+	"github.com/squadracorsepolito/can-debug/internal/bus"
 	"github.com/squadracorsepolito/can-debug/internal/can"
+	"github.com/squadracorsepolito/can-debug/internal/candump"
+	"github.com/squadracorsepolito/can-debug/internal/canopen"
+	"github.com/squadracorsepolito/can-debug/internal/flash"
+	"github.com/squadracorsepolito/can-debug/internal/history"
+	"github.com/squadracorsepolito/can-debug/internal/isotp"
+	"github.com/squadracorsepolito/can-debug/internal/replay"
+	"github.com/squadracorsepolito/can-debug/internal/stats"
+	"github.com/squadracorsepolito/can-debug/internal/trace"
+	"github.com/squadracorsepolito/can-debug/internal/uds"
 )
 
 // State represents the current state of the UI
@@ -28,16 +38,70 @@ const (
 	StateMessageSelector
 	StateMonitoring
 	StateSendConfiguration
+	StateReplayFilePicker
+	StateReplay
+	StateNodeSelector
+	StateISOTP
+	StateDiagnostics
+	StateFlash
+	StateJ1939
+	StateCANopen
 )
 
+// Choices of the mode selector (values of SendReceiveChoice)
+const (
+	ChoiceSend = iota
+	ChoiceReceive
+	ChoiceReplay
+	ChoiceSimulate
+	ChoiceISOTP
+	ChoiceDiagnostics
+	ChoiceFlash
+	ChoiceJ1939
+	ChoiceCANopen
+)
+
+// choiceLabels are the entries shown by the mode selector, indexed by choice
+var choiceLabels = []string{
+	ChoiceSend:    "📤 Send CAN messages",
+	ChoiceReceive: "📥 Receive and monitor CAN messages",
+	ChoiceReplay:  "🔁 Replay a candump log file",
+	ChoiceSimulate: "🤖 Simulate a node (restbus)",
+	ChoiceISOTP:    "📦 Send/receive ISO-TP payloads",
+	ChoiceDiagnostics: "🩺 UDS diagnostics",
+	ChoiceFlash:       "⚡ Flash firmware over UDS",
+	ChoiceJ1939:       "🚜 Monitor J1939 traffic",
+	ChoiceCANopen:     "🔧 CANopen (NMT, heartbeat, SDO, PDO)",
+}
+
 // CANMessage represents a message in the CAN bus
 type CANMessage struct {
-	ID       uint32
+	ID       uint32 // ID sent on the bus
+	Extended bool   // true if ID is a 29-bit extended ID
+	FD       bool   // true if the message is sent as a CAN FD frame
 	Name     string
 	Selected bool
 	Message  *acmelib.Message
 }
 
+// newCANMessage creates the list entry for a DBC message
+func newCANMessage(msg *acmelib.Message, name string, selected bool) CANMessage {
+	id, extended := can.MessageID(msg)
+	return CANMessage{
+		ID:       id,
+		Extended: extended,
+		FD:       can.IsFD(msg),
+		Name:     name,
+		Selected: selected,
+		Message:  msg,
+	}
+}
+
+// Key identifies the CAN ID of the message (see can.Key)
+func (c CANMessage) Key() uint32 {
+	return can.Key(c.ID, c.Extended)
+}
+
 func (c CANMessage) Title() string {
 	if c.Selected {
 		return lipgloss.NewStyle().
@@ -49,7 +113,13 @@ func (c CANMessage) Title() string {
 }
 
 func (c CANMessage) Description() string {
-	desc := fmt.Sprintf("ID: 0x%X", c.ID)
+	desc := fmt.Sprintf("ID: %s", can.FormatID(c.ID, c.Extended))
+	if c.Extended {
+		desc += " (extended)"
+	}
+	if c.FD {
+		desc += " [FD]"
+	}
 	if c.Selected {
 		return lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#8800CC")).
@@ -63,9 +133,11 @@ func (c CANMessage) FilterValue() string { return c.Name }
 // infoSending contains info of the message being currenty send (cyclically)
 // frequancy is the frequency at wich is being sent (in ms)
 // stop is the function that needs to be call in order to stop the sending
-type infoSending struct{
+// frame is the frame sent at every cycle, it is replaced when a value is edited
+type infoSending struct {
 	frequency int
-	stop context.CancelFunc
+	stop      context.CancelFunc
+	frame     *atomic.Pointer[bus.Frame]
 }
 
 // SendSignal represents a signal to be sent with its input field
@@ -73,7 +145,158 @@ type SendSignal struct {
 	SignalName   string
 	Unit         string
 	TextInput    textinput.Model
-	IsSingleShot bool // true if this is a single shot send (shows "-" in cycle column)
+	// EnumValues are the labels allowed for enum signals (nil for the other signals),
+	// EnumIndex is the position of the selected one
+	EnumValues []*acmelib.SignalEnumValue
+	EnumIndex  int
+	// Muxor is the name of the muxor selecting the signal ("" if always sent),
+	// Layouts the mux values the signal is sent with and Depth its nesting level
+	Muxor   string
+	Layouts []int
+	Depth   int
+	// MuxCount is the number of layouts selected by muxor signals (0 for the other signals)
+	MuxCount int
+}
+
+// IsEnum reports whether the value of the signal is chosen from its DBC labels
+func (s *SendSignal) IsEnum() bool {
+	return len(s.EnumValues) > 0
+}
+
+// Value returns the value to send as shown to the user: "LABEL (raw)" for enum signals
+func (s *SendSignal) Value() string {
+	if s.IsEnum() {
+		enumValue := s.EnumValues[s.EnumIndex]
+		return fmt.Sprintf("%s (%d)", enumValue.Name(), enumValue.Index())
+	}
+
+	value := s.TextInput.Value()
+	if value == "" {
+		value = "0"
+	}
+	return value
+}
+
+// View renders the input of the signal, a selector for enum signals
+func (s *SendSignal) View() string {
+	if !s.IsEnum() {
+		return s.TextInput.View()
+	}
+	if s.TextInput.Focused() {
+		return "◀ " + s.Value() + " ▶"
+	}
+	return "  " + s.Value()
+}
+
+// IsMuxor reports whether the signal selects the layout of a multiplexed layer
+func (s *SendSignal) IsMuxor() bool {
+	return s.MuxCount > 0
+}
+
+// MuxValue returns the layout selected by a muxor signal, false if the input is not a valid one
+func (s *SendSignal) MuxValue() (int, bool) {
+	value, err := strconv.Atoi(s.Value())
+	if err != nil || value < 0 || value >= s.MuxCount {
+		return 0, false
+	}
+	return value, true
+}
+
+// StepMux moves the layout selected by a muxor signal by delta (wrapping around)
+func (s *SendSignal) StepMux(delta int) {
+	value, _ := s.MuxValue()
+	value = ((value+delta)%s.MuxCount + s.MuxCount) % s.MuxCount
+	s.TextInput.SetValue(strconv.Itoa(value))
+}
+
+// SelectEnum moves the selected label of an enum signal by delta positions (wrapping around)
+func (s *SendSignal) SelectEnum(delta int) {
+	n := len(s.EnumValues)
+	if n == 0 {
+		return
+	}
+	s.EnumIndex = ((s.EnumIndex+delta)%n + n) % n
+}
+
+// SendMessage represents a message of the send configuration, with its signals and sending options
+type SendMessage struct {
+	CANMessage
+	Signals    []SendSignal
+	CycleTime  int  // cycle time of the cyclical sending (in ms)
+	SingleShot bool // true if the message was just sent once (shows "-" in cycle column)
+	BRS        bool // bit rate switch of the CAN FD frames sent
+	DLC        int  // DLC override of the frames sent (fault injection), -1 sends the DLC declared in the DBC
+}
+
+// signalIndex returns the position of the signal with the given name, -1 if there is none
+func (s *SendMessage) signalIndex(name string) int {
+	for i := range s.Signals {
+		if s.Signals[i].SignalName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsSent reports whether the signal at index is in the frame: multiplexed signals
+// are sent only if their muxor is sent and selects one of their layouts
+func (s *SendMessage) IsSent(index int) bool {
+	signal := &s.Signals[index]
+	if signal.Muxor == "" {
+		return true
+	}
+
+	muxor := s.signalIndex(signal.Muxor)
+	if muxor < 0 || !s.IsSent(muxor) {
+		return false
+	}
+	value, ok := s.Signals[muxor].MuxValue()
+	return ok && inLayout(signal.Layouts, value)
+}
+
+// sentSignals returns the number of signals in the frame with the selected layouts
+func (s *SendMessage) sentSignals() int {
+	count := 0
+	for i := range s.Signals {
+		if s.IsSent(i) {
+			count++
+		}
+	}
+	return count
+}
+
+// SpecCycleTime returns the cycle time of the message declared in the DBC (GenMsgCycleTime), 0 if there is none
+func (s *SendMessage) SpecCycleTime() int {
+	return s.Message.CycleTime()
+}
+
+// DeclaredDLC returns the DLC of the message as declared in the DBC
+func (s *SendMessage) DeclaredDLC() uint8 {
+	return bus.LengthToDLC(s.Message.SizeByte())
+}
+
+// SendDLC returns the DLC of the frames sent: the override if set, otherwise the declared one
+func (s *SendMessage) SendDLC() uint8 {
+	if s.DLC >= 0 {
+		return uint8(s.DLC)
+	}
+	return s.DeclaredDLC()
+}
+
+// sendRow identifies the message and the signal shown by a row of the send table
+type sendRow struct {
+	message int // index in Model.SendMessages
+	signal  int // index in SendMessage.Signals, -1 for messages without signals
+}
+
+// monitorRow identifies the signal shown by a row of the monitoring table
+type monitorRow struct {
+	key     uint32 // key of the message (see CANMessage.Key)
+	signal  string // "" for messages without signals
+	label   string // signal name with its unit
+	muxor   string // muxor selecting the signal, "" if always present
+	layouts []int  // mux values the signal is present in
+	depth   int    // nesting level of the multiplexed layer
 }
 
 // Main model of the application
@@ -82,28 +305,113 @@ type Model struct {
 	FilePicker         filepicker.Model
 	MessageList        list.Model
 	MonitoringTable    table.Model
+	MonitorRows        []monitorRow     // signal shown by each row of the monitoring table
+	monitorIndex       rowIndex         // row of the monitoring table of each signal
+	monitorTop         int              // first row of the monitoring table shown (see monitoringTableView)
+	monitor            *monitorPipeline // goroutine decoding the received frames (nil if not monitoring)
 	SelectedMessages   []CANMessage
 	DBCPath            string
 	DBCFromCommandLine bool // true if DBC file was provided via command line
 	Messages           []*acmelib.Message
+	Nodes              []*acmelib.NodeInterface // nodes of the DBC sending at least one message
 	Decoder            *can.Decoder
 	LastUpdate         time.Time
 	Width              int
 	Height             int
 	Err                error
-	CanNetwork         net.Conn
-	Transmitter        *socketcan.Transmitter
+	Bus                bus.Bus      // CAN bus used to send and receive frames (nil if not connected)
+	MonitorReceiver    bus.Receiver // receiver used while in the "StateMonitoring" State
+	Recorder           *candump.Recorder // active recording of the received traffic (nil if not recording)
+	Stats              *stats.Tracker    // reception statistics of the monitored traffic
+	StatsTable         table.Model       // reception statistics of the selected messages
+	TimeoutFactor      float64              // a message is stale after TimeoutFactor times its DBC cycle time without frames
+	DefaultTimeout     time.Duration        // timeout of the messages without a DBC cycle time (0 never stale)
+	StaleMessages      map[uint32]time.Time // key (see CANMessage.Key) of the stale messages -> time of their last frame (start of the monitoring if never received)
+	monitorStart       time.Time            // start of the monitoring session, the messages never received time out from it
+	EventLog           []MonitorEvent       // timeout/recovery events of the monitored messages
+	MonitorView        MonitorView          // view shown while monitoring (decoded signals or raw trace)
+	Trace              *trace.Buffer        // last frames seen on the bus, for the trace view
+	TracePaused        bool                 // true if the trace view is frozen on TraceSnapshot
+	TraceSnapshot      *trace.Buffer        // frames shown while the trace is paused
+	TraceScroll        int                  // how many frames the trace view is scrolled back
+	TraceFilter        replay.Filter        // IDs shown by the trace view
+	TraceFilterInput   textinput.Model
+	TraceFilterEdit    bool // true while editing the ID filter of the trace
+	UnknownTable       table.Model // IDs seen on the bus but not declared in the DBC
+	History            *history.Store // time series of the signals of the monitoring table
+	PlotVisible        bool           // true if the plot pane is shown under the monitoring table
+	PlotPinned         []history.Key  // signals overlaid in the plot besides the one under the cursor
+	PlotWindow         int            // index in plotWindows of the time span of the plot
  	// send/receive functionality
-	SendReceiveChoice         int // 0 = send, 1 = receive
+	SendReceiveChoice         int    // one of the Choice* constants
 	PreviousSendReceiveChoice int // to track when mode actually changes
 	SendStatus                string // Status message for sending operations
 	// send configuration fields
-	SendSignals       []SendSignal
+	SendMessages      []*SendMessage // one section of the send table for each selected message
+	SendRows          []sendRow      // message and signal of each row of the send table
 	SendTable         table.Model
-	CurrentInputIndex int // which input is currently focused
-	CycleTime         int
+	CurrentInputIndex int // which row of the send table is currently focused
+	// node simulation fields
+	NodeList      list.Model
+	SimulatedNode string // name of the last node picked for simulation
+	// replay fields
+	ReplayPicker      filepicker.Model
+	ReplayPath        string
+	Player            *replay.Player
+	ReplayFilterInput textinput.Model
+	ReplayFilterEdit  int // 0 = not editing, 1 = editing include filter, 2 = editing exclude filter
+	// ISO-TP fields
+	ISOTPInputs []textinput.Model // TX ID, RX ID, block size, STmin and payload (see isotpField*)
+	ISOTPField  int               // focused input
+	ISOTPLog    []isotpEntry      // last payloads sent and received
+	ISOTPFD     bool              // send CAN FD frames of up to 64 bytes
+	isotpConn   *isotp.Conn       // connection with the TX/RX IDs of the inputs (nil if closed)
+	// diagnostics fields
+	DiagTargets      []can.DiagTarget         // ECUs reachable by the DIAG_TOOL node of the DBC
+	DiagMixed        []string                 // ECUs of the DBC whose pair with DIAG_TOOL mixes standard and extended IDs
+	DiagInputs       map[int]*textinput.Model // TX ID, RX ID and arguments (see diagField*)
+	DiagField        int                      // focused field
+	DiagService      int                      // index in diagServices of the service to request
+	DiagKeyAlgorithm int                      // index in uds.KeyAlgorithms of the SecurityAccess key algorithm
+	DiagLog          []diagEntry              // last requests with their outcome
+	diagClient       *uds.Client              // client talking to the ECU with the IDs of the inputs (nil if closed)
+	diagKeepAlive    context.CancelFunc       // stops the TesterPresent keep-alive (nil if not running)
+	// flashing fields
+	FlashInputs       map[int]*textinput.Model // TX ID, RX ID, image, base address and security level (see flashField*)
+	FlashField        int                      // focused field
+	FlashKeyAlgorithm int                      // index in uds.KeyAlgorithms of the SecurityAccess key algorithm
+	FlashProgress     flash.Progress           // progress of the current or last download
+	FlashLog          []string                 // steps and outcome of the last download
+	FlashStart        time.Time                // start of the current or last download
+	FlashEnd          time.Time                // end of the last download
+	FlashErr          error                    // error of the last download (nil if it succeeded)
+	flashSession      *flashSession            // download in progress (nil if none)
+	flashBootloader   *flash.Bootloader        // simulated bootloader on the bus (nil if off)
+	// J1939 fields
+	J1939View         int                         // view shown (see j1939View*)
+	J1939PGNTable     table.Model                 // frames by PGN, source and destination
+	J1939AddressTable table.Model                 // nodes with their address claims
+	J1939Traffic      []j1939Traffic              // rows of J1939PGNTable
+	J1939Transfers    []j1939Transfer             // last messages of the transport protocol
+	J1939Frames       uint64                      // extended frames received
+	j1939Monitor      *j1939Monitor               // follows the traffic (nil if not running)
+	j1939Receiver     bus.Receiver                // receiver of j1939Monitor
+	j1939Messages     map[uint32]*acmelib.Message // extended DBC messages by PGN (see j1939Message)
+	// CANopen fields
+	CANopenInputs   map[int]*textinput.Model // node, object, value and EDS path (see canopenField*)
+	CANopenField    int                      // focused field
+	CANopenNMT      int                      // index in canopen.NMTCommands of the NMT command to send
+	CANopenPanel    int                      // panel shown below the nodes (see canopenPanel*)
+	CANopenEDS      *canopen.EDS             // names and data types of the objects (nil if not loaded)
+	CANopenLog      []canopenEntry           // last SDO transfers
+	CANopenNodes    []canopen.Node           // nodes seen through their heartbeats and emergencies
+	CANopenPDOs     []canopenPDO             // last frame of each PDO
+	canopenMonitor  *canopenMonitor          // follows the nodes and PDOs (nil if not running)
+	canopenReceiver bus.Receiver             // receiver of canopenMonitor
+	canopenBusy     bool                     // an SDO transfer is in progress
 	// data structure for message sending
-	ActiveMessages map[int]infoSending //map of messageID -> struct with info of the message being currenty send (cyclically) 
+	ActiveMessages map[int]infoSending //map of message key (see CANMessage.Key) -> struct with info of the message being currenty send (cyclically)
+	sendErrors     chan SendErrorMsg   // errors of the cyclical sending goroutines, delivered to Update
 }
 
 // Message for updating real-time data
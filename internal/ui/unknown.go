@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+
+	canDebug "github.com/squadracorsepolito/can-debug/internal/can"
+)
+
+// setupUnknownTable configures the table of the IDs seen on the bus but not declared in the DBC
+func (m *Model) setupUnknownTable() {
+	columns := []table.Column{
+		{Title: "ID", Width: 12},
+		{Title: "Count", Width: 10},
+		{Title: "Rate(Hz)", Width: 10},
+		{Title: "DLC", Width: 5},
+		{Title: "Last payload", Width: 50},
+		{Title: "Age", Width: 9},
+	}
+
+	m.UnknownTable = table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(m.unknownTableHeight()),
+	)
+	m.updateUnknownTable(time.Now())
+}
+
+// unknownTableHeight returns the height of the unknown IDs table, it takes the whole screen below the help
+func (m *Model) unknownTableHeight() int {
+	return max(5, m.Height-9)
+}
+
+// updateUnknownTable refreshes the unknown IDs from the reception statistics, called at every tick while monitoring
+func (m *Model) updateUnknownTable(now time.Time) {
+	if m.Stats == nil || m.Decoder == nil {
+		return
+	}
+
+	rows := make([]table.Row, 0)
+	for _, key := range m.Stats.Keys() {
+		id, extended := canDebug.SplitID(key)
+		if _, ok := m.Decoder.Lookup(id, extended); ok {
+			continue
+		}
+
+		s, ok := m.Stats.Get(key)
+		if !ok {
+			continue
+		}
+
+		data := make([]string, 0, s.Frame.Length)
+		for _, b := range s.Frame.Payload() {
+			data = append(data, fmt.Sprintf("%02X", b))
+		}
+		payload := strings.Join(data, " ")
+		if s.Frame.IsRemote {
+			payload = "(remote frame)"
+		}
+
+		rate := "-"
+		if s.Count > 1 {
+			rate = fmt.Sprintf("%.1f", s.Rate())
+		}
+
+		rows = append(rows, table.Row{
+			canDebug.FormatID(id, extended),
+			fmt.Sprintf("%d", s.Count),
+			rate,
+			fmt.Sprintf("%d", s.Frame.DLC()),
+			payload,
+			formatAge(s.Age(now)),
+		})
+	}
+
+	m.UnknownTable.SetRows(rows)
+}
+
+// unknownView renders the IDs seen on the bus but not declared in the DBC
+func (m Model) unknownView() string {
+	var s strings.Builder
+
+	rows := len(m.UnknownTable.Rows())
+	if rows == 0 {
+		s.WriteString("✅ Every ID seen on the bus is declared in the DBC")
+		return s.String()
+	}
+
+	s.WriteString(fmt.Sprintf("⚠️  %d IDs seen on the bus are not declared in the DBC (%s)", rows, m.DBCPath))
+	s.WriteString("\n\n")
+	s.WriteString(m.UnknownTable.View())
+
+	return s.String()
+}
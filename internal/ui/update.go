@@ -30,18 +30,38 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.MessageList.SetHeight(msg.Height - 6)
 		case StateMonitoring:
 			m.MonitoringTable.SetWidth(msg.Width)
-			m.MonitoringTable.SetHeight(msg.Height - 4)
+			m.MonitoringTable.SetHeight(m.monitoringTableHeight())
+			m.StatsTable.SetWidth(msg.Width)
+			m.UnknownTable.SetWidth(msg.Width)
+			m.UnknownTable.SetHeight(m.unknownTableHeight())
+		case StateJ1939:
+			m.J1939PGNTable.SetHeight(m.j1939TableHeight())
+			m.J1939AddressTable.SetHeight(m.j1939TableHeight())
 		case StateSendConfiguration:
 			m.SendTable.SetWidth(msg.Width)
 			m.SendTable.SetHeight(msg.Height - 10)
+		case StateReplayFilePicker:
+			m.ReplayPicker.Height = msg.Height - 4
+		case StateNodeSelector:
+			m.NodeList.SetWidth(msg.Width)
+			m.NodeList.SetHeight(msg.Height - 6)
 		}
 
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "q":
-			if m.CanNetwork != nil {
-				m.Transmitter.Close()
+			if msg.String() == "q" && (m.State == StateReplay && m.ReplayFilterEdit != 0 || m.State == StateMonitoring && m.TraceFilterEdit || m.State == StateISOTP || m.State == StateDiagnostics || m.State == StateFlash || m.State == StateCANopen) {
+				// 'q' is part of the filter being typed
+				break
 			}
+			m.stopReceivingMessages()
+			m.stopRecording()
+			m.stopReplay()
+			m.closeISOTP()
+			m.closeDiagnostics()
+			m.closeFlash()
+			m.closeJ1939()
+			m.closeCANopen()
 			return m, tea.Quit
 		case "tab":
 			// Tab sempre torna indietro alla schermata precedente
@@ -55,22 +75,132 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Da message selector, torna a send/receive selector
 				m.State = StateSendReceiveSelector
 			case StateMonitoring:
+				if m.TraceFilterEdit {
+					// Tab cancels the filter being edited
+					m.TraceFilterEdit = false
+					break
+				}
 				// Da monitoring, torna a message selector
 				m.State = StateMessageSelector
+				m.stopReceivingMessages()
 				// Update the message list when returning from monitoring mode
 				m.updateMessageListItems()
 				// Reset the table to avoid it being visible
 				m.MonitoringTable = table.Model{}
 			case StateSendConfiguration:
+				if m.SendReceiveChoice == ChoiceSimulate {
+					// Back to the node selection, the simulated messages keep being sent
+					m.State = StateNodeSelector
+					break
+				}
 				// Da send configuration, torna a message selector
 				m.State = StateMessageSelector
 				// Update the message list when returning from send configuration
 				m.updateMessageListItems()
+			case StateNodeSelector:
+				m.State = StateSendReceiveSelector
+			case StateReplayFilePicker:
+				m.State = StateSendReceiveSelector
+			case StateReplay:
+				if m.ReplayFilterEdit != 0 {
+					// Tab cancels the filter being edited
+					m.ReplayFilterEdit = 0
+					break
+				}
+				// Stop the replay and go back to the log file selection
+				m.stopReplay()
+				m.State = StateReplayFilePicker
+			case StateISOTP:
+				m.closeISOTP()
+				m.State = StateSendReceiveSelector
+			case StateDiagnostics:
+				m.closeDiagnostics()
+				m.State = StateSendReceiveSelector
+			case StateFlash:
+				m.closeFlash()
+				m.State = StateSendReceiveSelector
+			case StateJ1939:
+				m.closeJ1939()
+				m.State = StateSendReceiveSelector
+			case StateCANopen:
+				m.closeCANopen()
+				m.State = StateSendReceiveSelector
 			}
 		}
 
+	case FramesMsg:
+		// Updates of the monitoring table, from the goroutine receiving the frames
+		if msg.pipeline != m.monitor {
+			return m, nil // the monitoring session it comes from is over
+		}
+		m.applyFrames(msg.Batch)
+		return m, m.monitor.wait()
+
+	case SendErrorMsg:
+		// Errors of the messages stopped in the meantime are stale
+		if _, ok := m.ActiveMessages[int(msg.Key)]; ok {
+			m.SendStatus = fmt.Sprintf("⚠️ CAN bus error sending '%s': %v", msg.Name, msg.Err)
+		}
+		return m, m.waitSendError()
+
+	case ISOTPReceivedMsg:
+		// Payloads of a connection closed in the meantime are stale
+		if msg.conn != m.isotpConn {
+			return m, nil
+		}
+		m.logISOTP(isotpEntry{Time: time.Now(), Payload: msg.Payload, Err: msg.Err})
+		return m, waitISOTP(msg.conn)
+
+	case ISOTPSentMsg:
+		if msg.conn != m.isotpConn {
+			return m, nil
+		}
+		m.logISOTP(isotpEntry{Time: time.Now(), Tx: true, Payload: msg.Payload, Err: msg.Err, Elapsed: msg.Elapsed})
+		if msg.Err != nil {
+			m.SendStatus = fmt.Sprintf("⚠️ ISO-TP transmission failed: %v", msg.Err)
+		} else {
+			m.SendStatus = fmt.Sprintf("✅ Sent %d bytes in %s", len(msg.Payload), msg.Elapsed.Round(time.Microsecond))
+		}
+		return m, nil
+
+	case DiagResultMsg:
+		return m, m.handleDiagResult(msg)
+
+	case DiagKeepAliveMsg:
+		m.handleDiagKeepAlive(msg)
+		return m, nil
+
+	case FlashProgressMsg:
+		return m, m.handleFlashProgress(msg)
+
+	case FlashDoneMsg:
+		m.handleFlashDone(msg)
+		return m, nil
+
+	case CANopenSDOMsg:
+		m.handleCANopenSDO(msg)
+		return m, nil
+
+	case SingleShotDoneMsg:
+		msg.Message.SingleShot = false
+		if m.State == StateSendConfiguration {
+			m.updateSendTableRows()
+		}
+		return m, nil
+
 	case TickMsg:
 		m.LastUpdate = time.Now()
+		if m.State == StateMonitoring {
+			m.checkTimeouts(m.LastUpdate)
+			m.updateStatsTable(m.LastUpdate)
+			m.updateUnknownTable(m.LastUpdate)
+		}
+		if m.State == StateJ1939 {
+			m.updateJ1939Tables(m.LastUpdate)
+		}
+		if m.State == StateCANopen {
+			m.updateCANopenNodes()
+		}
 		return m, TickCmd()
 	}
 
@@ -110,7 +240,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// it will be updated when Enter is pressed
 				}
 			case "down", "j":
-				if m.SendReceiveChoice < 1 {
+				if m.SendReceiveChoice < len(choiceLabels)-1 {
 					m.SendReceiveChoice++
 					// Clear selected messages when changing mode
 					m.SelectedMessages = []CANMessage{}
@@ -125,20 +255,49 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Check if mode actually changed since last time
 				modeChanged := m.SendReceiveChoice != m.PreviousSendReceiveChoice
 
-				if m.SendReceiveChoice == 0 {
+				switch m.SendReceiveChoice {
+				case ChoiceSend:
 					// Send mode - go to message selector
 					m.State = StateMessageSelector
 					if modeChanged {
 						m.SelectedMessages = []CANMessage{} // Clear selection only if mode changed
 					}
 					m.setupMessageList()
-				} else {
+				case ChoiceReceive:
 					// Receive mode
 					m.State = StateMessageSelector
 					if modeChanged {
 						m.SelectedMessages = []CANMessage{} // Clear selection only if mode changed
 					}
 					m.setupMessageList()
+				case ChoiceReplay:
+					// Replay mode - choose the log file first
+					m.State = StateReplayFilePicker
+					cmds = append(cmds, m.setupReplayFilePicker())
+				case ChoiceSimulate:
+					// Simulation mode - choose the node to simulate
+					m.State = StateNodeSelector
+					m.setupNodeList()
+				case ChoiceISOTP:
+					// ISO-TP mode - no message to choose
+					m.State = StateISOTP
+					cmds = append(cmds, m.setupISOTP())
+				case ChoiceDiagnostics:
+					// Diagnostics mode - the targets come from the DIAG_TOOL node of the DBC
+					m.State = StateDiagnostics
+					cmds = append(cmds, m.setupDiagnostics())
+				case ChoiceFlash:
+					// Flashing mode - the targets come from the DIAG_TOOL node of the DBC
+					m.State = StateFlash
+					m.setupFlash()
+				case ChoiceJ1939:
+					// J1939 mode - every extended frame on the bus
+					m.State = StateJ1939
+					m.setupJ1939()
+				case ChoiceCANopen:
+					// CANopen mode - the nodes are found through their heartbeats
+					m.State = StateCANopen
+					m.setupCANopen()
 				}
 
 				// Update previous choice to current
@@ -152,7 +311,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "enter":
 				if len(m.SelectedMessages) > 0 {
-					if m.SendReceiveChoice == 0 {
+					if m.SendReceiveChoice == ChoiceSend {
 						// Send mode - vai a send configuration
 						m.State = StateSendConfiguration
 						m.setupSendConfiguration()
@@ -161,7 +320,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.setupMonitoringTable()
 						m.initializesTableDBCSignals()
 						m.State = StateMonitoring
-						go m.startReceavingMessages()
+						cmds = append(cmds, m.startMonitoring())
 					}
 				}
 			case " ":
@@ -173,92 +332,173 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 
 	case StateMonitoring:
+		if msg, ok := msg.(tea.KeyMsg); ok && !m.TraceFilterEdit {
+			switch msg.String() {
+			case "r":
+				// Start/stop recording the received frames
+				m.toggleRecording()
+			case "v":
+				// Switch between the decoded and the trace view
+				m.toggleMonitorView()
+			}
+		}
+
+		if m.MonitorView == MonitorViewTrace {
+			cmds = append(cmds, m.updateTrace(msg))
+			break
+		}
+		if m.MonitorView == MonitorViewUnknown {
+			// Scroll the unknown IDs
+			m.UnknownTable, cmd = m.UnknownTable.Update(msg)
+			cmds = append(cmds, cmd)
+			break
+		}
+
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "p":
+				// Show/hide the plot of the signal under the cursor
+				m.togglePlot()
+			case "o":
+				// Overlay the signal under the cursor in the plot
+				m.togglePlotPin()
+			case "+", "=":
+				m.adjustPlotWindow(1)
+			case "-":
+				m.adjustPlotWindow(-1)
+			}
+		}
+
 		// Update the table to handle scroll and cursor
 		m.MonitoringTable, cmd = m.MonitoringTable.Update(msg)
 		cmds = append(cmds, cmd)
+		m.monitorTop = visibleTop(m.monitorTop, m.MonitoringTable.Cursor(), m.MonitoringTable.Height())
 
 		// Always ensure the table cursor is properly positioned and visible
 		m.ensureTableCursorVisible()
 
+	case StateReplayFilePicker:
+		m.ReplayPicker, cmd = m.ReplayPicker.Update(msg)
+		cmds = append(cmds, cmd)
+
+		// Check if a log file was selected
+		if didSelect, path := m.ReplayPicker.DidSelectFile(msg); didSelect {
+			m.startReplay(path)
+		}
+
+	case StateReplay:
+		cmds = append(cmds, m.updateReplay(msg))
+
+	case StateNodeSelector:
+		cmds = append(cmds, m.updateNodeSelector(msg))
+
+	case StateISOTP:
+		cmds = append(cmds, m.updateISOTP(msg))
+
+	case StateDiagnostics:
+		cmds = append(cmds, m.updateDiagnostics(msg))
+
+	case StateFlash:
+		cmds = append(cmds, m.updateFlash(msg))
+
+	case StateJ1939:
+		cmds = append(cmds, m.updateJ1939(msg))
+
+	case StateCANopen:
+		cmds = append(cmds, m.updateCANopen(msg))
+
 	case StateSendConfiguration:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
+			// Message of the section the cursor is in, the actions apply to it
+			current := m.currentSendMessage()
+			if current == nil {
+				break
+			}
+
 			switch msg.String() {
 			case "enter":
 				// Send once all signals of the current message
-				m.sendSingleMessage()
+				cmds = append(cmds, m.sendSingleMessage(current))
 			case " ":
-				// Toggle start/stop for all signals of the current message
-				_, ok := m.ActiveMessages[int(m.SelectedMessages[0].ID)]
-				if ok {
-					m.stopCyclicalSending()
-				} else {
-					m.startCyclicalSending()
-				}
+				// Toggle start/stop of the cyclical sending of the current message
+				m.toggleCyclicalSending(current)
 			case "right", "l":
-				// Increase cycle time for all signals of the current message
-				m.adjustMessageCycleTime(rangeMs)
+				// Increase cycle time of the current message
+				m.adjustMessageCycleTime(current, rangeMs)
 			case "left":
-				// Decrease cycle time for all signals of the current message
-				m.adjustMessageCycleTime(-rangeMs)
+				// Decrease cycle time of the current message
+				m.adjustMessageCycleTime(current, -rangeMs)
 			case "s":
 				// Stop ALL message sending
 				m.stopAllMessages()
-			case "-":
-				// Handle '-' for negative numbers in input field
-				if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
-					// Check if we're in input mode and the input is focused
-					currentInput := &m.SendSignals[m.CurrentInputIndex].TextInput
-					if currentInput.Focused() {
-						// Let the input handle the '-' for negative numbers
-						*currentInput, cmd = currentInput.Update(msg)
-						cmds = append(cmds, cmd)
-						m.updateSendTableRows()
-					}
-				}
+			case "b":
+				// Toggle the bit rate switch of CAN FD messages
+				m.toggleBRS(current)
+			case "]":
+				// Increase the DLC sent (fault injection)
+				m.adjustDLC(current, 1)
+			case "[":
+				// Decrease the DLC sent (fault injection)
+				m.adjustDLC(current, -1)
+			case "d":
+				// Send the DLC declared in the DBC
+				m.resetDLC(current)
 			case "up", "k":
 				if m.CurrentInputIndex > 0 {
-					// Remove focus from current input
-					if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
-						m.SendSignals[m.CurrentInputIndex].TextInput.Blur()
-					}
-					// Move to previous input
-					m.CurrentInputIndex--
 					// Let the table handle the navigation, then sync
 					m.SendTable, cmd = m.SendTable.Update(msg)
 					cmds = append(cmds, cmd)
-					// Sync table cursor with our index
-					m.SendTable.SetCursor(m.CurrentInputIndex)
-					// Set focus to new input
-					if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
-						m.SendSignals[m.CurrentInputIndex].TextInput.Focus()
-					}
+					// Move to previous row and focus its input
+					m.focusSendRow(m.CurrentInputIndex - 1)
 				}
 			case "down", "j":
-				if m.CurrentInputIndex < len(m.SendSignals)-1 {
-					// Remove focus from current input
-					if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
-						m.SendSignals[m.CurrentInputIndex].TextInput.Blur()
-					}
-					// Move to next input
-					m.CurrentInputIndex++
+				if m.CurrentInputIndex < len(m.SendRows)-1 {
 					// Let the table handle the navigation, then sync
 					m.SendTable, cmd = m.SendTable.Update(msg)
 					cmds = append(cmds, cmd)
-					// Sync table cursor with our index
-					m.SendTable.SetCursor(m.CurrentInputIndex)
-					// Set focus to new input
-					if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
-						m.SendSignals[m.CurrentInputIndex].TextInput.Focus()
+					// Move to next row and focus its input
+					m.focusSendRow(m.CurrentInputIndex + 1)
+				}
+			case "<", ",", ">", ".":
+				// Choose the label of enum signals
+				if signal := m.currentSendSignal(); signal != nil && signal.IsEnum() {
+					if msg.String() == "<" || msg.String() == "," {
+						signal.SelectEnum(-1)
+					} else {
+						signal.SelectEnum(1)
+					}
+					m.updateSendTableRows()
+					m.updateSignalValue(current, signal)
+					break
+				}
+				// Choose the layout of muxor signals
+				if signal := m.currentSendSignal(); signal != nil && signal.IsMuxor() {
+					if msg.String() == "<" || msg.String() == "," {
+						signal.StepMux(-1)
+					} else {
+						signal.StepMux(1)
 					}
+					m.updateSignalValue(current, signal)
+					break
 				}
+				// Otherwise '.' is the decimal point of the value
+				if msg.String() != "." {
+					break
+				}
+				fallthrough
 			default:
-				// Update the current input field
-				if m.CurrentInputIndex >= 0 && m.CurrentInputIndex < len(m.SendSignals) {
-					m.SendSignals[m.CurrentInputIndex].TextInput, cmd = m.SendSignals[m.CurrentInputIndex].TextInput.Update(msg)
+				// Update the current input field (also handles '-' for negative numbers)
+				if signal := m.currentSendSignal(); signal != nil && !signal.IsEnum() && signal.TextInput.Focused() {
+					previous := signal.TextInput.Value()
+					signal.TextInput, cmd = signal.TextInput.Update(msg)
 					cmds = append(cmds, cmd)
 					// Update the table rows to reflect the new input value
 					m.updateSendTableRows()
+					// Confirm the new value and apply it to the cyclical sending
+					if signal.TextInput.Value() != previous {
+						m.updateSignalValue(current, signal)
+					}
 				}
 			}
 		}
@@ -57,6 +57,12 @@ func (m Model) wrapStatus(status string, maxWidth int) string {
 	return result
 }
 
+// progressBar renders a progress bar of the given width, filled by fraction (0-1)
+func progressBar(fraction float64, width int) string {
+	filled := max(0, min(int(float64(width)*fraction), width))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
 // View renders the current state of the UI
 func (m Model) View() string {
 	switch m.State {
@@ -70,6 +76,22 @@ func (m Model) View() string {
 		return m.monitoringView()
 	case StateSendConfiguration:
 		return m.sendConfigurationView()
+	case StateReplayFilePicker:
+		return m.replayFilePickerView()
+	case StateReplay:
+		return m.replayView()
+	case StateNodeSelector:
+		return m.nodeSelectorView()
+	case StateISOTP:
+		return m.isotpView()
+	case StateDiagnostics:
+		return m.diagnosticsView()
+	case StateFlash:
+		return m.flashView()
+	case StateJ1939:
+		return m.j1939View()
+	case StateCANopen:
+		return m.canopenView()
 	default:
 		return "Not recognized state"
 	}
@@ -107,9 +129,9 @@ func (m Model) messageSelectorView() string {
 	s.WriteString("Navigation: ↑/k up • ↓/j down • / filter • Tab back to mode selection • q quit\n")
 
 	// Different instructions based on send/receive mode
-	if m.SendReceiveChoice == 0 {
-		// Send mode - single selection
-		s.WriteString("Actions: Space select message • Enter configure sending\n")
+	if m.SendReceiveChoice == ChoiceSend {
+		// Send mode - multiple selection, one section of the send table per message
+		s.WriteString("Actions: Space select/deselect • Enter configure sending\n")
 	} else {
 		// Receive mode - multiple selection
 		s.WriteString("Actions: Space select/deselect • Enter start monitoring\n")
@@ -140,15 +162,58 @@ func (m Model) monitoringView() string {
 		s.WriteString("\n\n")
 
 		// Status bar with commands for the monitoring table
-		s.WriteString("↑/k up • ↓/j down • Tab back to message selection • q quit")
+		switch m.MonitorView {
+		case MonitorViewTrace:
+			s.WriteString("Trace: Space pause/resume • ↑/↓ PgUp/PgDn scroll back • g oldest • G live • f filter IDs\n")
+			s.WriteString("v unknown IDs view • r start/stop recording • Tab back to message selection • q quit")
+		case MonitorViewUnknown:
+			s.WriteString("↑/k up • ↓/j down • v decoded view • r start/stop recording • Tab back to message selection • q quit")
+		default:
+			s.WriteString("↑/k up • ↓/j down • p plot • v trace view • r start/stop recording • Tab back to message selection • q quit")
+		}
+		s.WriteString("\n")
+		s.WriteString(m.recordingStatus())
 		s.WriteString("\n\n")
 
-		s.WriteString(m.MonitoringTable.View())
+		switch m.MonitorView {
+		case MonitorViewTrace:
+			s.WriteString(m.traceView())
+			return s.String()
+		case MonitorViewUnknown:
+			s.WriteString(m.unknownView())
+			return s.String()
+		}
+
+		s.WriteString(m.monitoringTableView())
+		s.WriteString("\n\n")
+
+		// The plot takes the place of the statistics and the event log
+		if m.PlotVisible {
+			s.WriteString(m.plotView())
+			return s.String()
+		}
+
+		s.WriteString(lipgloss.NewStyle().Bold(true).Render("⏱️  Reception statistics"))
+		s.WriteString("\n")
+		s.WriteString(m.StatsTable.View())
+		s.WriteString("\n\n")
+
+		s.WriteString(m.eventLogView())
 	}
 
 	return s.String()
 }
 
+// recordingStatus renders the status of the recording of the received frames
+func (m Model) recordingStatus() string {
+	if m.Recorder == nil {
+		return "⚪ Not recording"
+	}
+	return lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FF0000")).
+		Render(fmt.Sprintf("🔴 Recording to %s (%d frames)", m.Recorder.Path(), m.Recorder.Count()))
+}
+
 // sendReceiveSelectorView renders the send/receive selector view
 func (m Model) sendReceiveSelectorView() string {
 	var s strings.Builder
@@ -159,13 +224,13 @@ func (m Model) sendReceiveSelectorView() string {
 
 	s.WriteString("Select use mode:\n\n")
 
-	// Display the send/receive options
-	if m.SendReceiveChoice == 0 {
-		s.WriteString("> 📤 Send CAN messages\n")
-		s.WriteString("  📥 Receive and monitor CAN messages\n")
-	} else {
-		s.WriteString("  📤 Send CAN messages\n")
-		s.WriteString("> 📥 Receive and monitor CAN messages\n")
+	// Display the mode options
+	for choice, label := range choiceLabels {
+		if m.SendReceiveChoice == choice {
+			s.WriteString("> " + label + "\n")
+		} else {
+			s.WriteString("  " + label + "\n")
+		}
 	}
 
 	// Show navigation instructions based on how DBC was loaded
@@ -182,21 +247,68 @@ func (m Model) sendReceiveSelectorView() string {
 func (m Model) sendConfigurationView() string {
 	var s strings.Builder
 
-	s.WriteString(lipgloss.NewStyle().Bold(true).Render("📤 Configure message values to send"))
+	if m.SendReceiveChoice == ChoiceSimulate {
+		s.WriteString(lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("🤖 Simulating node %s: edit values and periods to override the defaults", m.SimulatedNode)))
+	} else {
+		s.WriteString(lipgloss.NewStyle().Bold(true).Render("📤 Configure message values to send"))
+	}
 	s.WriteString(fmt.Sprintf(" (File: %s)", m.DBCPath))
 	s.WriteString("\n\n")
 
 	// Instructions organized by category
 	s.WriteString("Navigation: ↑/k up • ↓/j down • Tab back • q quit\n")
-	s.WriteString("Action: Enter send message • Space toggle message • ←→ adjust message cycle • s stop all")
+	s.WriteString("Action (on the message under the cursor): Enter send message • Space toggle message • ←→ adjust message cycle • s stop all\n")
+	s.WriteString("DLC: [/] decrease/increase (fault injection) • d reset to DBC")
+	if current := m.currentSendMessage(); current != nil && current.FD {
+		brs := "off"
+		if current.BRS {
+			brs = "on"
+		}
+		s.WriteString(fmt.Sprintf("\nCAN FD: b toggle bit rate switch of '%s' (BRS %s)", current.Name, brs))
+	}
 	s.WriteString("\n\n")
 
 	// Show the send table
-	if len(m.SendSignals) > 0 {
+	if len(m.SendRows) > 0 {
 		s.WriteString(m.SendTable.View())
 		s.WriteString("\n\n")
 	}
 
+	// Show the labels allowed for the enum signal under the cursor
+	if signal := m.currentSendSignal(); signal != nil && signal.IsEnum() {
+		labels := make([]string, len(signal.EnumValues))
+		for i, enumValue := range signal.EnumValues {
+			label := fmt.Sprintf("%s (%d)", enumValue.Name(), enumValue.Index())
+			if i == signal.EnumIndex {
+				label = lipgloss.NewStyle().Bold(true).Reverse(true).Render(label)
+			}
+			labels[i] = label
+		}
+		s.WriteString(fmt.Sprintf("🏷️  %s (</> choose): %s", signal.SignalName, strings.Join(labels, " • ")))
+		s.WriteString("\n\n")
+	}
+
+	// Show the signals of the layout selected by the muxor under the cursor
+	if signal := m.currentSendSignal(); signal != nil && signal.IsMuxor() {
+		s.WriteString(fmt.Sprintf("🔀 %s (</> choose layout 0-%d): ", signal.SignalName, signal.MuxCount-1))
+		if value, ok := signal.MuxValue(); ok {
+			msg := m.currentSendMessage()
+			names := []string{}
+			for i := range msg.Signals {
+				if msg.Signals[i].Muxor == signal.SignalName && inLayout(msg.Signals[i].Layouts, value) {
+					names = append(names, msg.Signals[i].SignalName)
+				}
+			}
+			if len(names) == 0 {
+				names = append(names, "no signals")
+			}
+			s.WriteString(fmt.Sprintf("layout %d sends %s", value, strings.Join(names, ", ")))
+		} else {
+			s.WriteString("invalid layout, nothing multiplexed is sent")
+		}
+		s.WriteString("\n\n")
+	}
+
 	// Show status messages if available
 	if m.SendStatus != "" {
 		wrappedStatus := m.wrapStatus(m.SendStatus, m.Width)
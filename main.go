@@ -2,47 +2,66 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/squadracorsepolito/can-debug/internal/bus"
 	"github.com/squadracorsepolito/can-debug/internal/ui"
-	"go.einride.tech/can/pkg/socketcan"
 )
 
 func main() {
-	// Handle help
-	if len(os.Args) > 1 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
-		showHelp()
+	// Handle subcommands
+	if len(os.Args) > 1 && os.Args[1] == "decode" {
+		if err := runDecode(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
+
+	// Handle flags and help
+	loopback := flag.Bool("loopback", false, "use the in-memory loopback bus (same as mem://)")
+	record := flag.String("record", "", "record the received frames to a candump log file without starting the TUI")
+	timeoutFactor := flag.Float64("timeout-factor", ui.DefaultTimeoutFactor, "monitored messages are stale after this many DBC cycle times without frames (0 disables)")
+	timeout := flag.Duration("timeout", ui.DefaultMessageTimeout, "timeout of the monitored messages without a DBC cycle time (0 disables)")
+	flag.Usage = showHelp
+	flag.Parse()
+	args := flag.Args()
 	
 	//connecting and setting up the can network
-	var canNetworkName string
-	if len(os.Args) <= 1 {
+	var busAddress string
+	if *loopback {
+		busAddress = "mem://"
+	} else if len(args) == 0 {
 		fmt.Print("Warning: no name for the can network was provided\n")
-		return   //canNetworkName = ""     <---------------------------------   replace this line for debug TUI
+		return
 	}else{
-		canNetworkName = os.Args[1]
+		busAddress = args[0]
+		args = args[1:]
 	}
-	var conn net.Conn
-	// Try to connect to SocketCAN
-	c, err := socketcan.DialContext(context.Background(), "can", canNetworkName)
+	// Open the bus, a plain interface name is opened with SocketCAN
+	canBus, err := bus.Open(context.Background(), busAddress)
 	if err != nil {
-		fmt.Printf("Warning: Error opening SocketCAN on Linux: %v\n", err)
-		return   //conn = nil               <---------------------------------   replace this line for debug TUI
-	} else {
-		conn = c
-		defer conn.Close()
+		fmt.Printf("Warning: Error opening the CAN bus: %v\n", err)
+		return
+	}
+	defer canBus.Close()
+
+	// Headless recording, no TUI needed
+	if *record != "" {
+		if err := runRecord(canBus, *record); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return
 	}
-	
 
 	// If a DBC file is provided as an argument, load it directly
 	var dbcPath string
-	if len(os.Args) > 2 {
-		dbcPath = os.Args[2]
+	if len(args) > 0 {
+		dbcPath = args[0]
 
 		// Check if the file exists
 		if _, err := os.Stat(dbcPath); os.IsNotExist(err) {
@@ -54,7 +73,9 @@ func main() {
 	}
 
 	// Create the initial model
-	m := ui.NewModelWithDBC(dbcPath, conn)
+	m := ui.NewModelWithDBC(dbcPath, canBus)
+	m.TimeoutFactor = *timeoutFactor
+	m.DefaultTimeout = *timeout
 
 	// Start bubbletea
 	p := tea.NewProgram(&m, tea.WithAltScreen())
@@ -68,17 +89,29 @@ func showHelp() {
 	fmt.Print(`🔧 CAN Debug Tool
 
 Use:
-  can-debug [name_of_can_network] -> specify the CAN network name and load a dbc file with the file picker
-  can-debug [name_of_can_network] [file.dbc] -> Directly load a DBC file
+  can-debug [bus] -> specify the CAN bus and load a dbc file with the file picker
+  can-debug [bus] [file.dbc] -> Directly load a DBC file
+  can-debug --loopback [file.dbc] -> Use the in-memory loopback bus (no CAN network needed)
+  can-debug --record file.log [bus] -> Record all the received frames (candump -L format) without the TUI
+  can-debug decode --dbc file.dbc [--format frames|wide] [--period 10ms] [-o out.csv] session.log
+                        -> Decode a candump -L log offline and write the signals as CSV
+  can-debug --timeout-factor 3 --timeout 1s [bus] [file.dbc]
+                        -> Monitored messages are stale after 3 DBC cycle times (1s if they have no cycle time) without frames
   can-debug -h|--help   Show this help
 
+Bus:
+  vcan0 or socketcan://vcan0   SocketCAN interface (a plain name is opened with SocketCAN), CAN FD is enabled if the interface supports it
+  mem:// or mem://name         In-memory loopback bus, every frame sent is echoed back to all the receivers
+                               of the process opened on the same name (works on every platform)
+
 Examples:
-  can-debug                              # Visualize only the interface(no real can network) + Use the file picker
+  can-debug --loopback                   # Visualize the interface on the loopback bus (no real can network) + Use the file picker
   can-debug vcan0                        # Try to connect to the can network "vcan0" + Use the file picker
   can-debug vcan0 internal/test/MCB.dbc  # Try to connect to the can network "vcan0" + Load specific file
 
 Platform Support:
-  You can only transmit CAN messages on Linux systems, and you need to create the can network beforehand (see README file).
+  You can only transmit CAN messages on a real network on Linux systems, and you need to create the can network beforehand (see README file).
+  The loopback bus works everywhere: send mode and monitor mode see each other's frames.
 
 Navigation Commands:
   ↑/↓ or k/j   Navigate up/down in lists and tables
@@ -88,8 +121,8 @@ Navigation Commands:
 File Selection:
   Enter        Open directory or select .dbc file
 
-Send/Receive Mode Selection:
-  ↑/↓          Choose between Send or Receive mode
+Mode Selection:
+  ↑/↓          Choose between Send, Receive, Replay, Simulate node, ISO-TP, UDS diagnostics, flashing, J1939 or CANopen mode
   Enter        Confirm selection
 
 Message List:
@@ -102,14 +135,80 @@ Send Configuration:
   Action:      Enter send once • Space toggle continuous sending
                ←→ adjust cycle time • s stop all signals
 
-  Individual Message Control:
+  Individual Message Control (the table has a section per selected message, actions apply to the message under the cursor):
     - Use ←→ arrows to adjust cycle time (10ms increments, range: 10ms-10s) (can be changed by changing the value rangeMs in internal/ui/types.go)
-    - Enter: Send signal once (single shot)
+      The cycle time starts from the DBC GenMsgCycleTime (if declared), a ⚠️ marks periods different from it
+    - Enter: Send message once (single shot)
     - Space: Toggle continuous sending at set frequency
     - s: Emergency stop all continuous signals
-    - Input field: Enter signal value (supports decimals, negatives)
+    - b: Toggle the bit rate switch (BRS) of CAN FD messages
+    - [ / ]: Decrease/increase the DLC sent, 0-15 (fault injection, classic frames with a DLC of 9-15
+      carry 8 bytes and need an interface with cc-len8-dlc on) • d: reset it to the DBC one
+    - Input field: Enter signal value (supports decimals, negatives), applied live to continuous sending
+    - < / >: Choose the label of enum signals (the allowed DBC labels are listed below the table)
+    - < / >: Choose the layout of muxor signals, only the signals of the selected layout are shown and sent
+
+Simulate Node Mode (restbus):
+  Pick a node of the DBC: all the messages it sends are shown in the send table, the ones with a
+  GenMsgCycleTime are sent cyclically at that period with the GenSigStartValue initial values
+  Edit values, periods, DLC... in the send table to override them • Tab pick another node (the simulation keeps running)
+
+Replay Mode:
+  Pick a candump -L log file (e.g. recorded with r in monitoring) and transmit it with the original timing
+  Space        Pause/resume (resume restarts a finished replay)
+  ←/→          Seek back/forward 5s • 0 restart
+  +/-          Double/halve the speed (x0.125 - x64)
+  o            Toggle looping
+  i / e        Edit the include / exclude ID filters (hex IDs, e.g. 100,1A0, 8 digits for extended IDs) • Enter apply • Esc cancel
+
+ISO-TP Mode:
+  Send and receive ISO-TP (ISO 15765-2) payloads with a TX/RX ID pair (default 7E0/7E8, IDs above 7FF are extended)
+  ↑/↓          Move between TX ID, RX ID, block size, STmin and payload
+  Enter        On the IDs/flow control: apply them • on the payload: send it (hex bytes, e.g. 22 F1 90)
+  Ctrl+F       Send classic or CAN FD frames (up to 64 bytes, BRS), both are received
+  Ctrl+L       Clear the log of the payloads sent and received • Ctrl+C quit (q is typed in the fields)
+
+UDS Diagnostics Mode:
+  Send UDS (ISO 14229) requests to an ECU over ISO-TP, the targets are the ECUs exchanging messages with the DIAG_TOOL node of the DBC
+  ↑/↓          Move between target, TX/RX IDs, service, arguments and SecurityAccess key algorithm
+  ←/→          Choose the target (fills the IDs), the service or the key algorithm
+  Enter        On the target/IDs: connect • on the service/arguments: send the request
+  Services:    DiagnosticSessionControl, TesterPresent, Read/WriteDataByIdentifier, ReadDTCInformation,
+               ClearDiagnosticInformation, ECUReset, SecurityAccess and raw requests (hex bytes)
+  Ctrl+T       Start/stop the TesterPresent keep-alive (started automatically by non-default sessions)
+  Ctrl+L       Clear the log • negative responses are shown with their NRC description
+
+Flashing Mode:
+  Download an Intel HEX (.hex) or binary (.bin, at the base address) image to the bootloader of the target over UDS:
+  programming session, SecurityAccess, erase (routine 0xFF00), RequestDownload, TransferData, RequestTransferExit,
+  CRC-32 check (routine 0x0202) of every segment, then ECUReset
+  ↑/↓          Move between target, TX/RX IDs, image path, base address, security level, key algorithm and simulation
+  ←/→          Choose the target (fills the IDs) or the key algorithm • on Simulated: start/stop the simulated bootloader
+  Enter        Start flashing (again after a failure) • Esc cancel • blocks not acknowledged are sent again automatically
+
+J1939 Mode:
+  Follow the J1939 traffic: extended IDs split into priority/PGN/source/destination, on this screen the DBC
+  messages are matched by PGN so they decode from any source address (other modes match the exact ID)
+  v            Switch view: PGNs (signals of the row under the cursor) → BAM and RTS/CTS messages → address claims
+  r            Send a Request for Address Claimed to every node • Ctrl+L clear
+
+CANopen Mode:
+  Node states from the heartbeats (lost after 3 periods) with the last emergency of each node
+  Enter        On node/object: SDO upload • on value: SDO download • on NMT: send the command (←/→ choose it)
+               Objects as 1018:01, 1018sub1 or 1000; node 0 sends the NMT command to every node
+  EDS          Optional file naming the objects, values are typed by their data type (hex bytes without it)
+  Ctrl+P       Switch SDO log/PDOs decoded with the DBC • Ctrl+L clear the log
 
 Receive Mode (Monitoring):
-  Real-time monitoring of selected CAN messages with signal decoding
+  Real-time monitoring of selected CAN messages with signal decoding (enum signals show "LABEL (raw)")
+  Multiplexed signals follow their muxor: ● present in the last frame (updated), ○ other layout (last value kept)
+  Reception statistics of each message (count, period, jitter, age) checked against the DBC GenMsgCycleTime
+  Messages without frames for longer than their timeout are shown in red with ⛔ (the ones with a cycle time also if never
+  received since the start of the monitoring), timeouts and recoveries go to the event log
+  r            Start/stop recording every received frame to can-debug_<date>.log (candump -L format)
+  v            Switch view: decoded signals → raw trace of every frame on the bus → IDs not declared in the DBC
+  p            Plot the signal under the cursor (last/min/max/avg) • o pin/unpin it to overlay signals • +/- time span
+  Trace view:  Space pause/resume • ↑/↓ PgUp/PgDn scroll back (last 10000 frames) • g oldest • G back to live
+               f filter the IDs shown (hex IDs, e.g. 100,1A0, 8 digits for extended IDs) • Enter apply • Esc cancel
 `)
 }